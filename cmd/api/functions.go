@@ -0,0 +1,15 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"go-workflow/pkg/framework"
+)
+
+// listFunctionsHandler lists the Go functions that codeNode nodes can run
+// by name, with their parameters.
+func (s *Server) listFunctionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(framework.ListFunctions())
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-workflow/pkg/framework"
+)
+
+func TestListFunctionsHandler(t *testing.T) {
+	router := newTestServer().Router()
+	req := httptest.NewRequest("GET", "/api/v1/functions", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var functions []framework.FunctionInfo
+	if err := json.NewDecoder(rr.Body).Decode(&functions); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	for _, fn := range functions {
+		if fn.Name == "top" {
+			if len(fn.Params) != 3 || fn.Params[0] != (framework.FunctionParam{Name: "field", Type: "string", Required: true, Description: "Field to sort the records by."}) {
+				t.Errorf("unexpected parameters of top: %+v", fn.Params)
+			}
+			return
+		}
+	}
+	t.Errorf("expected the built-in function top, got %+v", functions)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"go-workflow/pkg/framework"
+	"go-workflow/pkg/store"
+
+	"github.com/gorilla/mux"
+)
+
+// graphContentTypes are the response content types of the graph formats.
+var graphContentTypes = map[string]string{
+	framework.GraphFormatDOT:     "text/vnd.graphviz; charset=utf-8",
+	framework.GraphFormatMermaid: "text/plain; charset=utf-8",
+}
+
+// graphWorkflowHandler renders a workflow definition as a DOT or Mermaid
+// graph. The "format" query parameter selects the format (default dot) and
+// "version" a stored version instead of the active one. With "run", the
+// version that run executed is drawn with its nodes colored by status.
+func (s *Server) graphWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = framework.GraphFormatDOT
+	}
+	contentType, ok := graphContentTypes[format]
+	if !ok {
+		http.Error(w, jsonError(fmt.Sprintf("Unknown graph format %q; use dot or mermaid", format)), http.StatusBadRequest)
+		return
+	}
+
+	workflow, err := s.store.GetWorkflow(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, "Workflow", err)
+		return
+	}
+	version := workflow.Version
+	if v := query.Get("version"); v != "" {
+		if version, ok = parseVersion(w, v); !ok {
+			return
+		}
+	}
+
+	var statuses map[string]string
+	if runID := query.Get("run"); runID != "" {
+		run, err := s.store.GetRun(r.Context(), runID)
+		if err == nil && run.WorkflowID != id {
+			err = fmt.Errorf("workflow run %s belongs to another workflow: %w", runID, store.ErrNotFound)
+		}
+		if err != nil {
+			writeLookupError(w, "Workflow run", err)
+			return
+		}
+		version = run.Version
+		statuses = run.NodeStatuses
+		if statuses == nil {
+			statuses = map[string]string{}
+		}
+	}
+
+	definition := workflow.Definition
+	if version != workflow.Version {
+		v, err := s.store.GetVersion(r.Context(), id, version)
+		if err != nil {
+			writeLookupError(w, "Workflow version", err)
+			return
+		}
+		definition = v.Definition
+	}
+
+	def, err := framework.LoadWorkflowDefFromYAMLString(definition)
+	if err != nil {
+		http.Error(w, jsonError(fmt.Sprintf("Failed to parse workflow definition: %v", err)), http.StatusInternalServerError)
+		return
+	}
+	graph, err := framework.RenderGraph(def, format, statuses, s.nodeTypes)
+	if err != nil {
+		http.Error(w, jsonError(fmt.Sprintf("Failed to render workflow graph: %v", err)), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	fmt.Fprint(w, graph)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go-workflow/pkg/store"
+
+	"github.com/gorilla/mux"
+)
+
+const graphTestDefinition = `
+start: trigger
+nodes:
+  trigger:
+    type: manualTrigger
+  check:
+    type: ifNode
+    conditions:
+      - field: total
+        operation: larger
+        value: 100
+  notify:
+    type: waitNode
+    maxSeconds: 1
+ports:
+  check:
+    0: [notify]
+connections:
+  trigger: [check]
+`
+
+func TestGraphWorkflowHandler(t *testing.T) {
+	srv := newTestServer()
+	ctx := context.Background()
+
+	wf := &store.Workflow{ID: "graph_test_id", Name: "graph_test_workflow", Definition: graphTestDefinition}
+	if err := srv.store.SaveWorkflow(ctx, wf); err != nil {
+		t.Fatalf("Failed to save workflow for graph test: %v", err)
+	}
+	other := &store.Workflow{ID: "other_id", Name: "other_workflow", Definition: graphTestDefinition}
+	if err := srv.store.SaveWorkflow(ctx, other); err != nil {
+		t.Fatalf("Failed to save workflow for graph test: %v", err)
+	}
+	runs := []*store.WorkflowRun{
+		{ID: "graph_run", WorkflowID: wf.ID, Version: 1, Status: store.RunStatusCompleted, StartedAt: time.Now(),
+			NodeStatuses: map[string]string{"trigger": store.NodeStatusCompleted, "check": store.NodeStatusFailed}},
+		{ID: "other_run", WorkflowID: other.ID, Version: 1, Status: store.RunStatusCompleted, StartedAt: time.Now()},
+	}
+	for _, run := range runs {
+		if err := srv.store.SaveRun(ctx, run); err != nil {
+			t.Fatalf("Failed to save run for graph test: %v", err)
+		}
+		if err := srv.store.UpdateRun(ctx, run); err != nil {
+			t.Fatalf("Failed to update run for graph test: %v", err)
+		}
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/workflows/{id}/graph", srv.graphWorkflowHandler).Methods("GET")
+
+	tests := []struct {
+		name        string
+		query       string
+		status      int
+		contentType string
+		contains    []string
+	}{
+		{"dot by default", "", http.StatusOK, "text/vnd.graphviz; charset=utf-8",
+			[]string{"digraph workflow {", `"check" -> "notify" [label="true"];`}},
+		{"mermaid", "?format=mermaid", http.StatusOK, "text/plain; charset=utf-8",
+			[]string{"flowchart LR", `n1 -->|"true"| n2`}},
+		{"run overlay", "?run=graph_run", http.StatusOK, "text/vnd.graphviz; charset=utf-8",
+			[]string{`fillcolor="#c8e6c9"`, `fillcolor="#ffcdd2"`, `fillcolor="#eeeeee"`}},
+		{"unknown format", "?format=svg", http.StatusBadRequest, "", nil},
+		{"unknown version", "?version=7", http.StatusNotFound, "", nil},
+		{"unknown run", "?run=missing", http.StatusNotFound, "", nil},
+		{"run of another workflow", "?run=other_run", http.StatusNotFound, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/workflows/graph_test_id/graph"+tt.query, nil)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("handler returned wrong status code: got %v want %v: %s", rr.Code, tt.status, rr.Body.String())
+			}
+			if tt.contentType != "" && rr.Header().Get("Content-Type") != tt.contentType {
+				t.Errorf("handler returned wrong content type: got %q want %q", rr.Header().Get("Content-Type"), tt.contentType)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(rr.Body.String(), want) {
+					t.Errorf("expected graph to contain %s, got:\n%s", want, rr.Body.String())
+				}
+			}
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/workflows/missing/graph", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown workflow, got %v", rr.Code)
+	}
+}
@@ -1,19 +1,28 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"go-workflow/pkg/framework"
-	_ "go-workflow/internal/noderegistry" // Import for side effect of registering nodes
+	"go-workflow/internal/noderegistry" // Registers the built-in nodes
+	"go-workflow/pkg/llm"
 	"go-workflow/pkg/store"
+	"go-workflow/pkg/vectorstore"
 
+	_ "github.com/go-sql-driver/mysql" // SQL nodes may use MySQL databases
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // WorkflowRequest represents the request body for uploading a workflow.
@@ -24,8 +33,33 @@ type WorkflowRequest struct {
 
 // WorkflowResponse represents the response body for a workflow.
 type WorkflowResponse struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+}
+
+// WorkflowDefinitionResponse represents a workflow together with its active definition.
+type WorkflowDefinitionResponse struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+	Version    int    `json:"version"`
+}
+
+// RunResponse represents a recorded workflow run.
+type RunResponse struct {
+	ID         string     `json:"id"`
+	WorkflowID string     `json:"workflow_id"`
+	Version    int        `json:"version"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// NodeStatuses maps the nodes the run executed to "completed" or "failed".
+	NodeStatuses map[string]string `json:"node_statuses,omitempty"`
+	// Trace lists the steps of nodes that work in several steps, such as
+	// the tool calls of aiAgent nodes.
+	Trace []store.TraceStep `json:"trace,omitempty"`
 }
 
 // APIError represents a generic API error response.
@@ -33,30 +67,160 @@ type APIError struct {
 	Message string `json:"message"`
 }
 
-var workflowStore store.WorkflowStore
+// Server serves the workflow API on top of a WorkflowStore. The store is
+// injected, so tests and services embedding the API can use any backend,
+// e.g. store.NewMemoryStore.
+type Server struct {
+	store           store.WorkflowStore
+	metricsRegistry *prometheus.Registry
+	// metrics are shared by every workflow run and served on /metrics.
+	metrics *framework.Metrics
+	// llms builds the LLM clients of workflow runs from the environment
+	// and shares them between runs.
+	llms framework.LLMClientFactory
+	// aws creates the clients of named AWS profiles, one per profile,
+	// shared between runs.
+	aws *framework.AWSClients
+	// sql opens the databases of SQL nodes, one pool per database,
+	// shared between runs.
+	sql *framework.SQLDatabases
+	// llmCache, if set, caches the LLM responses of all runs.
+	llmCache framework.LLMCache
+	// vectors holds the indexes of vectorStore nodes, shared by all runs.
+	vectors framework.VectorStore
+	// nodeTypes are the node types workflows of this server may use, the
+	// built-in ones and those of plugins unless restricted.
+	nodeTypes *framework.Registry
+
+	// usage adds up the LLM usage of all runs of a workflow since the
+	// server started, by workflow ID, for the workflow budgets.
+	usageMu sync.Mutex
+	usage   map[string]*framework.UsageMeter
+}
+
+// NewServer creates a Server backed by an initialized workflow store.
+func NewServer(workflowStore store.WorkflowStore) *Server {
+	registry := prometheus.NewRegistry()
+	env := environ()
+	return &Server{
+		store:           workflowStore,
+		metricsRegistry: registry,
+		metrics:         framework.NewMetrics(registry),
+		llms:            llm.NewClientFactory(env),
+		usage:           map[string]*framework.UsageMeter{},
+		vectors:         vectorstore.NewMemoryStore(),
+		aws:             framework.NewAWSClients(nil, env, nil).RestrictProfiles(splitList(os.Getenv("WORKFLOW_ALLOWED_ENV"))),
+		sql:             framework.NewSQLDatabases(nil, env),
+		nodeTypes:       framework.DefaultRegistry.Clone(),
+	}
+}
+
+// workflowUsage returns the usage meter of a workflow with its current
+// budget.
+func (s *Server) workflowUsage(workflowID string, budget framework.Budget) *framework.UsageMeter {
+	s.usageMu.Lock()
+	defer s.usageMu.Unlock()
+	meter, ok := s.usage[workflowID]
+	if !ok {
+		meter = framework.NewUsageMeter("workflow", budget)
+		s.usage[workflowID] = meter
+	}
+	meter.SetBudget(budget)
+	return meter
+}
+
+// Router returns the routes of the API.
+func (s *Server) Router() *mux.Router {
+	router := mux.NewRouter()
+
+	router.HandleFunc("/api/v1/workflows", s.createWorkflowHandler).Methods("POST")
+	router.HandleFunc("/api/v1/workflows/{id}", s.getWorkflowHandler).Methods("GET")
+	router.HandleFunc("/api/v1/workflows/{id}", s.updateWorkflowHandler).Methods("PUT")
+	router.HandleFunc("/api/v1/workflows/{id}/graph", s.graphWorkflowHandler).Methods("GET")
+	router.HandleFunc("/api/v1/workflows/{id}/versions", s.listVersionsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/workflows/{id}/versions/{version}", s.getVersionHandler).Methods("GET")
+	router.HandleFunc("/api/v1/workflows/{id}/versions/{version}/diff", s.diffVersionsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/workflows/{id}/versions/{version}/activate", s.activateVersionHandler).Methods("POST")
+	router.HandleFunc("/api/v1/workflows/{id}/run", s.runWorkflowHandler).Methods("POST")
+	router.HandleFunc("/api/v1/workflows/{id}/runs", s.listRunsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/runs/{id}", s.getRunHandler).Methods("GET")
+	router.HandleFunc("/api/v1/functions", s.listFunctionsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/node-types", s.listNodeTypesHandler).Methods("GET")
+	router.Handle("/metrics", promhttp.HandlerFor(s.metricsRegistry, promhttp.HandlerOpts{}))
+
+	return router
+}
 
 func main() {
 	// Initialize workflow store
-	dbPath := os.Getenv("WORKFLOW_DB_PATH")
-	if dbPath == "" {
-		dbPath = "workflows.db"
+	// WORKFLOW_DB_URL selects the backend, e.g. postgres://... for a
+	// database shared by several replicas; it defaults to a SQLite file.
+	dsn := os.Getenv("WORKFLOW_DB_URL")
+	if dsn == "" {
+		dsn = os.Getenv("WORKFLOW_DB_PATH")
 	}
-	workflowStore = store.NewSQLiteStore(dbPath)
-	if err := workflowStore.Init(); err != nil {
+	if dsn == "" {
+		dsn = "workflows.db"
+	}
+	workflowStore, err := store.NewFromDSN(dsn)
+	if err != nil {
+		log.Fatalf("Invalid workflow store DSN: %v", err)
+	}
+	if err := workflowStore.Init(context.Background()); err != nil {
 		log.Fatalf("Failed to initialize workflow store: %v", err)
 	}
 
-	router := mux.NewRouter()
+	server := NewServer(workflowStore)
+	// LLM_CACHE caches LLM responses: "memory", or a SQLite file.
+	if server.llmCache, err = llm.OpenCache(os.Getenv("LLM_CACHE")); err != nil {
+		log.Fatalf("Failed to open LLM cache: %v", err)
+	}
+	// VECTOR_STORE keeps vector indexes in a SQLite file instead of memory.
+	if spec := os.Getenv("VECTOR_STORE"); spec != "" {
+		if server.vectors, err = vectorstore.Open(spec); err != nil {
+			log.Fatalf("Failed to open vector store: %v", err)
+		}
+	}
 
-	router.HandleFunc("/api/v1/workflows", createWorkflowHandler).Methods("POST")
-	router.HandleFunc("/api/v1/workflows/{id}", getWorkflowHandler).Methods("GET")
-	router.HandleFunc("/api/v1/workflows/{id}/run", runWorkflowHandler).Methods("POST")
+	// AWS_PROFILES_FILE holds the AWS profiles, with their keys, that the
+	// workflows of this server may select. Workflows may add profiles of
+	// their own, restricted to static keys and the environment variables
+	// listed in WORKFLOW_ALLOWED_ENV.
+	if path := os.Getenv("AWS_PROFILES_FILE"); path != "" {
+		profiles, err := framework.LoadAWSProfiles(path)
+		if err != nil {
+			log.Fatalf("Failed to load AWS profiles: %v", err)
+		}
+		server.aws = framework.NewAWSClients(profiles, environ(), nil).RestrictProfiles(splitList(os.Getenv("WORKFLOW_ALLOWED_ENV")))
+	}
+	// SQL_DATABASES_FILE holds the databases, with their passwords, that
+	// SQL nodes may select.
+	if path := os.Getenv("SQL_DATABASES_FILE"); path != "" {
+		databases, err := framework.LoadSQLDatabases(path)
+		if err != nil {
+			log.Fatalf("Failed to load SQL databases: %v", err)
+		}
+		server.sql = framework.NewSQLDatabases(databases, environ())
+	}
+
+	// WORKFLOW_PLUGINS_DIR holds plugin programs implementing further node
+	// types.
+	if _, err := noderegistry.LoadPlugins(os.Getenv("WORKFLOW_PLUGINS_DIR"), server.nodeTypes); err != nil {
+		log.Fatalf("Failed to load plugins: %v", err)
+	}
+	// WORKFLOW_NODE_TYPES restricts the node types workflows may use to a
+	// comma-separated list, e.g. for servers running untrusted workflows.
+	if list := os.Getenv("WORKFLOW_NODE_TYPES"); list != "" {
+		if server.nodeTypes, err = restrictNodeTypes(server.nodeTypes, list); err != nil {
+			log.Fatalf("Invalid WORKFLOW_NODE_TYPES: %v", err)
+		}
+	}
 
 	log.Printf("Server starting on :8080")
-	log.Fatal(http.ListenAndServe(":8080", router))
+	log.Fatal(http.ListenAndServe(":8080", server.Router()))
 }
 
-func createWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) createWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 	var req WorkflowRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, jsonError("Invalid request body"), http.StatusBadRequest)
@@ -67,6 +231,9 @@ func createWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, jsonError("Name and definition are required"), http.StatusBadRequest)
 		return
 	}
+	if !checkUpload(w, req.Definition) {
+		return
+	}
 
 	workflow := &store.Workflow{
 		ID:         uuid.New().String(),
@@ -74,23 +241,23 @@ func createWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 		Definition: req.Definition,
 	}
 
-	if err := workflowStore.SaveWorkflow(workflow); err != nil {
-		http.Error(w, jsonError(fmt.Sprintf("Failed to save workflow: %v", err)), http.StatusInternalServerError)
+	if err := s.store.SaveWorkflow(r.Context(), workflow); err != nil {
+		writeSaveError(w, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(WorkflowResponse{ID: workflow.ID, Name: workflow.Name})
+	json.NewEncoder(w).Encode(WorkflowResponse{ID: workflow.ID, Name: workflow.Name, Version: workflow.Version})
 }
 
-func getWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) getWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	workflow, err := workflowStore.GetWorkflow(id)
+	workflow, err := s.store.GetWorkflow(r.Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, store.ErrNotFound) {
 			http.Error(w, jsonError("Workflow not found"), http.StatusNotFound)
 		} else {
 			http.Error(w, jsonError(fmt.Sprintf("Failed to retrieve workflow: %v", err)), http.StatusInternalServerError)
@@ -99,20 +266,21 @@ func getWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"id":         workflow.ID,
-		"name":       workflow.Name,
-		"definition": workflow.Definition,
+	json.NewEncoder(w).Encode(WorkflowDefinitionResponse{
+		ID:         workflow.ID,
+		Name:       workflow.Name,
+		Definition: workflow.Definition,
+		Version:    workflow.Version,
 	})
 }
 
-func runWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) runWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	id := vars["id"]
 
-	storedWorkflow, err := workflowStore.GetWorkflow(id)
+	storedWorkflow, err := s.store.GetWorkflow(r.Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, store.ErrNotFound) {
 			http.Error(w, jsonError("Workflow not found"), http.StatusNotFound)
 		} else {
 			http.Error(w, jsonError(fmt.Sprintf("Failed to retrieve workflow: %v", err)), http.StatusInternalServerError)
@@ -134,14 +302,15 @@ func runWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, jsonError(fmt.Sprintf("Failed to parse workflow definition: %v", err)), http.StatusInternalServerError)
 		return
 	}
+	if err := checkDefinition(workflowDef); err != nil {
+		http.Error(w, jsonError(err.Error()), http.StatusBadRequest)
+		return
+	}
 
-	log.Printf("DEBUG: Stored Workflow Definition:\n%s\n", storedWorkflow.Definition)
-	log.Printf("DEBUG: Parsed WorkflowDef Nodes: %+v\n", workflowDef.Nodes)
-
-	// Create a framework.Workflow instance
-	wf := &framework.Workflow{
-		Definition:  workflowDef,
-		ErrorConnections: map[string]string{}, // TODO: Get from workflowDef
+	wf, err := framework.BuildWorkflow(workflowDef, s.nodeTypes)
+	if err != nil {
+		http.Error(w, jsonError(fmt.Sprintf("Failed to build workflow: %v", err)), http.StatusInternalServerError)
+		return
 	}
 
 	// Create a framework.Context (simplified for now)
@@ -151,30 +320,249 @@ func runWorkflowHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, jsonError("Internal server error"), http.StatusInternalServerError)
 		return
 	}
+	aws, err := s.aws.WithProfiles(workflowDef.AWSProfiles)
+	if err != nil {
+		http.Error(w, jsonError(err.Error()), http.StatusBadRequest)
+		return
+	}
 	ctx := &framework.Context{
-		Ctx:    r.Context(),
-		Logger: logger,
-		// Other context fields (HTTPClient, LangChain, DynamoDBClient, Metrics) would be initialized here
+		// The run outlives the request, so it must not inherit r.Context().
+		Ctx:     context.Background(),
+		Logger:  logger,
+		Metrics: s.metrics,
+		// Nodes that select an LLM provider get their client here.
+		LLMClientFactory: s.llms,
+		LLMCache:         s.llmCache,
+		LLMCacheBypass:   r.URL.Query().Get("no_cache") == "true",
+		VectorStore:      s.vectors,
+		AWS:              aws,
+		SQL:              s.sql,
+		Workflow:         storedWorkflow.Name,
+		RunUsage:         framework.NewUsageMeter("run", workflowDef.Budgets.Run),
+		WorkflowUsage:    s.workflowUsage(storedWorkflow.ID, workflowDef.Budgets.Workflow),
+		// Other context fields (HTTPClient, LangChain) would be initialized here
+	}
+	// Record the status of every node the run executes, for the run overlay
+	// of the workflow graph. Nodes run one at a time, in the run goroutine.
+	nodeStatuses := map[string]string{}
+	ctx.NodeDone = func(name string, err error) {
+		if err != nil {
+			nodeStatuses[name] = store.NodeStatusFailed
+		} else {
+			nodeStatuses[name] = store.NodeStatusCompleted
+		}
+	}
+	var trace []store.TraceStep
+	ctx.Trace = func(step framework.TraceStep) {
+		trace = append(trace, store.TraceStep(step))
+	}
+	ctx.LoadWorkflow = s.loadWorkflow
+	ctx.NodeTypes = s.nodeTypes
+
+	// Record which version of the definition this run executes.
+	run := &store.WorkflowRun{
+		ID:         uuid.New().String(),
+		WorkflowID: storedWorkflow.ID,
+		Version:    storedWorkflow.Version,
+		Status:     store.RunStatusRunning,
+		StartedAt:  time.Now().UTC(),
+	}
+	if err := s.store.SaveRun(r.Context(), run); err != nil {
+		http.Error(w, jsonError(fmt.Sprintf("Failed to record workflow run: %v", err)), http.StatusInternalServerError)
+		return
 	}
 
 	// Run the workflow in a goroutine to avoid blocking the API response
 	go func() {
-		// For now, hardcode the start node. This should ideally be part of the workflowDef.
-		startNode := "manualTrigger"
-		if err := wf.Run(ctx, startNode, initialInput); err != nil {
-			log.Printf("Workflow %s run failed: %v", storedWorkflow.ID, err)
+		runErr := wf.Run(ctx, workflowDef.StartNode(), initialInput)
+		finishedAt := time.Now().UTC()
+		run.FinishedAt = &finishedAt
+		run.NodeStatuses = nodeStatuses
+		run.Trace = trace
+		if usage := ctx.RunUsage.Usage(); usage.TotalTokens() > 0 {
+			log.Printf("Workflow %s run %s used %d prompt and %d completion tokens ($%.4f).", storedWorkflow.ID, run.ID, usage.PromptTokens, usage.CompletionTokens, usage.Cost)
+		}
+		if runErr != nil {
+			log.Printf("Workflow %s run %s failed: %v", storedWorkflow.ID, run.ID, runErr)
+			run.Status = store.RunStatusFailed
+			run.Error = runErr.Error()
+		} else {
+			log.Printf("Workflow %s run %s completed.", storedWorkflow.ID, run.ID)
+			run.Status = store.RunStatusCompleted
+		}
+		if err := s.store.UpdateRun(context.Background(), run); err != nil {
+			log.Printf("Failed to record result of workflow run %s: %v", run.ID, err)
 		}
-		log.Printf("Workflow %s run completed.", storedWorkflow.ID)
 	}()
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Workflow triggered successfully",
-		"workflow_run_id": uuid.New().String(), // Placeholder for a real run ID
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":         "Workflow triggered successfully",
+		"workflow_run_id": run.ID,
+		"version":         run.Version,
 	})
 }
 
+func (s *Server) getRunHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	run, err := s.store.GetRun(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			http.Error(w, jsonError("Workflow run not found"), http.StatusNotFound)
+		} else {
+			http.Error(w, jsonError(fmt.Sprintf("Failed to retrieve workflow run: %v", err)), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(toRunResponse(run))
+}
+
+func (s *Server) listRunsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	runs, err := s.store.ListRuns(r.Context(), id)
+	if err != nil {
+		http.Error(w, jsonError(fmt.Sprintf("Failed to list workflow runs: %v", err)), http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]RunResponse, 0, len(runs))
+	for _, run := range runs {
+		res = append(res, toRunResponse(run))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func toRunResponse(run *store.WorkflowRun) RunResponse {
+	return RunResponse{
+		ID:           run.ID,
+		WorkflowID:   run.WorkflowID,
+		Version:      run.Version,
+		Status:       run.Status,
+		Error:        run.Error,
+		StartedAt:    run.StartedAt,
+		FinishedAt:   run.FinishedAt,
+		NodeStatuses: run.NodeStatuses,
+		Trace:        run.Trace,
+	}
+}
+
+// loadWorkflow loads the active definition of a stored workflow by ID or
+// name, for nodes that run other workflows.
+func (s *Server) loadWorkflow(ctx context.Context, name string) (*framework.WorkflowDef, error) {
+	storedWorkflow, err := s.store.GetWorkflow(ctx, name)
+	if errors.Is(err, store.ErrNotFound) {
+		storedWorkflow, err = s.store.GetWorkflowByName(ctx, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	def, err := framework.LoadWorkflowDefFromYAMLString(storedWorkflow.Definition)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDefinition(def); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// errWorkflowDatabases rejects the databases of workflows uploaded through
+// the API: a DSN could open any file the server can read, such as its own
+// store, or send the server's environment elsewhere through ${NAME}
+// references.
+var errWorkflowDatabases = errors.New("workflows run by the API server cannot define databases; configure them in SQL_DATABASES_FILE")
+
+// checkDefinition rejects what workflows uploaded through the API may not
+// configure. Their nodes cannot read prompt files either, which would be
+// read from the server's file system.
+func checkDefinition(def *framework.WorkflowDef) error {
+	if len(def.Databases) > 0 {
+		return errWorkflowDatabases
+	}
+	for _, name := range def.Nodes {
+		if nodeDef := def.NodeDefs[name]; nodeDef != nil && readsPromptFiles(nodeDef.Params) {
+			return fmt.Errorf("node %s: workflows run by the API server cannot read prompt files; give the prompts inline", name)
+		}
+	}
+	return nil
+}
+
+// readsPromptFiles reports whether node parameters name prompt files, as
+// systemPromptFile, userPromptFile and the file of messages do.
+func readsPromptFiles(params map[string]interface{}) bool {
+	for _, key := range []string{"systemPromptFile", "userPromptFile"} {
+		if file, _ := params[key].(string); file != "" {
+			return true
+		}
+	}
+	messages, _ := params["messages"].([]interface{})
+	for _, message := range messages {
+		if fields, ok := message.(map[string]interface{}); ok {
+			if file, _ := fields["file"].(string); file != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkUpload rejects a definition uploaded through the API that parses
+// but configures what it may not. Definitions that do not parse are
+// reported when they run.
+func checkUpload(w http.ResponseWriter, definition string) bool {
+	def, err := framework.LoadWorkflowDefFromYAMLString(definition)
+	if err != nil {
+		return true
+	}
+	if err := checkDefinition(def); err != nil {
+		http.Error(w, jsonError(err.Error()), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// restrictNodeTypes returns the node types of registry named in a
+// comma-separated list. It fails if the list names no type or an unknown
+// one.
+func restrictNodeTypes(registry *framework.Registry, list string) (*framework.Registry, error) {
+	nodeTypes := splitList(list)
+	if len(nodeTypes) == 0 {
+		return nil, fmt.Errorf("no node types in %q", list)
+	}
+	return registry.Subset(nodeTypes...)
+}
+
+// environ returns the environment of the server by variable name.
+func environ() map[string]string {
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// splitList splits a comma-separated list from the environment, dropping
+// blanks around and between its entries.
+func splitList(list string) []string {
+	var entries []string
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
 func jsonError(message string) string {
 	b, _ := json.Marshal(APIError{Message: message})
 	return string(b)
@@ -2,32 +2,28 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"go-workflow/pkg/framework"
 	"go-workflow/pkg/store"
 
 	"github.com/gorilla/mux"
 )
 
-// initTestStore initializes a new in-memory SQLite store for testing.
-func initTestStore() store.WorkflowStore {
-	dbPath := "file::memory:?cache=shared" // In-memory SQLite
-	store := store.NewSQLiteStore(dbPath)
-	if err := store.Init(); err != nil {
-		panic(fmt.Sprintf("Failed to initialize test store: %v", err))
-	}
-	return store
+// newTestServer creates a Server backed by a fresh in-memory store.
+func newTestServer() *Server {
+	return NewServer(store.NewMemoryStore())
 }
 
 func TestCreateWorkflowHandler(t *testing.T) {
-	workflowStore = initTestStore()
+	srv := newTestServer()
 
 	router := mux.NewRouter()
-	router.HandleFunc("/api/v1/workflows", createWorkflowHandler).Methods("POST")
+	router.HandleFunc("/api/v1/workflows", srv.createWorkflowHandler).Methods("POST")
 
 	// Test case 1: Valid workflow creation
 	validWorkflow := WorkflowRequest{
@@ -52,7 +48,7 @@ func TestCreateWorkflowHandler(t *testing.T) {
 	}
 
 	// Verify workflow is stored
-	_, err := workflowStore.GetWorkflow(res.ID)
+	_, err := srv.store.GetWorkflow(context.Background(), res.ID)
 	if err != nil {
 		t.Errorf("workflow not found in store after creation: %v", err)
 	}
@@ -84,13 +80,13 @@ func TestCreateWorkflowHandler(t *testing.T) {
 	rr = httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
 
-	if status := rr.Code; status != http.StatusInternalServerError {
-		t.Errorf("handler returned wrong status code for duplicate name: got %v want %v", status, http.StatusInternalServerError)
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("handler returned wrong status code for duplicate name: got %v want %v", status, http.StatusConflict)
 	}
 }
 
 func TestGetWorkflowHandler(t *testing.T) {
-	workflowStore = initTestStore()
+	srv := newTestServer()
 
 	// Save a workflow first
 	wf := &store.Workflow{
@@ -98,12 +94,12 @@ func TestGetWorkflowHandler(t *testing.T) {
 		Name:       "get_test_workflow",
 		Definition: "get_workflow_def",
 	}
-	if err := workflowStore.SaveWorkflow(wf); err != nil {
+	if err := srv.store.SaveWorkflow(context.Background(), wf); err != nil {
 		t.Fatalf("Failed to save workflow for get test: %v", err)
 	}
 
 	router := mux.NewRouter()
-	router.HandleFunc("/api/v1/workflows/{id}", getWorkflowHandler).Methods("GET")
+	router.HandleFunc("/api/v1/workflows/{id}", srv.getWorkflowHandler).Methods("GET")
 
 	// Test case 1: Valid workflow ID
 	req := httptest.NewRequest("GET", "/api/v1/workflows/test_id_123", nil)
@@ -114,9 +110,9 @@ func TestGetWorkflowHandler(t *testing.T) {
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
 	}
 
-	var res map[string]string
+	var res WorkflowDefinitionResponse
 	json.NewDecoder(rr.Body).Decode(&res)
-	if res["id"] != wf.ID || res["name"] != wf.Name || res["definition"] != wf.Definition {
+	if res.ID != wf.ID || res.Name != wf.Name || res.Definition != wf.Definition || res.Version != 1 {
 		t.Errorf("handler returned unexpected body: got %v want %v", res, wf)
 	}
 
@@ -131,7 +127,7 @@ func TestGetWorkflowHandler(t *testing.T) {
 }
 
 func TestRunWorkflowHandler(t *testing.T) {
-	workflowStore = initTestStore()
+	srv := newTestServer()
 
 	// Save a workflow first
 	wf := &store.Workflow{
@@ -152,12 +148,12 @@ connections:
   manualTrigger: [setNode]
 `,
 	}
-	if err := workflowStore.SaveWorkflow(wf); err != nil {
+	if err := srv.store.SaveWorkflow(context.Background(), wf); err != nil {
 		t.Fatalf("Failed to save workflow for run test: %v", err)
 	}
 
 	router := mux.NewRouter()
-	router.HandleFunc("/api/v1/workflows/{id}/run", runWorkflowHandler).Methods("POST")
+	router.HandleFunc("/api/v1/workflows/{id}/run", srv.runWorkflowHandler).Methods("POST")
 
 	// Test case 1: Valid workflow run with input
 	inputPayload := []map[string]interface{}{
@@ -174,9 +170,9 @@ connections:
 		t.Errorf("handler returned wrong status code: got %v want %v", status, http.StatusAccepted)
 	}
 
-	var res map[string]string
+	var res map[string]interface{}
 	json.NewDecoder(rr.Body).Decode(&res)
-	if res["message"] != "Workflow triggered successfully" || res["workflow_run_id"] == "" {
+	if res["message"] != "Workflow triggered successfully" || res["workflow_run_id"] == "" || res["version"] != float64(1) {
 		t.Errorf("handler returned unexpected body: %v", res)
 	}
 
@@ -199,4 +195,157 @@ connections:
 	if status := rr.Code; status != http.StatusBadRequest {
 		t.Errorf("handler returned wrong status code for invalid payload: got %v want %v", status, http.StatusBadRequest)
 	}
-}
\ No newline at end of file
+}
+func TestServerRouter(t *testing.T) {
+	srv := newTestServer()
+	router := srv.Router()
+
+	body, _ := json.Marshal(WorkflowRequest{Name: "router_workflow", Definition: "definition"})
+	req := httptest.NewRequest("POST", "/api/v1/workflows", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusCreated {
+		t.Fatalf("create returned wrong status code: got %v want %v", status, http.StatusCreated)
+	}
+	var created WorkflowResponse
+	json.NewDecoder(rr.Body).Decode(&created)
+
+	req = httptest.NewRequest("GET", "/api/v1/workflows/"+created.ID, nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("get returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	req = httptest.NewRequest("GET", "/metrics", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("metrics returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	// Servers do not share state.
+	if _, err := newTestServer().store.GetWorkflow(context.Background(), created.ID); err == nil {
+		t.Error("expected a new server to have an empty store")
+	}
+}
+
+func TestLoadWorkflow(t *testing.T) {
+	srv := newTestServer()
+	wf := &store.Workflow{ID: "tool_id", Name: "company-research", Definition: `
+nodes:
+  manualTrigger:
+    type: manualTrigger
+`}
+	if err := srv.store.SaveWorkflow(context.Background(), wf); err != nil {
+		t.Fatalf("Failed to save workflow: %v", err)
+	}
+
+	for _, name := range []string{"tool_id", "company-research"} {
+		def, err := srv.loadWorkflow(context.Background(), name)
+		if err != nil {
+			t.Fatalf("loadWorkflow(%s) failed: %v", name, err)
+		}
+		if def.NodeDefs["manualTrigger"] == nil {
+			t.Errorf("loadWorkflow(%s) returned %+v", name, def)
+		}
+	}
+	if _, err := srv.loadWorkflow(context.Background(), "missing"); err == nil {
+		t.Error("expected an error for an unknown workflow")
+	}
+}
+
+func TestWorkflowDatabasesRejected(t *testing.T) {
+	srv := newTestServer()
+	router := srv.Router()
+	definition := `
+databases:
+  store:
+    driver: sqlite3
+    dsn: workflows.db
+nodes:
+  trigger:
+    type: webhookTrigger
+`
+	body, _ := json.Marshal(WorkflowRequest{Name: "reads-the-store", Definition: definition})
+	req := httptest.NewRequest("POST", "/api/v1/workflows", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("expected a workflow with databases to be rejected, got %v", status)
+	}
+
+	// Workflows stored before are rejected when they run or are loaded.
+	wf := &store.Workflow{ID: "db_id", Name: "stored", Definition: definition}
+	if err := srv.store.SaveWorkflow(context.Background(), wf); err != nil {
+		t.Fatalf("Failed to save workflow: %v", err)
+	}
+	req = httptest.NewRequest("POST", "/api/v1/workflows/db_id/run", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("expected the run to be rejected, got %v", status)
+	}
+	if _, err := srv.loadWorkflow(context.Background(), "db_id"); err != errWorkflowDatabases {
+		t.Errorf("expected the workflow not to load, got %v", err)
+	}
+}
+
+func TestWorkflowAWSProfilesRestricted(t *testing.T) {
+	t.Setenv("WORKFLOW_ALLOWED_ENV", "OWN_KEY_ID, OWN_SECRET")
+	srv := newTestServer()
+	router := srv.Router()
+	for name, tc := range map[string]struct {
+		profile string
+		want    int
+	}{
+		"own_keys":      {"accessKeyID: ${OWN_KEY_ID}\n    secretAccessKey: ${OWN_SECRET}", http.StatusAccepted},
+		"server_chain":  {"region: eu-west-1", http.StatusBadRequest},
+		"server_secret": {"accessKeyID: ${OWN_KEY_ID}\n    secretAccessKey: ${AWS_SECRET_ACCESS_KEY}", http.StatusBadRequest},
+	} {
+		definition := "awsProfiles:\n  own:\n    " + tc.profile + "\nnodes:\n  trigger:\n    type: webhookTrigger\n"
+		wf := &store.Workflow{ID: name, Name: name, Definition: definition}
+		if err := srv.store.SaveWorkflow(context.Background(), wf); err != nil {
+			t.Fatalf("Failed to save workflow: %v", err)
+		}
+		req := httptest.NewRequest("POST", "/api/v1/workflows/"+name+"/run", nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if status := rr.Code; status != tc.want {
+			t.Errorf("%s: expected status %v, got %v", name, tc.want, status)
+		}
+	}
+}
+
+func TestWorkflowPromptFilesRejected(t *testing.T) {
+	srv := newTestServer()
+	router := srv.Router()
+	for name, params := range map[string]string{
+		"system_prompt": "systemPromptFile: /etc/passwd",
+		"message":       "messages:\n      - role: user\n        file: ../../secrets.txt",
+	} {
+		definition := "nodes:\n  ask:\n    type: openaiNode\n    " + params + "\n"
+		body, _ := json.Marshal(WorkflowRequest{Name: name, Definition: definition})
+		req := httptest.NewRequest("POST", "/api/v1/workflows", bytes.NewBuffer(body))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if status := rr.Code; status != http.StatusBadRequest {
+			t.Errorf("%s: expected the workflow to be rejected, got %v", name, status)
+		}
+	}
+}
+
+func TestRestrictNodeTypes(t *testing.T) {
+	registry, err := restrictNodeTypes(framework.DefaultRegistry, " setNode, ifNode ,,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if types := registry.List(); len(types) != 2 || types[0].Type != "ifNode" || types[1].Type != "setNode" {
+		t.Errorf("expected ifNode and setNode, got %+v", types)
+	}
+	for _, list := range []string{"setNode,unknownNode", " , "} {
+		if _, err := restrictNodeTypes(framework.DefaultRegistry, list); err == nil {
+			t.Errorf("expected an error for %q", list)
+		}
+	}
+}
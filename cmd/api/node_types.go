@@ -0,0 +1,13 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// listNodeTypesHandler lists the node types workflows of the server can
+// use, with the JSON schema of their parameters and their ports.
+func (s *Server) listNodeTypesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.nodeTypes.List())
+}
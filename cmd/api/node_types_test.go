@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"go-workflow/pkg/framework"
+)
+
+func TestListNodeTypesHandler(t *testing.T) {
+	router := newTestServer().Router()
+	req := httptest.NewRequest("GET", "/api/v1/node-types", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var nodeTypes []framework.NodeTypeInfo
+	if err := json.NewDecoder(rr.Body).Decode(&nodeTypes); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	byType := map[string]framework.NodeTypeInfo{}
+	for _, info := range nodeTypes {
+		byType[info.Type] = info
+	}
+
+	trigger := byType["manualTrigger"]
+	if trigger.Category != framework.CategoryTrigger || len(trigger.Inputs) != 0 {
+		t.Errorf("expected manualTrigger to be a trigger without inputs, got %+v", trigger)
+	}
+	if outputs := byType["ifNode"].Outputs; !reflect.DeepEqual(outputs, []string{"true", "false"}) {
+		t.Errorf("unexpected outputs of ifNode: %v", outputs)
+	}
+	if !byType["switchNode"].DynamicOutputs {
+		t.Error("expected switchNode to have dynamic outputs")
+	}
+
+	sqlQuery := byType["sqlQuery"]
+	if !reflect.DeepEqual(sqlQuery.Credentials, []string{framework.CredentialDatabase}) {
+		t.Errorf("unexpected credentials of sqlQuery: %v", sqlQuery.Credentials)
+	}
+	if required, _ := sqlQuery.Params["required"].([]interface{}); len(required) != 2 || required[0] != "database" || required[1] != "query" {
+		t.Errorf("unexpected required params of sqlQuery: %v", sqlQuery.Params["required"])
+	}
+
+	// Inlined parameters are part of the schema.
+	properties, _ := byType["dynamodbScan"].Params["properties"].(map[string]interface{})
+	for _, name := range []string{"awsProfile", "table", "indexName", "limit"} {
+		if _, ok := properties[name]; !ok {
+			t.Errorf("expected dynamodbScan param %s, got %v", name, properties)
+		}
+	}
+	if _, ok := properties["type"]; ok {
+		t.Error("expected the node type not to be a param")
+	}
+	mode, _ := byType["codeNode"].Params["properties"].(map[string]interface{})["mode"].(map[string]interface{})
+	if mode["default"] != "all" || !reflect.DeepEqual(mode["enum"], []interface{}{"all", "each"}) {
+		t.Errorf("unexpected codeNode mode param: %v", mode)
+	}
+}
+
+func TestListNodeTypesHandler_Restricted(t *testing.T) {
+	srv := newTestServer()
+	restricted, err := srv.nodeTypes.Subset("webhookTrigger", "setNode")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	srv.nodeTypes = restricted
+	req := httptest.NewRequest("GET", "/api/v1/node-types", nil)
+	rr := httptest.NewRecorder()
+	srv.Router().ServeHTTP(rr, req)
+
+	var nodeTypes []framework.NodeTypeInfo
+	if err := json.NewDecoder(rr.Body).Decode(&nodeTypes); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(nodeTypes) != 2 || nodeTypes[0].Type != "setNode" || nodeTypes[1].Type != "webhookTrigger" {
+		t.Errorf("expected only the allowed node types, got %+v", nodeTypes)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-workflow/pkg/store"
+
+	"github.com/gorilla/mux"
+)
+
+// VersionResponse represents a single stored version of a workflow definition.
+type VersionResponse struct {
+	WorkflowID string    `json:"workflow_id"`
+	Version    int       `json:"version"`
+	Definition string    `json:"definition,omitempty"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DiffResponse represents the line diff between two versions of a workflow.
+type DiffResponse struct {
+	WorkflowID string           `json:"workflow_id"`
+	From       int              `json:"from"`
+	To         int              `json:"to"`
+	Lines      []store.DiffLine `json:"lines"`
+	Diff       string           `json:"diff"`
+}
+
+// updateWorkflowHandler saves a new definition for an existing workflow.
+// Every save is recorded as a new, immutable version which becomes active.
+func (s *Server) updateWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var req WorkflowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, jsonError("Invalid request body"), http.StatusBadRequest)
+		return
+	}
+	if req.Definition == "" {
+		http.Error(w, jsonError("Definition is required"), http.StatusBadRequest)
+		return
+	}
+	if !checkUpload(w, req.Definition) {
+		return
+	}
+
+	// Read and update in one transaction so a concurrent rename or rollback
+	// is not overwritten with stale fields.
+	var workflow *store.Workflow
+	err := s.store.WithTx(r.Context(), func(tx store.Tx) error {
+		var err error
+		workflow, err = tx.GetWorkflow(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		if req.Name != "" {
+			workflow.Name = req.Name
+		}
+		workflow.Definition = req.Definition
+		return tx.UpdateWorkflow(r.Context(), workflow)
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		writeLookupError(w, "Workflow", err)
+		return
+	}
+	if err != nil {
+		writeSaveError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WorkflowResponse{ID: workflow.ID, Name: workflow.Name, Version: workflow.Version})
+}
+
+func (s *Server) listVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	workflow, err := s.store.GetWorkflow(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, "Workflow", err)
+		return
+	}
+
+	versions, err := s.store.ListVersions(r.Context(), id)
+	if err != nil {
+		http.Error(w, jsonError(fmt.Sprintf("Failed to list workflow versions: %v", err)), http.StatusInternalServerError)
+		return
+	}
+
+	res := make([]VersionResponse, 0, len(versions))
+	for _, v := range versions {
+		res = append(res, VersionResponse{
+			WorkflowID: v.WorkflowID,
+			Version:    v.Version,
+			Active:     v.Version == workflow.Version,
+			CreatedAt:  v.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func (s *Server) getVersionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	version, ok := parseVersion(w, vars["version"])
+	if !ok {
+		return
+	}
+
+	workflow, err := s.store.GetWorkflow(r.Context(), id)
+	if err != nil {
+		writeLookupError(w, "Workflow", err)
+		return
+	}
+
+	v, err := s.store.GetVersion(r.Context(), id, version)
+	if err != nil {
+		writeLookupError(w, "Workflow version", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(VersionResponse{
+		WorkflowID: v.WorkflowID,
+		Version:    v.Version,
+		Definition: v.Definition,
+		Active:     v.Version == workflow.Version,
+		CreatedAt:  v.CreatedAt,
+	})
+}
+
+// diffVersionsHandler diffs {version} against the version given by the
+// "against" query parameter, which defaults to the preceding version.
+func (s *Server) diffVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	to, ok := parseVersion(w, vars["version"])
+	if !ok {
+		return
+	}
+	from := to - 1
+	if against := r.URL.Query().Get("against"); against != "" {
+		if from, ok = parseVersion(w, against); !ok {
+			return
+		}
+	}
+
+	toVersion, err := s.store.GetVersion(r.Context(), id, to)
+	if err != nil {
+		writeLookupError(w, "Workflow version", err)
+		return
+	}
+	fromDefinition := ""
+	if from > 0 {
+		fromVersion, err := s.store.GetVersion(r.Context(), id, from)
+		if err != nil {
+			writeLookupError(w, "Workflow version", err)
+			return
+		}
+		fromDefinition = fromVersion.Definition
+	}
+
+	lines := store.DiffDefinitions(fromDefinition, toVersion.Definition)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(DiffResponse{
+		WorkflowID: id,
+		From:       from,
+		To:         to,
+		Lines:      lines,
+		Diff:       store.FormatDiff(lines),
+	})
+}
+
+// activateVersionHandler makes a previous version live again (rollback).
+func (s *Server) activateVersionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	version, ok := parseVersion(w, vars["version"])
+	if !ok {
+		return
+	}
+
+	workflow, err := s.store.ActivateVersion(r.Context(), id, version)
+	if err != nil {
+		writeLookupError(w, "Workflow version", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(WorkflowResponse{ID: workflow.ID, Name: workflow.Name, Version: workflow.Version})
+}
+
+func parseVersion(w http.ResponseWriter, s string) (int, bool) {
+	version, err := strconv.Atoi(s)
+	if err != nil || version < 0 {
+		http.Error(w, jsonError(fmt.Sprintf("Invalid version %q", s)), http.StatusBadRequest)
+		return 0, false
+	}
+	return version, true
+}
+
+func writeLookupError(w http.ResponseWriter, what string, err error) {
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, jsonError(what+" not found"), http.StatusNotFound)
+		return
+	}
+	http.Error(w, jsonError(fmt.Sprintf("Failed to retrieve %s: %v", what, err)), http.StatusInternalServerError)
+}
+
+// writeSaveError reports a failed write, mapping store.ErrConflict (e.g. a
+// duplicate workflow name) to 409 Conflict.
+func writeSaveError(w http.ResponseWriter, err error) {
+	if errors.Is(err, store.ErrConflict) {
+		http.Error(w, jsonError(fmt.Sprintf("Workflow conflicts with an existing one: %v", err)), http.StatusConflict)
+		return
+	}
+	http.Error(w, jsonError(fmt.Sprintf("Failed to save workflow: %v", err)), http.StatusInternalServerError)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-workflow/pkg/store"
+
+	"github.com/gorilla/mux"
+)
+
+func newVersionsRouter(srv *Server) *mux.Router {
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/workflows/{id}", srv.getWorkflowHandler).Methods("GET")
+	router.HandleFunc("/api/v1/workflows/{id}", srv.updateWorkflowHandler).Methods("PUT")
+	router.HandleFunc("/api/v1/workflows/{id}/versions", srv.listVersionsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/workflows/{id}/versions/{version}", srv.getVersionHandler).Methods("GET")
+	router.HandleFunc("/api/v1/workflows/{id}/versions/{version}/diff", srv.diffVersionsHandler).Methods("GET")
+	router.HandleFunc("/api/v1/workflows/{id}/versions/{version}/activate", srv.activateVersionHandler).Methods("POST")
+	return router
+}
+
+func TestWorkflowVersionHandlers(t *testing.T) {
+	srv := newTestServer()
+
+	wf := &store.Workflow{
+		ID:         "versions_test_id",
+		Name:       "versions_test_workflow",
+		Definition: "line1\nline2\n",
+	}
+	if err := srv.store.SaveWorkflow(context.Background(), wf); err != nil {
+		t.Fatalf("Failed to save workflow for versions test: %v", err)
+	}
+
+	router := newVersionsRouter(srv)
+
+	// Saving a new definition creates version 2.
+	body, _ := json.Marshal(WorkflowRequest{Definition: "line1\nline2 changed\n"})
+	req := httptest.NewRequest("PUT", "/api/v1/workflows/versions_test_id", bytes.NewBuffer(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("update returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+	var updated WorkflowResponse
+	json.NewDecoder(rr.Body).Decode(&updated)
+	if updated.Version != 2 || updated.Name != wf.Name {
+		t.Errorf("update returned unexpected body: %+v", updated)
+	}
+
+	// Both versions are listed and version 2 is active.
+	req = httptest.NewRequest("GET", "/api/v1/workflows/versions_test_id/versions", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var versions []VersionResponse
+	json.NewDecoder(rr.Body).Decode(&versions)
+	if len(versions) != 2 || versions[0].Active || !versions[1].Active {
+		t.Errorf("list versions returned unexpected body: %+v", versions)
+	}
+
+	// Diff version 2 against its predecessor.
+	req = httptest.NewRequest("GET", "/api/v1/workflows/versions_test_id/versions/2/diff", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var diff DiffResponse
+	json.NewDecoder(rr.Body).Decode(&diff)
+	if diff.From != 1 || diff.To != 2 || !strings.Contains(diff.Diff, "-line2\n+line2 changed\n") {
+		t.Errorf("diff returned unexpected body: %+v", diff)
+	}
+
+	// Roll back to version 1.
+	req = httptest.NewRequest("POST", "/api/v1/workflows/versions_test_id/versions/1/activate", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("activate returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/workflows/versions_test_id", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var current WorkflowDefinitionResponse
+	json.NewDecoder(rr.Body).Decode(&current)
+	if current.Version != 1 || current.Definition != wf.Definition {
+		t.Errorf("expected version 1 to be live after rollback, got %+v", current)
+	}
+
+	// Unknown versions and malformed version numbers.
+	req = httptest.NewRequest("POST", "/api/v1/workflows/versions_test_id/versions/9/activate", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("activate of unknown version returned %v want %v", status, http.StatusNotFound)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/workflows/versions_test_id/versions/abc", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusBadRequest {
+		t.Errorf("get of malformed version returned %v want %v", status, http.StatusBadRequest)
+	}
+
+	// Renaming onto another workflow's name conflicts and records nothing.
+	other := &store.Workflow{ID: "versions_test_other_id", Name: "versions_test_other", Definition: "other"}
+	if err := srv.store.SaveWorkflow(context.Background(), other); err != nil {
+		t.Fatalf("Failed to save workflow for versions test: %v", err)
+	}
+	body, _ = json.Marshal(WorkflowRequest{Name: other.Name, Definition: "renamed"})
+	req = httptest.NewRequest("PUT", "/api/v1/workflows/versions_test_id", bytes.NewBuffer(body))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusConflict {
+		t.Errorf("rename to an existing name returned %v want %v", status, http.StatusConflict)
+	}
+	stored, err := srv.store.ListVersions(context.Background(), wf.ID)
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(stored) != 2 {
+		t.Errorf("expected the conflicting update to be rolled back, got %d versions", len(stored))
+	}
+
+	req = httptest.NewRequest("PUT", "/api/v1/workflows/missing", bytes.NewBuffer(body))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("update of unknown workflow returned %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestGetRunHandler(t *testing.T) {
+	srv := newTestServer()
+
+	wf := &store.Workflow{
+		ID:         "run_record_test_id",
+		Name:       "run_record_test_workflow",
+		Definition: "definition",
+	}
+	if err := srv.store.SaveWorkflow(context.Background(), wf); err != nil {
+		t.Fatalf("Failed to save workflow for run record test: %v", err)
+	}
+	run := &store.WorkflowRun{
+		ID:         "run_record_run_id",
+		WorkflowID: wf.ID,
+		Version:    wf.Version,
+		Status:     store.RunStatusRunning,
+	}
+	if err := srv.store.SaveRun(context.Background(), run); err != nil {
+		t.Fatalf("Failed to save run: %v", err)
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/runs/{id}", srv.getRunHandler).Methods("GET")
+
+	req := httptest.NewRequest("GET", "/api/v1/runs/run_record_run_id", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var res RunResponse
+	json.NewDecoder(rr.Body).Decode(&res)
+	if res.ID != run.ID || res.Version != 1 || res.Status != store.RunStatusRunning {
+		t.Errorf("handler returned unexpected body: %+v", res)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/runs/missing", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code for unknown run: got %v want %v", status, http.StatusNotFound)
+	}
+}
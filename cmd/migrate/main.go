@@ -0,0 +1,95 @@
+// Command migrate applies and inspects the schema migrations of the workflow store.
+//
+//	migrate [-db workflows.db | -db postgres://...] [-status] [-dry-run]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"go-workflow/pkg/store"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	defaultDB := os.Getenv("WORKFLOW_DB_URL")
+	if defaultDB == "" {
+		defaultDB = os.Getenv("WORKFLOW_DB_PATH")
+	}
+	if defaultDB == "" {
+		defaultDB = "workflows.db"
+	}
+
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	dsn := flags.String("db", defaultDB, "SQLite database path or postgres:// DSN of the workflow store")
+	status := flags.Bool("status", false, "Show applied and pending migrations without applying them")
+	dryRun := flags.Bool("dry-run", false, "Print the SQL of pending migrations without applying them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, dialect, err := store.OpenDB(*dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	migrator := store.NewMigrator(db, dialect, store.MigrationsFor(dialect))
+
+	switch {
+	case *status:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, appliedAt := "pending", ""
+			if s.Applied {
+				state, appliedAt = "applied", s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		return tw.Flush()
+
+	case *dryRun:
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			fmt.Fprintln(out, "No pending migrations.")
+			return nil
+		}
+		for _, m := range pending {
+			fmt.Fprintf(out, "-- %d: %s\n%s\n\n", m.Version, m.Name, m.SQL)
+		}
+		fmt.Fprintf(out, "%d pending migration(s) not applied (dry run).\n", len(pending))
+		return nil
+
+	default:
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		for _, m := range applied {
+			fmt.Fprintf(out, "Applied %d: %s\n", m.Version, m.Name)
+		}
+		fmt.Fprintf(out, "%d migration(s) applied.\n", len(applied))
+		return nil
+	}
+}
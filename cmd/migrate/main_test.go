@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dbPath := "test_migrate.db"
+	os.Remove(dbPath)
+	defer os.Remove(dbPath)
+
+	// Dry run lists pending migrations without applying them.
+	var out bytes.Buffer
+	if err := run([]string{"-db", dbPath, "-dry-run"}, &out); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "-- 1: create_workflows") || !strings.Contains(out.String(), "not applied (dry run)") {
+		t.Errorf("unexpected dry run output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := run([]string{"-db", dbPath, "-status"}, &out); err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if strings.Contains(out.String(), "applied ") || !strings.Contains(out.String(), "pending") {
+		t.Errorf("expected only pending migrations after dry run:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := run([]string{"-db", dbPath}, &out); err != nil {
+		t.Fatalf("migrate failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "Applied 1: create_workflows") {
+		t.Errorf("unexpected migrate output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := run([]string{"-db", dbPath, "-status"}, &out); err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if strings.Contains(out.String(), "pending") {
+		t.Errorf("expected no pending migrations after migrate:\n%s", out.String())
+	}
+}
@@ -1,90 +1,364 @@
+// Command workflow runs workflow definitions, converts them from and to n8n
+// workflows and draws them as graphs.
+//
+//	workflow run [-config workflow.yaml] [-input '[{"key": "value"}]'] [-no-cache]
+//	workflow convert -n8n flow.json > workflow.yaml
+//	workflow export --format n8n -config workflow.yaml > flow.json
+//	workflow graph --format dot -config workflow.yaml | dot -Tsvg > workflow.svg
 package main
 
 import (
-    "context"
-    "flag"
-    "os"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 
-    retryablehttp "github.com/hashicorp/go-retryablehttp"
-    "github.com/tmc/langchaingo"
-    "go.uber.org/zap"
+	_ "github.com/go-sql-driver/mysql" // SQL nodes may use MySQL databases
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
 
-    "go-workflow/pkg/framework"
-    "go-workflow/pkg/nodes"
-    "go-workflow/pkg/store"
+	"go-workflow/internal/noderegistry" // Registers the built-in nodes
+	"go-workflow/pkg/framework"
+	"go-workflow/pkg/llm"
+	"go-workflow/pkg/store"
+	"go-workflow/pkg/vectorstore"
 )
 
+const usage = `usage:
+  workflow run [-config workflow.yaml] [-input JSON]
+  workflow convert -n8n flow.json
+  workflow export --format n8n [-config workflow.yaml] [-name NAME]
+  workflow graph [--format dot|mermaid] [-config workflow.yaml] [-db DSN -run RUN_ID]`
+
 func main() {
-    cfgPath := flag.String("config", "config/example_workflow.yaml", "Path to workflow YAML definition")
-    flag.Parse()
-
-    logger, err := framework.NewLogger()
-    if err != nil {
-        panic(err)
-    }
-    metrics := framework.NewMetrics()
-
-    env := map[string]string{
-        "X-API-KEY":  os.Getenv("UNIPILE_API_KEY"),
-        "account_id": os.Getenv("UNIPILE_ACCOUNT_ID"),
-    }
-
-    httpCli := retryablehttp.NewClient()
-    langCli := langchaingo.NewClient(os.Getenv("OPENAI_API_KEY"))
-    dbCli, err := store.NewClient(context.Background())
-    if err != nil {
-        logger.Fatalf("failed to create DynamoDB client: %v", err)
-    }
-
-    ctx := &framework.Context{
-        Ctx:            context.Background(),
-        HTTPClient:     httpCli,
-        LangChain:      langCli,
-        DynamoDBClient: dbCli,
-        Logger:         logger,
-        Metrics:        metrics,
-        Env:            env,
-    }
-
-    def, err := framework.LoadFromYAML(*cfgPath)
-    if err != nil {
-        logger.Fatalf("failed to load workflow definition: %v", err)
-    }
-
-    nodesMap := map[string]framework.Node{
-        "Trigger": &nodes.ManualTrigger{Payload: []map[string]interface{}{{"keywords": "business development manager fintech"}}},
-        "Search": nodes.NewHTTPRequest(
-            "POST",
-            "https://api15.unipile.com:14501/api/v1/linkedin/search?account_id={{.account_id}}",
-            `{"api":"classic","category":"people","keywords":"{{.keywords}}"}`,
-        ),
-        "Slice": &nodes.CodeNode{Fn: func(items []map[string]interface{}) []map[string]interface{} {
-            if len(items) > 20 {
-                items = items[:20]
-            }
-            for i := range items {
-                items[i]["outreach_index"] = i + 1
-                items[i]["total_to_process"] = len(items)
-            }
-            return items
-        }},
-        "Details": nodes.NewHTTPRequest(
-            "GET",
-            "https://api15.unipile.com:14501/api/v1/users/{{.public_identifier}}?linkedin_sections=*&account_id={{.account_id}}",
-            "",
-        ),
-        "AI":    &nodes.OpenAINode{SystemPrompt: os.Getenv("OPENAI_SYSTEM_PROMPT")},
-        "Store": &nodes.DynamoDBUpsert{TableName: os.Getenv("DYNAMODB_CONTACTS_TABLE")},
-        "Invite": nodes.NewHTTPRequest(
-            "POST",
-            "https://api15.unipile.com:14501/api/v1/users/invite",
-            `{"provider_id":"{{.provider_id}}","account_id":"{{.account_id}}"}`,
-        ),
-        "Wait": &nodes.WaitNode{MaxSeconds: 30},
-    }
-
-    wf := &framework.Workflow{Nodes: nodesMap, Connections: def.Connections}
-    if err := wf.Run(ctx, "Trigger"); err != nil {
-        logger.Fatalf("workflow execution failed: %v", err)
-    }
-}
\ No newline at end of file
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "workflow: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out, errOut io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	switch args[0] {
+	case "run":
+		return runWorkflow(args[1:], errOut)
+	case "convert":
+		return convert(args[1:], out, errOut)
+	case "export":
+		return export(args[1:], out, errOut)
+	case "graph":
+		return graph(args[1:], out, errOut)
+	}
+	return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+}
+
+// convert writes the workflow definition converted from an n8n workflow to
+// out and every conversion issue to errOut. It fails if the workflow has
+// nodes without an equivalent, after writing the definition.
+func convert(args []string, out, errOut io.Writer) error {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	n8nPath := flags.String("n8n", "", "Path to n8n JSON flow")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *n8nPath == "" {
+		return errors.New("-n8n is required")
+	}
+
+	def, issues, err := framework.ConvertN8nJSON(*n8nPath)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(def)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(data); err != nil {
+		return err
+	}
+	printIssues(errOut, issues)
+	if framework.HasUnsupportedNodes(issues) {
+		return errors.New("the workflow contains unsupported nodes; replace them before running it")
+	}
+	return nil
+}
+
+// export writes a workflow definition in another format to out and every
+// export issue to errOut.
+func export(args []string, out, errOut io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	format := flags.String("format", "n8n", "Output format: n8n")
+	cfgPath := flags.String("config", "config/example_workflow.yaml", "Path to workflow YAML definition")
+	name := flags.String("name", "", "Workflow name (default: the file name of -config)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *format != "n8n" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	if *name == "" {
+		*name = strings.TrimSuffix(filepath.Base(*cfgPath), filepath.Ext(*cfgPath))
+	}
+
+	def, err := framework.LoadFromYAML(*cfgPath)
+	if err != nil {
+		return fmt.Errorf("failed to load workflow definition: %w", err)
+	}
+	data, issues, err := framework.ExportN8nJSON(def, *name)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(out, "%s\n", data); err != nil {
+		return err
+	}
+	printIssues(errOut, issues)
+	return nil
+}
+
+// graph writes a workflow definition as a DOT or Mermaid graph to out. With
+// -run, it draws the workflow version that run executed from the store in
+// -db, with its nodes colored by status.
+func graph(args []string, out, errOut io.Writer) error {
+	flags := flag.NewFlagSet("graph", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	format := flags.String("format", framework.GraphFormatDOT, "Output format: dot or mermaid")
+	cfgPath := flags.String("config", "config/example_workflow.yaml", "Path to workflow YAML definition")
+	dsn := flags.String("db", "workflows.db", "Workflow store DSN to load -run from")
+	runID := flags.String("run", "", "ID of a stored run to overlay")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var def *framework.WorkflowDef
+	var statuses map[string]string
+	var err error
+	if *runID != "" {
+		def, statuses, err = loadRun(context.Background(), *dsn, *runID)
+	} else {
+		def, err = framework.LoadFromYAML(*cfgPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load workflow definition: %w", err)
+	}
+	rendered, err := framework.RenderGraph(def, *format, statuses, nil)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(out, rendered)
+	return err
+}
+
+// loadRun loads the workflow definition a stored run executed and the
+// statuses of its nodes.
+func loadRun(ctx context.Context, dsn, runID string) (*framework.WorkflowDef, map[string]string, error) {
+	workflowStore, err := store.NewFromDSN(dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := workflowStore.Init(ctx); err != nil {
+		return nil, nil, err
+	}
+	run, err := workflowStore.GetRun(ctx, runID)
+	if err != nil {
+		return nil, nil, err
+	}
+	version, err := workflowStore.GetVersion(ctx, run.WorkflowID, run.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+	def, err := framework.LoadWorkflowDefFromYAMLString(version.Definition)
+	if err != nil {
+		return nil, nil, err
+	}
+	statuses := run.NodeStatuses
+	if statuses == nil {
+		statuses = map[string]string{}
+	}
+	return def, statuses, nil
+}
+
+func printIssues(w io.Writer, issues []framework.N8nIssue) {
+	for _, issue := range issues {
+		level := "warning"
+		if issue.Unsupported {
+			level = "unsupported"
+		}
+		fmt.Fprintf(w, "%s: %s\n", level, issue)
+	}
+}
+
+// runWorkflow builds the workflow in a YAML definition and runs it from its
+// start node.
+func runWorkflow(args []string, errOut io.Writer) error {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(errOut)
+	cfgPath := flags.String("config", "config/example_workflow.yaml", "Path to workflow YAML definition")
+	input := flags.String("input", "", "JSON array of records passed to the start node")
+	noCache := flags.Bool("no-cache", false, "Send LLM requests even if their response is cached")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var initialInput []map[string]interface{}
+	if *input != "" {
+		if err := json.Unmarshal([]byte(*input), &initialInput); err != nil {
+			return fmt.Errorf("invalid -input: %w", err)
+		}
+	}
+
+	def, err := framework.LoadFromYAML(*cfgPath)
+	if err != nil {
+		return fmt.Errorf("failed to load workflow definition: %w", err)
+	}
+	// WORKFLOW_PLUGINS_DIR holds plugin programs implementing further node
+	// types, which are added to those of this run only.
+	nodeTypes := framework.DefaultRegistry.Clone()
+	plugins, err := noderegistry.LoadPlugins(os.Getenv("WORKFLOW_PLUGINS_DIR"), nodeTypes)
+	if err != nil {
+		return fmt.Errorf("failed to load plugins: %w", err)
+	}
+	for _, plugin := range plugins {
+		defer plugin.Close()
+	}
+	wf, err := framework.BuildWorkflow(def, nodeTypes)
+	if err != nil {
+		return fmt.Errorf("failed to build workflow: %w", err)
+	}
+
+	ctx, err := newContext(context.Background())
+	if err != nil {
+		return err
+	}
+	ctx.LLMCacheBypass = *noCache
+	// A single run: the workflow budget limits it as well.
+	ctx.Workflow = strings.TrimSuffix(filepath.Base(*cfgPath), filepath.Ext(*cfgPath))
+	ctx.RunUsage = framework.NewUsageMeter("run", def.Budgets.Run)
+	ctx.WorkflowUsage = framework.NewUsageMeter("workflow", def.Budgets.Workflow)
+	ctx.LoadWorkflow = workflowLoader(filepath.Dir(*cfgPath))
+	ctx.NodeTypes = nodeTypes
+	if ctx.AWS, err = ctx.AWS.WithProfiles(def.AWSProfiles); err != nil {
+		return err
+	}
+	if ctx.SQL, err = ctx.SQL.WithDatabases(def.Databases); err != nil {
+		return err
+	}
+	runErr := wf.Run(ctx, def.StartNode(), initialInput)
+	if usage := ctx.RunUsage.Usage(); usage.TotalTokens() > 0 {
+		ctx.Logger.Infof("LLM usage: %d prompt and %d completion tokens, $%.4f", usage.PromptTokens, usage.CompletionTokens, usage.Cost)
+	}
+	if runErr != nil {
+		return fmt.Errorf("workflow execution failed: %w", runErr)
+	}
+	return nil
+}
+
+// workflowLoader loads the workflows that nodes run, such as the workflow
+// tools of an aiAgent, from YAML files relative to dir. The extension .yaml
+// may be left out.
+func workflowLoader(dir string) func(ctx context.Context, name string) (*framework.WorkflowDef, error) {
+	return func(ctx context.Context, name string) (*framework.WorkflowDef, error) {
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		if filepath.Ext(path) == "" {
+			path += ".yaml"
+		}
+		return framework.LoadFromYAML(path)
+	}
+}
+
+// newContext creates the clients shared by the nodes of a run from the
+// environment.
+func newContext(ctx context.Context) (*framework.Context, error) {
+	logger, err := framework.NewLogger()
+	if err != nil {
+		return nil, err
+	}
+
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	// Names used by the example workflow's templates.
+	env["X-API-KEY"] = os.Getenv("UNIPILE_API_KEY")
+	env["account_id"] = os.Getenv("UNIPILE_ACCOUNT_ID")
+
+	wctx := &framework.Context{
+		Ctx:        ctx,
+		HTTPClient: retryablehttp.NewClient(),
+		Logger:     logger,
+		Metrics:    framework.NewMetrics(prometheus.NewRegistry()),
+		Env:        env,
+		DynamoDBClientFactory: func(ctx context.Context, region, accessKeyID, secretAccessKey string) (framework.DynamoDBAPI, error) {
+			return store.NewClient(ctx, region, accessKeyID, secretAccessKey)
+		},
+	}
+
+	// AWS_PROFILES_FILE holds AWS profiles, with their keys, that nodes
+	// select by name besides those of the workflow.
+	var profiles map[string]framework.AWSProfile
+	if path := env["AWS_PROFILES_FILE"]; path != "" {
+		if profiles, err = framework.LoadAWSProfiles(path); err != nil {
+			return nil, fmt.Errorf("failed to load AWS profiles: %w", err)
+		}
+	}
+	wctx.AWS = framework.NewAWSClients(profiles, env, nil)
+
+	// SQL_DATABASES_FILE holds the databases, with their passwords, that
+	// SQL nodes select by name besides those of the workflow.
+	var databases map[string]framework.SQLDatabase
+	if path := env["SQL_DATABASES_FILE"]; path != "" {
+		if databases, err = framework.LoadSQLDatabases(path); err != nil {
+			return nil, fmt.Errorf("failed to load SQL databases: %w", err)
+		}
+	}
+	wctx.SQL = framework.NewSQLDatabases(databases, env)
+
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		client, err := store.NewClient(ctx, region, os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create DynamoDB client: %w", err)
+		}
+		wctx.DynamoDBClient = client
+	}
+
+	// LLM_CACHE caches LLM responses: "memory", or a SQLite file that keeps
+	// them across runs.
+	if wctx.LLMCache, err = llm.OpenCache(env["LLM_CACHE"]); err != nil {
+		return nil, fmt.Errorf("failed to open LLM cache: %w", err)
+	}
+
+	// VECTOR_STORE keeps the indexes of vectorStore nodes in a SQLite file;
+	// in memory they only last for the run.
+	vectors := env["VECTOR_STORE"]
+	if vectors == "" {
+		vectors = "memory"
+	}
+	if wctx.VectorStore, err = vectorstore.Open(vectors); err != nil {
+		return nil, fmt.Errorf("failed to open vector store: %w", err)
+	}
+
+	// openaiNode uses the OpenAI client unless it selects a provider.
+	wctx.LLMClientFactory = llm.NewClientFactory(env)
+	if env["OPENAI_API_KEY"] != "" {
+		client, err := wctx.LLMClientFactory(ctx, "openai")
+		if err != nil {
+			return nil, err
+		}
+		wctx.LangChain = client
+	}
+	return wctx, nil
+}
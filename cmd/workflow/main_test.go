@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-workflow/pkg/framework"
+	"go-workflow/pkg/store"
+)
+
+const n8nFlow = `{
+  "nodes": [
+    {"name": "Webhook", "type": "n8n-nodes-base.webhook", "typeVersion": 1, "parameters": {"path": "orders", "httpMethod": "POST"}},
+    {"name": "Big order?", "type": "n8n-nodes-base.if", "typeVersion": 1,
+     "parameters": {"conditions": {"number": [{"value1": "={{$json.total}}", "operation": "larger", "value2": 100}]}}},
+    {"name": "Mark priority", "type": "n8n-nodes-base.set", "typeVersion": 2,
+     "parameters": {"values": {"string": [{"name": "priority", "value": "high"}]}}},
+    {"name": "Notify", "type": "n8n-nodes-base.httpRequest", "typeVersion": 4.2,
+     "parameters": {"method": "POST", "url": %q, "sendBody": true, "specifyBody": "json", "jsonBody": "{\"text\": \"big order\"}"}}
+  ],
+  "connections": {
+    "Webhook": {"main": [[{"node": "Big order?", "type": "main", "index": 0}]]},
+    "Big order?": {"main": [[{"node": "Mark priority", "type": "main", "index": 0}], []]},
+    "Mark priority": {"main": [[{"node": "Notify", "type": "main", "index": 0}]]}
+  }
+}`
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConvertAndRun(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	n8nPath := writeFile(t, "flow.json", fmt.Sprintf(n8nFlow, server.URL))
+	var out, errOut bytes.Buffer
+	if err := run([]string{"convert", "-n8n", n8nPath}, &out, &errOut); err != nil {
+		t.Fatalf("convert failed: %v\n%s", err, errOut.String())
+	}
+	if !strings.Contains(errOut.String(), "warning: node \"Webhook\"") {
+		t.Errorf("expected conversion warnings on stderr, got:\n%s", errOut.String())
+	}
+
+	yamlPath := writeFile(t, "flow.yaml", out.String())
+	input := `[{"total": 250}, {"total": 20}]`
+	if err := run([]string{"run", "-config", yamlPath, "-input", input}, &out, &errOut); err != nil {
+		t.Fatalf("run failed: %v\n%s", err, out.String())
+	}
+	if len(bodies) != 1 || bodies[0]["text"] != "big order" {
+		t.Errorf("expected one notification for the big order, got %v", bodies)
+	}
+}
+
+func TestConvert_Unsupported(t *testing.T) {
+	n8nPath := writeFile(t, "flow.json", `{
+  "nodes": [{"name": "Slack", "type": "n8n-nodes-base.slack", "typeVersion": 2, "parameters": {"channel": "#ops"}}],
+  "connections": {}
+}`)
+	var out, errOut bytes.Buffer
+	err := run([]string{"convert", "-n8n", n8nPath}, &out, &errOut)
+	if err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Fatalf("expected an unsupported nodes error, got %v", err)
+	}
+	if !strings.Contains(out.String(), "type: n8n-nodes-base.slack") {
+		t.Errorf("expected the definition to be written anyway, got:\n%s", out.String())
+	}
+	if !strings.Contains(errOut.String(), `unsupported: node "Slack"`) {
+		t.Errorf("expected the unsupported node on stderr, got:\n%s", errOut.String())
+	}
+}
+
+func TestRun_UnknownCommand(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if err := run(nil, &out, &errOut); err == nil {
+		t.Error("expected usage error without a command")
+	}
+	if err := run([]string{"deploy"}, &out, &errOut); err == nil || !strings.Contains(err.Error(), "unknown command") {
+		t.Errorf("expected unknown command error, got %v", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	yamlPath := writeFile(t, "orders.yaml", `
+start: trigger
+nodes:
+  trigger:
+    type: manualTrigger
+  wait:
+    type: waitNode
+    maxSeconds: 5
+connections:
+  trigger: [wait]
+`)
+	var out, errOut bytes.Buffer
+	if err := run([]string{"export", "--format", "n8n", "-config", yamlPath}, &out, &errOut); err != nil {
+		t.Fatalf("export failed: %v", err)
+	}
+	var wf struct {
+		Name  string `json:"name"`
+		Nodes []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+		} `json:"nodes"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &wf); err != nil {
+		t.Fatalf("export is not valid JSON: %v\n%s", err, out.String())
+	}
+	if wf.Name != "orders" || len(wf.Nodes) != 2 || wf.Nodes[1].Type != "n8n-nodes-base.wait" {
+		t.Errorf("unexpected export: %+v", wf)
+	}
+	if !strings.Contains(errOut.String(), `warning: node "wait"`) {
+		t.Errorf("expected export warnings on stderr, got:\n%s", errOut.String())
+	}
+
+	if err := run([]string{"export", "--format", "bpmn", "-config", yamlPath}, &out, &errOut); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestGraph(t *testing.T) {
+	definition := `
+start: trigger
+nodes:
+  trigger:
+    type: manualTrigger
+  wait:
+    type: waitNode
+    maxSeconds: 5
+connections:
+  trigger: [wait]
+`
+	yamlPath := writeFile(t, "orders.yaml", definition)
+	var out, errOut bytes.Buffer
+	if err := run([]string{"graph", "--format", "mermaid", "-config", yamlPath}, &out, &errOut); err != nil {
+		t.Fatalf("graph failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "flowchart LR\n") || !strings.Contains(out.String(), "n0 --> n1") {
+		t.Errorf("unexpected Mermaid graph:\n%s", out.String())
+	}
+
+	// Overlay a run stored in a SQLite database.
+	ctx := context.Background()
+	dsn := filepath.Join(t.TempDir(), "workflows.db")
+	workflowStore, err := store.NewFromDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := workflowStore.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+	wf := &store.Workflow{ID: "wf1", Name: "orders", Definition: definition}
+	if err := workflowStore.SaveWorkflow(ctx, wf); err != nil {
+		t.Fatal(err)
+	}
+	runRecord := &store.WorkflowRun{ID: "run1", WorkflowID: "wf1", Version: 1, Status: store.RunStatusFailed, StartedAt: time.Now()}
+	if err := workflowStore.SaveRun(ctx, runRecord); err != nil {
+		t.Fatal(err)
+	}
+	runRecord.NodeStatuses = map[string]string{"trigger": store.NodeStatusCompleted, "wait": store.NodeStatusFailed}
+	if err := workflowStore.UpdateRun(ctx, runRecord); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if err := run([]string{"graph", "-db", dsn, "-run", "run1"}, &out, &errOut); err != nil {
+		t.Fatalf("graph with run overlay failed: %v", err)
+	}
+	if !strings.Contains(out.String(), `fillcolor="#ffcdd2"`) {
+		t.Errorf("expected the failed node to be colored, got:\n%s", out.String())
+	}
+
+	if err := run([]string{"graph", "--format", "svg", "-config", yamlPath}, &out, &errOut); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestWorkflowLoader(t *testing.T) {
+	path := writeFile(t, "research.yaml", `
+nodes:
+  manualTrigger:
+    type: manualTrigger
+`)
+	load := workflowLoader(filepath.Dir(path))
+	for _, name := range []string{"research", "research.yaml", path} {
+		def, err := load(context.Background(), name)
+		if err != nil {
+			t.Fatalf("load(%s) failed: %v", name, err)
+		}
+		if def.NodeDefs["manualTrigger"] == nil {
+			t.Errorf("load(%s) returned %+v", name, def)
+		}
+	}
+}
+
+func TestRun_CodeNodes(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	path := writeFile(t, "top.yaml", fmt.Sprintf(`
+start: trigger
+nodes:
+  trigger:
+    type: webhookTrigger
+  best:
+    type: codeNode
+    function: top
+    params:
+      field: score
+      limit: 2
+  label:
+    type: codeNode
+    mode: each
+    code: "return {body: {label: item.name.toUpperCase() + ' ' + item.score}};"
+  notify:
+    type: httpRequest
+    method: POST
+    url: %s
+    bodyKey: body
+connections:
+  trigger: [best]
+  best: [label]
+  label: [notify]
+`, server.URL))
+	var out, errOut bytes.Buffer
+	input := `[{"name": "ann", "score": 3}, {"name": "bob", "score": 8}, {"name": "cid", "score": 5}]`
+	if err := run([]string{"run", "-config", path, "-input", input}, &out, &errOut); err != nil {
+		t.Fatalf("run failed: %v\n%s", err, errOut.String())
+	}
+	sort.Slice(bodies, func(i, j int) bool { return bodies[i]["label"].(string) < bodies[j]["label"].(string) })
+	if len(bodies) != 2 || bodies[0]["label"] != "BOB 8" || bodies[1]["label"] != "CID 5" {
+		t.Errorf("expected the two best records to be labeled, got %v", bodies)
+	}
+}
+
+// TestHelperPlugin is the plugin program of TestRun_Plugin, started as the
+// test binary with WORKFLOW_TEST_PLUGIN set. Its testUpper node upper-cases
+// the strings of an object field.
+func TestHelperPlugin(t *testing.T) {
+	if os.Getenv("WORKFLOW_TEST_PLUGIN") == "" {
+		t.Skip("only run as a plugin")
+	}
+	nodes := []framework.PluginNodeType{{Type: "testUpper", Params: []framework.FunctionParam{{Name: "field", Type: "string", Required: true}}}}
+	framework.ServePlugin(os.Stdin, os.Stdout, nodes, func(req framework.PluginRequest) ([]map[string]interface{}, error) {
+		field := req.Config["field"].(string)
+		for _, item := range req.Items {
+			values, _ := item[field].(map[string]interface{})
+			for k, v := range values {
+				if s, ok := v.(string); ok {
+					values[k] = strings.ToUpper(s)
+				}
+			}
+		}
+		return req.Items, nil
+	})
+	os.Exit(0)
+}
+
+func TestRun_Plugin(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\nexec %q -test.run='^TestHelperPlugin$'\n", os.Args[0])
+	if err := os.WriteFile(filepath.Join(dir, "upper"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("not a plugin"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WORKFLOW_TEST_PLUGIN", "1")
+	t.Setenv("WORKFLOW_PLUGINS_DIR", dir)
+
+	path := writeFile(t, "plugin.yaml", fmt.Sprintf(`
+start: trigger
+nodes:
+  trigger:
+    type: webhookTrigger
+  upper:
+    type: testUpper
+    field: body
+  notify:
+    type: httpRequest
+    method: POST
+    url: %s
+    bodyKey: body
+connections:
+  trigger: [upper]
+  upper: [notify]
+`, server.URL))
+	var out, errOut bytes.Buffer
+	if err := run([]string{"run", "-config", path, "-input", `[{"body": {"name": "ann"}}]`}, &out, &errOut); err != nil {
+		t.Fatalf("run failed: %v\n%s", err, errOut.String())
+	}
+	if len(bodies) != 1 || bodies[0]["name"] != "ANN" {
+		t.Errorf("expected the record changed by the plugin, got %v", bodies)
+	}
+}
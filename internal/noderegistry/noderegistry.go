@@ -1,165 +1,521 @@
 package noderegistry
 
 import (
-	"log"
+	"fmt"
+	"strings"
+	"time"
 
 	"go-workflow/pkg/framework"
+	"go-workflow/pkg/llm"
 	"go-workflow/pkg/nodes"
-
-	"gopkg.in/yaml.v3"
 )
 
 func init() {
-	framework.RegisterNodeFactory("manualTrigger", func(nodeDef *yaml.Node) (framework.Node, error) {
-		var temp struct {
-			Type string `yaml:"type"`
-			Payload []map[string]interface{} `yaml:"payload"`
+	framework.RegisterNode(triggerType("manualTrigger", "Manual Trigger", "Starts the workflow with a fixed payload."), func(p struct {
+		Payload []map[string]interface{} `yaml:"payload" doc:"Records the workflow starts with."`
+	}) (framework.Node, error) {
+		return nodes.NewManualTrigger(p.Payload), nil
+	})
+
+	framework.RegisterNode(nodeType("setNode", "Set", framework.CategoryTransform, "Adds, updates or removes fields of every record."), func(p struct {
+		SetValues  map[string]interface{} `yaml:"setValues" doc:"Fields to set, by name."`
+		RemoveKeys []string               `yaml:"removeKeys" doc:"Fields to remove."`
+	}) (framework.Node, error) {
+		return nodes.NewSetNode(p.SetValues, p.RemoveKeys), nil
+	})
+
+	framework.RegisterNode(nodeType("dedupeNode", "Remove Duplicates", framework.CategoryTransform, "Drops records whose key field was seen before."), func(p struct {
+		Key string `yaml:"key" doc:"Field that identifies a record."`
+	}) (framework.Node, error) {
+		return nodes.NewDedupeNode(p.Key), nil
+	})
+
+	framework.RegisterNode(nodeType("mergeNode", "Merge", framework.CategoryTransform, "Merges the fields of records with the same key."), func(p struct {
+		Key string `yaml:"key" doc:"Field that identifies a record."`
+	}) (framework.Node, error) {
+		return nodes.NewMergeNode(p.Key), nil
+	})
+
+	framework.RegisterNode(nodeType("splitInBatchesNode", "Split in Batches", framework.CategoryFlow, "Groups the records into batches, one record per batch."), func(p struct {
+		BatchSize int `yaml:"batchSize" doc:"Number of records per batch."`
+	}) (framework.Node, error) {
+		return nodes.NewSplitInBatchesNode(p.BatchSize), nil
+	})
+
+	switchInfo := nodeType("switchNode", "Switch", framework.CategoryFlow, "Routes records to outputs by conditions.")
+	switchInfo.Outputs, switchInfo.DynamicOutputs = nil, true
+	framework.RegisterNode(switchInfo, func(p struct {
+		Conditions     map[string]nodes.Condition `yaml:"conditions" doc:"Conditions by branch name; output i is the i-th branch in name order."`
+		Rules          []nodes.SwitchRule         `yaml:"rules" doc:"Rules in order, each routing the records its conditions match to its output."`
+		FallbackOutput *int                       `yaml:"fallbackOutput" doc:"Output of the records no rule matches; -1 drops them."`
+	}) (framework.Node, error) {
+		for _, cond := range p.Conditions {
+			if err := nodes.ValidateCondition(cond); err != nil {
+				return nil, err
+			}
 		}
-		if err := nodeDef.Decode(&temp); err != nil {
-			return nil, err
+		for _, rule := range p.Rules {
+			for _, cond := range rule.Conditions {
+				if err := nodes.ValidateCondition(cond); err != nil {
+					return nil, err
+				}
+			}
+		}
+		node := nodes.NewSwitchNode(p.Conditions)
+		node.Rules = p.Rules
+		if p.FallbackOutput != nil {
+			node.FallbackOutput = *p.FallbackOutput
 		}
-		return nodes.NewManualTrigger(temp.Payload), nil
+		return node, nil
 	})
 
-	framework.RegisterNodeFactory("setNode", func(nodeDef *yaml.Node) (framework.Node, error) {
-		var temp struct {
-			Type string `yaml:"type"`
-			SetValues map[string]interface{} `yaml:"setValues"`
-			RemoveKeys []string `yaml:"removeKeys"`
+	ifInfo := nodeType("ifNode", "If", framework.CategoryFlow, "Routes the records that match its conditions to output 0 and the others to output 1.")
+	ifInfo.Outputs = []string{"true", "false"}
+	framework.RegisterNode(ifInfo, func(p struct {
+		Conditions []nodes.Condition `yaml:"conditions" doc:"Conditions on the fields of a record."`
+		Combine    string            `yaml:"combine" enum:"all,any" default:"all" doc:"Whether all or any of the conditions must match."`
+	}) (framework.Node, error) {
+		for _, cond := range p.Conditions {
+			if err := nodes.ValidateCondition(cond); err != nil {
+				return nil, err
+			}
 		}
-		if err := nodeDef.Decode(&temp); err != nil {
+		return nodes.NewIfNode(p.Conditions, p.Combine), nil
+	})
+
+	framework.RegisterNode(triggerType("webhookTrigger", "Webhook Trigger", "Starts the workflow with the records it is run with."), func(p struct{}) (framework.Node, error) {
+		return nodes.NewWebhookTrigger(), nil
+	})
+
+	framework.RegisterNode(nodeType("waitForNode", "Wait Until", framework.CategoryFlow, "Holds every record until the time in one of its fields."), func(p struct {
+		TimestampKey string `yaml:"timestampKey" doc:"Field holding the time to wait for."`
+	}) (framework.Node, error) {
+		return nodes.NewWaitForNode(p.TimestampKey), nil
+	})
+
+	framework.RegisterNode(nodeType("errorHandlerNode", "Error Handler", framework.CategoryFlow, "Receives the records of failed nodes through errorConnections."), func(p struct{}) (framework.Node, error) {
+		return nodes.NewErrorHandlerNode(), nil
+	})
+
+	framework.RegisterNode(nodeType("httpRequest", "HTTP Request", framework.CategoryIntegration, "Sends an HTTP request for every record."), func(p struct {
+		URLKey     string            `yaml:"urlKey" doc:"Field holding the URL."`
+		MethodKey  string            `yaml:"methodKey" doc:"Field holding the method."`
+		HeadersKey string            `yaml:"headersKey" doc:"Field holding the headers."`
+		BodyKey    string            `yaml:"bodyKey" doc:"Field holding the body."`
+		URL        string            `yaml:"url" doc:"URL, unless urlKey is set."`
+		Method     string            `yaml:"method" default:"GET" doc:"Method, unless methodKey is set."`
+		Headers    map[string]string `yaml:"headers" doc:"Headers, unless headersKey is set."`
+		Body       interface{}       `yaml:"body" doc:"Body, unless bodyKey is set."`
+	}) (framework.Node, error) {
+		node := nodes.NewHTTPRequest(p.URLKey, p.MethodKey, p.HeadersKey, p.BodyKey)
+		node.URL = p.URL
+		node.Method = p.Method
+		node.Headers = p.Headers
+		node.Body = p.Body
+		return node, nil
+	})
+
+	framework.RegisterNode(nodeType("openaiNode", "OpenAI", framework.CategoryAI, "Prompts a model for every record and adds its JSON answer.", framework.CredentialLLMProvider), func(p openAIParams) (framework.Node, error) {
+		return newOpenAINode(p)
+	})
+
+	framework.RegisterNode(nodeType("llm", "LLM", framework.CategoryAI, "Prompts the model of an LLM provider for every record and adds its JSON answer.", framework.CredentialLLMProvider), func(p openAIParams) (framework.Node, error) {
+		node, err := newOpenAINode(p)
+		if err != nil {
 			return nil, err
 		}
-		return nodes.NewSetNode(temp.SetValues, temp.RemoveKeys), nil
+		if node.Provider == "" {
+			return nil, fmt.Errorf("llm node requires a provider, use one of %s", strings.Join(llm.Providers(), ", "))
+		}
+		return node, nil
 	})
 
-	framework.RegisterNodeFactory("dedupeNode", func(nodeDef *yaml.Node) (framework.Node, error) {
-		var temp struct {
-			Type string `yaml:"type"`
-			Key string `yaml:"key"`
+	framework.RegisterNode(nodeType("aiAgent", "AI Agent", framework.CategoryAI, "Lets a model call nodes and workflows as tools before it answers for every record.", framework.CredentialLLMProvider), func(p struct {
+		openAIParams  `yaml:",inline"`
+		Tools         []nodes.AgentTool `yaml:"tools" required:"true" doc:"Nodes and workflows the model can call."`
+		MaxIterations int               `yaml:"maxIterations" default:"5" doc:"Maximum number of model responses per record."`
+	}) (framework.Node, error) {
+		if len(p.Tools) == 0 {
+			return nil, fmt.Errorf("aiAgent node requires tools")
 		}
-		if err := nodeDef.Decode(&temp); err != nil {
+		if err := nodes.ValidateAgentTools(p.Tools); err != nil {
 			return nil, err
 		}
-		return nodes.NewDedupeNode(temp.Key), nil
-	})
-
-	framework.RegisterNodeFactory("mergeNode", func(nodeDef *yaml.Node) (framework.Node, error) {
-		var temp struct {
-			Type string `yaml:"type"`
-			Key string `yaml:"key"`
+		if p.MaxIterations < 0 {
+			return nil, fmt.Errorf("maxIterations must not be negative")
 		}
-		if err := nodeDef.Decode(&temp); err != nil {
+		llmNode, err := newOpenAINode(p.openAIParams)
+		if err != nil {
 			return nil, err
 		}
-		return nodes.NewMergeNode(temp.Key), nil
+		node := nodes.NewAIAgentNode(llmNode.SystemPrompt, p.Tools)
+		node.OpenAINode = *llmNode
+		node.MaxIterations = p.MaxIterations
+		return node, nil
 	})
 
-	framework.RegisterNodeFactory("splitInBatchesNode", func(nodeDef *yaml.Node) (framework.Node, error) {
-		var temp struct {
-			Type string `yaml:"type"`
-			BatchSize int `yaml:"batchSize"`
+	framework.RegisterNode(nodeType("embeddings", "Embeddings", framework.CategoryAI, "Adds the embedding vector of a text field to every record.", framework.CredentialLLMProvider), func(p struct {
+		Field     string `yaml:"field" required:"true" doc:"Field holding the text."`
+		OutputKey string `yaml:"outputKey" default:"embedding" doc:"Field to store the vector in."`
+		Provider  string `yaml:"provider" doc:"LLM provider; the OpenAI client if empty."`
+		BatchSize int    `yaml:"batchSize" default:"100" doc:"Number of texts per request."`
+	}) (framework.Node, error) {
+		if p.Field == "" {
+			return nil, fmt.Errorf("embeddings node requires a field")
 		}
-		if err := nodeDef.Decode(&temp); err != nil {
-			return nil, err
+		if p.Provider != "" && !llm.Registered(p.Provider) {
+			return nil, fmt.Errorf("unknown LLM provider %q, use one of %s", p.Provider, strings.Join(llm.Providers(), ", "))
 		}
-		return nodes.NewSplitInBatchesNode(temp.BatchSize), nil
+		node := nodes.NewEmbeddingsNode(p.Field)
+		node.OutputKey = p.OutputKey
+		node.Provider = p.Provider
+		node.BatchSize = p.BatchSize
+		return node, nil
 	})
 
-	framework.RegisterNodeFactory("switchNode", func(nodeDef *yaml.Node) (framework.Node, error) {
-		var temp struct {
-			Type string `yaml:"type"`
-			Conditions map[string]nodes.Condition `yaml:"conditions"`
-		}
-		if err := nodeDef.Decode(&temp); err != nil {
+	framework.RegisterNode(nodeType("vectorStore", "Vector Store", framework.CategoryAI, "Stores, searches or deduplicates records by their vectors in a named index."), func(p struct {
+		Operation      string                 `yaml:"operation" required:"true" enum:"upsert,query,dedupe" doc:"What to do with the records."`
+		Index          string                 `yaml:"index" required:"true" doc:"Name of the index."`
+		VectorField    string                 `yaml:"vectorField" default:"embedding" doc:"Field holding the vector."`
+		IDField        string                 `yaml:"idField" default:"id" doc:"Field identifying a document on upsert."`
+		MetadataFields []string               `yaml:"metadataFields" doc:"Fields stored with a document; all but the vector if empty."`
+		TopK           int                    `yaml:"topK" default:"5" doc:"Number of documents a query returns."`
+		MinScore       float64                `yaml:"minScore" doc:"Minimum similarity of a match; 0.95 for dedupe."`
+		Filter         map[string]interface{} `yaml:"filter" doc:"Metadata values documents must have; strings are templates."`
+		OutputKey      string                 `yaml:"outputKey" default:"matches" doc:"Field to store the matches of a query in."`
+	}) (framework.Node, error) {
+		if err := nodes.ValidateVectorOperation(p.Operation); err != nil {
 			return nil, err
 		}
-		return nodes.NewSwitchNode(temp.Conditions), nil
+		if p.Index == "" {
+			return nil, fmt.Errorf("vectorStore node requires an index")
+		}
+		for key, value := range p.Filter {
+			if text, ok := value.(string); ok {
+				if err := nodes.ValidatePromptTemplate(text); err != nil {
+					return nil, fmt.Errorf("filter %s: %w", key, err)
+				}
+			}
+		}
+		node := nodes.NewVectorStoreNode(p.Operation, p.Index)
+		node.VectorField = p.VectorField
+		node.IDField = p.IDField
+		node.MetadataFields = p.MetadataFields
+		node.TopK = p.TopK
+		node.MinScore = p.MinScore
+		node.Filter = p.Filter
+		node.OutputKey = p.OutputKey
+		return node, nil
+	})
+
+	framework.RegisterNode(nodeType("waitNode", "Wait", framework.CategoryFlow, "Pauses for a random time of up to maxSeconds."), func(p struct {
+		MaxSeconds int `yaml:"maxSeconds" doc:"Longest pause, in seconds."`
+	}) (framework.Node, error) {
+		return nodes.NewWaitNode(p.MaxSeconds), nil
 	})
 
-	framework.RegisterNodeFactory("waitForNode", func(nodeDef *yaml.Node) (framework.Node, error) {
-		var temp struct {
-			Type string `yaml:"type"`
-			TimestampKey string `yaml:"timestampKey"`
+	upsertInfo := nodeType("dynamodbUpsert", "DynamoDB Upsert", framework.CategoryData, "Writes every record as an item of a DynamoDB table.", framework.CredentialAWSProfile)
+	upsertInfo.Outputs = []string{"written", "condition failed"}
+	framework.RegisterNode(upsertInfo, func(p struct {
+		dynamoDBCredentialParams `yaml:",inline"`
+		TableNameKey             string                 `yaml:"tableNameKey" doc:"Field holding the table name."`
+		Fields                   []string               `yaml:"fields" doc:"Fields to write; all if empty."`
+		Exclude                  []string               `yaml:"exclude" doc:"Fields not to write."`
+		Rename                   map[string]string      `yaml:"rename" doc:"Attribute names of fields."`
+		EmptyStrings             string                 `yaml:"emptyStrings" enum:"keep,null,omit" default:"keep" doc:"How empty strings are written."`
+		Floats                   string                 `yaml:"floats" enum:"number,string" default:"number" doc:"How floating-point numbers are written."`
+		Mode                     string                 `yaml:"mode" enum:"put,batch,update" default:"put" doc:"How items are written."`
+		KeyFields                []string               `yaml:"keyFields" doc:"Fields of the item key, for batch and update mode."`
+		UpdateExpression         string                 `yaml:"updateExpression" doc:"Update expression; sets every attribute but the key if empty."`
+		ConditionExpression      string                 `yaml:"conditionExpression" doc:"Condition an item must meet to be written."`
+		ExpressionNames          map[string]string      `yaml:"expressionNames" doc:"Attribute name placeholders."`
+		ExpressionValues         map[string]interface{} `yaml:"expressionValues" doc:"Value placeholders; strings are templates."`
+		MaxRetries               int                    `yaml:"maxRetries" default:"5" doc:"Retries of unprocessed items in batch mode; -1 disables them."`
+	}) (framework.Node, error) {
+		node := nodes.NewDynamoDBUpsert(p.TableNameKey, p.AWSRegionKey, p.AWSAccessKeyIDKey, p.AWSSecretAccessKeyKey)
+		node.DynamoDBItemOptions = nodes.DynamoDBItemOptions{
+			Fields:       p.Fields,
+			Exclude:      p.Exclude,
+			Rename:       p.Rename,
+			EmptyStrings: p.EmptyStrings,
+			Floats:       p.Floats,
 		}
-		if err := nodeDef.Decode(&temp); err != nil {
+		if err := nodes.ValidateDynamoDBItemOptions(node.DynamoDBItemOptions); err != nil {
 			return nil, err
 		}
-		return nodes.NewWaitForNode(temp.TimestampKey), nil
+		node.AWSProfile = p.AWSProfile
+		node.Mode = p.Mode
+		node.KeyFields = p.KeyFields
+		node.UpdateExpression = p.UpdateExpression
+		node.ConditionExpression = p.ConditionExpression
+		node.ExpressionNames = p.ExpressionNames
+		node.ExpressionValues = p.ExpressionValues
+		node.MaxRetries = p.MaxRetries
+		if err := nodes.ValidateDynamoDBWrite(node); err != nil {
+			return nil, err
+		}
+		return node, nil
 	})
 
-	framework.RegisterNodeFactory("errorHandlerNode", func(nodeDef *yaml.Node) (framework.Node, error) {
-		var temp struct {
-			Type string `yaml:"type"`
+	getInfo := nodeType("dynamodbGet", "DynamoDB Get", framework.CategoryData, "Adds the DynamoDB item with a key to every record.", framework.CredentialAWSProfile)
+	getInfo.Outputs = []string{"found", "not found"}
+	framework.RegisterNode(getInfo, func(p struct {
+		dynamoDBTableParams  `yaml:",inline"`
+		Key                  map[string]interface{} `yaml:"key" required:"true" doc:"Key of the item; strings are templates."`
+		ProjectionExpression string                 `yaml:"projectionExpression" doc:"Attributes to read."`
+		ExpressionNames      map[string]string      `yaml:"expressionNames" doc:"Attribute name placeholders."`
+		ConsistentRead       bool                   `yaml:"consistentRead" doc:"Read with strong consistency."`
+		OutputKey            string                 `yaml:"outputKey" default:"item" doc:"Field to store the item in."`
+	}) (framework.Node, error) {
+		node := &nodes.DynamoDBGet{
+			DynamoDBTable:        p.table(),
+			Key:                  p.Key,
+			ProjectionExpression: p.ProjectionExpression,
+			ExpressionNames:      p.ExpressionNames,
+			ConsistentRead:       p.ConsistentRead,
+			OutputKey:            p.OutputKey,
 		}
-		if err := nodeDef.Decode(&temp); err != nil {
+		if err := nodes.ValidateDynamoDBGet(node); err != nil {
 			return nil, err
 		}
-		return nodes.NewErrorHandlerNode(), nil
+		return node, nil
 	})
 
-	framework.RegisterNodeFactory("httpRequest", func(nodeDef *yaml.Node) (framework.Node, error) {
-		var temp struct {
-			Type string `yaml:"type"`
-			URLKey string `yaml:"urlKey"`
-			MethodKey string `yaml:"methodKey"`
-			HeadersKey string `yaml:"headersKey"`
-			BodyKey string `yaml:"bodyKey"`
+	framework.RegisterNode(nodeType("dynamodbQuery", "DynamoDB Query", framework.CategoryData, "Reads the DynamoDB items matching a key condition for every record.", framework.CredentialAWSProfile), func(p struct {
+		dynamoDBSearchParams   `yaml:",inline"`
+		KeyConditionExpression string `yaml:"keyConditionExpression" required:"true" doc:"Key condition of the items."`
+		Descending             bool   `yaml:"descending" doc:"Read in descending sort key order."`
+	}) (framework.Node, error) {
+		node := &nodes.DynamoDBQuery{
+			DynamoDBSearch:         p.search(),
+			KeyConditionExpression: p.KeyConditionExpression,
+			Descending:             p.Descending,
 		}
-		if err := nodeDef.Decode(&temp); err != nil {
+		if err := nodes.ValidateDynamoDBQuery(node); err != nil {
 			return nil, err
 		}
-		return nodes.NewHTTPRequest(temp.URLKey, temp.MethodKey, temp.HeadersKey, temp.BodyKey), nil
+		return node, nil
 	})
 
-	framework.RegisterNodeFactory("openaiNode", func(nodeDef *yaml.Node) (framework.Node, error) {
-		var temp struct {
-			Type string `yaml:"type"`
-			SystemPrompt string `yaml:"systemPrompt"`
+	framework.RegisterNode(nodeType("dynamodbScan", "DynamoDB Scan", framework.CategoryData, "Reads all items of a DynamoDB table or index for every record.", framework.CredentialAWSProfile), func(p dynamoDBSearchParams) (framework.Node, error) {
+		node := &nodes.DynamoDBScan{DynamoDBSearch: p.search()}
+		if err := nodes.ValidateDynamoDBSearch(node.DynamoDBSearch); err != nil {
+			return nil, err
 		}
-		if err := nodeDef.Decode(&temp); err != nil {
-			return nil, err	
+		return node, nil
+	})
+
+	framework.RegisterNode(nodeType("sqlQuery", "SQL Query", framework.CategoryData, "Runs a query for every record and emits the rows.", framework.CredentialDatabase), func(p struct {
+		Database  string   `yaml:"database" required:"true" doc:"Name of the database."`
+		Query     string   `yaml:"query" required:"true" doc:"Query with ? placeholders."`
+		Params    []string `yaml:"params" doc:"Fields bound to the placeholders, in order."`
+		OutputKey string   `yaml:"outputKey" doc:"Field to store the rows in, instead of emitting them."`
+	}) (framework.Node, error) {
+		node := &nodes.SQLQuery{Database: p.Database, Query: p.Query, Params: p.Params, OutputKey: p.OutputKey}
+		if err := nodes.ValidateSQLQuery(node); err != nil {
+			return nil, err
 		}
-		return nodes.NewOpenAINode(temp.SystemPrompt), nil
+		return node, nil
 	})
 
-	framework.RegisterNodeFactory("waitNode", func(nodeDef *yaml.Node) (framework.Node, error) {
-		var temp struct {
-			Type string `yaml:"type"`
-			MaxSeconds int `yaml:"maxSeconds"`
+	framework.RegisterNode(nodeType("sqlExecute", "SQL Execute", framework.CategoryData, "Writes the records to a database in a transaction and passes them on.", framework.CredentialDatabase), func(p struct {
+		Database        string   `yaml:"database" required:"true" doc:"Name of the database."`
+		Mode            string   `yaml:"mode" enum:"exec,insert,upsert" default:"exec" doc:"How records are written."`
+		Statement       string   `yaml:"statement" doc:"Statement with ? placeholders, for exec mode."`
+		Params          []string `yaml:"params" doc:"Fields bound to the placeholders, in order."`
+		Table           string   `yaml:"table" doc:"Table to insert into."`
+		Columns         []string `yaml:"columns" doc:"Fields to insert, into the columns of the same names."`
+		ConflictColumns []string `yaml:"conflictColumns" doc:"Columns identifying the rows to update, for upsert mode."`
+		BatchSize       int      `yaml:"batchSize" default:"100" doc:"Records per insert statement."`
+		OutputKey       string   `yaml:"outputKey" doc:"Field to store the number of affected rows in, for exec mode."`
+	}) (framework.Node, error) {
+		node := &nodes.SQLExecute{
+			Database:        p.Database,
+			Mode:            p.Mode,
+			Statement:       p.Statement,
+			Params:          p.Params,
+			Table:           p.Table,
+			Columns:         p.Columns,
+			ConflictColumns: p.ConflictColumns,
+			BatchSize:       p.BatchSize,
+			OutputKey:       p.OutputKey,
 		}
-		if err := nodeDef.Decode(&temp); err != nil {
+		if err := nodes.ValidateSQLExecute(node); err != nil {
 			return nil, err
 		}
-		return nodes.NewWaitNode(temp.MaxSeconds), nil
+		return node, nil
 	})
 
-	framework.RegisterNodeFactory("dynamodbUpsert", func(nodeDef *yaml.Node) (framework.Node, error) {
-		var temp struct {
-			Type string `yaml:"type"`
-			TableNameKey string `yaml:"tableNameKey"`
-			AWSRegionKey string `yaml:"awsRegionKey"`
-			AWSAccessKeyIDKey string `yaml:"awsAccessKeyIDKey"`
-			AWSSecretAccessKeyKey string `yaml:"awsSecretAccessKeyKey"`
+	framework.RegisterNode(nodeType("codeNode", "Code", framework.CategoryTransform, "Transforms the records with JavaScript or a registered Go function."), func(p struct {
+		Code     string                 `yaml:"code" doc:"Body of a JavaScript function."`
+		Mode     string                 `yaml:"mode" enum:"all,each" default:"all" doc:"Run the code once for all records or for each record."`
+		Timeout  time.Duration          `yaml:"timeout" default:"10s" doc:"How long the code may run."`
+		Function string                 `yaml:"function" doc:"Registered Go function to run instead of code."`
+		Params   map[string]interface{} `yaml:"params" doc:"Parameters of the function."`
+	}) (framework.Node, error) {
+		node := &nodes.CodeNode{Code: p.Code, Mode: p.Mode, Timeout: p.Timeout}
+		if p.Function != "" {
+			fn, err := framework.BindFunction(p.Function, p.Params)
+			if err != nil {
+				return nil, err
+			}
+			node.Function = fn
+		} else if p.Params != nil {
+			return nil, fmt.Errorf("params are only used with function")
 		}
-		if err := nodeDef.Decode(&temp); err != nil {
+		if err := nodes.ValidateCodeNode(node); err != nil {
 			return nil, err
 		}
-		return nodes.NewDynamoDBUpsert(temp.TableNameKey, temp.AWSRegionKey, temp.AWSAccessKeyIDKey, temp.AWSSecretAccessKeyKey), nil
+		return node, nil
 	})
 
-	framework.RegisterNodeFactory("codeNode", func(nodeDef *yaml.Node) (framework.Node, error) {
-		var temp struct {
-			Type string `yaml:"type"`
-			// CodeNode's Fn field is a function, which cannot be directly decoded from YAML.
-			// This factory will need to handle how the function is provided or referenced.
-			// For now, we'll return a basic CodeNode without a functional Fn.
+	// Built-in functions that codeNode runs by name.
+	framework.RegisterFunction("slice", nodes.SliceFunction)
+	framework.RegisterFunction("top", nodes.TopFunction)
+}
+
+// nodeType describes a node type with a single input and output.
+func nodeType(typ, displayName, category, description string, credentials ...string) framework.NodeTypeInfo {
+	return framework.NodeTypeInfo{
+		Type:        typ,
+		DisplayName: displayName,
+		Category:    category,
+		Description: description,
+		Inputs:      []string{framework.MainPort},
+		Outputs:     []string{framework.MainPort},
+		Credentials: credentials,
+	}
+}
+
+// triggerType describes a trigger node type, which has no input.
+func triggerType(typ, displayName, description string) framework.NodeTypeInfo {
+	info := nodeType(typ, displayName, framework.CategoryTrigger, description)
+	info.Inputs = nil
+	return info
+}
+
+// openAIParams are the parameters of openaiNode and llm nodes, which
+// aiAgent nodes take as well.
+type openAIParams struct {
+	Provider         string                 `yaml:"provider" doc:"LLM provider; the OpenAI client if empty."`
+	Fallbacks        []nodes.LLMTarget      `yaml:"fallbacks" doc:"Providers and models tried in order if the provider fails."`
+	SystemPrompt     string                 `yaml:"systemPrompt" doc:"System prompt template."`
+	SystemPromptFile string                 `yaml:"systemPromptFile" doc:"File to read the system prompt from."`
+	UserPrompt       string                 `yaml:"userPrompt" default:"Payload: {{json record}}" doc:"User prompt template."`
+	UserPromptFile   string                 `yaml:"userPromptFile" doc:"File to read the user prompt from."`
+	Messages         []nodes.PromptMessage  `yaml:"messages" doc:"Messages sent before the user prompt."`
+	Examples         []nodes.PromptExample  `yaml:"examples" doc:"Few-shot examples of inputs and answers."`
+	Model            string                 `yaml:"model" doc:"Model; the provider's default if empty."`
+	Temperature      *float64               `yaml:"temperature" doc:"Sampling temperature."`
+	MaxTokens        int                    `yaml:"maxTokens" doc:"Maximum number of tokens of an answer."`
+	OutputSchema     map[string]interface{} `yaml:"outputSchema" doc:"JSON schema the answers must match."`
+	OutputKey        string                 `yaml:"outputKey" doc:"Field to store the answer in, instead of merging it into the record."`
+	MaxRepairs       *int                   `yaml:"maxRepairs" doc:"Times an invalid answer is sent back to the model; 2 with outputSchema, 0 without."`
+	NoCache          bool                   `yaml:"noCache" doc:"Do not cache the answers."`
+	CacheTTL         time.Duration          `yaml:"cacheTTL" doc:"How long answers are cached."`
+}
+
+// newOpenAINode builds the OpenAINode of an openaiNode or llm definition.
+func newOpenAINode(p openAIParams) (*nodes.OpenAINode, error) {
+	// Prompt files are read by BuildWorkflow, see OpenAINode.ReadFiles.
+	for _, prompt := range []string{p.SystemPrompt, p.UserPrompt} {
+		if err := nodes.ValidatePromptTemplate(prompt); err != nil {
+			return nil, err
 		}
-		if err := nodeDef.Decode(&temp); err != nil {
+	}
+	for _, message := range p.Messages {
+		if err := nodes.ValidatePromptMessage(message); err != nil {
 			return nil, err
 		}
-		// This will create a CodeNode with a nil Fn, which will likely cause a panic if executed.
-		// A proper solution would involve a way to define and load Go functions dynamically or via a registry.
-		return nodes.NewCodeNode(nil), nil
-	})
-}
\ No newline at end of file
+	}
+	for _, target := range append([]nodes.LLMTarget{{Provider: p.Provider}}, p.Fallbacks...) {
+		if target.Provider != "" && !llm.Registered(target.Provider) {
+			return nil, fmt.Errorf("unknown LLM provider %q, use one of %s", target.Provider, strings.Join(llm.Providers(), ", "))
+		}
+	}
+	node := nodes.NewOpenAINode(p.SystemPrompt)
+	node.UserPrompt = p.UserPrompt
+	node.SystemPromptFile = p.SystemPromptFile
+	node.UserPromptFile = p.UserPromptFile
+	node.Messages = p.Messages
+	node.Examples = p.Examples
+	node.Provider = p.Provider
+	node.Fallbacks = p.Fallbacks
+	node.Model = p.Model
+	node.Temperature = p.Temperature
+	node.MaxTokens = p.MaxTokens
+	node.OutputSchema = p.OutputSchema
+	node.OutputKey = p.OutputKey
+	if p.OutputSchema != nil {
+		if err := nodes.ValidateJSONSchema(p.OutputSchema); err != nil {
+			return nil, err
+		}
+		node.MaxRepairs = nodes.DefaultMaxRepairs
+	}
+	if p.MaxRepairs != nil {
+		node.MaxRepairs = *p.MaxRepairs
+	}
+	if p.CacheTTL < 0 {
+		return nil, fmt.Errorf("cacheTTL must not be negative")
+	}
+	node.NoCache = p.NoCache
+	node.CacheTTL = p.CacheTTL
+	return node, nil
+}
+
+// dynamoDBCredentialParams select the AWS credentials of the DynamoDB
+// nodes.
+type dynamoDBCredentialParams struct {
+	AWSProfile            string `yaml:"awsProfile" doc:"Name of the AWS profile."`
+	AWSRegionKey          string `yaml:"awsRegionKey" doc:"Field holding the AWS region, without awsProfile."`
+	AWSAccessKeyIDKey     string `yaml:"awsAccessKeyIDKey" doc:"Field holding the AWS access key ID, without awsProfile."`
+	AWSSecretAccessKeyKey string `yaml:"awsSecretAccessKeyKey" doc:"Field holding the AWS secret access key, without awsProfile."`
+}
+
+// dynamoDBTableParams are the parameters the DynamoDB read nodes share.
+type dynamoDBTableParams struct {
+	dynamoDBCredentialParams `yaml:",inline"`
+	Table                    string `yaml:"table" doc:"Table name template."`
+	TableNameKey             string `yaml:"tableNameKey" doc:"Field holding the table name, without table."`
+}
+
+func (p dynamoDBTableParams) table() nodes.DynamoDBTable {
+	return nodes.DynamoDBTable{
+		TableName:    p.Table,
+		TableNameKey: p.TableNameKey,
+		DynamoDBCredentials: nodes.DynamoDBCredentials{
+			AWSProfile:            p.AWSProfile,
+			AWSRegionKey:          p.AWSRegionKey,
+			AWSAccessKeyIDKey:     p.AWSAccessKeyIDKey,
+			AWSSecretAccessKeyKey: p.AWSSecretAccessKeyKey,
+		},
+	}
+}
+
+// dynamoDBSearchParams are the parameters dynamodbQuery and dynamodbScan
+// share.
+type dynamoDBSearchParams struct {
+	dynamoDBTableParams  `yaml:",inline"`
+	IndexName            string                 `yaml:"indexName" doc:"Index to read instead of the table."`
+	FilterExpression     string                 `yaml:"filterExpression" doc:"Condition the items read must meet."`
+	ProjectionExpression string                 `yaml:"projectionExpression" doc:"Attributes to read."`
+	ExpressionNames      map[string]string      `yaml:"expressionNames" doc:"Attribute name placeholders."`
+	ExpressionValues     map[string]interface{} `yaml:"expressionValues" doc:"Value placeholders; strings are templates."`
+	ConsistentRead       bool                   `yaml:"consistentRead" doc:"Read with strong consistency."`
+	Limit                int                    `yaml:"limit" doc:"Maximum number of items read per record."`
+	OutputKey            string                 `yaml:"outputKey" doc:"Field to store the items in, instead of emitting them."`
+}
+
+func (p dynamoDBSearchParams) search() nodes.DynamoDBSearch {
+	return nodes.DynamoDBSearch{
+		DynamoDBTable:        p.table(),
+		IndexName:            p.IndexName,
+		FilterExpression:     p.FilterExpression,
+		ProjectionExpression: p.ProjectionExpression,
+		ExpressionNames:      p.ExpressionNames,
+		ExpressionValues:     p.ExpressionValues,
+		ConsistentRead:       p.ConsistentRead,
+		Limit:                p.Limit,
+		OutputKey:            p.OutputKey,
+	}
+}
@@ -0,0 +1,72 @@
+package noderegistry
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go-workflow/pkg/framework"
+	"go-workflow/pkg/nodes"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadPlugins starts every executable in dir as a plugin and registers the
+// node types it implements in registry, where they must not be registered
+// yet. The caller
+// closes the plugins when it is done. Files starting with a dot are
+// skipped, and an empty dir loads nothing.
+func LoadPlugins(dir string, registry *framework.Registry) ([]*framework.Plugin, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var plugins []*framework.Plugin
+	fail := func(err error) ([]*framework.Plugin, error) {
+		for _, plugin := range plugins {
+			plugin.Close()
+		}
+		return nil, err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return fail(err)
+		}
+		if strings.HasPrefix(entry.Name(), ".") || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+		plugin, err := framework.StartPlugin(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return fail(err)
+		}
+		plugins = append(plugins, plugin)
+		for _, nodeType := range plugin.Nodes {
+			if err := registry.Register(nodeType.Info(), pluginNodeFactory(plugin, nodeType.Type)); err != nil {
+				return fail(fmt.Errorf("plugin %s: %w", plugin.Path, err))
+			}
+		}
+	}
+	return plugins, nil
+}
+
+// pluginNodeFactory builds the nodes of a plugin node type. All parameters
+// of a node but its type are passed to the plugin.
+func pluginNodeFactory(plugin *framework.Plugin, nodeType string) framework.NodeFactory {
+	return func(nodeDef *yaml.Node) (framework.Node, error) {
+		var config map[string]interface{}
+		if err := nodeDef.Decode(&config); err != nil {
+			return nil, err
+		}
+		delete(config, "type")
+		node := &nodes.PluginNode{Plugin: plugin, Type: nodeType, Config: config}
+		if err := nodes.ValidatePluginNode(node); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+}
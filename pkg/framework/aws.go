@@ -0,0 +1,304 @@
+package framework
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"gopkg.in/yaml.v3"
+)
+
+// AWSProfile configures a named AWS connection that nodes select with
+// their awsProfile parameter, instead of reading credentials from records.
+//
+// With AccessKeyID and SecretAccessKey the profile uses these static keys;
+// otherwise the default credential chain: the AWS_* environment variables,
+// the shared config files (with SharedProfile) and instance or task roles.
+// With RoleARN the profile assumes that role with those credentials.
+// Endpoint replaces the service endpoint, e.g. http://localhost:8000 for
+// DynamoDB Local.
+//
+// Values may reference environment variables as ${NAME}. In workflow
+// definitions the keys must do so, so secrets are not stored with them.
+type AWSProfile struct {
+	Region          string `yaml:"region,omitempty" json:"region,omitempty"`
+	AccessKeyID     string `yaml:"accessKeyID,omitempty" json:"accessKeyID,omitempty"`
+	SecretAccessKey string `yaml:"secretAccessKey,omitempty" json:"secretAccessKey,omitempty"`
+	SessionToken    string `yaml:"sessionToken,omitempty" json:"sessionToken,omitempty"`
+	SharedProfile   string `yaml:"sharedProfile,omitempty" json:"sharedProfile,omitempty"`
+	RoleARN         string `yaml:"roleARN,omitempty" json:"roleARN,omitempty"`
+	ExternalID      string `yaml:"externalID,omitempty" json:"externalID,omitempty"`
+	RoleSessionName string `yaml:"roleSessionName,omitempty" json:"roleSessionName,omitempty"`
+	Endpoint        string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+}
+
+// Validate checks that static keys are given together. With
+// requireReferences, as in workflow definitions, the keys must reference
+// environment variables.
+func (p AWSProfile) Validate(requireReferences bool) error {
+	if (p.AccessKeyID == "") != (p.SecretAccessKey == "") {
+		return fmt.Errorf("accessKeyID and secretAccessKey must be set together")
+	}
+	if p.SessionToken != "" && p.AccessKeyID == "" {
+		return fmt.Errorf("sessionToken requires accessKeyID and secretAccessKey")
+	}
+	if p.SharedProfile != "" && p.AccessKeyID != "" {
+		return fmt.Errorf("use either static keys or sharedProfile")
+	}
+	if requireReferences {
+		for name, value := range map[string]string{"accessKeyID": p.AccessKeyID, "secretAccessKey": p.SecretAccessKey, "sessionToken": p.SessionToken} {
+			if value != "" && !isEnvReference(value) {
+				return fmt.Errorf("%s must reference an environment variable, e.g. ${AWS_SECRET_ACCESS_KEY}", name)
+			}
+		}
+	}
+	return nil
+}
+
+// isEnvReference reports whether value is a single ${NAME} reference.
+func isEnvReference(value string) bool {
+	return strings.HasPrefix(value, "${") && strings.HasSuffix(value, "}") && strings.Count(value, "$") == 1
+}
+
+// ValidateAWSProfiles validates named profiles, see AWSProfile.Validate.
+func ValidateAWSProfiles(profiles map[string]AWSProfile, requireReferences bool) error {
+	for name, profile := range profiles {
+		if name == "" {
+			return fmt.Errorf("AWS profile without name")
+		}
+		if err := profile.Validate(requireReferences); err != nil {
+			return fmt.Errorf("AWS profile %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Expand replaces the ${NAME} references of the profile with the values
+// of env.
+func (p AWSProfile) Expand(env map[string]string) AWSProfile {
+	expand := func(value string) string {
+		return os.Expand(value, func(name string) string { return env[name] })
+	}
+	return AWSProfile{
+		Region:          expand(p.Region),
+		AccessKeyID:     expand(p.AccessKeyID),
+		SecretAccessKey: expand(p.SecretAccessKey),
+		SessionToken:    expand(p.SessionToken),
+		SharedProfile:   expand(p.SharedProfile),
+		RoleARN:         expand(p.RoleARN),
+		ExternalID:      expand(p.ExternalID),
+		RoleSessionName: expand(p.RoleSessionName),
+		Endpoint:        expand(p.Endpoint),
+	}
+}
+
+// Config loads the AWS configuration of an expanded profile.
+func (p AWSProfile) Config(ctx context.Context) (aws.Config, error) {
+	var opts []func(*config.LoadOptions) error
+	if p.Region != "" {
+		opts = append(opts, config.WithRegion(p.Region))
+	}
+	if p.AccessKeyID != "" {
+		opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(p.AccessKeyID, p.SecretAccessKey, p.SessionToken)))
+	}
+	if p.SharedProfile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(p.SharedProfile))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	if p.RoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), p.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			if p.ExternalID != "" {
+				o.ExternalID = aws.String(p.ExternalID)
+			}
+			if p.RoleSessionName != "" {
+				o.RoleSessionName = p.RoleSessionName
+			}
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+	if p.Endpoint != "" {
+		cfg.BaseEndpoint = aws.String(p.Endpoint)
+	}
+	return cfg, nil
+}
+
+// NewDynamoDBClient creates the DynamoDB client of an expanded profile.
+func NewDynamoDBClient(ctx context.Context, p AWSProfile) (DynamoDBAPI, error) {
+	cfg, err := p.Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return dynamodb.NewFromConfig(cfg), nil
+}
+
+// LoadAWSProfiles reads named profiles from a YAML file mapping names to
+// profiles. Such a file is the credential store of a deployment: unlike
+// workflow definitions, it may hold keys.
+func LoadAWSProfiles(path string) (map[string]AWSProfile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var profiles map[string]AWSProfile
+	if err := yaml.Unmarshal(data, &profiles); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := ValidateAWSProfiles(profiles, false); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return profiles, nil
+}
+
+// AWSClients creates the AWS clients of named profiles. It creates one
+// client per profile and reuses it for all nodes and runs.
+type AWSClients struct {
+	profiles map[string]AWSProfile
+	env      map[string]string
+	cache    *awsClientCache
+	// allowedEnv, if set by RestrictProfiles, names the environment
+	// variables that profiles added by WithProfiles may reference.
+	allowedEnv map[string]bool
+}
+
+// awsClientCache holds the clients of profiles. It is shared by the
+// AWSClients that WithProfiles derives.
+type awsClientCache struct {
+	newDynamoDB func(ctx context.Context, p AWSProfile) (DynamoDBAPI, error)
+	mu          sync.Mutex
+	dynamoDB    map[awsClientKey]awsClient
+}
+
+// awsClientKey identifies the cached client of a profile by its name,
+// region and endpoint, so the credentials are not kept as keys.
+type awsClientKey struct {
+	name, region, endpoint string
+}
+
+// awsClient is a cached client with the fingerprint of the expanded
+// profile it was created for. Profiles of different workflows can have
+// the same name, and credentials change, so a client is only reused for
+// the same fingerprint and replaced otherwise.
+type awsClient struct {
+	fingerprint [sha256.Size]byte
+	dynamoDB    DynamoDBAPI
+}
+
+// fingerprint returns a hash of an expanded profile.
+func (p AWSProfile) fingerprint() [sha256.Size]byte {
+	data, _ := json.Marshal(p)
+	return sha256.Sum256(data)
+}
+
+// NewAWSClients creates the clients of profiles, whose references are
+// expanded with env. newDynamoDB creates DynamoDB clients;
+// NewDynamoDBClient if nil.
+func NewAWSClients(profiles map[string]AWSProfile, env map[string]string, newDynamoDB func(ctx context.Context, p AWSProfile) (DynamoDBAPI, error)) *AWSClients {
+	if newDynamoDB == nil {
+		newDynamoDB = NewDynamoDBClient
+	}
+	return &AWSClients{
+		profiles: profiles,
+		env:      env,
+		cache:    &awsClientCache{newDynamoDB: newDynamoDB, dynamoDB: map[awsClientKey]awsClient{}},
+	}
+}
+
+// RestrictProfiles returns AWSClients for untrusted workflows: the
+// profiles that WithProfiles adds must use static keys, not the credential
+// chain of the server, must not set an endpoint, and may only reference
+// the environment variables in allowedEnv. The clients are still shared.
+func (c *AWSClients) RestrictProfiles(allowedEnv []string) *AWSClients {
+	restricted := *c
+	restricted.allowedEnv = map[string]bool{}
+	for _, name := range allowedEnv {
+		restricted.allowedEnv[name] = true
+	}
+	return &restricted
+}
+
+// WithProfiles returns AWSClients that also know profiles, such as those
+// of a workflow definition. It fails if one of them has the name of a
+// known profile, or breaks the restrictions of RestrictProfiles. The
+// clients are still shared.
+func (c *AWSClients) WithProfiles(profiles map[string]AWSProfile) (*AWSClients, error) {
+	if len(profiles) == 0 {
+		return c, nil
+	}
+	merged := make(map[string]AWSProfile, len(c.profiles)+len(profiles))
+	for name, profile := range c.profiles {
+		merged[name] = profile
+	}
+	for name, profile := range profiles {
+		if _, ok := c.profiles[name]; ok {
+			return nil, fmt.Errorf("AWS profile %s is already defined", name)
+		}
+		if err := c.checkRestricted(profile); err != nil {
+			return nil, fmt.Errorf("AWS profile %s: %w", name, err)
+		}
+		merged[name] = profile
+	}
+	return &AWSClients{profiles: merged, env: c.env, cache: c.cache, allowedEnv: c.allowedEnv}, nil
+}
+
+// checkRestricted checks a profile against the restrictions of
+// RestrictProfiles, if any.
+func (c *AWSClients) checkRestricted(p AWSProfile) error {
+	if c.allowedEnv == nil {
+		return nil
+	}
+	if p.AccessKeyID == "" || p.SharedProfile != "" {
+		return fmt.Errorf("must use static keys on this server")
+	}
+	if p.Endpoint != "" {
+		return fmt.Errorf("cannot set an endpoint on this server")
+	}
+	for _, value := range []string{p.Region, p.AccessKeyID, p.SecretAccessKey, p.SessionToken, p.RoleARN, p.ExternalID, p.RoleSessionName, p.Endpoint} {
+		var denied string
+		os.Expand(value, func(name string) string {
+			if !c.allowedEnv[name] && denied == "" {
+				denied = name
+			}
+			return ""
+		})
+		if denied != "" {
+			return fmt.Errorf("environment variable %s may not be referenced on this server", denied)
+		}
+	}
+	return nil
+}
+
+// DynamoDB returns the DynamoDB client of a named profile.
+func (c *AWSClients) DynamoDB(ctx context.Context, name string) (DynamoDBAPI, error) {
+	profile, ok := c.profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown AWS profile %q", name)
+	}
+	profile = profile.Expand(c.env)
+	key := awsClientKey{name: name, region: profile.Region, endpoint: profile.Endpoint}
+	fingerprint := profile.fingerprint()
+
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+	if cached, ok := c.cache.dynamoDB[key]; ok && cached.fingerprint == fingerprint {
+		return cached.dynamoDB, nil
+	}
+	client, err := c.cache.newDynamoDB(ctx, profile)
+	if err != nil {
+		return nil, fmt.Errorf("AWS profile %s: %w", name, err)
+	}
+	c.cache.dynamoDB[key] = awsClient{fingerprint: fingerprint, dynamoDB: client}
+	return client, nil
+}
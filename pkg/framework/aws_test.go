@@ -0,0 +1,164 @@
+package framework
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// fakeDynamoDB is a DynamoDBAPI that only tells clients apart.
+type fakeDynamoDB struct {
+	DynamoDBAPI
+	profile AWSProfile
+}
+
+func TestAWSClients(t *testing.T) {
+	var created []AWSProfile
+	newClient := func(ctx context.Context, p AWSProfile) (DynamoDBAPI, error) {
+		created = append(created, p)
+		return &fakeDynamoDB{profile: p}, nil
+	}
+	env := map[string]string{"KEY_ID": "id", "SECRET": "secret"}
+	clients := NewAWSClients(map[string]AWSProfile{
+		"contacts": {Region: "eu-west-1", AccessKeyID: "${KEY_ID}", SecretAccessKey: "${SECRET}"},
+	}, env, newClient)
+
+	first, err := clients.DynamoDB(context.Background(), "contacts")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := first.(*fakeDynamoDB).profile; got.AccessKeyID != "id" || got.SecretAccessKey != "secret" {
+		t.Errorf("expected the expanded profile, got %+v", got)
+	}
+
+	// A workflow adds a profile; the client of the shared one is reused.
+	run, err := clients.WithProfiles(map[string]AWSProfile{"local": {Endpoint: "http://localhost:8000"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	again, err := run.DynamoDB(context.Background(), "contacts")
+	if err != nil || again != first {
+		t.Errorf("expected the cached client, got %v, %v", again, err)
+	}
+	if _, err := run.DynamoDB(context.Background(), "local"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(created) != 2 {
+		t.Errorf("expected one client per profile, got %d", len(created))
+	}
+	if _, err := clients.DynamoDB(context.Background(), "local"); err == nil {
+		t.Error("expected the workflow profile to stay out of the shared clients")
+	}
+
+	// Another workflow's profile of the same name gets its own client.
+	other, err := clients.WithProfiles(map[string]AWSProfile{"local": {Endpoint: "http://localhost:8000", AccessKeyID: "${KEY_ID}", SecretAccessKey: "${SECRET}"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	local, err := other.DynamoDB(context.Background(), "local")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := local.(*fakeDynamoDB).profile; got.AccessKeyID != "id" {
+		t.Errorf("expected the client of the other workflow's profile, got %+v", got)
+	}
+
+	// Rotated credentials replace the client instead of adding one.
+	env["SECRET"] = "rotated"
+	rotated, err := clients.DynamoDB(context.Background(), "contacts")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rotated == first || rotated.(*fakeDynamoDB).profile.SecretAccessKey != "rotated" {
+		t.Errorf("expected a client with the rotated credentials, got %+v", rotated)
+	}
+	if len(clients.cache.dynamoDB) != 2 {
+		t.Errorf("expected one cached client per profile name, got %d", len(clients.cache.dynamoDB))
+	}
+}
+
+func TestAWSClients_WithProfiles_Restricted(t *testing.T) {
+	env := map[string]string{"KEY_ID": "id", "SECRET": "secret", "SERVER_SECRET": "server"}
+	clients := NewAWSClients(map[string]AWSProfile{
+		"contacts": {Region: "eu-west-1"},
+	}, env, nil)
+
+	if _, err := clients.WithProfiles(map[string]AWSProfile{"contacts": {Region: "us-east-1"}}); err == nil {
+		t.Error("expected a profile replacing a deployment profile to be rejected")
+	}
+
+	restricted := clients.RestrictProfiles([]string{"KEY_ID", "SECRET"})
+	if _, err := restricted.WithProfiles(map[string]AWSProfile{
+		"own": {Region: "eu-west-1", AccessKeyID: "${KEY_ID}", SecretAccessKey: "$SECRET"},
+	}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for name, profile := range map[string]AWSProfile{
+		"default chain":  {Region: "eu-west-1"},
+		"shared profile": {Region: "eu-west-1", AccessKeyID: "id", SharedProfile: "prod"},
+		"endpoint":       {Region: "eu-west-1", AccessKeyID: "id", Endpoint: "http://169.254.169.254"},
+		"server env":     {Region: "eu-west-1", AccessKeyID: "id", SecretAccessKey: "${SERVER_SECRET}"},
+	} {
+		if _, err := restricted.WithProfiles(map[string]AWSProfile{"own": profile}); err == nil {
+			t.Errorf("%s: expected the profile to be rejected", name)
+		}
+	}
+}
+
+func TestAWSProfile_Config(t *testing.T) {
+	p := AWSProfile{Region: "eu-west-1", AccessKeyID: "id", SecretAccessKey: "secret", Endpoint: "http://localhost:8000"}
+	cfg, err := p.Config(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Region != "eu-west-1" || cfg.BaseEndpoint == nil || *cfg.BaseEndpoint != "http://localhost:8000" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	creds, err := cfg.Credentials.Retrieve(context.Background())
+	if err != nil || creds.AccessKeyID != "id" || creds.SecretAccessKey != "secret" {
+		t.Errorf("expected the static keys, got %+v, %v", creds, err)
+	}
+
+	client, err := NewDynamoDBClient(context.Background(), p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := client.(*dynamodb.Client); !ok {
+		t.Errorf("expected a DynamoDB client, got %T", client)
+	}
+}
+
+func TestAWSProfile_Validate(t *testing.T) {
+	tests := map[string]AWSProfile{
+		"key without secret":       {AccessKeyID: "${ID}"},
+		"token without keys":       {SessionToken: "${TOKEN}"},
+		"keys and shared profile":  {AccessKeyID: "${ID}", SecretAccessKey: "${SECRET}", SharedProfile: "prod"},
+		"secret without reference": {AccessKeyID: "${ID}", SecretAccessKey: "secret"},
+	}
+	for name, p := range tests {
+		if err := p.Validate(true); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if err := (AWSProfile{AccessKeyID: "id", SecretAccessKey: "secret"}).Validate(false); err != nil {
+		t.Errorf("expected keys to be allowed in the credential store, got %v", err)
+	}
+}
+
+func TestLoadAWSProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "aws.yaml")
+	data := "prod:\n  region: us-east-1\n  accessKeyID: id\n  secretAccessKey: secret\nlocal:\n  endpoint: http://localhost:8000\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := LoadAWSProfiles(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if profiles["prod"].SecretAccessKey != "secret" || profiles["local"].Endpoint != "http://localhost:8000" {
+		t.Errorf("unexpected profiles %+v", profiles)
+	}
+}
@@ -2,8 +2,10 @@ package framework
 
 import (
     "context"
+    "time"
     retryablehttp "github.com/hashicorp/go-retryablehttp"
     "github.com/tmc/langchaingo/llms"
+    "github.com/tmc/langchaingo/schema"
     "github.com/aws/aws-sdk-go-v2/service/dynamodb"
     "go.uber.org/zap"
 )
@@ -14,25 +16,93 @@ type LangChainClient interface {
     GenerateFromSinglePrompt(ctx context.Context, prompt string, options ...llms.CallOption) (string, error)
 }
 
-// DynamoDBPutItemAPI defines the interface for the PutItem function.
+// LangChainChatClient is a LangChainClient that can also send a chat of
+// messages with roles. Nodes fall back to a single prompt for clients that
+// do not implement it.
+type LangChainChatClient interface {
+    LangChainClient
+    GenerateFromMessages(ctx context.Context, messages []schema.ChatMessage, options ...llms.CallOption) (string, error)
+}
+
+// LLMClientFactory returns the client of a named LLM provider, such as
+// "openai" or "anthropic". See package go-workflow/pkg/llm.
+type LLMClientFactory func(ctx context.Context, provider string) (LangChainChatClient, error)
+
+// LLMCache stores LLM responses by request key, so identical requests are
+// not paid for twice. See package go-workflow/pkg/llm for implementations.
+type LLMCache interface {
+    // Get returns the cached response for key, if any.
+    Get(ctx context.Context, key string) (string, bool, error)
+    // Set caches response for ttl; responses with a zero ttl do not expire.
+    Set(ctx context.Context, key, response string, ttl time.Duration) error
+}
+
+// DynamoDBAPI defines the DynamoDB operations of the DynamoDB nodes: the
+// writes PutItem, UpdateItem and BatchWriteItem, and the reads GetItem,
+// Query and Scan. *dynamodb.Client implements it.
 // We use this interface to test the code without needing a real DynamoDB instance.
-type DynamoDBPutItemAPI interface {
+type DynamoDBAPI interface {
 	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
 }
 
+// DynamoDBPutItemAPI is the former name of DynamoDBAPI.
+//
+// Deprecated: use DynamoDBAPI.
+type DynamoDBPutItemAPI = DynamoDBAPI
+
 // DynamoDBClientFactory defines a function type for creating DynamoDB clients dynamically.
-type DynamoDBClientFactory func(ctx context.Context, region, accessKeyID, secretAccessKey string) (DynamoDBPutItemAPI, error)
+type DynamoDBClientFactory func(ctx context.Context, region, accessKeyID, secretAccessKey string) (DynamoDBAPI, error)
 
 // Context holds shared clients and config
 type Context struct {
     Ctx            context.Context
     HTTPClient     *retryablehttp.Client
     LangChain      LangChainClient
-    DynamoDBClient DynamoDBPutItemAPI
+    // LLMClientFactory provides the clients of nodes that select an LLM
+    // provider by name.
+    LLMClientFactory LLMClientFactory
+    // LLMCache, if set, caches the responses of LLM nodes. With
+    // LLMCacheBypass the cache is not read, but still refreshed.
+    LLMCache       LLMCache
+    LLMCacheBypass bool
+    // VectorStore holds the indexes of vectorStore nodes.
+    VectorStore    VectorStore
+    DynamoDBClient DynamoDBAPI
     DynamoDBClientFactory DynamoDBClientFactory
+    // AWS provides the clients of the named AWS profiles that nodes
+    // select, one per profile.
+    AWS            *AWSClients
+    // SQL provides the connection pools of the named databases that SQL
+    // nodes select.
+    SQL            *SQLDatabases
+    // NodeTypes builds the workflows that nodes run, such as the workflow
+    // tools of agents; DefaultRegistry if nil.
+    NodeTypes      *Registry
     Logger         *zap.SugaredLogger
     Metrics        *Metrics
     Env            map[string]string
+    // Workflow names the running workflow, and Node the node being
+    // executed, which the engine sets. They label the LLM metrics.
+    Workflow       string
+    Node           string
+    // RunUsage and WorkflowUsage, if set, add up the LLM usage of the run
+    // and of all runs of the workflow, and enforce their budgets.
+    RunUsage       *UsageMeter
+    WorkflowUsage  *UsageMeter
+    // NodeDone, if set, is called after every node execution with the
+    // node's name and the error it returned, e.g. to record node statuses.
+    NodeDone       func(name string, err error)
+    // Trace, if set, receives the steps of nodes that work in several
+    // steps, e.g. to record them in the run history. See AddTrace.
+    Trace          func(step TraceStep)
+    // LoadWorkflow, if set, loads another workflow by name, for nodes
+    // that run workflows, such as an aiAgent using them as tools.
+    LoadWorkflow   func(ctx context.Context, name string) (*WorkflowDef, error)
 }
 
 // Node represents a workflow step
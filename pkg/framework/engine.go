@@ -1,33 +1,82 @@
 package framework
 
 import (
+    "errors"
     "time"
 )
 
+// PortNode is a Node with several numbered outputs, such as an IF or a
+// Switch. ExecutePorts returns the records emitted on each output; records on
+// output i are passed to the nodes listed in Workflow.Ports[name][i].
+type PortNode interface {
+    Node
+    ExecutePorts(ctx *Context, input []map[string]interface{}) ([][]map[string]interface{}, error)
+}
+
 // Workflow manages nodes and their connections
 type Workflow struct {
     Nodes           map[string]Node
     Connections     map[string][]string
+    Ports           map[string]map[int][]string // Map from node name to output index to child node names
     ErrorConnections map[string]string // Map from node name to error handler node name
 }
 
+// targets returns the children of output port of a node. Connections are
+// the children of output 0.
+func (w *Workflow) targets(name string, port int) []string {
+    var targets []string
+    if port == 0 {
+        targets = append(targets, w.Connections[name]...)
+    }
+    return append(targets, w.Ports[name][port]...)
+}
+
 // Run executes starting at startNode, supporting branching and loops
 func (w *Workflow) Run(ctx *Context, startNode string, initialInput []map[string]interface{}) error {
+    _, err := w.RunOutputs(ctx, startNode, initialInput)
+    return err
+}
+
+// RunOutputs runs the workflow like Run and returns its outputs: the records
+// emitted by the nodes that have no children.
+func (w *Workflow) RunOutputs(ctx *Context, startNode string, initialInput []map[string]interface{}) ([]map[string]interface{}, error) {
+    var results []map[string]interface{}
     data := map[string][]map[string]interface{}{}
     data[startNode] = initialInput
     var exec func(name string) error
     exec = func(name string) error {
         node := w.Nodes[name]
+        // The node consumes its inputs, so if it is reached again, it only
+        // gets what arrived since.
         inputs := data[name]
+        delete(data, name)
+        ctx.Node = name
         start := time.Now()
-        outputs, err := node.Execute(ctx, inputs)
+        // Nodes with output ports only route by port when the workflow
+        // connects them by port; otherwise Execute is their single output.
+        var ports [][]map[string]interface{}
+        var err error
+        portNode, usesPorts := node.(PortNode)
+        usesPorts = usesPorts && len(w.Ports[name]) > 0
+        if usesPorts {
+            ports, err = portNode.ExecutePorts(ctx, inputs)
+        } else {
+            var outputs []map[string]interface{}
+            outputs, err = node.Execute(ctx, inputs)
+            ports = [][]map[string]interface{}{outputs}
+        }
         elapsed := time.Since(start).Seconds()
         ctx.Metrics.NodeDuration.WithLabelValues(name).Observe(elapsed)
+        if ctx.NodeDone != nil {
+            ctx.NodeDone(name, err)
+        }
         if err != nil {
             ctx.Metrics.NodeErrors.WithLabelValues(name).Inc()
             ctx.Logger.Errorf("node %s error: %v", name, err)
 
-            if errorNodeName, ok := w.ErrorConnections[name]; ok {
+            // A run over its LLM budget stops, even if the node has an
+            // error handler.
+            if errorNodeName, ok := w.ErrorConnections[name]; ok && !errors.Is(err, ErrBudgetExceeded) {
                 // Route error to the specified error handling node
                 errorRecord := map[string]interface{}{
                     "original_input": inputs,
@@ -42,15 +91,37 @@ func (w *Workflow) Run(ctx *Context, startNode string, initialInput []map[string
                 return err
             }
         }
-        for _, child := range w.Connections[name] {
-            data[child] = append(data[child], outputs...)
+        if len(w.Connections[name]) == 0 && len(w.Ports[name]) == 0 {
+            for _, outputs := range ports {
+                results = append(results, outputs...)
+            }
+            return nil
+        }
+        // Branches that emitted nothing are not run. A child connected to
+        // several ports runs once, with the outputs of all of them.
+        var children []string
+        scheduled := map[string]bool{}
+        for port, outputs := range ports {
+            if usesPorts && len(outputs) == 0 {
+                continue
+            }
+            for _, child := range w.targets(name, port) {
+                data[child] = append(data[child], outputs...)
+                if !scheduled[child] {
+                    scheduled[child] = true
+                    children = append(children, child)
+                }
+            }
         }
-        for _, child := range w.Connections[name] {
+        for _, child := range children {
             if err := exec(child); err != nil {
                 return err
             }
         }
         return nil
     }
-    return exec(startNode)
-}
\ No newline at end of file
+    if err := exec(startNode); err != nil {
+        return nil, err
+    }
+    return results, nil
+}
@@ -157,4 +157,260 @@ func TestWorkflow_Run_DataPassing(t *testing.T) {
 	if !node2.executed {
 		t.Error("node2 was not executed")
 	}
-}
\ No newline at end of file
+}
+
+// mockPortNode sends even "n" values to output 0 and odd ones to output 1.
+type mockPortNode struct {
+	mockNode
+}
+
+func (n *mockPortNode) ExecutePorts(ctx *Context, inputs []map[string]interface{}) ([][]map[string]interface{}, error) {
+	ports := make([][]map[string]interface{}, 2)
+	for _, input := range inputs {
+		port := input["n"].(int) % 2
+		ports[port] = append(ports[port], input)
+	}
+	return ports, nil
+}
+
+func TestWorkflow_Run_Ports(t *testing.T) {
+	ctx := &Context{
+		Ctx:     context.Background(),
+		Logger:  zap.NewNop().Sugar(),
+		Metrics: NewMetrics(prometheus.NewRegistry()),
+	}
+
+	var even, odd []map[string]interface{}
+	record := func(dst *[]map[string]interface{}) *mockNode {
+		return &mockNode{execute: func(ctx *Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+			*dst = inputs
+			return inputs, nil
+		}}
+	}
+	evenNode, oddNode := record(&even), record(&odd)
+	unused := &mockNode{}
+
+	workflow := &Workflow{
+		Nodes: map[string]Node{
+			"split":  &mockPortNode{},
+			"even":   evenNode,
+			"odd":    oddNode,
+			"unused": unused,
+		},
+		Ports: map[string]map[int][]string{
+			"split": {0: {"even"}, 1: {"odd"}},
+		},
+	}
+
+	input := []map[string]interface{}{{"n": 1}, {"n": 2}, {"n": 3}}
+	if err := workflow.Run(ctx, "split", input); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(even) != 1 || even[0]["n"] != 2 {
+		t.Errorf("expected output 0 to receive n=2, got %v", even)
+	}
+	if len(odd) != 2 {
+		t.Errorf("expected output 1 to receive 2 records, got %v", odd)
+	}
+
+	// A branch that emits nothing is not run.
+	even, odd = nil, nil
+	oddNode.executed = false
+	if err := workflow.Run(ctx, "split", []map[string]interface{}{{"n": 2}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if oddNode.executed {
+		t.Error("expected the empty odd branch not to run")
+	}
+
+	// Without ports the node is an ordinary node with a single output.
+	evenNode.executed = false
+	workflow.Ports = nil
+	workflow.Connections = map[string][]string{"split": {"even"}}
+	if err := workflow.Run(ctx, "split", input); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !evenNode.executed || len(even) != 3 {
+		t.Errorf("expected all records on the single output, got %v", even)
+	}
+}
+
+func TestWorkflow_Run_SharedChild(t *testing.T) {
+	ctx := &Context{
+		Ctx:     context.Background(),
+		Logger:  zap.NewNop().Sugar(),
+		Metrics: NewMetrics(prometheus.NewRegistry()),
+	}
+	var runs [][]map[string]interface{}
+	merge := &mockNode{execute: func(ctx *Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+		runs = append(runs, inputs)
+		return inputs, nil
+	}}
+
+	// Both outputs of an if-like node lead to the same merge node, which
+	// runs once with the records of both.
+	workflow := &Workflow{
+		Nodes: map[string]Node{"split": &mockPortNode{}, "merge": merge},
+		Ports: map[string]map[int][]string{"split": {0: {"merge"}, 1: {"merge"}}},
+	}
+	outputs, err := workflow.RunOutputs(ctx, "split", []map[string]interface{}{{"n": 1}, {"n": 2}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(runs) != 1 || len(runs[0]) != 2 || len(outputs) != 2 {
+		t.Errorf("expected merge to run once on 2 records, got runs %v and outputs %v", runs, outputs)
+	}
+
+	// Reached through two parents, a node runs for each, on the records of
+	// that parent only.
+	runs = nil
+	workflow = &Workflow{
+		Nodes: map[string]Node{"start": &mockNode{}, "left": &mockNode{}, "right": &mockNode{}, "merge": merge},
+		Connections: map[string][]string{
+			"start": {"left", "right"},
+			"left":  {"merge"},
+			"right": {"merge"},
+		},
+	}
+	outputs, err = workflow.RunOutputs(ctx, "start", []map[string]interface{}{{"n": 1}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(runs) != 2 || len(runs[0]) != 1 || len(runs[1]) != 1 || len(outputs) != 2 {
+		t.Errorf("expected merge to run on each parent's record, got runs %v and outputs %v", runs, outputs)
+	}
+}
+
+func TestWorkflow_Run_PortChildAndSibling(t *testing.T) {
+	ctx := &Context{
+		Ctx:     context.Background(),
+		Logger:  zap.NewNop().Sugar(),
+		Metrics: NewMetrics(prometheus.NewRegistry()),
+	}
+	received := map[string][]map[string]interface{}{}
+	record := func(name string) *mockNode {
+		return &mockNode{execute: func(ctx *Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+			received[name] = append(received[name], inputs...)
+			return inputs, nil
+		}}
+	}
+
+	// The output of start feeds both split, which routes by port and runs
+	// first, and audit, which must still get all of it.
+	workflow := &Workflow{
+		Nodes: map[string]Node{
+			"start": &mockNode{},
+			"split": &mockPortNode{},
+			"even":  record("even"),
+			"odd":   record("odd"),
+			"audit": record("audit"),
+		},
+		Connections: map[string][]string{"start": {"split", "audit"}},
+		Ports:       map[string]map[int][]string{"split": {0: {"even"}, 1: {"odd"}}},
+	}
+	input := []map[string]interface{}{{"n": 1}, {"n": 2}, {"n": 3}}
+	if err := workflow.Run(ctx, "start", input); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(received["even"]) != 1 || len(received["odd"]) != 2 {
+		t.Errorf("expected split to route 1 even and 2 odd records, got %v", received)
+	}
+	if len(received["audit"]) != 3 {
+		t.Errorf("expected audit to receive all 3 records of start, got %v", received["audit"])
+	}
+}
+
+func TestWorkflow_Run_NodeDone(t *testing.T) {
+	done := map[string]error{}
+	ctx := &Context{
+		Ctx:      context.Background(),
+		Logger:   zap.NewNop().Sugar(),
+		Metrics:  NewMetrics(prometheus.NewRegistry()),
+		NodeDone: func(name string, err error) { done[name] = err },
+	}
+	failure := errors.New("test error")
+	workflow := &Workflow{
+		Nodes: map[string]Node{
+			"first": &mockNode{name: "first"},
+			"failing": &mockNode{name: "failing", execute: func(ctx *Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+				return nil, failure
+			}},
+			"handler": &mockNode{name: "handler"},
+			"skipped": &mockNode{name: "skipped"},
+		},
+		Connections:      map[string][]string{"first": {"failing"}, "failing": {"skipped"}},
+		ErrorConnections: map[string]string{"failing": "handler"},
+	}
+
+	if err := workflow.Run(ctx, "first", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(done) != 3 || done["first"] != nil || done["failing"] != failure || done["handler"] != nil {
+		t.Errorf("expected first, failing and handler to be reported, got %v", done)
+	}
+	if _, ok := done["skipped"]; ok {
+		t.Error("expected skipped not to be reported")
+	}
+}
+
+func TestWorkflow_Run_BudgetExceeded(t *testing.T) {
+	ctx := &Context{
+		Ctx:      context.Background(),
+		Logger:   zap.NewNop().Sugar(),
+		Metrics:  NewMetrics(prometheus.NewRegistry()),
+		RunUsage: NewUsageMeter("run", Budget{MaxTokens: 10}),
+	}
+	var nodes []string
+	handler := &mockNode{name: "handler"}
+	workflow := &Workflow{
+		Nodes: map[string]Node{
+			"llm": &mockNode{name: "llm", execute: func(ctx *Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+				nodes = append(nodes, ctx.Node)
+				return nil, ctx.RecordLLMUsage(LLMUsage{PromptTokens: 20})
+			}},
+			"handler": handler,
+		},
+		ErrorConnections: map[string]string{"llm": "handler"},
+	}
+
+	err := workflow.Run(ctx, "llm", nil)
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected the run to stop with a budget error, got %v", err)
+	}
+	if handler.executed {
+		t.Error("expected the error handler not to run after the budget was exceeded")
+	}
+	if len(nodes) != 1 || nodes[0] != "llm" {
+		t.Errorf("expected the context to name the executing node, got %v", nodes)
+	}
+}
+
+func TestWorkflow_RunOutputs(t *testing.T) {
+	ctx := &Context{
+		Ctx:     context.Background(),
+		Logger:  zap.NewNop().Sugar(),
+		Metrics: NewMetrics(prometheus.NewRegistry()),
+	}
+	workflow := &Workflow{
+		Nodes: map[string]Node{
+			"first": &mockNode{name: "first"},
+			"split": &mockPortNode{},
+			"left": &mockNode{name: "left", execute: func(ctx *Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+				return []map[string]interface{}{{"leaf": "left"}}, nil
+			}},
+			"right": &mockNode{name: "right", execute: func(ctx *Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+				return []map[string]interface{}{{"leaf": "right"}}, nil
+			}},
+		},
+		Connections: map[string][]string{"first": {"split"}},
+		Ports:       map[string]map[int][]string{"split": {0: {"left"}, 1: {"right"}}},
+	}
+
+	outputs, err := workflow.RunOutputs(ctx, "first", []map[string]interface{}{{"n": 2}, {"n": 3}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(outputs) != 2 || outputs[0]["leaf"] != "left" || outputs[1]["leaf"] != "right" {
+		t.Errorf("expected the records of both leaf nodes, got %v", outputs)
+	}
+}
@@ -0,0 +1,187 @@
+package framework
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FunctionCall is a registered function bound to the parameters of a node,
+// which codeNode runs on its records.
+type FunctionCall func(ctx *Context, items []map[string]interface{}) ([]map[string]interface{}, error)
+
+// FunctionParam describes a parameter of a registered function.
+type FunctionParam struct {
+	Name string `json:"name"`
+	// Type is the JSON type of the parameter: string, integer, number,
+	// boolean, array or object.
+	Type        string `json:"type"`
+	Required    bool   `json:"required,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// FunctionInfo describes a registered function and its parameters.
+type FunctionInfo struct {
+	Name   string          `json:"name"`
+	Params []FunctionParam `json:"params"`
+}
+
+// functionValidator is implemented by parameter types that check their
+// values when a node is built.
+type functionValidator interface {
+	Validate() error
+}
+
+// registeredFunction is a function with its type-erased binding.
+type registeredFunction struct {
+	info FunctionInfo
+	bind func(params map[string]interface{}) (FunctionCall, error)
+}
+
+var (
+	functionsMu sync.RWMutex
+	functions   = map[string]registeredFunction{}
+)
+
+// RegisterFunction registers a Go function that codeNode nodes run by
+// name. P is a struct of the function's parameters, decoded from the
+// params of a node by their yaml tags. A field tagged `required:"true"`
+// must be given, and `doc:"..."` describes it in ListFunctions. If P has a
+// Validate method, it checks the parameters when the workflow is built.
+//
+// Like database/sql.Register, RegisterFunction is meant to be called from
+// init functions and panics if fn is nil or the name is taken.
+func RegisterFunction[P any](name string, fn func(ctx *Context, params P, items []map[string]interface{}) ([]map[string]interface{}, error)) {
+	if fn == nil {
+		panic("framework: RegisterFunction " + name + " with nil function")
+	}
+	info := FunctionInfo{Name: name, Params: functionParams(reflect.TypeOf((*P)(nil)).Elem())}
+	bind := func(values map[string]interface{}) (FunctionCall, error) {
+		var params P
+		if err := decodeFunctionParams(values, &params, info.Params); err != nil {
+			return nil, err
+		}
+		if v, ok := interface{}(&params).(functionValidator); ok {
+			if err := v.Validate(); err != nil {
+				return nil, err
+			}
+		}
+		return func(ctx *Context, items []map[string]interface{}) ([]map[string]interface{}, error) {
+			return fn(ctx, params, items)
+		}, nil
+	}
+
+	functionsMu.Lock()
+	defer functionsMu.Unlock()
+	if _, ok := functions[name]; ok {
+		panic("framework: RegisterFunction called twice for " + name)
+	}
+	functions[name] = registeredFunction{info: info, bind: bind}
+}
+
+// BindFunction binds a registered function to the params of a node.
+func BindFunction(name string, params map[string]interface{}) (FunctionCall, error) {
+	functionsMu.RLock()
+	fn, ok := functions[name]
+	functionsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+	call, err := fn.bind(params)
+	if err != nil {
+		return nil, fmt.Errorf("function %s: %w", name, err)
+	}
+	return call, nil
+}
+
+// ListFunctions returns the registered functions, sorted by name.
+func ListFunctions() []FunctionInfo {
+	functionsMu.RLock()
+	defer functionsMu.RUnlock()
+	list := make([]FunctionInfo, 0, len(functions))
+	for _, fn := range functions {
+		list = append(list, fn.info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// decodeFunctionParams decodes values into v, rejecting unknown and
+// missing required parameters.
+func decodeFunctionParams(values map[string]interface{}, v interface{}, described []FunctionParam) error {
+	for _, param := range described {
+		if _, ok := values[param.Name]; param.Required && !ok {
+			return fmt.Errorf("param %s is required", param.Name)
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	return nil
+}
+
+// functionParams describes the fields of a parameter struct.
+func functionParams(t reflect.Type) []FunctionParam {
+	params := []FunctionParam{}
+	if t.Kind() != reflect.Struct {
+		return params
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		params = append(params, FunctionParam{
+			Name:        name,
+			Type:        jsonType(field.Type),
+			Required:    field.Tag.Get("required") == "true",
+			Description: field.Tag.Get("doc"),
+		})
+	}
+	return params
+}
+
+// jsonType returns the JSON type of values decoded into t.
+func jsonType(t reflect.Type) string {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	case reflect.Ptr:
+		return jsonType(t.Elem())
+	}
+	return "string"
+}
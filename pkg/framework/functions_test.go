@@ -0,0 +1,103 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stampParams are the parameters of the test function testStamp.
+type stampParams struct {
+	Field string   `yaml:"field" required:"true" doc:"Field to set."`
+	Value string   `yaml:"value"`
+	Tags  []string `yaml:"tags"`
+}
+
+func (p *stampParams) Validate() error {
+	if p.Value == "bad" {
+		return errors.New("bad value")
+	}
+	return nil
+}
+
+func init() {
+	RegisterFunction("testStamp", func(ctx *Context, p stampParams, items []map[string]interface{}) ([]map[string]interface{}, error) {
+		if ctx.Node == "" {
+			return nil, errors.New("no node")
+		}
+		out := make([]map[string]interface{}, len(items))
+		for i, item := range items {
+			out[i] = map[string]interface{}{p.Field: p.Value + "@" + ctx.Node}
+			for k, v := range item {
+				out[i][k] = v
+			}
+		}
+		return out, nil
+	})
+}
+
+func TestBindFunction(t *testing.T) {
+	call, err := BindFunction("testStamp", map[string]interface{}{"field": "seen", "value": "yes"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := call(&Context{Ctx: context.Background(), Node: "stamp"}, []map[string]interface{}{{"id": 1}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(out, []map[string]interface{}{{"id": 1, "seen": "yes@stamp"}}) {
+		t.Errorf("unexpected output %v", out)
+	}
+	if _, err := call(&Context{Ctx: context.Background()}, nil); err == nil || err.Error() != "no node" {
+		t.Errorf("expected the error of the function, got %v", err)
+	}
+
+	tests := map[string]struct {
+		name   string
+		params map[string]interface{}
+		want   string
+	}{
+		"unknown function": {"nope", nil, `unknown function "nope"`},
+		"missing required": {"testStamp", map[string]interface{}{"value": "x"}, "param field is required"},
+		"no params":        {"testStamp", nil, "param field is required"},
+		"unknown param":    {"testStamp", map[string]interface{}{"field": "a", "colour": "red"}, "field colour not found"},
+		"wrong type":       {"testStamp", map[string]interface{}{"field": "a", "tags": "x"}, "cannot unmarshal"},
+		"invalid":          {"testStamp", map[string]interface{}{"field": "a", "value": "bad"}, "bad value"},
+	}
+	for name, tt := range tests {
+		if _, err := BindFunction(tt.name, tt.params); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tt.want, err)
+		}
+	}
+}
+
+func TestListFunctions(t *testing.T) {
+	for _, fn := range ListFunctions() {
+		if fn.Name != "testStamp" {
+			continue
+		}
+		want := []FunctionParam{
+			{Name: "field", Type: "string", Required: true, Description: "Field to set."},
+			{Name: "value", Type: "string"},
+			{Name: "tags", Type: "array"},
+		}
+		if !reflect.DeepEqual(fn.Params, want) {
+			t.Errorf("expected params %v, got %v", want, fn.Params)
+		}
+		return
+	}
+	t.Error("expected testStamp to be listed")
+}
+
+func TestRegisterFunction_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a name twice to panic")
+		}
+	}()
+	RegisterFunction("testStamp", func(ctx *Context, p struct{}, items []map[string]interface{}) ([]map[string]interface{}, error) {
+		return items, nil
+	})
+}
@@ -0,0 +1,319 @@
+package framework
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Graph formats supported by RenderGraph.
+const (
+	GraphFormatDOT     = "dot"
+	GraphFormatMermaid = "mermaid"
+)
+
+// Node statuses RenderGraph colors nodes by. They match the node statuses
+// recorded for stored runs.
+const (
+	graphStatusCompleted = "completed"
+	graphStatusFailed    = "failed"
+)
+
+// graphEdge is a connection drawn between two nodes.
+type graphEdge struct {
+	from, to string
+	label    string
+	error    bool // an error connection, drawn dashed
+	loop     bool // leads back to a node the path came through
+}
+
+// RenderGraph draws a workflow definition as a Graphviz DOT or Mermaid
+// flowchart. Nodes show their name and type, with their parameters as a
+// tooltip; outputs are labeled as their node types in registry describe
+// them, or DefaultRegistry if registry is nil, error connections are
+// dashed and connections that close a loop are marked.
+//
+// If statuses is not nil, it is a run overlay: nodes are colored by their
+// status ("completed" or "failed"), and nodes without a status are grayed
+// out as not run.
+func RenderGraph(def *WorkflowDef, format string, statuses map[string]string, registry *Registry) (string, error) {
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+	edges := graphEdges(def, registry)
+	switch format {
+	case GraphFormatDOT:
+		return renderDOT(def, edges, statuses), nil
+	case GraphFormatMermaid:
+		return renderMermaid(def, edges, statuses), nil
+	}
+	return "", fmt.Errorf("unknown graph format %q", format)
+}
+
+// graphEdges lists every connection of def: connections, then ports in
+// port order, then the error connection of each node.
+func graphEdges(def *WorkflowDef, registry *Registry) []graphEdge {
+	var edges []graphEdge
+	for _, from := range def.Nodes {
+		for _, to := range def.Connections[from] {
+			edges = append(edges, graphEdge{from: from, to: to})
+		}
+		ports := make([]int, 0, len(def.Ports[from]))
+		for port := range def.Ports[from] {
+			ports = append(ports, port)
+		}
+		sort.Ints(ports)
+		for _, port := range ports {
+			label := graphPortLabel(def, registry, from, port)
+			for _, to := range def.Ports[from][port] {
+				edges = append(edges, graphEdge{from: from, to: to, label: label})
+			}
+		}
+		if to, ok := def.ErrorConnections[from]; ok {
+			edges = append(edges, graphEdge{from: from, to: to, label: "error", error: true})
+		}
+	}
+	markLoops(def, edges)
+	return edges
+}
+
+// graphPortLabel names an output of a node: the label of the output in the
+// description of its type, such as true and false for ifNode, the branch
+// name of switchNode conditions, and the output number otherwise.
+func graphPortLabel(def *WorkflowDef, registry *Registry, name string, port int) string {
+	nodeDef := def.NodeDefs[name]
+	if nodeDef == nil {
+		return fmt.Sprintf("output %d", port)
+	}
+	if nodeDef.Type == "switchNode" {
+		if rules, _ := nodeDef.Params["rules"].([]interface{}); len(rules) == 0 {
+			// Without rules, output i is the i-th branch in name order.
+			branches, _ := nodeDef.Params["conditions"].(map[string]interface{})
+			names := make([]string, 0, len(branches))
+			for branch := range branches {
+				names = append(names, branch)
+			}
+			sort.Strings(names)
+			if port < len(names) {
+				return names[port]
+			}
+		}
+		if fallback, ok := nodeDef.Params["fallbackOutput"].(int); ok && fallback == port {
+			return "fallback"
+		}
+	}
+	// Node types registered by name only describe a single main output.
+	if info, ok := registry.Lookup(nodeDef.Type); ok && !info.DynamicOutputs && port < len(info.Outputs) && info.Outputs[port] != MainPort {
+		return info.Outputs[port]
+	}
+	return fmt.Sprintf("output %d", port)
+}
+
+// markLoops marks the edges that lead back to a node on the current path of
+// a depth-first walk from the start node.
+func markLoops(def *WorkflowDef, edges []graphEdge) {
+	out := map[string][]int{}
+	for i, edge := range edges {
+		out[edge.from] = append(out[edge.from], i)
+	}
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := map[string]int{}
+	var walk func(name string)
+	walk = func(name string) {
+		state[name] = onPath
+		for _, i := range out[name] {
+			switch state[edges[i].to] {
+			case onPath:
+				edges[i].loop = true
+			case unvisited:
+				walk(edges[i].to)
+			}
+		}
+		state[name] = done
+	}
+	walk(def.StartNode())
+	for _, name := range def.Nodes {
+		if state[name] == unvisited {
+			walk(name)
+		}
+	}
+}
+
+// graphTooltip lists the parameters of a node, one per line.
+func graphTooltip(nodeDef *NodeDef) string {
+	if nodeDef == nil {
+		return ""
+	}
+	keys := make([]string, 0, len(nodeDef.Params))
+	for key := range nodeDef.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value, err := json.Marshal(nodeDef.Params[key])
+		if err != nil {
+			value = []byte(fmt.Sprint(nodeDef.Params[key]))
+		}
+		line := fmt.Sprintf("%s: %s", key, value)
+		if len(line) > 200 {
+			line = line[:197] + "..."
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// graphFills are the fill colors of node statuses in a run overlay.
+var graphFills = map[string]string{
+	graphStatusCompleted: "#c8e6c9",
+	graphStatusFailed:    "#ffcdd2",
+	"":                   "#eeeeee",
+}
+
+func renderDOT(def *WorkflowDef, edges []graphEdge, statuses map[string]string) string {
+	var b strings.Builder
+	b.WriteString("digraph workflow {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=\"rounded,filled\", fillcolor=\"#ffffff\", fontname=\"Helvetica\"];\n")
+	b.WriteString("  edge [fontname=\"Helvetica\", fontsize=10];\n")
+	for _, name := range def.Nodes {
+		label := name
+		attrs := []string{}
+		if nodeDef := def.NodeDefs[name]; nodeDef != nil {
+			label += "\n" + nodeDef.Type
+			if tooltip := graphTooltip(nodeDef); tooltip != "" {
+				attrs = append(attrs, "tooltip="+dotQuote(tooltip))
+			}
+		}
+		attrs = append([]string{"label=" + dotQuote(label)}, attrs...)
+		if name == def.StartNode() {
+			attrs = append(attrs, "peripheries=2")
+		}
+		if statuses != nil {
+			status := statuses[name]
+			if fill, ok := graphFills[status]; ok {
+				attrs = append(attrs, "fillcolor="+dotQuote(fill))
+			}
+			if status == "" {
+				attrs = append(attrs, `fontcolor="#757575"`)
+			}
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(name), strings.Join(attrs, ", "))
+	}
+	for _, edge := range edges {
+		var attrs []string
+		label := edge.label
+		if edge.loop {
+			label = strings.TrimSpace(label + " (loop)")
+			attrs = append(attrs, `color="#1565c0"`, "constraint=false")
+		}
+		if label != "" {
+			attrs = append(attrs, "label="+dotQuote(label))
+		}
+		if edge.error {
+			attrs = append(attrs, "style=dashed", `color="#c62828"`)
+		}
+		fmt.Fprintf(&b, "  %s -> %s", dotQuote(edge.from), dotQuote(edge.to))
+		if len(attrs) > 0 {
+			fmt.Fprintf(&b, " [%s]", strings.Join(attrs, ", "))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotQuote quotes s as a DOT string.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+func renderMermaid(def *WorkflowDef, edges []graphEdge, statuses map[string]string) string {
+	// Mermaid IDs cannot hold arbitrary names, so nodes are numbered.
+	ids := map[string]string{}
+	id := func(name string) string {
+		if _, ok := ids[name]; !ok {
+			ids[name] = fmt.Sprintf("n%d", len(ids))
+		}
+		return ids[name]
+	}
+
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, name := range def.Nodes {
+		label := mermaidEscape(name)
+		if nodeDef := def.NodeDefs[name]; nodeDef != nil {
+			label += "<br/><i>" + mermaidEscape(nodeDef.Type) + "</i>"
+		}
+		if name == def.StartNode() {
+			fmt.Fprintf(&b, "  %s([\"%s\"])\n", id(name), label)
+		} else {
+			fmt.Fprintf(&b, "  %s[\"%s\"]\n", id(name), label)
+		}
+	}
+	for _, edge := range edges {
+		label := edge.label
+		if edge.loop {
+			label = strings.TrimSpace(label + " (loop)")
+		}
+		arrow := "-->"
+		switch {
+		case edge.error:
+			arrow = "-.->"
+		case edge.loop:
+			arrow = "==>"
+		}
+		if label != "" {
+			arrow += "|\"" + mermaidEscape(label) + "\"|"
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", id(edge.from), arrow, id(edge.to))
+	}
+	for _, name := range def.Nodes {
+		if tooltip := graphTooltip(def.NodeDefs[name]); tooltip != "" {
+			fmt.Fprintf(&b, "  click %s callback \"%s\"\n", id(name), mermaidEscape(tooltip))
+		}
+	}
+
+	if statuses != nil {
+		b.WriteString("  classDef completed fill:#c8e6c9,stroke:#2e7d32\n")
+		b.WriteString("  classDef failed fill:#ffcdd2,stroke:#c62828\n")
+		b.WriteString("  classDef notRun fill:#eeeeee,stroke:#9e9e9e,color:#757575\n")
+		classes := map[string][]string{}
+		for _, name := range def.Nodes {
+			class := "notRun"
+			switch statuses[name] {
+			case graphStatusCompleted:
+				class = "completed"
+			case graphStatusFailed:
+				class = "failed"
+			}
+			classes[class] = append(classes[class], id(name))
+		}
+		for _, class := range []string{"completed", "failed", "notRun"} {
+			if len(classes[class]) > 0 {
+				fmt.Fprintf(&b, "  class %s %s\n", strings.Join(classes[class], ","), class)
+			}
+		}
+	}
+	return b.String()
+}
+
+// mermaidEscape replaces the characters that end a quoted Mermaid label
+// with entity codes.
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(
+		`"`, "#quot;",
+		"\n", "&#10;",
+		"<", "#lt;",
+		">", "#gt;",
+	).Replace(s)
+}
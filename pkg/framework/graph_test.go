@@ -0,0 +1,213 @@
+package framework
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const graphWorkflowYAML = `
+start: trigger
+nodes:
+  trigger:
+    type: manualTrigger
+  check:
+    type: ifNode
+    conditions:
+      - field: total
+        operation: larger
+        value: 100
+  route:
+    type: switchNode
+    conditions:
+      germany:
+        field: country
+        value: DE
+      france:
+        field: country
+        value: FR
+  wait:
+    type: waitNode
+    maxSeconds: 5
+  handler:
+    type: errorHandlerNode
+connections:
+  trigger: [check]
+  wait: [check]
+ports:
+  check:
+    0: [route]
+    1: [wait]
+  route:
+    0: [wait]
+    1: [handler]
+errorConnections:
+  route: handler
+`
+
+// graphRegistry describes the outputs of the node types of
+// graphWorkflowYAML.
+func graphRegistry(t *testing.T) *Registry {
+	t.Helper()
+	registry := NewRegistry()
+	factory := func(nodeDef *yaml.Node) (Node, error) { return &mockNode{}, nil }
+	for _, info := range []NodeTypeInfo{
+		{Type: "ifNode", Outputs: []string{"true", "false"}},
+		{Type: "switchNode", DynamicOutputs: true},
+		{Type: "waitNode", Outputs: []string{MainPort}},
+	} {
+		if err := registry.Register(info, factory); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return registry
+}
+
+func loadGraphWorkflow(t *testing.T) *WorkflowDef {
+	t.Helper()
+	def, err := LoadWorkflowDefFromYAMLString(graphWorkflowYAML)
+	if err != nil {
+		t.Fatalf("failed to load workflow: %v", err)
+	}
+	return def
+}
+
+func TestRenderGraph_DOT(t *testing.T) {
+	out, err := RenderGraph(loadGraphWorkflow(t), GraphFormatDOT, nil, graphRegistry(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		`digraph workflow {`,
+		`"trigger" [label="trigger\nmanualTrigger", peripheries=2];`,
+		`"wait" [label="wait\nwaitNode", tooltip="maxSeconds: 5"];`,
+		`"trigger" -> "check";`,
+		`"check" -> "route" [label="true"];`,
+		`"check" -> "wait" [label="false"];`,
+		// Switch outputs are named after the branches in name order.
+		`"route" -> "wait" [label="france"];`,
+		`"route" -> "handler" [label="germany"];`,
+		`"route" -> "handler" [label="error", style=dashed, color="#c62828"];`,
+		`"wait" -> "check" [color="#1565c0", constraint=false, label="(loop)"];`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected DOT output to contain %s, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "fillcolor=\"#c8e6c9\"") {
+		t.Errorf("expected no run overlay without statuses, got:\n%s", out)
+	}
+}
+
+func TestRenderGraph_Mermaid(t *testing.T) {
+	out, err := RenderGraph(loadGraphWorkflow(t), GraphFormatMermaid, nil, graphRegistry(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		"flowchart LR\n",
+		`n0(["trigger<br/><i>manualTrigger</i>"])`,
+		`n1["check<br/><i>ifNode</i>"]`,
+		`n0 --> n1`,
+		`n1 -->|"true"| n2`,
+		`n2 -.->|"error"| n4`,
+		`n3 ==>|"(loop)"| n1`,
+		`click n3 callback "maxSeconds: 5"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected Mermaid output to contain %s, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderGraph_RunOverlay(t *testing.T) {
+	def := loadGraphWorkflow(t)
+	statuses := map[string]string{"trigger": "completed", "check": "completed", "route": "failed", "handler": "completed"}
+
+	dot, err := RenderGraph(def, GraphFormatDOT, statuses, graphRegistry(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		`"route" [label="route\nswitchNode", tooltip=`,
+		`fillcolor="#ffcdd2"`,
+		`"wait" [label="wait\nwaitNode", tooltip="maxSeconds: 5", fillcolor="#eeeeee", fontcolor="#757575"];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("expected DOT overlay to contain %s, got:\n%s", want, dot)
+		}
+	}
+
+	mermaid, err := RenderGraph(def, GraphFormatMermaid, statuses, graphRegistry(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"class n0,n1,n4 completed", "class n2 failed", "class n3 notRun"} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("expected Mermaid overlay to contain %s, got:\n%s", want, mermaid)
+		}
+	}
+}
+
+func TestRenderGraph_Escaping(t *testing.T) {
+	def := &WorkflowDef{
+		Nodes:       []string{`say "hi"`, "next"},
+		Connections: map[string][]string{`say "hi"`: {"next"}},
+	}
+	dot, err := RenderGraph(def, GraphFormatDOT, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dot, `"say \"hi\"" -> "next";`) {
+		t.Errorf("expected quotes to be escaped in DOT, got:\n%s", dot)
+	}
+	mermaid, err := RenderGraph(def, GraphFormatMermaid, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(mermaid, `n0["say #quot;hi#quot;"]`) {
+		t.Errorf("expected quotes to be escaped in Mermaid, got:\n%s", mermaid)
+	}
+
+	if _, err := RenderGraph(def, "svg", nil, nil); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestRenderGraph_PortLabels(t *testing.T) {
+	def, err := LoadWorkflowDefFromYAMLString(`
+nodes:
+  check:
+    type: ifNode
+  unknown:
+    type: customRouter
+  wait:
+    type: waitNode
+ports:
+  check:
+    1: [unknown]
+  unknown:
+    0: [wait]
+  wait:
+    0: [check]
+`)
+	if err != nil {
+		t.Fatalf("failed to load workflow: %v", err)
+	}
+	out, err := RenderGraph(def, GraphFormatDOT, nil, graphRegistry(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Outputs of types without a description, or only a main one, are
+	// numbered.
+	for _, want := range []string{
+		`"check" -> "unknown" [label="false"];`,
+		`"unknown" -> "wait" [label="output 0"];`,
+		`label="output 0 (loop)"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected DOT output to contain %s, got:\n%s", want, out)
+		}
+	}
+}
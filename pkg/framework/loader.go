@@ -1,16 +1,145 @@
 package framework
 
 import (
-    "encoding/json"
     "fmt"
     "io/ioutil"
+    "path/filepath"
     "gopkg.in/yaml.v3"
 )
 
+// NodeDef describes a single node: its registered type plus the
+// type-specific parameters decoded by the node's factory.
+type NodeDef struct {
+    Type   string                 `yaml:"type"`
+    Params map[string]interface{} `yaml:",inline"`
+}
+
 // WorkflowDef captures a node list + connections
+//
+// Nodes may be given either as a plain list of names, or as a mapping from
+// name to NodeDef. Only the mapping form can be turned into a runnable
+// Workflow by BuildWorkflow; NodeDefs is nil for the list form.
+//
+// Connections are the children of a node's only (or first) output. Ports
+// connects the numbered outputs of nodes that have several, such as
+// ifNode (0 true, 1 false) and switchNode. ErrorConnections names the node
+// that receives the error of a failing node instead of the run failing.
+// Start names the node a run begins at; it defaults to "manualTrigger".
+// Budgets limit the LLM usage of the workflow's runs. AWSProfiles names
+// the AWS connections its nodes select with awsProfile, and Databases the
+// SQL databases they select with database. Dir is the directory of the
+// definition file, which the files that nodes name are relative to; it is
+// empty for definitions not read from a file, such as those stored by the
+// API server, whose nodes cannot name files.
 type WorkflowDef struct {
-    Nodes       []string            `yaml:"nodes"`
-    Connections map[string][]string `yaml:"connections"`
+    Start            string                      `yaml:"start,omitempty"`
+    Nodes            []string                    `yaml:"nodes"`
+    NodeDefs         map[string]*NodeDef         `yaml:"-"`
+    Connections      map[string][]string         `yaml:"connections"`
+    Ports            map[string]map[int][]string `yaml:"ports,omitempty"`
+    ErrorConnections map[string]string           `yaml:"errorConnections,omitempty"`
+    Budgets          Budgets                     `yaml:"budgets,omitempty"`
+    AWSProfiles      map[string]AWSProfile       `yaml:"awsProfiles,omitempty"`
+    Databases        map[string]SQLDatabase      `yaml:"databases,omitempty"`
+    Dir              string                      `yaml:"-"`
+}
+
+// DefaultStartNode is the node runs begin at when WorkflowDef.Start is empty.
+const DefaultStartNode = "manualTrigger"
+
+// StartNode returns the node a run of the workflow begins at.
+func (d *WorkflowDef) StartNode() string {
+    if d.Start != "" {
+        return d.Start
+    }
+    return DefaultStartNode
+}
+
+// UnmarshalYAML accepts both the list and the mapping form of nodes.
+func (d *WorkflowDef) UnmarshalYAML(value *yaml.Node) error {
+    var raw struct {
+        Start            string                      `yaml:"start"`
+        Nodes            yaml.Node                   `yaml:"nodes"`
+        Connections      map[string][]string         `yaml:"connections"`
+        Ports            map[string]map[int][]string `yaml:"ports"`
+        ErrorConnections map[string]string           `yaml:"errorConnections"`
+        Budgets          Budgets                     `yaml:"budgets"`
+        AWSProfiles      map[string]AWSProfile       `yaml:"awsProfiles"`
+        Databases        map[string]SQLDatabase      `yaml:"databases"`
+    }
+    if err := value.Decode(&raw); err != nil {
+        return err
+    }
+    d.Start = raw.Start
+    d.Connections = raw.Connections
+    d.Ports = raw.Ports
+    d.ErrorConnections = raw.ErrorConnections
+    d.Budgets = raw.Budgets
+    if err := ValidateAWSProfiles(raw.AWSProfiles, true); err != nil {
+        return err
+    }
+    d.AWSProfiles = raw.AWSProfiles
+    if err := ValidateSQLDatabases(raw.Databases, true); err != nil {
+        return err
+    }
+    d.Databases = raw.Databases
+    d.Nodes = nil
+    d.NodeDefs = nil
+    switch raw.Nodes.Kind {
+    case 0:
+    case yaml.SequenceNode:
+        if err := raw.Nodes.Decode(&d.Nodes); err != nil {
+            return err
+        }
+    case yaml.MappingNode:
+        d.NodeDefs = map[string]*NodeDef{}
+        for i := 0; i+1 < len(raw.Nodes.Content); i += 2 {
+            name := raw.Nodes.Content[i].Value
+            nodeDef := &NodeDef{}
+            if err := raw.Nodes.Content[i+1].Decode(nodeDef); err != nil {
+                return fmt.Errorf("node %s: %w", name, err)
+            }
+            d.Nodes = append(d.Nodes, name)
+            d.NodeDefs[name] = nodeDef
+        }
+    default:
+        return fmt.Errorf("line %d: nodes must be a list or a mapping", raw.Nodes.Line)
+    }
+    return nil
+}
+
+// MarshalYAML writes nodes in the mapping form when node definitions are
+// present, preserving the order of Nodes.
+func (d WorkflowDef) MarshalYAML() (interface{}, error) {
+    nodes := &yaml.Node{}
+    if len(d.NodeDefs) == 0 {
+        if err := nodes.Encode(d.Nodes); err != nil {
+            return nil, err
+        }
+    } else {
+        nodes.Kind = yaml.MappingNode
+        for _, name := range d.Nodes {
+            nodeDef, ok := d.NodeDefs[name]
+            if !ok {
+                return nil, fmt.Errorf("node %s has no definition", name)
+            }
+            value := &yaml.Node{}
+            if err := value.Encode(nodeDef); err != nil {
+                return nil, err
+            }
+            nodes.Content = append(nodes.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, value)
+        }
+    }
+    return struct {
+        Start            string                      `yaml:"start,omitempty"`
+        Nodes            *yaml.Node                  `yaml:"nodes"`
+        Connections      map[string][]string         `yaml:"connections"`
+        Ports            map[string]map[int][]string `yaml:"ports,omitempty"`
+        ErrorConnections map[string]string           `yaml:"errorConnections,omitempty"`
+        Budgets          Budgets                     `yaml:"budgets,omitempty"`
+        AWSProfiles      map[string]AWSProfile       `yaml:"awsProfiles,omitempty"`
+        Databases        map[string]SQLDatabase      `yaml:"databases,omitempty"`
+    }{d.Start, nodes, d.Connections, d.Ports, d.ErrorConnections, d.Budgets, d.AWSProfiles, d.Databases}, nil
 }
 
 // LoadFromYAML parses a YAML workflow definition
@@ -19,34 +148,20 @@ func LoadFromYAML(path string) (*WorkflowDef, error) {
     if err != nil {
         return nil, err
     }
-    var def WorkflowDef
-    if err := yaml.Unmarshal(data, &def); err != nil {
+    def, err := LoadWorkflowDefFromYAMLString(string(data))
+    if err != nil {
         return nil, err
     }
-    return &def, nil
+    def.Dir = filepath.Dir(path)
+    return def, nil
 }
 
-// ConvertN8nJSON maps an n8n flow JSON to WorkflowDef stub
-func ConvertN8nJSON(path string) (*WorkflowDef, error) {
-    data, err := ioutil.ReadFile(path)
-    if err != nil {
-        return nil, err
-    }
-    var n8n struct{ Connections map[string][][]map[string]interface{} }
-    if err := json.Unmarshal(data, &n8n); err != nil {
+// LoadWorkflowDefFromYAMLString parses a YAML workflow definition held in memory,
+// e.g. one read back from a WorkflowStore.
+func LoadWorkflowDefFromYAMLString(data string) (*WorkflowDef, error) {
+    var def WorkflowDef
+    if err := yaml.Unmarshal([]byte(data), &def); err != nil {
         return nil, err
     }
-    def := &WorkflowDef{Connections: map[string][]string{}}
-    for from, lists := range n8n.Connections {
-        for _, arr := range lists {
-            for _, step := range arr {
-                to := fmt.Sprint(step["node"])
-                def.Connections[from] = append(def.Connections[from], to)
-            }
-        }
-    }
-    for k := range def.Connections {
-        def.Nodes = append(def.Nodes, k)
-    }
-    return def, nil
-}
\ No newline at end of file
+    return &def, nil
+}
@@ -4,6 +4,8 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
 func TestLoadFromYAML(t *testing.T) {
@@ -99,13 +101,13 @@ func TestConvertN8nJSON(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	def, err := ConvertN8nJSON(tmpfile.Name())
+	def, _, err := ConvertN8nJSON(tmpfile.Name())
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if len(def.Nodes) != 1 {
-		t.Errorf("expected 1 node, got %d", len(def.Nodes))
+	if len(def.Nodes) != 2 || def.Nodes[0] != "9" || def.Nodes[1] != "10" {
+		t.Errorf("expected nodes [9 10], got %v", def.Nodes)
 	}
 	if len(def.Connections["9"]) != 1 {
 		t.Errorf("expected 1 connection from node 9, got %d", len(def.Connections["9"]))
@@ -117,7 +119,7 @@ func TestConvertN8nJSON(t *testing.T) {
 
 func TestConvertN8nJSON_Error(t *testing.T) {
 	// Test case for non-existent file
-	_, err := ConvertN8nJSON("non_existent_file.json")
+	_, _, err := ConvertN8nJSON("non_existent_file.json")
 	if err == nil {
 		t.Fatal("expected an error for non-existent file, but got nil")
 	}
@@ -136,8 +138,125 @@ func TestConvertN8nJSON_Error(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, err = ConvertN8nJSON(tmpfile.Name())
+	_, _, err = ConvertN8nJSON(tmpfile.Name())
 	if err == nil {
 		t.Fatal("expected an error for invalid JSON, but got nil")
 	}
-}
\ No newline at end of file
+}
+func TestLoadWorkflowDefFromYAMLString_NodeDefs(t *testing.T) {
+	def, err := LoadWorkflowDefFromYAMLString(`
+nodes:
+  trigger:
+    type: manualTrigger
+    payload:
+      - message: hello
+  set:
+    type: setNode
+connections:
+  trigger: [set]
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(def.Nodes) != 2 || def.Nodes[0] != "trigger" || def.Nodes[1] != "set" {
+		t.Errorf("expected nodes [trigger set] in definition order, got %v", def.Nodes)
+	}
+	if def.NodeDefs["trigger"].Type != "manualTrigger" {
+		t.Errorf("expected trigger to be a manualTrigger, got %s", def.NodeDefs["trigger"].Type)
+	}
+	if _, ok := def.NodeDefs["trigger"].Params["payload"]; !ok {
+		t.Errorf("expected payload parameter to be kept, got %v", def.NodeDefs["trigger"].Params)
+	}
+
+	// Round trip keeps the mapping form and node order.
+	data, err := yaml.Marshal(def)
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+	again, err := LoadWorkflowDefFromYAMLString(string(data))
+	if err != nil {
+		t.Fatalf("unexpected error reloading: %v", err)
+	}
+	if len(again.NodeDefs) != 2 || again.Nodes[0] != "trigger" || again.Connections["trigger"][0] != "set" {
+		t.Errorf("round trip mismatch:\n%s", data)
+	}
+}
+
+func TestLoadWorkflowDefFromYAMLString_Budgets(t *testing.T) {
+	def, err := LoadWorkflowDefFromYAMLString(`
+nodes:
+  trigger:
+    type: manualTrigger
+budgets:
+  run:
+    maxTokens: 20000
+  workflow:
+    maxCost: 25.5
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Budgets{Run: Budget{MaxTokens: 20000}, Workflow: Budget{MaxCost: 25.5}}
+	if def.Budgets != want {
+		t.Errorf("expected budgets %+v, got %+v", want, def.Budgets)
+	}
+
+	data, err := yaml.Marshal(def)
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+	again, err := LoadWorkflowDefFromYAMLString(string(data))
+	if err != nil || again.Budgets != want {
+		t.Errorf("round trip lost the budgets:\n%s", data)
+	}
+}
+
+func TestLoadWorkflowDefFromYAMLString_InvalidNodes(t *testing.T) {
+	if _, err := LoadWorkflowDefFromYAMLString("nodes: 3"); err == nil {
+		t.Fatal("expected an error for scalar nodes, but got nil")
+	}
+}
+
+func TestLoadWorkflowDefFromYAMLString_AWSProfiles(t *testing.T) {
+	def, err := LoadWorkflowDefFromYAMLString(`
+nodes:
+  trigger:
+    type: manualTrigger
+awsProfiles:
+  contacts:
+    region: eu-west-1
+    accessKeyID: ${CONTACTS_KEY_ID}
+    secretAccessKey: ${CONTACTS_SECRET}
+    roleARN: arn:aws:iam::123456789012:role/contacts
+  local:
+    endpoint: http://localhost:8000
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := AWSProfile{Region: "eu-west-1", AccessKeyID: "${CONTACTS_KEY_ID}", SecretAccessKey: "${CONTACTS_SECRET}", RoleARN: "arn:aws:iam::123456789012:role/contacts"}
+	if def.AWSProfiles["contacts"] != want || def.AWSProfiles["local"].Endpoint != "http://localhost:8000" {
+		t.Errorf("unexpected profiles %+v", def.AWSProfiles)
+	}
+
+	data, err := yaml.Marshal(def)
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+	again, err := LoadWorkflowDefFromYAMLString(string(data))
+	if err != nil || again.AWSProfiles["contacts"] != want {
+		t.Errorf("round trip lost the profiles:\n%s", data)
+	}
+
+	_, err = LoadWorkflowDefFromYAMLString(`
+nodes: [trigger]
+awsProfiles:
+  contacts:
+    accessKeyID: AKIAEXAMPLE
+    secretAccessKey: plain-secret
+`)
+	if err == nil {
+		t.Error("expected an error for keys stored in the workflow")
+	}
+}
@@ -8,6 +8,13 @@ import (
 type Metrics struct {
     NodeDuration *prometheus.HistogramVec
     NodeErrors   *prometheus.CounterVec
+    // LLMPromptTokens, LLMCompletionTokens and LLMCost count the usage of
+    // LLM requests by workflow, node and model.
+    LLMPromptTokens     *prometheus.CounterVec
+    LLMCompletionTokens *prometheus.CounterVec
+    LLMCost             *prometheus.CounterVec
+    // LLMCacheHits counts the LLM requests answered from the cache.
+    LLMCacheHits *prometheus.CounterVec
 }
 
 // NewMetrics registers and returns collectors
@@ -21,7 +28,23 @@ func NewMetrics(reg *prometheus.Registry) *Metrics {
             prometheus.CounterOpts{Namespace: "workflow", Name: "node_errors_total"},
             []string{"node"},
         ),
+        LLMPromptTokens: prometheus.NewCounterVec(
+            prometheus.CounterOpts{Namespace: "workflow", Name: "llm_prompt_tokens_total"},
+            []string{"workflow", "node", "model"},
+        ),
+        LLMCompletionTokens: prometheus.NewCounterVec(
+            prometheus.CounterOpts{Namespace: "workflow", Name: "llm_completion_tokens_total"},
+            []string{"workflow", "node", "model"},
+        ),
+        LLMCost: prometheus.NewCounterVec(
+            prometheus.CounterOpts{Namespace: "workflow", Name: "llm_cost_dollars_total"},
+            []string{"workflow", "node", "model"},
+        ),
+        LLMCacheHits: prometheus.NewCounterVec(
+            prometheus.CounterOpts{Namespace: "workflow", Name: "llm_cache_hits_total"},
+            []string{"workflow", "node", "model"},
+        ),
     }
-    reg.MustRegister(m.NodeDuration, m.NodeErrors)
+    reg.MustRegister(m.NodeDuration, m.NodeErrors, m.LLMPromptTokens, m.LLMCompletionTokens, m.LLMCost, m.LLMCacheHits)
     return m
 }
\ No newline at end of file
@@ -0,0 +1,987 @@
+package framework
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// N8nIssue describes something in an n8n workflow that could not be
+// converted faithfully: a node type without an equivalent, a parameter that
+// is ignored or an expression that is kept as a literal.
+type N8nIssue struct {
+	Node  string // n8n node name; empty for workflow-level issues
+	Param string // parameter path, e.g. "options.timeout"; empty for node-level issues
+	// Unsupported is set if the node has no equivalent and the converted
+	// definition cannot be built until it is replaced.
+	Unsupported bool
+	Message     string
+}
+
+func (i N8nIssue) String() string {
+	switch {
+	case i.Param != "":
+		return fmt.Sprintf("node %q: parameter %q: %s", i.Node, i.Param, i.Message)
+	case i.Node != "":
+		return fmt.Sprintf("node %q: %s", i.Node, i.Message)
+	default:
+		return i.Message
+	}
+}
+
+// HasUnsupportedNodes reports whether any issue is an unsupported node.
+func HasUnsupportedNodes(issues []N8nIssue) bool {
+	for _, issue := range issues {
+		if issue.Unsupported {
+			return true
+		}
+	}
+	return false
+}
+
+// n8nWorkflow is the part of an exported n8n workflow the importer reads.
+type n8nWorkflow struct {
+	Name        string                     `json:"name,omitempty"`
+	Nodes       []n8nNode                  `json:"nodes"`
+	Connections map[string]json.RawMessage `json:"connections"`
+	PinData     map[string][]n8nItem       `json:"pinData,omitempty"`
+}
+
+// n8nItem is an item of pinned data.
+type n8nItem struct {
+	JSON map[string]interface{} `json:"json"`
+}
+
+type n8nNode struct {
+	ID          string                 `json:"id,omitempty"`
+	Name        string                 `json:"name"`
+	Type        string                 `json:"type"`
+	TypeVersion float64                `json:"typeVersion"`
+	Position    []float64              `json:"position"`
+	Disabled    bool                   `json:"disabled,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters"`
+	Notes       string                 `json:"notes,omitempty"`
+	WebhookID   string                 `json:"webhookId,omitempty"`
+}
+
+type n8nConnection struct {
+	Node  string `json:"node"`
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+}
+
+// ConvertN8nJSON converts an exported n8n workflow file to a WorkflowDef.
+// See ParseN8nJSON.
+func ConvertN8nJSON(path string) (*WorkflowDef, []N8nIssue, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ParseN8nJSON(data)
+}
+
+// ParseN8nJSON converts an n8n workflow to a WorkflowDef with a definition
+// for every node. n8n node types are mapped to registered node types, their
+// parameters to the node's parameters and numbered outputs of IF and Switch
+// nodes to Ports. Everything that could not be converted faithfully is
+// returned as an issue; the definition is only runnable if none of the
+// issues is an unsupported node.
+//
+// A file without a nodes array yields a definition that only lists the
+// node names found in its connections.
+func ParseN8nJSON(data []byte) (*WorkflowDef, []N8nIssue, error) {
+	var wf n8nWorkflow
+	if err := json.Unmarshal(data, &wf); err != nil {
+		return nil, nil, err
+	}
+	connections, issues, err := parseN8nConnections(wf.Connections)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	def := &WorkflowDef{Connections: map[string][]string{}}
+	portNodes := map[string]bool{}
+	if len(wf.Nodes) > 0 {
+		def.NodeDefs = map[string]*NodeDef{}
+	}
+	for _, node := range wf.Nodes {
+		if _, ok := def.NodeDefs[node.Name]; ok {
+			return nil, nil, fmt.Errorf("duplicate n8n node name %q", node.Name)
+		}
+		c := &n8nNodeConverter{node: node, params: newN8nParams(node.Parameters), issues: &issues}
+		for _, item := range wf.PinData[node.Name] {
+			c.pinData = append(c.pinData, item.JSON)
+		}
+		nodeDef := c.convert()
+		def.Nodes = append(def.Nodes, node.Name)
+		def.NodeDefs[node.Name] = nodeDef
+		portNodes[node.Name] = n8nPortTypes[nodeDef.Type]
+		if def.Start == "" && n8nTriggerTypes[nodeDef.Type] {
+			def.Start = node.Name
+		}
+	}
+
+	froms := make([]string, 0, len(connections))
+	for from := range connections {
+		froms = append(froms, from)
+	}
+	sort.Strings(froms)
+	for _, from := range froms {
+		for port, targets := range connections[from] {
+			if len(targets) == 0 {
+				continue
+			}
+			var names []string
+			for _, target := range targets {
+				names = append(names, target.Node)
+			}
+			switch {
+			case portNodes[from]:
+				if def.Ports == nil {
+					def.Ports = map[string]map[int][]string{}
+				}
+				if def.Ports[from] == nil {
+					def.Ports[from] = map[int][]string{}
+				}
+				def.Ports[from][port] = append(def.Ports[from][port], names...)
+			default:
+				if port > 0 && def.NodeDefs != nil {
+					issues = append(issues, N8nIssue{Node: from, Message: fmt.Sprintf("output %d is merged into the node's only output", port)})
+				}
+				def.Connections[from] = append(def.Connections[from], names...)
+			}
+		}
+	}
+
+	if def.NodeDefs == nil {
+		// Connections-only file: list every node named in a connection.
+		seen := map[string]bool{}
+		add := func(name string) {
+			if !seen[name] {
+				seen[name] = true
+				def.Nodes = append(def.Nodes, name)
+			}
+		}
+		for _, from := range froms {
+			add(from)
+			for _, to := range def.Connections[from] {
+				add(to)
+			}
+		}
+		return def, issues, nil
+	}
+
+	for _, from := range froms {
+		if _, ok := def.NodeDefs[from]; !ok {
+			return nil, nil, fmt.Errorf("connection from unknown n8n node %q", from)
+		}
+		for _, targets := range connections[from] {
+			for _, target := range targets {
+				if _, ok := def.NodeDefs[target.Node]; !ok {
+					return nil, nil, fmt.Errorf("connection from %q to unknown n8n node %q", from, target.Node)
+				}
+			}
+		}
+	}
+	if def.Start == "" {
+		def.Start = firstRootNode(def)
+		issues = append(issues, N8nIssue{Message: fmt.Sprintf("workflow has no trigger node; runs start at %q", def.Start)})
+	}
+	return def, issues, nil
+}
+
+// parseN8nConnections reads the "main" connections of every node, indexed by
+// output. The legacy form without the "main" key is accepted as well.
+func parseN8nConnections(raw map[string]json.RawMessage) (map[string][][]n8nConnection, []N8nIssue, error) {
+	var issues []N8nIssue
+	connections := map[string][][]n8nConnection{}
+	for from, data := range raw {
+		var outputs [][]n8nConnection
+		if err := json.Unmarshal(data, &outputs); err == nil {
+			connections[from] = outputs
+			continue
+		}
+		var byType map[string][][]n8nConnection
+		if err := json.Unmarshal(data, &byType); err != nil {
+			return nil, nil, fmt.Errorf("invalid connections of n8n node %q: %w", from, err)
+		}
+		for connType, outputs := range byType {
+			if connType != "main" {
+				issues = append(issues, N8nIssue{Node: from, Message: fmt.Sprintf("%s connections are not supported and were dropped", connType)})
+				continue
+			}
+			connections[from] = outputs
+		}
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].String() < issues[j].String() })
+	return connections, issues, nil
+}
+
+// firstRootNode returns the first node without incoming connections.
+func firstRootNode(def *WorkflowDef) string {
+	incoming := map[string]bool{}
+	for _, targets := range def.Connections {
+		for _, to := range targets {
+			incoming[to] = true
+		}
+	}
+	for _, ports := range def.Ports {
+		for _, targets := range ports {
+			for _, to := range targets {
+				incoming[to] = true
+			}
+		}
+	}
+	for _, name := range def.Nodes {
+		if !incoming[name] {
+			return name
+		}
+	}
+	if len(def.Nodes) > 0 {
+		return def.Nodes[0]
+	}
+	return ""
+}
+
+// n8nPortTypes are the node types whose n8n outputs map to Ports.
+var n8nPortTypes = map[string]bool{"ifNode": true, "switchNode": true}
+
+// n8nTriggerTypes are the node types a converted workflow can start at.
+var n8nTriggerTypes = map[string]bool{"manualTrigger": true, "webhookTrigger": true}
+
+// n8nConverters maps n8n node types, without their package prefix, to the
+// function that converts them.
+var n8nConverters = map[string]func(c *n8nNodeConverter) *NodeDef{
+	"manualTrigger":    convertN8nManualTrigger,
+	"start":            convertN8nManualTrigger,
+	"webhook":          convertN8nWebhook,
+	"cron":             convertN8nSchedule,
+	"scheduleTrigger":  convertN8nSchedule,
+	"httpRequest":      convertN8nHTTPRequest,
+	"set":              convertN8nSet,
+	"if":               convertN8nIf,
+	"switch":           convertN8nSwitch,
+	"merge":            convertN8nMerge,
+	"splitInBatches":   convertN8nSplitInBatches,
+	"wait":             convertN8nWait,
+	"code":             convertN8nCode,
+	"function":         convertN8nCode,
+	"functionItem":     convertN8nCode,
+	"openAi":           convertN8nOpenAI,
+	"removeDuplicates": convertN8nRemoveDuplicates,
+}
+
+// n8nNodeConverter converts a single n8n node and collects its issues.
+type n8nNodeConverter struct {
+	node    n8nNode
+	params  *n8nParams
+	pinData []map[string]interface{}
+	issues  *[]N8nIssue
+}
+
+func (c *n8nNodeConverter) convert() *NodeDef {
+	shortType := c.node.Type
+	if i := strings.LastIndex(shortType, "."); i >= 0 {
+		shortType = shortType[i+1:]
+	}
+	convert, ok := n8nConverters[shortType]
+	if !ok {
+		*c.issues = append(*c.issues, N8nIssue{
+			Node:        c.node.Name,
+			Unsupported: true,
+			Message:     fmt.Sprintf("node type %s is not supported", c.node.Type),
+		})
+		return &NodeDef{Type: c.node.Type, Params: c.node.Parameters}
+	}
+
+	if c.node.Disabled {
+		c.issue("", "node is disabled in n8n but is converted as enabled")
+	}
+	nodeDef := convert(c)
+	for _, param := range c.params.unused() {
+		c.issue(param, "not supported; ignored")
+	}
+	if c.pinData != nil {
+		c.issue("", "pinned data is only supported on manual triggers; ignored")
+	}
+	return nodeDef
+}
+
+func (c *n8nNodeConverter) issue(param, format string, args ...interface{}) {
+	*c.issues = append(*c.issues, N8nIssue{Node: c.node.Name, Param: param, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *n8nNodeConverter) unsupported(format string, args ...interface{}) *NodeDef {
+	*c.issues = append(*c.issues, N8nIssue{Node: c.node.Name, Unsupported: true, Message: fmt.Sprintf(format, args...)})
+	c.params.useAll()
+	return &NodeDef{Type: c.node.Type, Params: c.node.Parameters}
+}
+
+// literal returns v, reporting n8n expressions, which are not evaluated and
+// are kept as literal strings.
+func (c *n8nNodeConverter) literal(param string, v interface{}) interface{} {
+	if s, ok := v.(string); ok && strings.HasPrefix(s, "=") {
+		c.issue(param, "expression %q is not evaluated and is kept as a literal", s)
+		return strings.TrimPrefix(s, "=")
+	}
+	return v
+}
+
+// field returns the record field an n8n expression such as
+// ={{ $json.email }} or ={{ $json["email"] }} refers to.
+func (c *n8nNodeConverter) field(param string, v interface{}) (string, bool) {
+	s, _ := v.(string)
+	if m := n8nFieldExpr.FindStringSubmatch(s); m != nil {
+		if m[1] != "" {
+			return m[1], true
+		}
+		return m[2], true
+	}
+	c.issue(param, "%v does not refer to a single field of the item ($json.field); the condition is dropped", v)
+	return "", false
+}
+
+var n8nFieldExpr = regexp.MustCompile(`^=?\s*\{\{\s*\$json(?:\.([A-Za-z_$][\w$]*)|\[\s*["']([^"']+)["']\s*\])\s*\}\}\s*$`)
+
+// n8nParams gives access to the parameters of an n8n node and remembers
+// which ones were used, so the rest can be reported.
+type n8nParams struct {
+	values map[string]interface{}
+	used   map[string]bool
+}
+
+func newN8nParams(values map[string]interface{}) *n8nParams {
+	return &n8nParams{values: values, used: map[string]bool{}}
+}
+
+func (p *n8nParams) get(key string) (interface{}, bool) {
+	p.used[key] = true
+	v, ok := p.values[key]
+	return v, ok
+}
+
+func (p *n8nParams) str(key, def string) string {
+	if v, ok := p.get(key); ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return def
+}
+
+func (p *n8nParams) number(key string, def float64) float64 {
+	if v, ok := p.get(key); ok {
+		switch n := v.(type) {
+		case float64:
+			return n
+		case int:
+			return float64(n)
+		}
+	}
+	return def
+}
+
+func (p *n8nParams) boolean(key string) bool {
+	v, _ := p.get(key)
+	b, _ := v.(bool)
+	return b
+}
+
+func (p *n8nParams) object(key string) map[string]interface{} {
+	v, _ := p.get(key)
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+// list returns the parameter key as a list of objects.
+func (p *n8nParams) list(key string) []map[string]interface{} {
+	if _, ok := p.get(key); !ok {
+		return nil
+	}
+	return n8nList(p.values, key)
+}
+
+func (p *n8nParams) useAll() {
+	for key := range p.values {
+		p.used[key] = true
+	}
+}
+
+// unused returns the parameters that were set but not used, sorted.
+func (p *n8nParams) unused() []string {
+	var keys []string
+	for key, v := range p.values {
+		if !p.used[key] && !isEmptyN8nValue(v) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isEmptyN8nValue(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// n8nList returns obj[key] as a list of objects.
+func n8nList(obj map[string]interface{}, key string) []map[string]interface{} {
+	items, _ := obj[key].([]interface{})
+	var list []map[string]interface{}
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
+// n8nNameValues reads n8n's {parameters: [{name, value}]} collections.
+func (c *n8nNodeConverter) n8nNameValues(param string, obj map[string]interface{}, key string) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, item := range n8nList(obj, key) {
+		name, _ := item["name"].(string)
+		values[name] = c.literal(param+"."+name, item["value"])
+	}
+	return values
+}
+
+func convertN8nManualTrigger(c *n8nNodeConverter) *NodeDef {
+	params := map[string]interface{}{}
+	if c.pinData != nil {
+		// Items pinned to the trigger are the payload it starts runs with.
+		payload := make([]interface{}, len(c.pinData))
+		for i, item := range c.pinData {
+			payload[i] = item
+		}
+		params["payload"] = payload
+		c.pinData = nil
+	}
+	return &NodeDef{Type: "manualTrigger", Params: params}
+}
+
+func convertN8nRemoveDuplicates(c *n8nNodeConverter) *NodeDef {
+	if compare := c.params.str("compare", "allFields"); compare != "selectedFields" {
+		return c.unsupported("removing duplicates by %q is not supported; only a single selected field is", compare)
+	}
+	fields := n8nList(c.params.object("fieldsToCompare"), "fields")
+	if len(fields) != 1 {
+		return c.unsupported("removing duplicates by %d fields is not supported; only a single selected field is", len(fields))
+	}
+	c.params.get("options")
+	key, _ := fields[0]["fieldName"].(string)
+	return &NodeDef{Type: "dedupeNode", Params: map[string]interface{}{"key": key}}
+}
+
+func convertN8nWebhook(c *n8nNodeConverter) *NodeDef {
+	params := map[string]interface{}{}
+	if path := c.params.str("path", ""); path != "" {
+		params["path"] = path
+	}
+	params["httpMethod"] = c.params.str("httpMethod", "GET")
+	c.params.get("responseMode")
+	c.issue("", "webhook triggers are started through POST /api/v1/workflows/{id}/run with the payload as input")
+	return &NodeDef{Type: "webhookTrigger", Params: params}
+}
+
+func convertN8nSchedule(c *n8nNodeConverter) *NodeDef {
+	c.params.useAll()
+	c.issue("", "schedules are not supported; converted to a manualTrigger that must be started externally")
+	return &NodeDef{Type: "manualTrigger", Params: map[string]interface{}{}}
+}
+
+func convertN8nHTTPRequest(c *n8nNodeConverter) *NodeDef {
+	params := map[string]interface{}{}
+	rawURL := c.params.str("url", "")
+	if strings.HasPrefix(rawURL, "=") {
+		rawURL = c.literal("url", rawURL).(string)
+	}
+
+	method := c.params.str("method", "")
+	if method == "" {
+		method = c.params.str("requestMethod", "GET")
+	}
+	params["method"] = method
+
+	headers := map[string]interface{}{}
+	if c.node.TypeVersion >= 3 {
+		if c.params.boolean("sendHeaders") {
+			headers = c.n8nNameValues("headerParameters", c.params.object("headerParameters"), "parameters")
+		}
+		if c.params.boolean("sendQuery") {
+			query := c.n8nNameValues("queryParameters", c.params.object("queryParameters"), "parameters")
+			rawURL = appendN8nQuery(rawURL, query)
+		}
+		if c.params.boolean("sendBody") {
+			switch c.params.str("specifyBody", "keypair") {
+			case "json":
+				body := c.params.str("jsonBody", "")
+				var parsed interface{}
+				if err := json.Unmarshal([]byte(body), &parsed); err != nil {
+					c.issue("jsonBody", "not valid JSON (expressions are not evaluated); sent as a string")
+					params["body"] = c.literal("jsonBody", body)
+				} else {
+					params["body"] = parsed
+				}
+			default:
+				params["body"] = c.n8nNameValues("bodyParameters", c.params.object("bodyParameters"), "parameters")
+			}
+		}
+		c.params.get("contentType")
+	} else {
+		headers = c.n8nNameValues("headerParametersUi", c.params.object("headerParametersUi"), "parameter")
+		query := c.n8nNameValues("queryParametersUi", c.params.object("queryParametersUi"), "parameter")
+		rawURL = appendN8nQuery(rawURL, query)
+		if body := c.n8nNameValues("bodyParametersUi", c.params.object("bodyParametersUi"), "parameter"); len(body) > 0 {
+			params["body"] = body
+		}
+		if c.params.boolean("jsonParameters") {
+			if body := c.params.str("bodyParametersJson", ""); body != "" {
+				var parsed interface{}
+				if err := json.Unmarshal([]byte(body), &parsed); err == nil {
+					params["body"] = parsed
+				} else {
+					c.issue("bodyParametersJson", "not valid JSON (expressions are not evaluated); dropped")
+				}
+			}
+		}
+	}
+
+	params["url"] = rawURL
+	if len(headers) > 0 {
+		stringHeaders := map[string]interface{}{}
+		for k, v := range headers {
+			stringHeaders[k] = fmt.Sprint(v)
+		}
+		params["headers"] = stringHeaders
+	}
+	if auth := c.params.str("authentication", "none"); auth != "none" {
+		c.issue("authentication", "credentials are not converted; add the required headers by hand")
+	}
+	return &NodeDef{Type: "httpRequest", Params: params}
+}
+
+func appendN8nQuery(rawURL string, query map[string]interface{}) string {
+	if len(query) == 0 {
+		return rawURL
+	}
+	values := url.Values{}
+	for k, v := range query {
+		values.Set(k, fmt.Sprint(v))
+	}
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	return rawURL + sep + values.Encode()
+}
+
+func convertN8nSet(c *n8nNodeConverter) *NodeDef {
+	setValues := map[string]interface{}{}
+
+	// Version 1 and 2: values.{string,number,boolean}: [{name, value}]
+	if values := c.params.object("values"); values != nil {
+		for _, typ := range []string{"string", "number", "boolean"} {
+			for _, item := range n8nList(values, typ) {
+				name, _ := item["name"].(string)
+				setValues[name] = c.literal("values."+name, item["value"])
+			}
+		}
+	}
+	// Version 3.0-3.2: fields.values: [{name, type, stringValue, ...}]
+	if fields := c.params.object("fields"); fields != nil {
+		for _, item := range n8nList(fields, "values") {
+			name, _ := item["name"].(string)
+			for _, key := range []string{"stringValue", "numberValue", "booleanValue", "arrayValue", "objectValue"} {
+				if v, ok := item[key]; ok {
+					setValues[name] = c.literal("fields."+name, v)
+					break
+				}
+			}
+		}
+	}
+	// Version 3.3 and later: assignments.assignments: [{name, value, type}]
+	if assignments := c.params.object("assignments"); assignments != nil {
+		for _, item := range n8nList(assignments, "assignments") {
+			name, _ := item["name"].(string)
+			setValues[name] = c.literal("assignments."+name, item["value"])
+		}
+	}
+
+	if mode := c.params.str("mode", "manual"); mode != "manual" {
+		c.issue("mode", "mode %q is not supported; only manually mapped fields are converted", mode)
+		c.params.get("jsonOutput")
+	}
+	// setNode always keeps the item's other fields, except removeKeys. n8n
+	// drops them if keepOnlySet is set (version 1 and 2) or
+	// includeOtherFields is not (version 3.3 and later).
+	keepOnlySet := c.params.boolean("keepOnlySet")
+	if c.node.TypeVersion >= 3.3 {
+		keepOnlySet = !c.params.boolean("includeOtherFields")
+	}
+	var removeKeys []interface{}
+	switch include := c.params.str("include", "all"); include {
+	case "all":
+	case "except":
+		for _, field := range strings.Split(c.params.str("excludeFields", ""), ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				removeKeys = append(removeKeys, field)
+			}
+		}
+	default:
+		keepOnlySet = true
+	}
+	if keepOnlySet {
+		c.issue("", "dropping the item's other fields is not supported; they are kept")
+	}
+	c.params.get("options")
+	params := map[string]interface{}{"setValues": setValues}
+	if len(removeKeys) > 0 {
+		params["removeKeys"] = removeKeys
+	}
+	return &NodeDef{Type: "setNode", Params: params}
+}
+
+// n8nOperations maps the comparison operations of IF and Switch nodes, both
+// the version 1 names and the version 2 operator names, to Condition
+// operations.
+var n8nOperations = map[string]string{
+	"equal":        "equal",
+	"equals":       "equal",
+	"notEqual":     "notEqual",
+	"notEquals":    "notEqual",
+	"contains":     "contains",
+	"notContains":  "notContains",
+	"startsWith":   "startsWith",
+	"endsWith":     "endsWith",
+	"regex":        "regex",
+	"larger":       "larger",
+	"gt":           "larger",
+	"largerEqual":  "largerEqual",
+	"gte":          "largerEqual",
+	"smaller":      "smaller",
+	"lt":           "smaller",
+	"smallerEqual": "smallerEqual",
+	"lte":          "smallerEqual",
+	"isEmpty":      "isEmpty",
+	"empty":        "isEmpty",
+	"notExists":    "isEmpty",
+	"isNotEmpty":   "isNotEmpty",
+	"notEmpty":     "isNotEmpty",
+	"exists":       "isNotEmpty",
+}
+
+// condition converts one n8n comparison to a Condition map, or nil.
+func (c *n8nNodeConverter) condition(param string, left, right interface{}, operation string) map[string]interface{} {
+	field, ok := c.field(param, left)
+	if !ok {
+		return nil
+	}
+	switch operation {
+	case "true", "false":
+		return map[string]interface{}{"field": field, "value": operation == "true", "operation": "equal"}
+	}
+	op, ok := n8nOperations[operation]
+	if !ok {
+		c.issue(param, "operation %q is not supported; the condition is dropped", operation)
+		return nil
+	}
+	cond := map[string]interface{}{"field": field, "operation": op}
+	if op != "isEmpty" && op != "isNotEmpty" {
+		cond["value"] = c.literal(param, right)
+	}
+	return cond
+}
+
+// conditions converts the conditions of IF nodes and version 3 Switch rules.
+// Version 1 groups comparisons by data type; version 2 lists them with an
+// operator and a combinator.
+func (c *n8nNodeConverter) conditions(param string, obj map[string]interface{}, combineOperation string) ([]interface{}, string) {
+	var conditions []interface{}
+	combine := "all"
+
+	if list := n8nList(obj, "conditions"); list != nil || obj["combinator"] != nil {
+		if obj["combinator"] == "or" {
+			combine = "any"
+		}
+		for i, item := range list {
+			operator, _ := item["operator"].(map[string]interface{})
+			operation, _ := operator["operation"].(string)
+			if cond := c.condition(fmt.Sprintf("%s[%d]", param, i), item["leftValue"], item["rightValue"], operation); cond != nil {
+				conditions = append(conditions, cond)
+			}
+		}
+		return conditions, combine
+	}
+
+	if combineOperation == "any" {
+		combine = "any"
+	}
+	for _, typ := range []string{"string", "number", "boolean", "dateTime"} {
+		for i, item := range n8nList(obj, typ) {
+			operation, _ := item["operation"].(string)
+			if operation == "" {
+				// n8n's defaults for the version 1 data types.
+				operation = "equal"
+				if typ == "number" {
+					operation = "smaller"
+				}
+			}
+			if typ == "dateTime" {
+				c.issue(fmt.Sprintf("%s.dateTime[%d]", param, i), "date comparisons are not supported; the condition is dropped")
+				continue
+			}
+			if cond := c.condition(fmt.Sprintf("%s.%s[%d]", param, typ, i), item["value1"], item["value2"], operation); cond != nil {
+				conditions = append(conditions, cond)
+			}
+		}
+	}
+	return conditions, combine
+}
+
+func convertN8nIf(c *n8nNodeConverter) *NodeDef {
+	combineOperation := c.params.str("combineOperation", "all")
+	conditions, combine := c.conditions("conditions", c.params.object("conditions"), combineOperation)
+	c.params.get("options")
+	if len(conditions) == 0 {
+		c.issue("conditions", "no condition could be converted; every item goes to the false output")
+	}
+	return &NodeDef{Type: "ifNode", Params: map[string]interface{}{"conditions": conditions, "combine": combine}}
+}
+
+func convertN8nSwitch(c *n8nNodeConverter) *NodeDef {
+	if mode := c.params.str("mode", "rules"); mode != "rules" {
+		return c.unsupported("switch mode %q is not supported; only rules are", mode)
+	}
+
+	var rules []interface{}
+	fallback := -1
+	rulesParam := c.params.object("rules")
+
+	if c.node.TypeVersion >= 3 {
+		for i, item := range n8nList(rulesParam, "values") {
+			conditionsObj, _ := item["conditions"].(map[string]interface{})
+			conditions, combine := c.conditions(fmt.Sprintf("rules.values[%d].conditions", i), conditionsObj, "")
+			if len(conditions) > 0 {
+				rules = append(rules, map[string]interface{}{"conditions": conditions, "combine": combine, "output": i})
+			}
+		}
+		options := c.params.object("options")
+		switch v := options["fallbackOutput"].(type) {
+		case float64:
+			fallback = int(v)
+		case string:
+			if v == "extra" {
+				fallback = len(n8nList(rulesParam, "values"))
+			}
+		}
+	} else {
+		dataType := c.params.str("dataType", "number")
+		value1, _ := c.params.get("value1")
+		for i, item := range n8nList(rulesParam, "rules") {
+			operation, _ := item["operation"].(string)
+			if operation == "" {
+				operation = "equal"
+			}
+			param := fmt.Sprintf("rules.rules[%d]", i)
+			cond := c.condition(param, value1, item["value2"], operation)
+			if cond == nil {
+				continue
+			}
+			if dataType == "boolean" {
+				if b, ok := cond["value"].(bool); ok {
+					cond["value"] = b
+				}
+			}
+			output := 0
+			if n, ok := item["output"].(float64); ok {
+				output = int(n)
+			}
+			rules = append(rules, map[string]interface{}{"conditions": []interface{}{cond}, "output": output})
+		}
+		fallback = int(c.params.number("fallbackOutput", -1))
+	}
+
+	params := map[string]interface{}{"rules": rules}
+	if fallback >= 0 {
+		params["fallbackOutput"] = fallback
+	}
+	return &NodeDef{Type: "switchNode", Params: params}
+}
+
+func convertN8nMerge(c *n8nNodeConverter) *NodeDef {
+	params := map[string]interface{}{}
+	switch mode := c.params.str("mode", "append"); mode {
+	case "append":
+	case "mergeByKey":
+		key1, key2 := c.params.str("propertyName1", ""), c.params.str("propertyName2", "")
+		params["key"] = key1
+		if key1 != key2 {
+			c.issue("propertyName2", "merging on different fields (%q, %q) is not supported; merging on %q", key1, key2, key1)
+		}
+	case "combine":
+		combination := c.params.str("combinationMode", "")
+		if combination == "" {
+			combination = c.params.str("combineBy", "combineByFields")
+		}
+		if combination != "mergeByFields" && combination != "combineByFields" {
+			c.issue("combinationMode", "combination %q is not supported; items are appended", combination)
+			break
+		}
+		fieldsParam := "mergeByFields"
+		fields := n8nList(c.params.object("mergeByFields"), "values")
+		if fields == nil {
+			fieldsParam = "fieldsToMatchString"
+			if s := c.params.str("fieldsToMatchString", ""); s != "" {
+				fields = []map[string]interface{}{{"field1": s, "field2": s}}
+			} else {
+				fieldsParam = "fields"
+				fields = n8nList(c.params.object("fields"), "values")
+			}
+		}
+		if len(fields) == 0 {
+			c.issue(fieldsParam, "no fields to merge on; items are appended")
+			break
+		}
+		field1, _ := fields[0]["field1"].(string)
+		field2, _ := fields[0]["field2"].(string)
+		params["key"] = field1
+		if field1 != field2 || len(fields) > 1 {
+			c.issue(fieldsParam, "only merging on a single shared field is supported; merging on %q", field1)
+		}
+		c.params.get("joinMode")
+		c.params.get("options")
+	default:
+		c.issue("mode", "mode %q is not supported; items are appended", mode)
+	}
+	return &NodeDef{Type: "mergeNode", Params: params}
+}
+
+func convertN8nSplitInBatches(c *n8nNodeConverter) *NodeDef {
+	batchSize := int(c.params.number("batchSize", 10))
+	return &NodeDef{Type: "splitInBatchesNode", Params: map[string]interface{}{"batchSize": batchSize}}
+}
+
+// n8nUnitSeconds are the seconds per unit of the Wait node's amount.
+var n8nUnitSeconds = map[string]float64{"seconds": 1, "minutes": 60, "hours": 3600, "days": 86400}
+
+func convertN8nWait(c *n8nNodeConverter) *NodeDef {
+	resume := c.params.str("resume", "timeInterval")
+	if resume != "timeInterval" {
+		c.issue("resume", "resuming on %q is not supported; waiting up to 1 second instead", resume)
+		c.params.useAll()
+		return &NodeDef{Type: "waitNode", Params: map[string]interface{}{"maxSeconds": 1}}
+	}
+	amount := c.params.number("amount", 1)
+	unit := c.params.str("unit", "hours")
+	seconds := int(amount * n8nUnitSeconds[unit])
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.issue("amount", "waitNode waits a random time of up to %d seconds rather than exactly that long", seconds)
+	return &NodeDef{Type: "waitNode", Params: map[string]interface{}{"maxSeconds": seconds}}
+}
+
+// n8nCodeOnly matches what n8n code may use that codeNode does not
+// provide: other nodes' data, workflow variables, modules and n8n helpers.
+var n8nCodeOnly = regexp.MustCompile(`\$\(|\$node\b|\$items\(|\$env\b|\$vars\b|\$workflow\b|\$execution\b|\bthis\.helpers\b|\brequire\(|\bawait\b`)
+
+func convertN8nCode(c *n8nNodeConverter) *NodeDef {
+	code := c.params.str("jsCode", "")
+	if code == "" {
+		code = c.params.str("functionCode", "")
+	}
+	if c.node.Type == "n8n-nodes-base.function" && code != "" {
+		// Function nodes see items as {json: ...}, like $input.all().
+		code = "items = $input.all();\n" + code
+	}
+	each := c.node.Type == "n8n-nodes-base.functionItem" || c.params.str("mode", "runOnceForAllItems") == "runOnceForEachItem"
+	if lang := c.params.str("language", "javaScript"); lang != "javaScript" {
+		c.issue("language", "%s code is not supported; port it to JavaScript", lang)
+		code = c.params.str("pythonCode", code)
+	} else if n8nCodeOnly.MatchString(code) {
+		c.issue("", "the code uses n8n features that codeNode does not provide, such as other nodes' data, $env or require; only items, item, $json and $input are available")
+	}
+	params := map[string]interface{}{"code": code}
+	if each {
+		params["mode"] = "each"
+	}
+	return &NodeDef{Type: "codeNode", Params: params}
+}
+
+func convertN8nOpenAI(c *n8nNodeConverter) *NodeDef {
+	resource := c.params.str("resource", "text")
+	if resource != "text" && resource != "chat" {
+		return c.unsupported("OpenAI resource %q is not supported", resource)
+	}
+	c.params.get("operation")
+
+	var system []string
+	var messages []map[string]interface{}
+	if prompt, ok := c.params.values["prompt"]; ok {
+		c.params.get("prompt")
+		switch prompt := prompt.(type) {
+		case string:
+			system = append(system, c.literal("prompt", prompt).(string))
+		case map[string]interface{}:
+			messages = n8nList(prompt, "messages")
+		}
+	}
+	if m := c.params.object("messages"); m != nil {
+		messages = append(messages, n8nList(m, "values")...)
+	}
+	var chat []interface{}
+	for i, message := range messages {
+		content, _ := message["content"].(string)
+		role, _ := message["role"].(string)
+		param := fmt.Sprintf("messages[%d]", i)
+		switch {
+		case role == "user" && content == n8nOpenAIPayload:
+			// The item, which openaiNode sends as the user prompt.
+		case role == "" || role == "system":
+			system = append(system, c.literal(param, content).(string))
+		case role == "user" || role == "assistant":
+			chat = append(chat, map[string]interface{}{"role": role, "content": c.literal(param, content)})
+		default:
+			c.issue(param, "%s messages are not supported", role)
+		}
+	}
+
+	params := map[string]interface{}{"systemPrompt": strings.Join(system, "\n\n")}
+	if len(chat) > 0 {
+		params["messages"] = chat
+	}
+	if model := c.params.str("model", ""); model != "" {
+		params["model"] = c.literal("model", model)
+	} else if model := c.params.object("modelId"); model != nil {
+		// A resource locator: {"__rl": true, "mode": "list", "value": "gpt-4o-mini"}.
+		if value, ok := model["value"].(string); ok && value != "" {
+			params["model"] = c.literal("modelId", value)
+		}
+	}
+	options := newN8nParams(c.params.object("options"))
+	if temperature, ok := options.get("temperature"); ok {
+		params["temperature"] = temperature
+	}
+	if maxTokens := options.number("maxTokens", 0); maxTokens > 0 {
+		params["maxTokens"] = int(maxTokens)
+	}
+	for _, option := range options.unused() {
+		c.issue("options."+option, "not supported; ignored")
+	}
+	c.issue("", "openaiNode expects the model to answer with a JSON object")
+	return &NodeDef{Type: "openaiNode", Params: params}
+}
@@ -0,0 +1,677 @@
+package framework
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// n8nOpenAIPayload is the user message of exported OpenAI nodes: the item,
+// which openaiNode appends to its system prompt.
+const n8nOpenAIPayload = "={{ JSON.stringify($json) }}"
+
+// Layout of exported nodes in n8n's editor: one column per step from the
+// start node, one row per node of a step.
+const (
+	n8nLayoutX     = 250
+	n8nLayoutY     = 300
+	n8nColumnWidth = 220
+	n8nRowHeight   = 180
+)
+
+// n8nExportWorkflow is the n8n workflow file ExportN8nJSON writes.
+type n8nExportWorkflow struct {
+	Name        string                                  `json:"name"`
+	Nodes       []n8nNode                               `json:"nodes"`
+	Connections map[string]map[string][][]n8nConnection `json:"connections"`
+	PinData     map[string][]n8nItem                    `json:"pinData,omitempty"`
+	Active      bool                                    `json:"active"`
+	Settings    map[string]interface{}                  `json:"settings"`
+}
+
+// ExportN8nJSON converts a workflow definition to an n8n workflow file named
+// name, the reverse of ParseN8nJSON. Node types are mapped to their n8n
+// equivalents, nodes are laid out from the start node to the right, and
+// Ports become numbered outputs. Nodes that kept their n8n type on import
+// are exported unchanged.
+//
+// Everything that could not be exported faithfully is returned as an issue.
+// Nodes without an n8n equivalent are exported as No Operation nodes whose
+// notes hold the original definition, and reported as unsupported.
+func ExportN8nJSON(def *WorkflowDef, name string) ([]byte, []N8nIssue, error) {
+	if def.NodeDefs == nil {
+		return nil, nil, errors.New("only workflow definitions with node definitions can be exported")
+	}
+
+	var issues []N8nIssue
+	positions := n8nLayout(def)
+	wf := n8nExportWorkflow{
+		Name:        name,
+		Nodes:       []n8nNode{},
+		Connections: map[string]map[string][][]n8nConnection{},
+		Settings:    map[string]interface{}{"executionOrder": "v1"},
+	}
+	outputs := map[string]map[int][]int{}
+	for _, nodeName := range def.Nodes {
+		nodeDef, ok := def.NodeDefs[nodeName]
+		if !ok {
+			return nil, nil, fmt.Errorf("node %q has no definition", nodeName)
+		}
+		e := &n8nNodeExporter{
+			node:   n8nNode{ID: n8nID(nodeName), Name: nodeName, TypeVersion: 1, Position: positions[nodeName]},
+			def:    nodeDef,
+			params: newN8nParams(nodeDef.Params),
+			issues: &issues,
+		}
+		e.export()
+		wf.Nodes = append(wf.Nodes, e.node)
+		if e.pinData != nil {
+			if wf.PinData == nil {
+				wf.PinData = map[string][]n8nItem{}
+			}
+			wf.PinData[nodeName] = e.pinData
+		}
+		if e.outputs != nil {
+			outputs[nodeName] = e.outputs
+		}
+	}
+
+	inputs := n8nInputIndexes(def, &issues)
+	for _, from := range def.Nodes {
+		var main [][]n8nConnection
+		connect := func(port int, targets []string) {
+			indexes, ok := outputs[from][port]
+			if !ok {
+				indexes = []int{port}
+			}
+			for _, index := range indexes {
+				for len(main) <= index {
+					main = append(main, []n8nConnection{})
+				}
+				for _, to := range targets {
+					main[index] = append(main[index], n8nConnection{Node: to, Type: "main", Index: inputs[[2]string{from, to}]})
+				}
+			}
+		}
+		connect(0, def.Connections[from])
+		ports := make([]int, 0, len(def.Ports[from]))
+		for port := range def.Ports[from] {
+			ports = append(ports, port)
+		}
+		sort.Ints(ports)
+		for _, port := range ports {
+			connect(port, def.Ports[from][port])
+		}
+		if len(main) > 0 {
+			wf.Connections[from] = map[string][][]n8nConnection{"main": main}
+		}
+		if to, ok := def.ErrorConnections[from]; ok {
+			issues = append(issues, N8nIssue{Node: from, Message: fmt.Sprintf("the error connection to %q is not exported; set the node to continue on error in n8n", to)})
+		}
+		if def.NodeDefs[from].Type == "switchNode" && len(def.Connections[from]) > 0 {
+			issues = append(issues, N8nIssue{Node: from, Message: "connections of a switchNode without ports are exported as its first output"})
+		}
+	}
+
+	data, err := json.MarshalIndent(wf, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, issues, nil
+}
+
+// n8nID returns a stable ID for an exported node, so exporting the same
+// definition twice gives the same file.
+func n8nID(parts ...string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(strings.Join(parts, "/"))).String()
+}
+
+// n8nChildren returns the children of a node, output 0 first.
+func n8nChildren(def *WorkflowDef, name string) []string {
+	children := append([]string{}, def.Connections[name]...)
+	ports := make([]int, 0, len(def.Ports[name]))
+	for port := range def.Ports[name] {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	for _, port := range ports {
+		children = append(children, def.Ports[name][port]...)
+	}
+	return children
+}
+
+// n8nLayout positions every node in the column of its distance from the
+// start node, or from the first node without inputs that reaches it. Loops
+// do not move nodes further right.
+func n8nLayout(def *WorkflowDef) map[string][]float64 {
+	incoming := map[string]bool{}
+	for _, name := range def.Nodes {
+		for _, child := range n8nChildren(def, name) {
+			incoming[child] = true
+		}
+	}
+	roots := []string{}
+	if _, ok := def.NodeDefs[def.StartNode()]; ok {
+		roots = append(roots, def.StartNode())
+	}
+	for _, name := range def.Nodes {
+		if !incoming[name] {
+			roots = append(roots, name)
+		}
+	}
+	roots = append(roots, def.Nodes...) // nodes only reachable from a loop
+
+	column := map[string]int{}
+	var rows [][]string
+	for _, root := range roots {
+		if _, ok := column[root]; ok {
+			continue
+		}
+		column[root] = 0
+		queue := []string{root}
+		for len(queue) > 0 {
+			name := queue[0]
+			queue = queue[1:]
+			for len(rows) <= column[name] {
+				rows = append(rows, nil)
+			}
+			rows[column[name]] = append(rows[column[name]], name)
+			for _, child := range n8nChildren(def, name) {
+				if _, ok := column[child]; !ok {
+					column[child] = column[name] + 1
+					queue = append(queue, child)
+				}
+			}
+		}
+	}
+
+	positions := map[string][]float64{}
+	for x, names := range rows {
+		for y, name := range names {
+			positions[name] = []float64{float64(n8nLayoutX + x*n8nColumnWidth), float64(n8nLayoutY + y*n8nRowHeight)}
+		}
+	}
+	return positions
+}
+
+// n8nInputIndexes numbers the parents of each Merge node, since n8n merges
+// items arriving on separate inputs. Other nodes have a single input 0.
+func n8nInputIndexes(def *WorkflowDef, issues *[]N8nIssue) map[[2]string]int {
+	indexes := map[[2]string]int{}
+	parents := map[string]int{}
+	for _, from := range def.Nodes {
+		for _, to := range n8nChildren(def, from) {
+			if nodeDef, ok := def.NodeDefs[to]; !ok || nodeDef.Type != "mergeNode" {
+				continue
+			}
+			if _, ok := indexes[[2]string{from, to}]; ok {
+				continue
+			}
+			indexes[[2]string{from, to}] = parents[to]
+			parents[to]++
+		}
+	}
+	for _, name := range def.Nodes {
+		if n := parents[name]; n > 2 {
+			*issues = append(*issues, N8nIssue{Node: name, Message: fmt.Sprintf("has %d parents; n8n's Merge node has 2 inputs", n)})
+		}
+	}
+	return indexes
+}
+
+// n8nExporters maps node types to the function that exports them.
+var n8nExporters = map[string]func(e *n8nNodeExporter){
+	"manualTrigger":      exportN8nManualTrigger,
+	"webhookTrigger":     exportN8nWebhook,
+	"httpRequest":        exportN8nHTTPRequest,
+	"setNode":            exportN8nSet,
+	"ifNode":             exportN8nIf,
+	"switchNode":         exportN8nSwitch,
+	"mergeNode":          exportN8nMerge,
+	"dedupeNode":         exportN8nDedupe,
+	"splitInBatchesNode": exportN8nSplitInBatches,
+	"waitNode":           exportN8nWait,
+	"codeNode":           exportN8nCode,
+	"openaiNode":         exportN8nOpenAI,
+	"llm":                exportN8nOpenAI,
+}
+
+// n8nNodeExporter exports a single node and collects its issues.
+type n8nNodeExporter struct {
+	node    n8nNode
+	def     *NodeDef
+	params  *n8nParams
+	pinData []n8nItem
+	// outputs maps ports of the node to n8n outputs, if they differ.
+	outputs map[int][]int
+	issues  *[]N8nIssue
+}
+
+func (e *n8nNodeExporter) export() {
+	if strings.Contains(e.def.Type, ".") {
+		// Kept from an n8n import: export it as it was.
+		e.node.Type = e.def.Type
+		e.node.Parameters = e.def.Params
+		if e.node.Parameters == nil {
+			e.node.Parameters = map[string]interface{}{}
+		}
+		return
+	}
+	export, ok := n8nExporters[e.def.Type]
+	if !ok {
+		notes, _ := json.MarshalIndent(e.def.Params, "", "  ")
+		e.node.Type = "n8n-nodes-base.noOp"
+		e.node.Parameters = map[string]interface{}{}
+		e.node.Notes = fmt.Sprintf("%s node with parameters:\n%s", e.def.Type, notes)
+		*e.issues = append(*e.issues, N8nIssue{
+			Node:        e.node.Name,
+			Unsupported: true,
+			Message:     fmt.Sprintf("node type %s has no n8n equivalent; exported as a No Operation node", e.def.Type),
+		})
+		return
+	}
+	export(e)
+	for _, param := range e.params.unused() {
+		e.issue(param, "not exported")
+	}
+}
+
+func (e *n8nNodeExporter) issue(param, format string, args ...interface{}) {
+	*e.issues = append(*e.issues, N8nIssue{Node: e.node.Name, Param: param, Message: fmt.Sprintf(format, args...)})
+}
+
+func (e *n8nNodeExporter) set(n8nType string, version float64, params map[string]interface{}) {
+	e.node.Type = "n8n-nodes-base." + n8nType
+	e.node.TypeVersion = version
+	e.node.Parameters = params
+}
+
+var n8nIdentifier = regexp.MustCompile(`^[A-Za-z_$][\w$]*$`)
+
+// n8nField returns the n8n expression for a field of the item.
+func n8nField(field string) string {
+	if n8nIdentifier.MatchString(field) {
+		return "={{ $json." + field + " }}"
+	}
+	return fmt.Sprintf("={{ $json[%q] }}", field)
+}
+
+// n8nValueType returns the n8n type of a value.
+func n8nValueType(v interface{}) string {
+	switch v.(type) {
+	case int, int64, float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "string"
+}
+
+func exportN8nManualTrigger(e *n8nNodeExporter) {
+	payload, _ := e.params.get("payload")
+	items, _ := payload.([]interface{})
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			e.pinData = append(e.pinData, n8nItem{JSON: m})
+		}
+	}
+	e.set("manualTrigger", 1, map[string]interface{}{})
+}
+
+func exportN8nWebhook(e *n8nNodeExporter) {
+	path := e.params.str("path", "")
+	if path == "" {
+		path = n8nID(e.node.Name, "webhook")
+	}
+	e.node.WebhookID = n8nID(e.node.Name, "webhookId")
+	e.set("webhook", 2, map[string]interface{}{
+		"path":         path,
+		"httpMethod":   e.params.str("httpMethod", "POST"),
+		"responseMode": "onReceived",
+		"options":      map[string]interface{}{},
+	})
+}
+
+func exportN8nHTTPRequest(e *n8nNodeExporter) {
+	params := map[string]interface{}{
+		"method":  e.params.str("method", "GET"),
+		"url":     e.params.str("url", ""),
+		"options": map[string]interface{}{},
+	}
+	if key := e.params.str("urlKey", ""); key != "" {
+		params["url"] = n8nField(key)
+	}
+	if key := e.params.str("methodKey", ""); key != "" {
+		params["method"] = n8nField(key)
+	}
+
+	headers, _ := e.params.get("headers")
+	if headers, ok := headers.(map[string]interface{}); ok && len(headers) > 0 {
+		names := make([]string, 0, len(headers))
+		for name := range headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var parameters []interface{}
+		for _, name := range names {
+			parameters = append(parameters, map[string]interface{}{"name": name, "value": fmt.Sprint(headers[name])})
+		}
+		params["sendHeaders"] = true
+		params["headerParameters"] = map[string]interface{}{"parameters": parameters}
+	}
+	if key := e.params.str("headersKey", ""); key != "" {
+		e.issue("headersKey", "headers taken from the item are not exported")
+	}
+
+	body, _ := e.params.get("body")
+	if key := e.params.str("bodyKey", ""); key != "" {
+		body = nil
+		params["sendBody"] = true
+		params["specifyBody"] = "json"
+		params["jsonBody"] = "={{ JSON.stringify(" + strings.TrimSuffix(strings.TrimPrefix(n8nField(key), "={{ "), " }}") + ") }}"
+	}
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			e.issue("body", "cannot be encoded as JSON: %v", err)
+		} else {
+			params["sendBody"] = true
+			params["specifyBody"] = "json"
+			params["jsonBody"] = string(data)
+		}
+	}
+	e.set("httpRequest", 4.2, params)
+}
+
+func exportN8nSet(e *n8nNodeExporter) {
+	setValues, _ := e.params.get("setValues")
+	values, _ := setValues.(map[string]interface{})
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	assignments := []interface{}{}
+	for _, name := range names {
+		assignments = append(assignments, map[string]interface{}{
+			"id":    n8nID(e.node.Name, "assignment", name),
+			"name":  name,
+			"value": values[name],
+			"type":  n8nValueType(values[name]),
+		})
+	}
+	params := map[string]interface{}{
+		"assignments":        map[string]interface{}{"assignments": assignments},
+		"includeOtherFields": true,
+		"options":            map[string]interface{}{},
+	}
+
+	removeKeys, _ := e.params.get("removeKeys")
+	if keys, ok := removeKeys.([]interface{}); ok && len(keys) > 0 {
+		var fields []string
+		for _, key := range keys {
+			fields = append(fields, fmt.Sprint(key))
+		}
+		params["include"] = "except"
+		params["excludeFields"] = strings.Join(fields, ", ")
+	}
+	e.set("set", 3.4, params)
+}
+
+// n8nOperators maps Condition operations to n8n's version 2 filter
+// operations. The operand type of equal and notEqual follows the value.
+var n8nOperators = map[string]struct{ typ, operation string }{
+	"":             {"", "equals"},
+	"equal":        {"", "equals"},
+	"notEqual":     {"", "notEquals"},
+	"contains":     {"string", "contains"},
+	"notContains":  {"string", "notContains"},
+	"startsWith":   {"string", "startsWith"},
+	"endsWith":     {"string", "endsWith"},
+	"regex":        {"string", "regex"},
+	"larger":       {"number", "gt"},
+	"largerEqual":  {"number", "gte"},
+	"smaller":      {"number", "lt"},
+	"smallerEqual": {"number", "lte"},
+	"isEmpty":      {"string", "empty"},
+	"isNotEmpty":   {"string", "notEmpty"},
+}
+
+// conditions converts Conditions to the filter of n8n's IF and Switch nodes.
+func (e *n8nNodeExporter) conditions(param string, conditions []interface{}, combine string) map[string]interface{} {
+	combinator := "and"
+	if combine == "any" {
+		combinator = "or"
+	}
+	var filters []interface{}
+	for i, item := range conditions {
+		cond, _ := item.(map[string]interface{})
+		field, _ := cond["field"].(string)
+		operation, _ := cond["operation"].(string)
+		op, ok := n8nOperators[operation]
+		if !ok {
+			e.issue(fmt.Sprintf("%s[%d]", param, i), "operation %q has no n8n equivalent; the condition is dropped", operation)
+			continue
+		}
+		typ := op.typ
+		if typ == "" {
+			typ = n8nValueType(cond["value"])
+		}
+		operator := map[string]interface{}{"type": typ, "operation": op.operation}
+		rightValue := cond["value"]
+		if op.operation == "empty" || op.operation == "notEmpty" {
+			operator["singleValue"] = true
+			rightValue = ""
+		}
+		filters = append(filters, map[string]interface{}{
+			"id":         n8nID(e.node.Name, param, fmt.Sprint(i)),
+			"leftValue":  n8nField(field),
+			"rightValue": rightValue,
+			"operator":   operator,
+		})
+	}
+	if filters == nil {
+		filters = []interface{}{}
+	}
+	return map[string]interface{}{
+		"options":    map[string]interface{}{"caseSensitive": true, "leftValue": "", "typeValidation": "loose"},
+		"conditions": filters,
+		"combinator": combinator,
+	}
+}
+
+func exportN8nIf(e *n8nNodeExporter) {
+	conditions, _ := e.params.get("conditions")
+	list, _ := conditions.([]interface{})
+	e.set("if", 2, map[string]interface{}{
+		"conditions": e.conditions("conditions", list, e.params.str("combine", "all")),
+		"options":    map[string]interface{}{},
+	})
+}
+
+// exportN8nSwitch exports a switchNode as a version 3 Switch, which has one
+// output per rule. Ports that several rules route to are connected to the
+// output of each of them.
+func exportN8nSwitch(e *n8nNodeExporter) {
+	type rule struct {
+		conditions []interface{}
+		combine    string
+		output     int
+	}
+	var rules []rule
+	rulesParam, _ := e.params.get("rules")
+	if list, ok := rulesParam.([]interface{}); ok && len(list) > 0 {
+		for _, item := range list {
+			r, _ := item.(map[string]interface{})
+			conditions, _ := r["conditions"].([]interface{})
+			combine, _ := r["combine"].(string)
+			output, _ := r["output"].(int)
+			rules = append(rules, rule{conditions, combine, output})
+		}
+	} else {
+		// One rule per branch in branch name order, as SwitchNode does.
+		conditionsParam, _ := e.params.get("conditions")
+		branches, _ := conditionsParam.(map[string]interface{})
+		names := make([]string, 0, len(branches))
+		for name := range branches {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			rules = append(rules, rule{conditions: []interface{}{branches[name]}, output: i})
+		}
+	}
+
+	e.outputs = map[int][]int{}
+	values := []interface{}{}
+	for i, r := range rules {
+		values = append(values, map[string]interface{}{
+			"conditions": e.conditions(fmt.Sprintf("rules[%d].conditions", i), r.conditions, r.combine),
+		})
+		e.outputs[r.output] = append(e.outputs[r.output], i)
+	}
+
+	options := map[string]interface{}{}
+	if fallback := int(e.params.number("fallbackOutput", -1)); fallback >= 0 {
+		if outputs, ok := e.outputs[fallback]; ok {
+			options["fallbackOutput"] = outputs[0]
+		} else {
+			options["fallbackOutput"] = "extra"
+			e.outputs[fallback] = []int{len(rules)}
+		}
+	}
+	e.set("switch", 3, map[string]interface{}{
+		"rules":   map[string]interface{}{"values": values},
+		"options": options,
+	})
+}
+
+func exportN8nMerge(e *n8nNodeExporter) {
+	key := e.params.str("key", "")
+	if key == "" {
+		e.set("merge", 3, map[string]interface{}{"mode": "append"})
+		return
+	}
+	e.set("merge", 3, map[string]interface{}{
+		"mode":                "combine",
+		"combineBy":           "combineByFields",
+		"fieldsToMatchString": key,
+		"joinMode":            "keepEverything",
+		"options":             map[string]interface{}{},
+	})
+}
+
+func exportN8nDedupe(e *n8nNodeExporter) {
+	e.set("removeDuplicates", 1, map[string]interface{}{
+		"compare": "selectedFields",
+		"fieldsToCompare": map[string]interface{}{
+			"fields": []interface{}{map[string]interface{}{"fieldName": e.params.str("key", "")}},
+		},
+		"options": map[string]interface{}{},
+	})
+}
+
+func exportN8nSplitInBatches(e *n8nNodeExporter) {
+	e.issue("", "n8n runs the following nodes once per batch; splitInBatchesNode emits one item holding each batch")
+	e.set("splitInBatches", 2, map[string]interface{}{
+		"batchSize": int(e.params.number("batchSize", 10)),
+		"options":   map[string]interface{}{},
+	})
+}
+
+func exportN8nWait(e *n8nNodeExporter) {
+	seconds := int(e.params.number("maxSeconds", 1))
+	e.issue("maxSeconds", "n8n waits exactly %d seconds rather than a random time of up to that long", seconds)
+	e.set("wait", 1.1, map[string]interface{}{
+		"resume": "timeInterval",
+		"amount": seconds,
+		"unit":   "seconds",
+	})
+}
+
+func exportN8nCode(e *n8nNodeExporter) {
+	code := e.params.str("code", "")
+	if function := e.params.str("function", ""); function != "" {
+		e.params.get("params")
+		code = "// Port the Go function " + function + " to JavaScript.\nreturn $input.all();"
+		e.issue("function", "the Go function %s cannot be exported; the Code node passes items through", function)
+	} else if code == "" {
+		code = "// Port the Go function of this node to JavaScript.\nreturn $input.all();"
+		e.issue("", "the Go function of a codeNode cannot be exported; the Code node passes items through")
+	} else if n8nCodeHelpers.MatchString(code) {
+		e.issue("code", "the dates and crypto helpers of codeNode are not available in n8n")
+	}
+	if _, ok := e.params.get("timeout"); ok {
+		e.issue("timeout", "n8n does not limit the code; not exported")
+	}
+	params := map[string]interface{}{"jsCode": code}
+	if e.params.str("mode", "all") == "each" {
+		params["mode"] = "runOnceForEachItem"
+	}
+	e.set("code", 2, params)
+}
+
+// n8nCodeHelpers matches uses of the helpers of codeNode.
+var n8nCodeHelpers = regexp.MustCompile(`\b(dates|crypto)\.`)
+
+func exportN8nOpenAI(e *n8nNodeExporter) {
+	messages := []interface{}{e.openAIMessage("systemPrompt", "system", e.params.str("systemPrompt", ""))}
+	for i, example := range e.params.list("examples") {
+		for _, m := range []struct{ key, role string }{{"input", "user"}, {"output", "assistant"}} {
+			content, ok := example[m.key].(string)
+			if !ok {
+				data, _ := json.Marshal(example[m.key])
+				content = string(data)
+			}
+			messages = append(messages, e.openAIMessage(fmt.Sprintf("examples[%d].%s", i, m.key), m.role, content))
+		}
+	}
+	for i, message := range e.params.list("messages") {
+		role, _ := message["role"].(string)
+		content, _ := message["content"].(string)
+		messages = append(messages, e.openAIMessage(fmt.Sprintf("messages[%d]", i), role, content))
+	}
+	if user := e.params.str("userPrompt", ""); user != "" {
+		messages = append(messages, e.openAIMessage("userPrompt", "user", user))
+	} else {
+		messages = append(messages, map[string]interface{}{"role": "user", "content": n8nOpenAIPayload})
+	}
+
+	model := e.params.str("model", "")
+	if model == "" {
+		e.issue("", "the model is chosen by the configured LLM client; set it on the n8n node")
+		model = "gpt-4o-mini"
+	}
+	options := map[string]interface{}{}
+	if temperature, ok := e.params.get("temperature"); ok {
+		options["temperature"] = temperature
+	}
+	if maxTokens := e.params.number("maxTokens", 0); maxTokens > 0 {
+		options["maxTokens"] = maxTokens
+	}
+	e.set("openAi", 1.1, map[string]interface{}{
+		"resource":  "chat",
+		"operation": "complete",
+		"model":     model,
+		"prompt":    map[string]interface{}{"messages": messages},
+		"options":   options,
+	})
+}
+
+// openAIMessage returns an n8n OpenAI message, reporting template actions,
+// which n8n would send verbatim.
+func (e *n8nNodeExporter) openAIMessage(param, role, content string) map[string]interface{} {
+	if strings.Contains(content, "{{") {
+		e.issue(param, "the prompt template is not converted to an n8n expression")
+	}
+	return map[string]interface{}{"role": role, "content": content}
+}
@@ -0,0 +1,320 @@
+package framework
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const exportWorkflowYAML = `
+start: trigger
+nodes:
+  trigger:
+    type: manualTrigger
+    payload:
+      - total: 250
+        status: new
+  bigOrder:
+    type: ifNode
+    combine: any
+    conditions:
+      - field: total
+        operation: larger
+        value: 100
+      - field: status
+        operation: isEmpty
+  route:
+    type: switchNode
+    fallbackOutput: 2
+    rules:
+      - output: 0
+        conditions:
+          - field: country
+            value: DE
+      - output: 0
+        conditions:
+          - field: country
+            value: AT
+      - output: 1
+        conditions:
+          - field: vip
+            value: true
+  priority:
+    type: setNode
+    setValues:
+      priority: high
+      score: 10
+    removeKeys: [status]
+  notify:
+    type: httpRequest
+    method: POST
+    url: https://example.com/notify
+    headers:
+      X-Token: abc
+    body:
+      text: big order
+  merge:
+    type: mergeNode
+    key: id
+  store:
+    type: dynamodbUpsert
+    tableNameKey: table
+connections:
+  trigger: [bigOrder]
+  priority: [notify, merge]
+  notify: [merge]
+  merge: [store]
+ports:
+  bigOrder:
+    0: [route]
+    1: [priority]
+  route:
+    0: [priority]
+    1: [notify]
+    2: [store]
+`
+
+func exportTestWorkflow(t *testing.T) (*WorkflowDef, map[string]interface{}, []N8nIssue) {
+	t.Helper()
+	def, err := LoadWorkflowDefFromYAMLString(exportWorkflowYAML)
+	if err != nil {
+		t.Fatalf("failed to load workflow: %v", err)
+	}
+	data, issues, err := ExportN8nJSON(def, "Orders")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var wf map[string]interface{}
+	if err := json.Unmarshal(data, &wf); err != nil {
+		t.Fatalf("export is not valid JSON: %v", err)
+	}
+	return def, wf, issues
+}
+
+func exportedNode(t *testing.T, wf map[string]interface{}, name string) map[string]interface{} {
+	t.Helper()
+	for _, n := range wf["nodes"].([]interface{}) {
+		node := n.(map[string]interface{})
+		if node["name"] == name {
+			return node
+		}
+	}
+	t.Fatalf("node %q not exported", name)
+	return nil
+}
+
+func TestExportN8nJSON(t *testing.T) {
+	_, wf, issues := exportTestWorkflow(t)
+
+	if wf["name"] != "Orders" {
+		t.Errorf("expected name Orders, got %v", wf["name"])
+	}
+	wantTypes := map[string]string{
+		"trigger":  "n8n-nodes-base.manualTrigger",
+		"bigOrder": "n8n-nodes-base.if",
+		"route":    "n8n-nodes-base.switch",
+		"priority": "n8n-nodes-base.set",
+		"notify":   "n8n-nodes-base.httpRequest",
+		"merge":    "n8n-nodes-base.merge",
+		"store":    "n8n-nodes-base.noOp",
+	}
+	ids := map[interface{}]bool{}
+	for name, typ := range wantTypes {
+		node := exportedNode(t, wf, name)
+		if node["type"] != typ {
+			t.Errorf("node %s: expected type %s, got %v", name, typ, node["type"])
+		}
+		if node["id"] == "" || ids[node["id"]] {
+			t.Errorf("node %s: expected a unique id, got %v", name, node["id"])
+		}
+		ids[node["id"]] = true
+	}
+
+	// Layout: one column per step from the start node.
+	positions := map[string][]interface{}{}
+	for name := range wantTypes {
+		positions[name] = exportedNode(t, wf, name)["position"].([]interface{})
+	}
+	if positions["trigger"][0].(float64) >= positions["bigOrder"][0].(float64) ||
+		positions["bigOrder"][0].(float64) >= positions["route"][0].(float64) {
+		t.Errorf("expected nodes to be laid out left to right, got %v", positions)
+	}
+	if reflect.DeepEqual(positions["route"], positions["priority"]) {
+		t.Errorf("expected nodes of the same step in separate rows, got %v", positions)
+	}
+
+	// The switch has one output per rule plus the extra fallback output;
+	// port 0 is routed to by rules 0 and 1.
+	connections := wf["connections"].(map[string]interface{})
+	route := connections["route"].(map[string]interface{})["main"].([]interface{})
+	if len(route) != 4 {
+		t.Fatalf("expected 4 switch outputs, got %v", route)
+	}
+	for i, want := range []string{"priority", "priority", "notify", "store"} {
+		targets := route[i].([]interface{})
+		if len(targets) != 1 || targets[0].(map[string]interface{})["node"] != want {
+			t.Errorf("switch output %d: expected %s, got %v", i, want, targets)
+		}
+	}
+	options := exportedNode(t, wf, "route")["parameters"].(map[string]interface{})["options"].(map[string]interface{})
+	if options["fallbackOutput"] != "extra" {
+		t.Errorf("expected the fallback on an extra output, got %v", options)
+	}
+
+	// The merge node receives its parents on separate inputs.
+	var mergeInputs []float64
+	for _, from := range []string{"priority", "notify"} {
+		for _, c := range connections[from].(map[string]interface{})["main"].([]interface{})[0].([]interface{}) {
+			if c := c.(map[string]interface{}); c["node"] == "merge" {
+				mergeInputs = append(mergeInputs, c["index"].(float64))
+			}
+		}
+	}
+	if !reflect.DeepEqual(mergeInputs, []float64{0, 1}) {
+		t.Errorf("expected merge inputs [0 1], got %v", mergeInputs)
+	}
+
+	pinData := wf["pinData"].(map[string]interface{})["trigger"].([]interface{})
+	if len(pinData) != 1 || pinData[0].(map[string]interface{})["json"].(map[string]interface{})["status"] != "new" {
+		t.Errorf("expected the payload as pinned data, got %v", pinData)
+	}
+
+	store := findIssue(issues, "store", "")
+	if store == nil || !store.Unsupported {
+		t.Errorf("expected dynamodbUpsert to be reported as unsupported, got %v", issues)
+	}
+	if notes, _ := exportedNode(t, wf, "store")["notes"].(string); !strings.Contains(notes, "tableNameKey") {
+		t.Errorf("expected the original definition in the notes, got %q", notes)
+	}
+}
+
+func TestExportN8nJSON_RoundTrip(t *testing.T) {
+	def, _, _ := exportTestWorkflow(t)
+	data, _, err := ExportN8nJSON(def, "Orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, _, err := ParseN8nJSON(data)
+	if err != nil {
+		t.Fatalf("failed to import the export: %v", err)
+	}
+
+	if imported.Start != "trigger" || !reflect.DeepEqual(imported.Nodes, def.Nodes) {
+		t.Errorf("expected start trigger and nodes %v, got %q and %v", def.Nodes, imported.Start, imported.Nodes)
+	}
+	if !reflect.DeepEqual(imported.Connections, def.Connections) {
+		t.Errorf("expected connections %v, got %v", def.Connections, imported.Connections)
+	}
+	if !reflect.DeepEqual(imported.Ports["bigOrder"], def.Ports["bigOrder"]) {
+		t.Errorf("expected if ports %v, got %v", def.Ports["bigOrder"], imported.Ports["bigOrder"])
+	}
+
+	wantParams := map[string]map[string]interface{}{
+		"trigger": {"payload": []interface{}{map[string]interface{}{"total": float64(250), "status": "new"}}},
+		"bigOrder": {"combine": "any", "conditions": []interface{}{
+			map[string]interface{}{"field": "total", "value": float64(100), "operation": "larger"},
+			map[string]interface{}{"field": "status", "operation": "isEmpty"},
+		}},
+		"priority": {"setValues": map[string]interface{}{"priority": "high", "score": float64(10)}, "removeKeys": []interface{}{"status"}},
+		"notify": {"method": "POST", "url": "https://example.com/notify",
+			"headers": map[string]interface{}{"X-Token": "abc"}, "body": map[string]interface{}{"text": "big order"}},
+		"merge": {"key": "id"},
+	}
+	for name, want := range wantParams {
+		if got := imported.NodeDefs[name]; got.Type != def.NodeDefs[name].Type || !reflect.DeepEqual(got.Params, want) {
+			t.Errorf("node %s: expected %s %v, got %s %v", name, def.NodeDefs[name].Type, want, got.Type, got.Params)
+		}
+	}
+}
+
+func TestExportN8nJSON_ListForm(t *testing.T) {
+	def := &WorkflowDef{Nodes: []string{"a", "b"}, Connections: map[string][]string{"a": {"b"}}}
+	if _, _, err := ExportN8nJSON(def, "list"); err == nil {
+		t.Error("expected an error for a definition without node definitions")
+	}
+}
+
+func TestExportN8nJSON_OpenAIRoundTrip(t *testing.T) {
+	def, err := LoadWorkflowDefFromYAMLString(`
+start: trigger
+nodes:
+  trigger:
+    type: manualTrigger
+  write:
+    type: openaiNode
+    systemPrompt: You write outreach emails.
+    model: gpt-4o
+    temperature: 0.2
+    maxTokens: 300
+    messages:
+      - role: user
+        content: Keep it short.
+    userPrompt: Write to {{.first_name}}.
+connections:
+  trigger: [write]
+`)
+	if err != nil {
+		t.Fatalf("failed to load workflow: %v", err)
+	}
+	data, issues, err := ExportN8nJSON(def, "Outreach")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if findIssue(issues, "write", "userPrompt") == nil {
+		t.Errorf("expected the template in the user prompt to be reported, got %v", issues)
+	}
+
+	imported, _, err := ParseN8nJSON(data)
+	if err != nil {
+		t.Fatalf("failed to import the export: %v", err)
+	}
+	want := map[string]interface{}{
+		"systemPrompt": "You write outreach emails.",
+		"model":        "gpt-4o",
+		"temperature":  0.2,
+		"maxTokens":    300,
+		"messages": []interface{}{
+			map[string]interface{}{"role": "user", "content": "Keep it short."},
+			map[string]interface{}{"role": "user", "content": "Write to {{.first_name}}."},
+		},
+	}
+	if got := imported.NodeDefs["write"].Params; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected parameters %v, got %v", want, got)
+	}
+}
+
+func TestExportN8nJSON_CodeRoundTrip(t *testing.T) {
+	def, err := LoadWorkflowDefFromYAMLString(`
+start: trigger
+nodes:
+  trigger:
+    type: manualTrigger
+  hash:
+    type: codeNode
+    mode: each
+    timeout: 2s
+    code: "return {id: $json.id, hash: crypto.sha256($json.id)};"
+connections:
+  trigger: [hash]
+`)
+	if err != nil {
+		t.Fatalf("failed to load workflow: %v", err)
+	}
+	data, issues, err := ExportN8nJSON(def, "Hashes")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if findIssue(issues, "hash", "code") == nil || findIssue(issues, "hash", "timeout") == nil {
+		t.Errorf("expected the helpers and the timeout to be reported, got %v", issues)
+	}
+
+	imported, _, err := ParseN8nJSON(data)
+	if err != nil {
+		t.Fatalf("failed to import the export: %v", err)
+	}
+	want := map[string]interface{}{"code": "return {id: $json.id, hash: crypto.sha256($json.id)};", "mode": "each"}
+	if got := imported.NodeDefs["hash"].Params; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected parameters %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,263 @@
+package framework
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const n8nOrderWorkflow = `{
+  "name": "Orders",
+  "nodes": [
+    {"name": "Webhook", "type": "n8n-nodes-base.webhook", "typeVersion": 1,
+     "parameters": {"path": "orders", "httpMethod": "POST"}},
+    {"name": "Big order?", "type": "n8n-nodes-base.if", "typeVersion": 2,
+     "parameters": {"conditions": {"combinator": "and", "conditions": [
+       {"leftValue": "={{ $json.total }}", "rightValue": 100, "operator": {"type": "number", "operation": "gt"}},
+       {"leftValue": "={{ $json[\"status\"] }}", "rightValue": "", "operator": {"type": "string", "operation": "notEmpty"}}
+     ]}}},
+    {"name": "Mark priority", "type": "n8n-nodes-base.set", "typeVersion": 3.4,
+     "parameters": {"includeOtherFields": true, "assignments": {"assignments": [
+       {"name": "priority", "value": "high", "type": "string"}
+     ]}}},
+    {"name": "Notify", "type": "n8n-nodes-base.httpRequest", "typeVersion": 4.2,
+     "parameters": {"method": "POST", "url": "https://example.com/notify",
+       "sendHeaders": true, "headerParameters": {"parameters": [{"name": "X-Token", "value": "abc"}]},
+       "sendQuery": true, "queryParameters": {"parameters": [{"name": "channel", "value": "ops"}]},
+       "sendBody": true, "specifyBody": "json", "jsonBody": "{\"text\": \"big order\"}",
+       "options": {"timeout": 5000}}},
+    {"name": "Slack", "type": "n8n-nodes-base.slack", "typeVersion": 2,
+     "parameters": {"channel": "#orders"}},
+    {"name": "Batches", "type": "n8n-nodes-base.splitInBatches", "typeVersion": 3,
+     "parameters": {"batchSize": 5}}
+  ],
+  "connections": {
+    "Webhook": {"main": [[{"node": "Big order?", "type": "main", "index": 0}]]},
+    "Big order?": {"main": [
+      [{"node": "Mark priority", "type": "main", "index": 0}],
+      [{"node": "Batches", "type": "main", "index": 0}]
+    ]},
+    "Mark priority": {"main": [[{"node": "Notify", "type": "main", "index": 0}, {"node": "Slack", "type": "main", "index": 0}]]}
+  }
+}`
+
+func findIssue(issues []N8nIssue, node, param string) *N8nIssue {
+	for i := range issues {
+		if issues[i].Node == node && issues[i].Param == param {
+			return &issues[i]
+		}
+	}
+	return nil
+}
+
+func TestParseN8nJSON(t *testing.T) {
+	def, issues, err := ParseN8nJSON([]byte(n8nOrderWorkflow))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wantNodes := []string{"Webhook", "Big order?", "Mark priority", "Notify", "Slack", "Batches"}
+	if !reflect.DeepEqual(def.Nodes, wantNodes) {
+		t.Errorf("expected nodes %v, got %v", wantNodes, def.Nodes)
+	}
+	if def.Start != "Webhook" {
+		t.Errorf("expected start Webhook, got %q", def.Start)
+	}
+
+	if !reflect.DeepEqual(def.Connections["Webhook"], []string{"Big order?"}) {
+		t.Errorf("unexpected connections from Webhook: %v", def.Connections["Webhook"])
+	}
+	if !reflect.DeepEqual(def.Connections["Mark priority"], []string{"Notify", "Slack"}) {
+		t.Errorf("unexpected connections from Mark priority: %v", def.Connections["Mark priority"])
+	}
+	if _, ok := def.Connections["Big order?"]; ok {
+		t.Error("expected IF outputs to be connected by port")
+	}
+	wantPorts := map[int][]string{0: {"Mark priority"}, 1: {"Batches"}}
+	if !reflect.DeepEqual(def.Ports["Big order?"], wantPorts) {
+		t.Errorf("expected ports %v, got %v", wantPorts, def.Ports["Big order?"])
+	}
+
+	if nd := def.NodeDefs["Webhook"]; nd.Type != "webhookTrigger" || nd.Params["path"] != "orders" || nd.Params["httpMethod"] != "POST" {
+		t.Errorf("unexpected webhook definition: %+v", nd)
+	}
+
+	ifDef := def.NodeDefs["Big order?"]
+	wantConditions := []interface{}{
+		map[string]interface{}{"field": "total", "value": float64(100), "operation": "larger"},
+		map[string]interface{}{"field": "status", "operation": "isNotEmpty"},
+	}
+	if ifDef.Type != "ifNode" || ifDef.Params["combine"] != "all" || !reflect.DeepEqual(ifDef.Params["conditions"], wantConditions) {
+		t.Errorf("unexpected if definition: %+v", ifDef)
+	}
+
+	setDef := def.NodeDefs["Mark priority"]
+	if setDef.Type != "setNode" || !reflect.DeepEqual(setDef.Params["setValues"], map[string]interface{}{"priority": "high"}) {
+		t.Errorf("unexpected set definition: %+v", setDef)
+	}
+
+	httpDef := def.NodeDefs["Notify"]
+	if httpDef.Type != "httpRequest" || httpDef.Params["method"] != "POST" ||
+		httpDef.Params["url"] != "https://example.com/notify?channel=ops" {
+		t.Errorf("unexpected http definition: %+v", httpDef)
+	}
+	if !reflect.DeepEqual(httpDef.Params["headers"], map[string]interface{}{"X-Token": "abc"}) {
+		t.Errorf("unexpected headers: %v", httpDef.Params["headers"])
+	}
+	if !reflect.DeepEqual(httpDef.Params["body"], map[string]interface{}{"text": "big order"}) {
+		t.Errorf("unexpected body: %v", httpDef.Params["body"])
+	}
+	if findIssue(issues, "Notify", "options") == nil {
+		t.Errorf("expected the unused options parameter to be reported, got %v", issues)
+	}
+
+	if nd := def.NodeDefs["Batches"]; nd.Type != "splitInBatchesNode" || nd.Params["batchSize"] != 5 {
+		t.Errorf("unexpected batch definition: %+v", nd)
+	}
+
+	slack := findIssue(issues, "Slack", "")
+	if slack == nil || !slack.Unsupported {
+		t.Fatalf("expected Slack to be reported as unsupported, got %v", issues)
+	}
+	if !strings.Contains(slack.String(), "n8n-nodes-base.slack") {
+		t.Errorf("expected the issue to name the n8n type, got %q", slack.String())
+	}
+	if !HasUnsupportedNodes(issues) {
+		t.Error("expected HasUnsupportedNodes to be true")
+	}
+	if nd := def.NodeDefs["Slack"]; nd.Type != "n8n-nodes-base.slack" || nd.Params["channel"] != "#orders" {
+		t.Errorf("expected the unsupported node to keep its type and parameters, got %+v", nd)
+	}
+}
+
+func TestParseN8nJSON_Switch(t *testing.T) {
+	def, issues, err := ParseN8nJSON([]byte(`{
+  "nodes": [
+    {"name": "Start", "type": "n8n-nodes-base.manualTrigger", "typeVersion": 1, "parameters": {}},
+    {"name": "Route", "type": "n8n-nodes-base.switch", "typeVersion": 1,
+     "parameters": {"dataType": "string", "value1": "={{$json.country}}", "fallbackOutput": 2,
+       "rules": {"rules": [{"operation": "equal", "value2": "DE", "output": 0}, {"value2": "FR", "output": 1}]}}},
+    {"name": "Germany", "type": "n8n-nodes-base.noOp", "typeVersion": 1, "parameters": {}},
+    {"name": "Rest", "type": "n8n-nodes-base.wait", "typeVersion": 1, "parameters": {"amount": 2, "unit": "minutes"}}
+  ],
+  "connections": {
+    "Start": {"main": [[{"node": "Route", "type": "main", "index": 0}]]},
+    "Route": {"main": [[{"node": "Germany", "type": "main", "index": 0}], [], [{"node": "Rest", "type": "main", "index": 0}]]}
+  }
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	route := def.NodeDefs["Route"]
+	wantRules := []interface{}{
+		map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"field": "country", "value": "DE", "operation": "equal"}}, "output": 0},
+		map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"field": "country", "value": "FR", "operation": "equal"}}, "output": 1},
+	}
+	if route.Type != "switchNode" || !reflect.DeepEqual(route.Params["rules"], wantRules) || route.Params["fallbackOutput"] != 2 {
+		t.Errorf("unexpected switch definition: %+v", route)
+	}
+	wantPorts := map[int][]string{0: {"Germany"}, 2: {"Rest"}}
+	if !reflect.DeepEqual(def.Ports["Route"], wantPorts) {
+		t.Errorf("expected ports %v, got %v", wantPorts, def.Ports["Route"])
+	}
+	if nd := def.NodeDefs["Rest"]; nd.Type != "waitNode" || nd.Params["maxSeconds"] != 120 {
+		t.Errorf("unexpected wait definition: %+v", nd)
+	}
+	if findIssue(issues, "Rest", "amount") == nil {
+		t.Errorf("expected the random wait to be reported, got %v", issues)
+	}
+}
+
+func TestParseN8nJSON_NoTrigger(t *testing.T) {
+	def, issues, err := ParseN8nJSON([]byte(`{
+  "nodes": [
+    {"name": "Merge", "type": "n8n-nodes-base.merge", "typeVersion": 1, "parameters": {"mode": "mergeByKey", "propertyName1": "id", "propertyName2": "id"}},
+    {"name": "Load", "type": "n8n-nodes-base.httpRequest", "typeVersion": 1, "parameters": {"url": "https://example.com/items"}}
+  ],
+  "connections": {
+    "Load": {"main": [[{"node": "Merge", "type": "main", "index": 0}]]}
+  }
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if def.Start != "Load" {
+		t.Errorf("expected the first node without inputs to be the start, got %q", def.Start)
+	}
+	if findIssue(issues, "", "") == nil {
+		t.Errorf("expected a workflow-level issue about the missing trigger, got %v", issues)
+	}
+	if nd := def.NodeDefs["Merge"]; nd.Type != "mergeNode" || nd.Params["key"] != "id" {
+		t.Errorf("unexpected merge definition: %+v", nd)
+	}
+	if nd := def.NodeDefs["Load"]; nd.Params["method"] != "GET" || nd.Params["url"] != "https://example.com/items" {
+		t.Errorf("unexpected http definition: %+v", nd)
+	}
+	if HasUnsupportedNodes(issues) {
+		t.Errorf("expected no unsupported nodes, got %v", issues)
+	}
+}
+
+func TestParseN8nJSON_Code(t *testing.T) {
+	def, issues, err := ParseN8nJSON([]byte(`{
+  "nodes": [
+    {"name": "Start", "type": "n8n-nodes-base.manualTrigger", "typeVersion": 1, "parameters": {}},
+    {"name": "Each", "type": "n8n-nodes-base.code", "typeVersion": 2,
+     "parameters": {"mode": "runOnceForEachItem", "jsCode": "return {json: {id: $json.id}};"}},
+    {"name": "Lookup", "type": "n8n-nodes-base.code", "typeVersion": 2,
+     "parameters": {"jsCode": "return $('Start').all();"}},
+    {"name": "Legacy", "type": "n8n-nodes-base.function", "typeVersion": 1,
+     "parameters": {"functionCode": "return items;"}}
+  ],
+  "connections": {
+    "Start": {"main": [[{"node": "Each", "type": "main", "index": 0}]]},
+    "Each": {"main": [[{"node": "Lookup", "type": "main", "index": 0}]]},
+    "Lookup": {"main": [[{"node": "Legacy", "type": "main", "index": 0}]]}
+  }
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := def.NodeDefs["Each"].Params; !reflect.DeepEqual(got, map[string]interface{}{"code": "return {json: {id: $json.id}};", "mode": "each"}) {
+		t.Errorf("unexpected code definition: %v", got)
+	}
+	if findIssue(issues, "Each", "") != nil || findIssue(issues, "Lookup", "") == nil {
+		t.Errorf("expected only the code reading another node to be reported, got %v", issues)
+	}
+	if got := def.NodeDefs["Legacy"].Params["code"]; got != "items = $input.all();\nreturn items;" {
+		t.Errorf("expected function code to see n8n items, got %q", got)
+	}
+}
+
+func TestParseN8nJSON_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{
+			name: "duplicate node",
+			json: `{"nodes": [{"name": "A", "type": "n8n-nodes-base.start"}, {"name": "A", "type": "n8n-nodes-base.start"}]}`,
+			want: "duplicate",
+		},
+		{
+			name: "unknown target",
+			json: `{"nodes": [{"name": "A", "type": "n8n-nodes-base.start"}], "connections": {"A": {"main": [[{"node": "B"}]]}}}`,
+			want: "unknown n8n node",
+		},
+		{
+			name: "invalid connections",
+			json: `{"connections": {"A": 1}}`,
+			want: "invalid connections",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := ParseN8nJSON([]byte(tt.json))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
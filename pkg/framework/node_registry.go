@@ -2,6 +2,8 @@ package framework
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 
 	"gopkg.in/yaml.v3"
 )
@@ -9,16 +11,127 @@ import (
 // NodeFactory is a function that creates a Node instance from a YAML node definition.
 type NodeFactory func(nodeDef *yaml.Node) (Node, error)
 
-// nodeFactories stores registered NodeFactory functions.
-var nodeFactories = make(map[string]NodeFactory)
+// NodeBinder is a Node that calls other nodes of its workflow, such as an
+// aiAgent using them as tools. BuildWorkflow passes it the nodes of the
+// workflow and their definitions; it fails if a node it calls is missing.
+type NodeBinder interface {
+	Node
+	BindNodes(nodes map[string]Node, defs map[string]*NodeDef) error
+}
 
-// RegisterNodeFactory registers a NodeFactory for a given node type.
-func RegisterNodeFactory(nodeType string, factory NodeFactory) {
-	nodeFactories[nodeType] = factory
+// FileNode is a Node whose parameters name files, such as the prompt
+// files of an openaiNode. BuildWorkflow passes it the directory of the
+// workflow definition, WorkflowDef.Dir, to read them from.
+type FileNode interface {
+	Node
+	ReadFiles(dir string) error
 }
 
-// CreateNode creates a Node instance based on the provided YAML node definition.
-func CreateNode(nodeDef *yaml.Node) (Node, error) {
+// Registry holds node types and the factories that build their nodes. It
+// is safe for concurrent use.
+//
+// DefaultRegistry holds the node types registered from init functions.
+// Tests can register fakes in a registry of their own, and a service can
+// build untrusted workflows with a Subset of the node types.
+type Registry struct {
+	mu    sync.RWMutex
+	types map[string]registeredNodeType
+}
+
+// registeredNodeType is a node type with its factory.
+type registeredNodeType struct {
+	info    NodeTypeInfo
+	factory NodeFactory
+}
+
+// DefaultRegistry is the registry of RegisterNodeFactory, RegisterNodeType
+// and RegisterNode, which internal/noderegistry fills with the built-in
+// node types.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{types: map[string]registeredNodeType{}}
+}
+
+// Register registers a NodeFactory for the node type described by info.
+// It fails if the type is already registered. The display name defaults
+// to the type.
+func (r *Registry) Register(info NodeTypeInfo, factory NodeFactory) error {
+	if info.Type == "" {
+		return fmt.Errorf("node type without name")
+	}
+	if factory == nil {
+		return fmt.Errorf("node type %s has no factory", info.Type)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.types[info.Type]; ok {
+		return fmt.Errorf("node type %s is already registered", info.Type)
+	}
+	r.types[info.Type] = registeredNodeType{info: normalizeNodeTypeInfo(info), factory: factory}
+	return nil
+}
+
+// Override registers a NodeFactory for the node type described by info,
+// replacing the type if it is registered.
+func (r *Registry) Override(info NodeTypeInfo, factory NodeFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[info.Type] = registeredNodeType{info: normalizeNodeTypeInfo(info), factory: factory}
+}
+
+// Lookup returns the description of a registered node type.
+func (r *Registry) Lookup(nodeType string) (NodeTypeInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	registered, ok := r.types[nodeType]
+	return registered.info, ok
+}
+
+// List returns the registered node types, sorted by type.
+func (r *Registry) List() []NodeTypeInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]NodeTypeInfo, 0, len(r.types))
+	for _, registered := range r.types {
+		list = append(list, registered.info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Type < list[j].Type })
+	return list
+}
+
+// Clone returns a registry with the node types of r, to which further
+// types can be added without changing r.
+func (r *Registry) Clone() *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	clone := NewRegistry()
+	for nodeType, registered := range r.types {
+		clone.types[nodeType] = registered
+	}
+	return clone
+}
+
+// Subset returns a registry with only the given node types of r. It fails
+// if one of them is not registered.
+func (r *Registry) Subset(nodeTypes ...string) (*Registry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	subset := NewRegistry()
+	for _, nodeType := range nodeTypes {
+		registered, ok := r.types[nodeType]
+		if !ok {
+			return nil, fmt.Errorf("unknown node type: %s", nodeType)
+		}
+		subset.types[nodeType] = registered
+	}
+	return subset, nil
+}
+
+// CreateNode creates a Node instance based on the provided YAML node
+// definition, with the factory of its type.
+func (r *Registry) CreateNode(nodeDef *yaml.Node) (Node, error) {
 	var nodeType struct {
 		Type string `yaml:"type"`
 	}
@@ -26,10 +139,160 @@ func CreateNode(nodeDef *yaml.Node) (Node, error) {
 		return nil, fmt.Errorf("failed to decode node type: %w", err)
 	}
 
-	factory, ok := nodeFactories[nodeType.Type]
+	r.mu.RLock()
+	registered, ok := r.types[nodeType.Type]
+	r.mu.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("unknown node type: %s", nodeType.Type)
 	}
 
-	return factory(nodeDef)
+	return registered.factory(nodeDef)
+}
+
+// normalizeNodeTypeInfo fills in the defaults of a node type description.
+func normalizeNodeTypeInfo(info NodeTypeInfo) NodeTypeInfo {
+	if info.DisplayName == "" {
+		info.DisplayName = info.Type
+	}
+	if info.Params == nil {
+		info.Params = map[string]interface{}{"type": "object"}
+	}
+	if info.Inputs == nil {
+		info.Inputs = []string{}
+	}
+	if info.Outputs == nil {
+		info.Outputs = []string{}
+	}
+	return info
+}
+
+// RegisterNodeFactory registers a NodeFactory in DefaultRegistry for a
+// given node type, which is described only by its name. Use
+// RegisterNodeType or RegisterNode to describe it.
+func RegisterNodeFactory(nodeType string, factory NodeFactory) {
+	RegisterNodeType(NodeTypeInfo{
+		Type:    nodeType,
+		Params:  map[string]interface{}{"type": "object"},
+		Inputs:  []string{MainPort},
+		Outputs: []string{MainPort},
+	}, factory)
+}
+
+// RegisterNodeType registers a NodeFactory in DefaultRegistry for the node
+// type described by info.
+//
+// Like RegisterFunction, it is meant to be called from init functions and
+// panics if the type is already registered.
+func RegisterNodeType(info NodeTypeInfo, factory NodeFactory) {
+	if err := DefaultRegistry.Register(info, factory); err != nil {
+		panic("framework: " + err.Error())
+	}
+}
+
+// HasNodeFactory reports whether a NodeFactory is registered for a node
+// type in DefaultRegistry.
+func HasNodeFactory(nodeType string) bool {
+	_, ok := DefaultRegistry.Lookup(nodeType)
+	return ok
+}
+
+// CreateNode creates a Node instance based on the provided YAML node
+// definition, with DefaultRegistry.
+func CreateNode(nodeDef *yaml.Node) (Node, error) {
+	return DefaultRegistry.CreateNode(nodeDef)
+}
+
+// BuildWorkflow instantiates every node of def through the factory of its
+// type in registry, or in DefaultRegistry if registry is nil.
+func BuildWorkflow(def *WorkflowDef, registry *Registry) (*Workflow, error) {
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+	if len(def.NodeDefs) == 0 {
+		return nil, fmt.Errorf("workflow definition has no node definitions")
+	}
+
+	nodes := make(map[string]Node, len(def.Nodes))
+	for _, name := range def.Nodes {
+		nodeDef, ok := def.NodeDefs[name]
+		if !ok {
+			return nil, fmt.Errorf("node %s has no definition", name)
+		}
+		var yamlNode yaml.Node
+		if err := yamlNode.Encode(nodeDef); err != nil {
+			return nil, fmt.Errorf("failed to encode node %s: %w", name, err)
+		}
+		node, err := registry.CreateNode(&yamlNode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create node %s: %w", name, err)
+		}
+		if fileNode, ok := node.(FileNode); ok {
+			if err := fileNode.ReadFiles(def.Dir); err != nil {
+				return nil, fmt.Errorf("failed to create node %s: %w", name, err)
+			}
+		}
+		nodes[name] = node
+	}
+	for from, targets := range def.Connections {
+		if _, ok := nodes[from]; !ok {
+			return nil, fmt.Errorf("connection from unknown node %s", from)
+		}
+		for _, to := range targets {
+			if _, ok := nodes[to]; !ok {
+				return nil, fmt.Errorf("connection from %s to unknown node %s", from, to)
+			}
+		}
+	}
+
+	for from, ports := range def.Ports {
+		node, ok := nodes[from]
+		if !ok {
+			return nil, fmt.Errorf("port connection from unknown node %s", from)
+		}
+		if _, ok := node.(PortNode); !ok {
+			return nil, fmt.Errorf("node %s has a single output and cannot be connected by port", from)
+		}
+		for port, targets := range ports {
+			if port < 0 {
+				return nil, fmt.Errorf("node %s: invalid output port %d", from, port)
+			}
+			for _, to := range targets {
+				if _, ok := nodes[to]; !ok {
+					return nil, fmt.Errorf("connection from %s output %d to unknown node %s", from, port, to)
+				}
+			}
+		}
+	}
+	for from, to := range def.ErrorConnections {
+		if _, ok := nodes[from]; !ok {
+			return nil, fmt.Errorf("error connection from unknown node %s", from)
+		}
+		if _, ok := nodes[to]; !ok {
+			return nil, fmt.Errorf("error connection from %s to unknown node %s", from, to)
+		}
+	}
+	for scope, budget := range map[string]Budget{"run": def.Budgets.Run, "workflow": def.Budgets.Workflow} {
+		if budget.MaxTokens < 0 || budget.MaxCost < 0 {
+			return nil, fmt.Errorf("%s budget must not be negative", scope)
+		}
+	}
+	for _, name := range def.Nodes {
+		if binder, ok := nodes[name].(NodeBinder); ok {
+			if err := binder.BindNodes(nodes, def.NodeDefs); err != nil {
+				return nil, fmt.Errorf("node %s: %w", name, err)
+			}
+		}
+	}
+	if def.Start != "" {
+		if _, ok := nodes[def.Start]; !ok {
+			return nil, fmt.Errorf("start node %s is not defined", def.Start)
+		}
+	}
+
+	return &Workflow{
+		Nodes:            nodes,
+		Connections:      def.Connections,
+		Ports:            def.Ports,
+		ErrorConnections: def.ErrorConnections,
+	}, nil
 }
@@ -0,0 +1,456 @@
+package framework
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	RegisterNodeFactory("testPorts", func(nodeDef *yaml.Node) (Node, error) {
+		return &mockPortNode{}, nil
+	})
+	RegisterNodeFactory("testPassthrough", func(nodeDef *yaml.Node) (Node, error) {
+		var temp struct {
+			Name string `yaml:"name"`
+		}
+		if err := nodeDef.Decode(&temp); err != nil {
+			return nil, err
+		}
+		return &mockNode{name: temp.Name}, nil
+	})
+	RegisterNodeFactory("testBinder", func(nodeDef *yaml.Node) (Node, error) {
+		var temp struct {
+			Target string `yaml:"target"`
+		}
+		if err := nodeDef.Decode(&temp); err != nil {
+			return nil, err
+		}
+		return &mockBinder{target: temp.Target}, nil
+	})
+	RegisterNodeFactory("testFile", func(nodeDef *yaml.Node) (Node, error) {
+		var temp struct {
+			File string `yaml:"file"`
+		}
+		if err := nodeDef.Decode(&temp); err != nil {
+			return nil, err
+		}
+		return &mockFileNode{file: temp.File}, nil
+	})
+}
+
+// mockFileNode is a FileNode that reads its file relative to the
+// definition.
+type mockFileNode struct {
+	mockNode
+	file    string
+	content string
+}
+
+func (n *mockFileNode) ReadFiles(dir string) error {
+	if dir == "" {
+		return fmt.Errorf("no directory to read %s from", n.file)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, n.file))
+	n.content = string(data)
+	return err
+}
+
+// mockBinder is a NodeBinder that calls its target node.
+type mockBinder struct {
+	mockNode
+	target string
+	bound  Node
+	def    *NodeDef
+}
+
+func (n *mockBinder) BindNodes(nodes map[string]Node, defs map[string]*NodeDef) error {
+	node, ok := nodes[n.target]
+	if !ok {
+		return fmt.Errorf("unknown node %s", n.target)
+	}
+	n.bound, n.def = node, defs[n.target]
+	return nil
+}
+
+func TestBuildWorkflow(t *testing.T) {
+	def, err := LoadWorkflowDefFromYAMLString(`
+nodes:
+  first:
+    type: testPassthrough
+    name: one
+  second:
+    type: testPassthrough
+    name: two
+connections:
+  first: [second]
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wf, err := BuildWorkflow(def, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(wf.Nodes) != 2 {
+		t.Fatalf("expected 2 nodes, got %d", len(wf.Nodes))
+	}
+	if wf.Nodes["second"].(*mockNode).name != "two" {
+		t.Errorf("expected factory parameters to be decoded, got %+v", wf.Nodes["second"])
+	}
+	if wf.Connections["first"][0] != "second" {
+		t.Errorf("expected connection first -> second, got %v", wf.Connections)
+	}
+}
+
+func TestBuildWorkflow_Ports(t *testing.T) {
+	def, err := LoadWorkflowDefFromYAMLString(`
+start: split
+nodes:
+  split:
+    type: testPorts
+  even:
+    type: testPassthrough
+  odd:
+    type: testPassthrough
+ports:
+  split:
+    0: [even]
+    1: [odd]
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if def.StartNode() != "split" {
+		t.Errorf("expected start node split, got %s", def.StartNode())
+	}
+
+	wf, err := BuildWorkflow(def, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wf.Ports["split"][1][0] != "odd" {
+		t.Errorf("expected output 1 of split to connect to odd, got %v", wf.Ports)
+	}
+
+	out, err := yaml.Marshal(def)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	roundTrip, err := LoadWorkflowDefFromYAMLString(string(out))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if roundTrip.Start != "split" || roundTrip.Ports["split"][0][0] != "even" {
+		t.Errorf("start and ports were not preserved:\n%s", out)
+	}
+}
+
+func TestBuildWorkflow_ErrorConnections(t *testing.T) {
+	def, err := LoadWorkflowDefFromYAMLString(`
+nodes:
+  first:
+    type: testPassthrough
+  handler:
+    type: testPassthrough
+errorConnections:
+  first: handler
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wf, err := BuildWorkflow(def, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wf.ErrorConnections["first"] != "handler" {
+		t.Errorf("expected first to route errors to handler, got %v", wf.ErrorConnections)
+	}
+
+	out, err := yaml.Marshal(def)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	roundTrip, err := LoadWorkflowDefFromYAMLString(string(out))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if roundTrip.ErrorConnections["first"] != "handler" {
+		t.Errorf("error connections were not preserved:\n%s", out)
+	}
+}
+
+func TestBuildWorkflow_BindNodes(t *testing.T) {
+	def, err := LoadWorkflowDefFromYAMLString(`
+start: agent
+nodes:
+  agent:
+    type: testBinder
+    target: tool
+  tool:
+    type: testPassthrough
+    name: lookup
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	wf, err := BuildWorkflow(def, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	binder := wf.Nodes["agent"].(*mockBinder)
+	if binder.bound != wf.Nodes["tool"] {
+		t.Errorf("expected the agent to be bound to the tool node, got %+v", binder.bound)
+	}
+	if binder.def == nil || binder.def.Params["name"] != "lookup" {
+		t.Errorf("expected the definition of the tool node, got %+v", binder.def)
+	}
+}
+
+func TestBuildWorkflow_ReadFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "workflow.yaml")
+	definition := "nodes:\n  prompt:\n    type: testFile\n    file: prompt.txt\n"
+	if err := os.WriteFile(path, []byte(definition), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "prompt.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	def, err := LoadFromYAML(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wf, err := BuildWorkflow(def, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := wf.Nodes["prompt"].(*mockFileNode).content; got != "hello" {
+		t.Errorf("expected the file next to the definition, got %q", got)
+	}
+
+	// A definition held in memory has no directory to read files from.
+	def, err = LoadWorkflowDefFromYAMLString(definition)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := BuildWorkflow(def, nil); err == nil {
+		t.Error("expected an error for a file without a definition directory")
+	}
+}
+
+func TestBuildWorkflow_Errors(t *testing.T) {
+	tests := map[string]string{
+		"unknown type": `
+nodes:
+  first:
+    type: doesNotExist
+`,
+		"unknown connection target": `
+nodes:
+  first:
+    type: testPassthrough
+connections:
+  first: [missing]
+`,
+		"no node definitions": `
+nodes: [first]
+`,
+		"ports on a single-output node": `
+nodes:
+  first:
+    type: testPassthrough
+  second:
+    type: testPassthrough
+ports:
+  first:
+    1: [second]
+`,
+		"unknown port target": `
+nodes:
+  first:
+    type: testPorts
+ports:
+  first:
+    1: [missing]
+`,
+		"unknown error connection target": `
+nodes:
+  first:
+    type: testPassthrough
+errorConnections:
+  first: missing
+`,
+		"unknown start node": `
+start: missing
+nodes:
+  first:
+    type: testPassthrough
+`,
+		"unknown bound node": `
+nodes:
+  agent:
+    type: testBinder
+    target: missing
+`,
+		"negative budget": `
+nodes:
+  first:
+    type: testPassthrough
+budgets:
+  run:
+    maxTokens: -1
+`,
+	}
+	for name, definition := range tests {
+		t.Run(name, func(t *testing.T) {
+			def, err := LoadWorkflowDefFromYAMLString(definition)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := BuildWorkflow(def, nil); err == nil {
+				t.Fatal("expected an error, but got nil")
+			}
+		})
+	}
+}
+
+// namedFactory builds mockNodes called name.
+func namedFactory(name string) NodeFactory {
+	return func(nodeDef *yaml.Node) (Node, error) {
+		return &mockNode{name: name}, nil
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.Register(NodeTypeInfo{Type: "fake"}, namedFactory("first")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := registry.Register(NodeTypeInfo{Type: "fake"}, namedFactory("second")); err == nil || err.Error() != "node type fake is already registered" {
+		t.Errorf("expected an error for a duplicate type, got %v", err)
+	}
+	if err := registry.Register(NodeTypeInfo{}, namedFactory("nameless")); err == nil {
+		t.Error("expected an error for a type without name")
+	}
+	if err := registry.Register(NodeTypeInfo{Type: "nil"}, nil); err == nil {
+		t.Error("expected an error for a type without factory")
+	}
+
+	create := func(r *Registry, nodeType string) (string, error) {
+		var nodeDef yaml.Node
+		if err := nodeDef.Encode(map[string]string{"type": nodeType}); err != nil {
+			t.Fatal(err)
+		}
+		node, err := r.CreateNode(&nodeDef)
+		if err != nil {
+			return "", err
+		}
+		return node.(*mockNode).name, nil
+	}
+	if name, err := create(registry, "fake"); err != nil || name != "first" {
+		t.Errorf("expected the registered factory, got %q, %v", name, err)
+	}
+	registry.Override(NodeTypeInfo{Type: "fake", DisplayName: "Fake"}, namedFactory("override"))
+	if name, _ := create(registry, "fake"); name != "override" {
+		t.Errorf("expected the overriding factory, got %q", name)
+	}
+	if info, ok := registry.Lookup("fake"); !ok || info.DisplayName != "Fake" {
+		t.Errorf("expected the overriding description, got %+v", info)
+	}
+
+	// The default registry does not see the types of other registries, nor
+	// they those of the default one.
+	if _, ok := LookupNodeType("fake"); ok {
+		t.Error("expected fake not to leak into the default registry")
+	}
+	if _, err := create(registry, "testPassthrough"); err == nil || err.Error() != "unknown node type: testPassthrough" {
+		t.Errorf("expected an unknown node type, got %v", err)
+	}
+
+	clone := registry.Clone()
+	clone.Override(NodeTypeInfo{Type: "fake"}, namedFactory("clone"))
+	if err := clone.Register(NodeTypeInfo{Type: "extra"}, namedFactory("extra")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if name, _ := create(registry, "fake"); name != "override" {
+		t.Errorf("expected the clone not to change its origin, got %q", name)
+	}
+	if list := registry.List(); len(list) != 1 {
+		t.Errorf("expected one type in the origin, got %+v", list)
+	}
+	if list := clone.List(); len(list) != 2 || list[0].Type != "extra" || list[1].Type != "fake" {
+		t.Errorf("expected the sorted types of the clone, got %+v", list)
+	}
+}
+
+func TestRegistry_Subset(t *testing.T) {
+	if _, err := DefaultRegistry.Subset("testPassthrough", "nope"); err == nil || err.Error() != "unknown node type: nope" {
+		t.Errorf("expected an error for an unknown type, got %v", err)
+	}
+	restricted, err := DefaultRegistry.Subset("testPassthrough")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	def, err := LoadWorkflowDefFromYAMLString(`
+nodes:
+  first:
+    type: testPassthrough
+  second:
+    type: testPorts
+connections:
+  first: [second]
+`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := BuildWorkflow(def, nil); err != nil {
+		t.Fatalf("unexpected error with the default registry: %v", err)
+	}
+	if _, err := BuildWorkflow(def, restricted); err == nil || !strings.Contains(err.Error(), "unknown node type: testPorts") {
+		t.Errorf("expected the restricted registry to reject testPorts, got %v", err)
+	}
+}
+
+func TestRegistry_Concurrent(t *testing.T) {
+	registry := NewRegistry()
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Every type is registered twice; one of the two fails.
+			errs <- registry.Register(NodeTypeInfo{Type: fmt.Sprintf("type%d", i/2)}, namedFactory("concurrent"))
+			registry.List()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	var failed int
+	for err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed != 10 || len(registry.List()) != 10 {
+		t.Errorf("expected 10 types and 10 duplicates, got %d types and %d errors", len(registry.List()), failed)
+	}
+}
+
+func TestRegisterNodeType_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a duplicate type")
+		}
+	}()
+	RegisterNodeFactory("testPassthrough", namedFactory("duplicate"))
+}
@@ -0,0 +1,220 @@
+package framework
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Categories of node types.
+const (
+	CategoryTrigger     = "trigger"
+	CategoryFlow        = "flow"
+	CategoryTransform   = "transform"
+	CategoryAI          = "ai"
+	CategoryData        = "data"
+	CategoryIntegration = "integration"
+	CategoryPlugin      = "plugin"
+)
+
+// Credential types that the parameters of nodes select by name.
+const (
+	// CredentialAWSProfile is an AWS profile, selected by awsProfile.
+	CredentialAWSProfile = "awsProfile"
+	// CredentialDatabase is a SQL database, selected by database.
+	CredentialDatabase = "database"
+	// CredentialLLMProvider is an LLM provider, selected by provider.
+	CredentialLLMProvider = "llmProvider"
+)
+
+// MainPort is the label of the single input or output of a node.
+const MainPort = "main"
+
+// NodeTypeInfo describes a node type: what it is for, the parameters its
+// nodes take and how they are connected. Editors and generated
+// documentation use it, and GET /api/v1/node-types lists it.
+type NodeTypeInfo struct {
+	Type        string `json:"type"`
+	DisplayName string `json:"displayName"`
+	Category    string `json:"category"`
+	Description string `json:"description,omitempty"`
+	// Params is the JSON schema of the parameters of a node, besides its
+	// type.
+	Params map[string]interface{} `json:"params"`
+	// Inputs and Outputs label the input and output ports, in port order.
+	// Triggers have no inputs.
+	Inputs  []string `json:"inputs"`
+	Outputs []string `json:"outputs"`
+	// DynamicOutputs is set when the outputs of a node depend on its
+	// parameters, like the rules of a switchNode.
+	DynamicOutputs bool `json:"dynamicOutputs,omitempty"`
+	// Credentials are the credential types the parameters select.
+	Credentials []string `json:"credentials,omitempty"`
+}
+
+// RegisterNode registers a node type in DefaultRegistry whose nodes are
+// built from their parameters decoded into P, see NodeTypeOf. Like
+// RegisterNodeType, it panics if the type is already registered.
+func RegisterNode[P any](info NodeTypeInfo, build func(params P) (Node, error)) {
+	RegisterNodeType(NodeTypeOf(info, build))
+}
+
+// NodeTypeOf returns the description and factory of a node type whose
+// nodes are built from their parameters decoded into P, for
+// Registry.Register. The parameter schema of info, unless set, is derived
+// from P: fields are named by their yaml tags, a field tagged
+// `required:"true"` must be given, and `doc:"..."`, `enum:"a,b"` and
+// `default:"..."` document it.
+func NodeTypeOf[P any](info NodeTypeInfo, build func(params P) (Node, error)) (NodeTypeInfo, NodeFactory) {
+	if info.Params == nil {
+		info.Params = ParamsSchema(reflect.TypeOf((*P)(nil)).Elem())
+	}
+	return info, func(nodeDef *yaml.Node) (Node, error) {
+		var params P
+		if err := nodeDef.Decode(&params); err != nil {
+			return nil, err
+		}
+		return build(params)
+	}
+}
+
+// ParamsSchema returns the JSON schema of the parameters decoded into the
+// struct type t, see RegisterNode.
+func ParamsSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return map[string]interface{}{"type": "object"}
+	}
+	return typeSchema(t)
+}
+
+// FunctionParamsSchema returns the JSON schema of parameters described as
+// a list, like those of plugin node types.
+func FunctionParamsSchema(params []FunctionParam) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	for _, param := range params {
+		schema := map[string]interface{}{}
+		if param.Type != "" {
+			schema["type"] = param.Type
+		}
+		if param.Description != "" {
+			schema["description"] = param.Description
+		}
+		properties[param.Name] = schema
+		if param.Required {
+			required = append(required, param.Name)
+		}
+	}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// ListNodeTypes returns the node types of DefaultRegistry, sorted by
+// type.
+func ListNodeTypes() []NodeTypeInfo {
+	return DefaultRegistry.List()
+}
+
+// LookupNodeType returns the description of a node type of
+// DefaultRegistry.
+func LookupNodeType(nodeType string) (NodeTypeInfo, bool) {
+	return DefaultRegistry.Lookup(nodeType)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// typeSchema returns the JSON schema of values decoded into t.
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if t == durationType {
+		return map[string]interface{}{"type": "string", "format": "duration"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Interface:
+		return map[string]interface{}{}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		var required []string
+		structProperties(t, properties, &required)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]interface{}{"type": jsonType(t)}
+}
+
+// structProperties adds the schemas of the fields of t to properties,
+// including those of inlined structs.
+func structProperties(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if field.Anonymous && strings.Contains(options, "inline") {
+			inlined := field.Type
+			if inlined.Kind() == reflect.Ptr {
+				inlined = inlined.Elem()
+			}
+			structProperties(inlined, properties, required)
+			continue
+		}
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		schema := typeSchema(field.Type)
+		if doc := field.Tag.Get("doc"); doc != "" {
+			schema["description"] = doc
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			schema["enum"] = strings.Split(enum, ",")
+		}
+		if value, ok := field.Tag.Lookup("default"); ok {
+			schema["default"] = defaultValue(field.Type, value)
+		}
+		if field.Tag.Get("required") == "true" {
+			*required = append(*required, name)
+		}
+		properties[name] = schema
+	}
+}
+
+// defaultValue converts the default tag of a field to the JSON type of the
+// field.
+func defaultValue(t reflect.Type, value string) interface{} {
+	if t == durationType {
+		return value
+	}
+	switch jsonType(t) {
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case "integer":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
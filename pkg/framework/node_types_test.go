@@ -0,0 +1,123 @@
+package framework
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// greetParams are the parameters of the test node type testGreet.
+type greetParams struct {
+	greetTarget `yaml:",inline"`
+	Greeting    string            `yaml:"greeting" required:"true" doc:"Greeting to add."`
+	Mode        string            `yaml:"mode" enum:"short,long" default:"short"`
+	Repeat      int               `yaml:"repeat" default:"1"`
+	Delay       time.Duration     `yaml:"delay"`
+	Tags        []string          `yaml:"tags"`
+	Labels      map[string]string `yaml:"labels"`
+	Extra       interface{}       `yaml:"extra"`
+	Ignored     string            `yaml:"-"`
+}
+
+type greetTarget struct {
+	Field string `yaml:"field"`
+}
+
+func init() {
+	RegisterNode(NodeTypeInfo{Type: "testGreet", Category: CategoryTransform, Inputs: []string{MainPort}, Outputs: []string{MainPort}}, func(p greetParams) (Node, error) {
+		return &mockNode{name: p.Greeting + " " + p.Field}, nil
+	})
+}
+
+func TestRegisterNode(t *testing.T) {
+	info, ok := LookupNodeType("testGreet")
+	if !ok {
+		t.Fatal("testGreet is not registered")
+	}
+	if info.DisplayName != "testGreet" {
+		t.Errorf("expected the type as display name, got %q", info.DisplayName)
+	}
+	want := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"field":    map[string]interface{}{"type": "string"},
+			"greeting": map[string]interface{}{"type": "string", "description": "Greeting to add."},
+			"mode":     map[string]interface{}{"type": "string", "enum": []string{"short", "long"}, "default": "short"},
+			"repeat":   map[string]interface{}{"type": "integer", "default": int64(1)},
+			"delay":    map[string]interface{}{"type": "string", "format": "duration"},
+			"tags":     map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"labels":   map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}},
+			"extra":    map[string]interface{}{},
+		},
+		"required": []string{"greeting"},
+	}
+	if !reflect.DeepEqual(info.Params, want) {
+		t.Errorf("unexpected params schema:\n got %v\nwant %v", info.Params, want)
+	}
+
+	var nodeDef yaml.Node
+	if err := yaml.Unmarshal([]byte("type: testGreet\ngreeting: hello\nfield: name\n"), &nodeDef); err != nil {
+		t.Fatal(err)
+	}
+	node, err := CreateNode(&nodeDef)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := node.(*mockNode).name; got != "hello name" {
+		t.Errorf("expected the decoded parameters, got %q", got)
+	}
+}
+
+func TestRegisterNodeFactoryInfo(t *testing.T) {
+	info, ok := LookupNodeType("testPorts")
+	if !ok {
+		t.Fatal("testPorts is not registered")
+	}
+	want := NodeTypeInfo{
+		Type:        "testPorts",
+		DisplayName: "testPorts",
+		Params:      map[string]interface{}{"type": "object"},
+		Inputs:      []string{MainPort},
+		Outputs:     []string{MainPort},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if _, ok := LookupNodeType("nope"); ok {
+		t.Error("expected an unknown type not to be found")
+	}
+}
+
+func TestListNodeTypes(t *testing.T) {
+	list := ListNodeTypes()
+	var found bool
+	for i, info := range list {
+		if i > 0 && list[i-1].Type >= info.Type {
+			t.Errorf("node types not sorted: %s before %s", list[i-1].Type, info.Type)
+		}
+		found = found || info.Type == "testGreet"
+	}
+	if !found {
+		t.Errorf("expected testGreet in %v", list)
+	}
+}
+
+func TestPluginNodeTypeInfo(t *testing.T) {
+	info := PluginNodeType{Type: "reverse", Description: "Reverses a field.", Params: []FunctionParam{
+		{Name: "field", Type: "string", Required: true, Description: "Field to reverse."},
+		{Name: "times", Type: "integer"},
+	}}.Info()
+	want := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"field": map[string]interface{}{"type": "string", "description": "Field to reverse."},
+			"times": map[string]interface{}{"type": "integer"},
+		},
+		"required": []string{"field"},
+	}
+	if info.Category != CategoryPlugin || info.Description != "Reverses a field." || !reflect.DeepEqual(info.Params, want) {
+		t.Errorf("unexpected info %+v", info)
+	}
+}
@@ -0,0 +1,309 @@
+package framework
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// PluginProtocolVersion is the version of the plugin protocol that
+// plugins report in their handshake.
+//
+// Plugins are programs that implement node types outside this module. The
+// engine starts a plugin once and talks JSON-RPC 2.0 with it over its
+// standard input and output, one JSON object per line. Its standard error
+// is passed through to the engine's.
+//
+// The "describe" request is the handshake; its result lists the node types
+// of the plugin with their parameters:
+//
+//	{"protocolVersion": 1, "nodes": [{"type": "reverse", "description": "...",
+//	  "params": [{"name": "field", "type": "string", "required": true}]}]}
+//
+// The "execute" request runs a node on a batch of records. Its params are
+// a PluginRequest, and its result the output records, {"items": [...]}. A
+// failing node answers with an error, {"code": -32000, "message": "..."}.
+//
+// ServePlugin implements the plugin side of the protocol for Go plugins.
+const PluginProtocolVersion = 1
+
+// PluginNodeType describes a node type that a plugin implements.
+type PluginNodeType struct {
+	Type        string          `json:"type"`
+	Description string          `json:"description,omitempty"`
+	Params      []FunctionParam `json:"params,omitempty"`
+}
+
+// Info describes the node type for ListNodeTypes.
+func (t PluginNodeType) Info() NodeTypeInfo {
+	return NodeTypeInfo{
+		Type:        t.Type,
+		DisplayName: t.Type,
+		Category:    CategoryPlugin,
+		Description: t.Description,
+		Params:      FunctionParamsSchema(t.Params),
+		Inputs:      []string{MainPort},
+		Outputs:     []string{MainPort},
+	}
+}
+
+// PluginRequest is an execution of a plugin node: its type and parameters,
+// the records and the names of the workflow and node.
+type PluginRequest struct {
+	Type     string                   `json:"type"`
+	Config   map[string]interface{}   `json:"config"`
+	Items    []map[string]interface{} `json:"items"`
+	Workflow string                   `json:"workflow,omitempty"`
+	Node     string                   `json:"node,omitempty"`
+}
+
+// pluginDescription is the result of the describe handshake.
+type pluginDescription struct {
+	ProtocolVersion int              `json:"protocolVersion"`
+	Nodes           []PluginNodeType `json:"nodes"`
+}
+
+// pluginResult is the result of an execute request.
+type pluginResult struct {
+	Items []map[string]interface{} `json:"items"`
+}
+
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int         `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// JSON-RPC error codes of plugins.
+const (
+	rpcMethodNotFound = -32601
+	rpcNodeFailed     = -32000
+)
+
+// pluginHandshakeTimeout limits how long a plugin may take to start and
+// describe itself.
+var pluginHandshakeTimeout = 10 * time.Second
+
+// Plugin is a running plugin program. Its requests are sent one at a time;
+// if it exits or a request is canceled, it is restarted for the next one.
+type Plugin struct {
+	Path  string
+	Args  []string
+	Nodes []PluginNodeType
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *json.Decoder
+	nextID int
+}
+
+// StartPlugin starts the plugin program at path and reads the node types it
+// implements.
+func StartPlugin(path string, args ...string) (*Plugin, error) {
+	p := &Plugin{Path: path, Args: args}
+	ctx, cancel := context.WithTimeout(context.Background(), pluginHandshakeTimeout)
+	defer cancel()
+	var description pluginDescription
+	if err := p.call(ctx, "describe", nil, &description); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	if description.ProtocolVersion != PluginProtocolVersion {
+		p.Close()
+		return nil, fmt.Errorf("plugin %s: unsupported protocol version %d", path, description.ProtocolVersion)
+	}
+	for _, node := range description.Nodes {
+		if node.Type == "" {
+			p.Close()
+			return nil, fmt.Errorf("plugin %s: node type without name", path)
+		}
+	}
+	p.Nodes = description.Nodes
+	return p, nil
+}
+
+// Execute runs a node of the plugin on records.
+func (p *Plugin) Execute(ctx *Context, nodeType string, config map[string]interface{}, items []map[string]interface{}) ([]map[string]interface{}, error) {
+	if items == nil {
+		items = []map[string]interface{}{}
+	}
+	request := PluginRequest{Type: nodeType, Config: config, Items: items, Workflow: ctx.Workflow, Node: ctx.Node}
+	var result pluginResult
+	if err := p.call(ctx.Ctx, "execute", request, &result); err != nil {
+		return nil, err
+	}
+	return result.Items, nil
+}
+
+// Close stops the plugin. A request sent afterwards starts it again.
+func (p *Plugin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stop()
+}
+
+// call sends a request and decodes its result, starting the plugin if it
+// is not running.
+func (p *Plugin) call(ctx context.Context, method string, params, result interface{}) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil {
+		if err := p.start(); err != nil {
+			return err
+		}
+	}
+	p.nextID++
+	id := p.nextID
+	data, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	var response rpcResponse
+	go func() {
+		if _, err := p.stdin.Write(append(data, '\n')); err != nil {
+			done <- err
+			return
+		}
+		done <- p.stdout.Decode(&response)
+	}()
+	select {
+	case <-ctx.Done():
+		// The plugin may still answer; restart it rather than reading a
+		// stale response later.
+		p.cmd.Process.Kill()
+		<-done
+		p.stop()
+		return ctx.Err()
+	case err := <-done:
+		if err != nil {
+			p.stop()
+			return fmt.Errorf("plugin exited: %w", err)
+		}
+	}
+
+	if response.ID != id {
+		p.stop()
+		return fmt.Errorf("plugin answered request %d instead of %d", response.ID, id)
+	}
+	if response.Error != nil {
+		return errors.New(response.Error.Message)
+	}
+	if result != nil {
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("invalid %s result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// start starts the plugin program.
+func (p *Plugin) start() error {
+	cmd := exec.Command(p.Path, p.Args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	p.cmd, p.stdin, p.stdout = cmd, stdin, json.NewDecoder(bufio.NewReader(stdout))
+	return nil
+}
+
+// stop kills the plugin program, if it runs.
+func (p *Plugin) stop() error {
+	if p.cmd == nil {
+		return nil
+	}
+	p.stdin.Close()
+	err := p.cmd.Process.Kill()
+	p.cmd.Wait()
+	p.cmd, p.stdin, p.stdout = nil, nil, nil
+	if errors.Is(err, os.ErrProcessDone) {
+		return nil
+	}
+	return err
+}
+
+// ServePlugin serves the plugin protocol on in and out, usually standard
+// input and output, until in is closed. nodes are the node types of the
+// plugin and execute runs them.
+func ServePlugin(in io.Reader, out io.Writer, nodes []PluginNodeType, execute func(req PluginRequest) ([]map[string]interface{}, error)) error {
+	decoder := json.NewDecoder(in)
+	encoder := json.NewEncoder(out)
+	for {
+		var request struct {
+			ID     int             `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := decoder.Decode(&request); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		response := rpcResponse{JSONRPC: "2.0", ID: request.ID}
+		var result interface{}
+		switch request.Method {
+		case "describe":
+			result = pluginDescription{ProtocolVersion: PluginProtocolVersion, Nodes: nodes}
+		case "execute":
+			var req PluginRequest
+			if err := json.Unmarshal(request.Params, &req); err != nil {
+				response.Error = &rpcError{Code: rpcNodeFailed, Message: err.Error()}
+				break
+			}
+			items, err := execute(req)
+			if err != nil {
+				response.Error = &rpcError{Code: rpcNodeFailed, Message: err.Error()}
+				break
+			}
+			result = pluginResult{Items: items}
+		default:
+			response.Error = &rpcError{Code: rpcMethodNotFound, Message: fmt.Sprintf("unknown method %q", request.Method)}
+		}
+		if result != nil {
+			data, err := json.Marshal(result)
+			if err != nil {
+				response.Error = &rpcError{Code: rpcNodeFailed, Message: err.Error()}
+			} else {
+				response.Result = data
+			}
+		}
+		if err := encoder.Encode(response); err != nil {
+			return err
+		}
+	}
+}
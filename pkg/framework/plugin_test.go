@@ -0,0 +1,100 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHelperPlugin is the plugin program of the plugin tests, started as
+// the test binary with WORKFLOW_TEST_PLUGIN set.
+func TestHelperPlugin(t *testing.T) {
+	if os.Getenv("WORKFLOW_TEST_PLUGIN") == "" {
+		t.Skip("only run as a plugin")
+	}
+	nodes := []PluginNodeType{{Type: "stamp", Params: []FunctionParam{{Name: "field", Type: "string", Required: true}}}}
+	ServePlugin(os.Stdin, os.Stdout, nodes, func(req PluginRequest) ([]map[string]interface{}, error) {
+		switch req.Config["action"] {
+		case "fail":
+			return nil, errors.New("stamp failed")
+		case "hang":
+			time.Sleep(time.Minute)
+		case "exit":
+			os.Exit(3)
+		}
+		for _, item := range req.Items {
+			item[req.Config["field"].(string)] = req.Node
+		}
+		return req.Items, nil
+	})
+	os.Exit(0)
+}
+
+// startTestPlugin starts the test binary as a plugin.
+func startTestPlugin(t *testing.T) *Plugin {
+	t.Helper()
+	t.Setenv("WORKFLOW_TEST_PLUGIN", "1")
+	plugin, err := StartPlugin(os.Args[0], "-test.run=^TestHelperPlugin$")
+	if err != nil {
+		t.Fatalf("failed to start plugin: %v", err)
+	}
+	t.Cleanup(func() { plugin.Close() })
+	return plugin
+}
+
+func TestPlugin(t *testing.T) {
+	plugin := startTestPlugin(t)
+	want := []PluginNodeType{{Type: "stamp", Params: []FunctionParam{{Name: "field", Type: "string", Required: true}}}}
+	if !reflect.DeepEqual(plugin.Nodes, want) {
+		t.Errorf("expected the node types of the handshake, got %+v", plugin.Nodes)
+	}
+
+	ctx := &Context{Ctx: context.Background(), Node: "stamper"}
+	out, err := plugin.Execute(ctx, "stamp", map[string]interface{}{"field": "by"}, []map[string]interface{}{{"id": 1}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(out, []map[string]interface{}{{"id": float64(1), "by": "stamper"}}) {
+		t.Errorf("unexpected output %v", out)
+	}
+
+	if _, err := plugin.Execute(ctx, "stamp", map[string]interface{}{"action": "fail"}, nil); err == nil || err.Error() != "stamp failed" {
+		t.Errorf("expected the error of the plugin, got %v", err)
+	}
+}
+
+func TestPlugin_Restart(t *testing.T) {
+	plugin := startTestPlugin(t)
+	stamp := map[string]interface{}{"field": "by"}
+
+	if _, err := plugin.Execute(&Context{Ctx: context.Background()}, "stamp", map[string]interface{}{"action": "exit"}, nil); err == nil || !strings.Contains(err.Error(), "plugin exited") {
+		t.Errorf("expected an error for the exited plugin, got %v", err)
+	}
+	if _, err := plugin.Execute(&Context{Ctx: context.Background()}, "stamp", stamp, nil); err != nil {
+		t.Errorf("expected the plugin to be restarted, got %v", err)
+	}
+
+	timeout, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := plugin.Execute(&Context{Ctx: timeout}, "stamp", map[string]interface{}{"action": "hang"}, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the request to be canceled, got %v", err)
+	}
+	if _, err := plugin.Execute(&Context{Ctx: context.Background()}, "stamp", stamp, nil); err != nil {
+		t.Errorf("expected the hanging plugin to be restarted, got %v", err)
+	}
+}
+
+func TestStartPlugin_Error(t *testing.T) {
+	if _, err := StartPlugin("/nonexistent/plugin"); err == nil {
+		t.Error("expected an error for a missing program")
+	}
+	// Without WORKFLOW_TEST_PLUGIN, the helper plugin is skipped and the
+	// test binary does not speak the protocol.
+	if _, err := StartPlugin(os.Args[0], "-test.run=^TestHelperPlugin$"); err == nil {
+		t.Error("expected an error for a program that is not a plugin")
+	}
+}
@@ -0,0 +1,95 @@
+package framework
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestSQLDatabase_Validate(t *testing.T) {
+	valid := []SQLDatabase{
+		{Driver: SQLDriverSQLite, DSN: "contacts.db"},
+		{Driver: SQLDriverPostgres, DSN: "${CONTACTS_DB_URL}"},
+		{Driver: SQLDriverPostgres, DSN: "postgres://app:${DB_PASSWORD}@db/contacts"},
+		{Driver: SQLDriverMySQL, DSN: "app@tcp(db:3306)/contacts"},
+	}
+	for _, d := range valid {
+		if err := d.Validate(true); err != nil {
+			t.Errorf("%+v: unexpected error: %v", d, err)
+		}
+	}
+	invalid := []SQLDatabase{
+		{Driver: "oracle", DSN: "x"},
+		{Driver: SQLDriverSQLite},
+		{Driver: SQLDriverPostgres, DSN: "postgres://app:secret@db/contacts"},
+		{Driver: SQLDriverPostgres, DSN: "host=db user=app password=secret"},
+		{Driver: SQLDriverMySQL, DSN: "app:secret@tcp(db:3306)/contacts"},
+	}
+	for _, d := range invalid {
+		if err := d.Validate(true); err == nil {
+			t.Errorf("%+v: expected an error", d)
+		}
+	}
+	if err := (SQLDatabase{Driver: SQLDriverPostgres, DSN: "postgres://app:secret@db/contacts"}).Validate(false); err != nil {
+		t.Errorf("expected passwords to be allowed in the credential store, got %v", err)
+	}
+}
+
+func TestSQLDB_Rebind(t *testing.T) {
+	db := &SQLDB{Driver: SQLDriverPostgres}
+	if got := db.Rebind(`SELECT * FROM t WHERE a = ? AND b = '?' AND "c?" = ?`); got != `SELECT * FROM t WHERE a = $1 AND b = '?' AND "c?" = $2` {
+		t.Errorf("unexpected query %s", got)
+	}
+	db.Driver = SQLDriverMySQL
+	if got := db.Rebind("SELECT ?"); got != "SELECT ?" {
+		t.Errorf("expected MySQL queries to be unchanged, got %s", got)
+	}
+}
+
+func TestSQLDatabases(t *testing.T) {
+	dir := t.TempDir()
+	databases := NewSQLDatabases(map[string]SQLDatabase{"shared": {Driver: SQLDriverSQLite, DSN: "${DATA_DIR}/shared.db"}}, map[string]string{"DATA_DIR": dir})
+	defer databases.Close()
+
+	first, err := databases.DB(context.Background(), "shared")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	run, err := databases.WithDatabases(map[string]SQLDatabase{"local": {Driver: SQLDriverSQLite, DSN: filepath.Join(dir, "local.db")}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	again, err := run.DB(context.Background(), "shared")
+	if err != nil || again != first {
+		t.Errorf("expected the shared pool, got %v, %v", again, err)
+	}
+	if _, err := run.DB(context.Background(), "local"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := databases.DB(context.Background(), "local"); err == nil {
+		t.Error("expected the workflow database to stay out of the shared databases")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "shared.db")); err != nil {
+		t.Errorf("expected the DSN to be expanded: %v", err)
+	}
+	if _, err := databases.WithDatabases(map[string]SQLDatabase{"shared": {Driver: SQLDriverSQLite, DSN: filepath.Join(dir, "other.db")}}); err == nil {
+		t.Error("expected a database replacing a deployment database to be rejected")
+	}
+}
+
+func TestLoadSQLDatabases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "databases.yaml")
+	if err := os.WriteFile(path, []byte("crm:\n  driver: postgres\n  dsn: postgres://app:secret@db/crm\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	databases, err := LoadSQLDatabases(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if databases["crm"].DSN != "postgres://app:secret@db/crm" {
+		t.Errorf("unexpected databases %+v", databases)
+	}
+}
@@ -0,0 +1,47 @@
+package framework
+
+import "time"
+
+// Types of trace steps.
+const (
+	// TraceToolCall is a tool an agent called, with its arguments and
+	// result.
+	TraceToolCall = "tool"
+	// TraceAnswer is the final answer of an agent.
+	TraceAnswer = "answer"
+	// TraceInvalid is a model response the agent could not act on.
+	TraceInvalid = "invalid"
+	// TraceConsole is a line the code of a codeNode wrote to the console,
+	// with its level and message.
+	TraceConsole = "console"
+)
+
+// TraceStep is a step of a node that works in several steps, such as an
+// aiAgent calling tools. Runs record the steps of their nodes in the run
+// history.
+type TraceStep struct {
+	Node string
+	// Step numbers the steps of a node execution from 1.
+	Step   int
+	Type   string
+	Tool   string
+	Input  interface{}
+	Output interface{}
+	Error  string
+	Time   time.Time
+}
+
+// AddTrace records a step of the current node through ctx.Trace, if set.
+// The node and the time default to the current ones.
+func (c *Context) AddTrace(step TraceStep) {
+	if c.Trace == nil {
+		return
+	}
+	if step.Node == "" {
+		step.Node = c.Node
+	}
+	if step.Time.IsZero() {
+		step.Time = time.Now().UTC()
+	}
+	c.Trace(step)
+}
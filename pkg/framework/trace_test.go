@@ -0,0 +1,19 @@
+package framework
+
+import "testing"
+
+func TestContext_AddTrace(t *testing.T) {
+	ctx := &Context{Node: "agent"}
+	// Without a Trace function steps are dropped.
+	ctx.AddTrace(TraceStep{Step: 1, Type: TraceAnswer})
+
+	var steps []TraceStep
+	ctx.Trace = func(step TraceStep) { steps = append(steps, step) }
+	ctx.AddTrace(TraceStep{Step: 1, Type: TraceToolCall, Tool: "lookup"})
+	if len(steps) != 1 {
+		t.Fatalf("expected 1 step, got %d", len(steps))
+	}
+	if steps[0].Node != "agent" || steps[0].Tool != "lookup" || steps[0].Time.IsZero() {
+		t.Errorf("expected the step to be completed with node and time, got %+v", steps[0])
+	}
+}
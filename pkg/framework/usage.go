@@ -0,0 +1,161 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+// LLMUsage is what LLM requests used.
+type LLMUsage struct {
+	// Model is the model that answered, if the client knows it.
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	// Cost is the price of the tokens in US dollars. Clients leave it
+	// zero; nodes fill it in from the prices of package go-workflow/pkg/llm.
+	Cost float64
+}
+
+// TotalTokens returns the number of prompt and completion tokens.
+func (u LLMUsage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// LLMUsageClient is a LangChainChatClient that reports the tokens a request
+// used, as counted by the provider. Nodes estimate the usage of other
+// clients.
+type LLMUsageClient interface {
+	LangChainChatClient
+	GenerateWithUsage(ctx context.Context, messages []schema.ChatMessage, options ...llms.CallOption) (string, LLMUsage, error)
+}
+
+// ErrBudgetExceeded is wrapped by the errors of LLM requests that exceed
+// the budget of their run or workflow. Such errors stop the run, even if the
+// failing node has an error connection.
+var ErrBudgetExceeded = errors.New("LLM budget exceeded")
+
+// Budget limits LLM usage. Zero limits are unlimited.
+type Budget struct {
+	MaxTokens int `yaml:"maxTokens,omitempty"`
+	// MaxCost is in US dollars. Only models with a known price count
+	// towards it.
+	MaxCost float64 `yaml:"maxCost,omitempty"`
+}
+
+// Budgets are the LLM budgets of a workflow: Run limits each run, and
+// Workflow all runs together.
+type Budgets struct {
+	Run      Budget `yaml:"run,omitempty"`
+	Workflow Budget `yaml:"workflow,omitempty"`
+}
+
+// UsageMeter adds up the LLM usage of a run, or of all runs of a workflow,
+// and enforces a budget on it. It is safe for concurrent use.
+type UsageMeter struct {
+	scope string
+
+	mu     sync.Mutex
+	budget Budget
+	usage  LLMUsage
+}
+
+// NewUsageMeter creates a UsageMeter. The scope, e.g. "run" or "workflow",
+// names it in errors.
+func NewUsageMeter(scope string, budget Budget) *UsageMeter {
+	return &UsageMeter{scope: scope, budget: budget}
+}
+
+// SetBudget replaces the budget, e.g. after the workflow was updated.
+func (m *UsageMeter) SetBudget(budget Budget) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.budget = budget
+}
+
+// Usage returns the usage added so far.
+func (m *UsageMeter) Usage() LLMUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
+
+// Add adds usage and returns an error wrapping ErrBudgetExceeded if the
+// total now exceeds the budget.
+func (m *UsageMeter) Add(usage LLMUsage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage.PromptTokens += usage.PromptTokens
+	m.usage.CompletionTokens += usage.CompletionTokens
+	m.usage.Cost += usage.Cost
+	switch {
+	case m.budget.MaxTokens > 0 && m.usage.TotalTokens() > m.budget.MaxTokens:
+		return fmt.Errorf("%w: %s used %d of %d tokens", ErrBudgetExceeded, m.scope, m.usage.TotalTokens(), m.budget.MaxTokens)
+	case m.budget.MaxCost > 0 && m.usage.Cost > m.budget.MaxCost:
+		return fmt.Errorf("%w: %s spent $%.4f of $%.4f", ErrBudgetExceeded, m.scope, m.usage.Cost, m.budget.MaxCost)
+	}
+	return nil
+}
+
+// Check returns an error wrapping ErrBudgetExceeded if the budget is used
+// up, so no further requests should be sent.
+func (m *UsageMeter) Check() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case m.budget.MaxTokens > 0 && m.usage.TotalTokens() >= m.budget.MaxTokens:
+		return fmt.Errorf("%w: %s used %d of %d tokens", ErrBudgetExceeded, m.scope, m.usage.TotalTokens(), m.budget.MaxTokens)
+	case m.budget.MaxCost > 0 && m.usage.Cost >= m.budget.MaxCost:
+		return fmt.Errorf("%w: %s spent $%.4f of $%.4f", ErrBudgetExceeded, m.scope, m.usage.Cost, m.budget.MaxCost)
+	}
+	return nil
+}
+
+// CheckLLMBudget returns an error wrapping ErrBudgetExceeded if the budget
+// of the run or the workflow is used up. Nodes call it before every LLM
+// request.
+func (c *Context) CheckLLMBudget() error {
+	for _, meter := range []*UsageMeter{c.RunUsage, c.WorkflowUsage} {
+		if meter == nil {
+			continue
+		}
+		if err := meter.Check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordLLMUsage counts an LLM request of the current node in the metrics
+// and in the usage of the run and the workflow. It returns an error
+// wrapping ErrBudgetExceeded if that exceeds a budget.
+func (c *Context) RecordLLMUsage(usage LLMUsage) error {
+	if c.Metrics != nil {
+		model := usage.Model
+		if model == "" {
+			model = "unknown"
+		}
+		c.Metrics.LLMPromptTokens.WithLabelValues(c.Workflow, c.Node, model).Add(float64(usage.PromptTokens))
+		c.Metrics.LLMCompletionTokens.WithLabelValues(c.Workflow, c.Node, model).Add(float64(usage.CompletionTokens))
+		c.Metrics.LLMCost.WithLabelValues(c.Workflow, c.Node, model).Add(usage.Cost)
+	}
+	var errs []error
+	for _, meter := range []*UsageMeter{c.RunUsage, c.WorkflowUsage} {
+		if meter == nil {
+			continue
+		}
+		// Add to every meter, so the workflow also counts the request
+		// that exceeded the budget of the run.
+		if err := meter.Add(usage); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package framework
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestUsageMeter(t *testing.T) {
+	tests := []struct {
+		name    string
+		budget  Budget
+		usage   []LLMUsage
+		wantErr string
+	}{
+		{name: "unlimited", usage: []LLMUsage{{PromptTokens: 1000, CompletionTokens: 1000, Cost: 10}}},
+		{name: "within tokens", budget: Budget{MaxTokens: 100}, usage: []LLMUsage{{PromptTokens: 60}, {CompletionTokens: 40}}},
+		{name: "over tokens", budget: Budget{MaxTokens: 100}, usage: []LLMUsage{{PromptTokens: 60}, {CompletionTokens: 41}}, wantErr: "run used 101 of 100 tokens"},
+		{name: "over cost", budget: Budget{MaxCost: 0.5}, usage: []LLMUsage{{Cost: 0.3}, {Cost: 0.3}}, wantErr: "run spent $0.6000 of $0.5000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meter := NewUsageMeter("run", tt.budget)
+			var err error
+			for _, usage := range tt.usage {
+				if err = meter.Add(usage); err != nil {
+					break
+				}
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrBudgetExceeded) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected budget error %q, got %v", tt.wantErr, err)
+			}
+			if err := meter.Check(); !errors.Is(err, ErrBudgetExceeded) {
+				t.Errorf("expected Check to fail after the budget is exceeded, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUsageMeter_CheckUsedUp(t *testing.T) {
+	meter := NewUsageMeter("workflow", Budget{MaxTokens: 10})
+	if err := meter.Add(LLMUsage{PromptTokens: 10}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := meter.Check(); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected a used-up budget to refuse requests, got %v", err)
+	}
+	meter.SetBudget(Budget{MaxTokens: 20})
+	if err := meter.Check(); err != nil {
+		t.Errorf("expected a raised budget to allow requests, got %v", err)
+	}
+}
+
+func TestContext_RecordLLMUsage(t *testing.T) {
+	ctx := &Context{
+		Metrics:       NewMetrics(prometheus.NewRegistry()),
+		Workflow:      "leads",
+		Node:          "classify",
+		RunUsage:      NewUsageMeter("run", Budget{MaxTokens: 100}),
+		WorkflowUsage: NewUsageMeter("workflow", Budget{}),
+	}
+	if err := ctx.RecordLLMUsage(LLMUsage{Model: "gpt-4o", PromptTokens: 50, CompletionTokens: 10, Cost: 0.25}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err := ctx.RecordLLMUsage(LLMUsage{Model: "gpt-4o", PromptTokens: 50, CompletionTokens: 10, Cost: 0.25})
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected the run budget to be exceeded, got %v", err)
+	}
+	if err := ctx.CheckLLMBudget(); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("expected CheckLLMBudget to fail, got %v", err)
+	}
+
+	if got := testutil.ToFloat64(ctx.Metrics.LLMPromptTokens.WithLabelValues("leads", "classify", "gpt-4o")); got != 100 {
+		t.Errorf("expected 100 prompt tokens, got %v", got)
+	}
+	if got := testutil.ToFloat64(ctx.Metrics.LLMCompletionTokens.WithLabelValues("leads", "classify", "gpt-4o")); got != 20 {
+		t.Errorf("expected 20 completion tokens, got %v", got)
+	}
+	if got := testutil.ToFloat64(ctx.Metrics.LLMCost.WithLabelValues("leads", "classify", "gpt-4o")); got != 0.5 {
+		t.Errorf("expected a cost of 0.5, got %v", got)
+	}
+	// The workflow counts the request that exceeded the run budget too.
+	if got := ctx.WorkflowUsage.Usage().TotalTokens(); got != 120 {
+		t.Errorf("expected the workflow to count 120 tokens, got %d", got)
+	}
+}
@@ -0,0 +1,43 @@
+package framework
+
+import "context"
+
+// LangChainEmbedder creates embedding vectors of texts, as the OpenAI and
+// Ollama clients of LangChain do.
+type LangChainEmbedder interface {
+	CreateEmbedding(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// VectorStore holds vectors with metadata in named indexes and finds the
+// ones most similar to a query vector. See package
+// go-workflow/pkg/vectorstore for implementations.
+type VectorStore interface {
+	// Upsert adds documents to an index, replacing documents with the same
+	// ID.
+	Upsert(ctx context.Context, index string, docs []VectorDocument) error
+	// Query returns the documents of an index most similar to the query,
+	// most similar first.
+	Query(ctx context.Context, index string, query VectorQuery) ([]VectorMatch, error)
+}
+
+// VectorDocument is a vector with an ID and metadata.
+type VectorDocument struct {
+	ID       string
+	Vector   []float32
+	Metadata map[string]interface{}
+}
+
+// VectorQuery selects the TopK documents whose cosine similarity to Vector
+// is at least MinScore and whose metadata has the values of Filter.
+type VectorQuery struct {
+	Vector   []float32
+	TopK     int
+	MinScore float64
+	Filter   map[string]interface{}
+}
+
+// VectorMatch is a document found by a query, with its cosine similarity.
+type VectorMatch struct {
+	VectorDocument
+	Score float64
+}
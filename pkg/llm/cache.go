@@ -0,0 +1,201 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+
+	"go-workflow/pkg/framework"
+)
+
+// DefaultCacheTTL is how long cached responses are used, unless a node sets
+// its own TTL.
+const DefaultCacheTTL = 24 * time.Hour
+
+// CacheKey returns the key of a request to a provider: a hash of the
+// provider, the messages and every call option that affects the response.
+func CacheKey(provider string, messages []schema.ChatMessage, options ...llms.CallOption) string {
+	var opts llms.CallOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
+	request := struct {
+		Provider string    `json:"provider"`
+		Messages []Message `json:"messages"`
+		// The options without StreamingFunc, which does not change the
+		// response and cannot be encoded.
+		Model                string                    `json:"model,omitempty"`
+		MaxTokens            int                       `json:"max_tokens,omitempty"`
+		Temperature          float64                   `json:"temperature,omitempty"`
+		StopWords            []string                  `json:"stop_words,omitempty"`
+		TopK                 int                       `json:"top_k,omitempty"`
+		TopP                 float64                   `json:"top_p,omitempty"`
+		Seed                 int                       `json:"seed,omitempty"`
+		MinLength            int                       `json:"min_length,omitempty"`
+		MaxLength            int                       `json:"max_length,omitempty"`
+		N                    int                       `json:"n,omitempty"`
+		RepetitionPenalty    float64                   `json:"repetition_penalty,omitempty"`
+		FrequencyPenalty     float64                   `json:"frequency_penalty,omitempty"`
+		PresencePenalty      float64                   `json:"presence_penalty,omitempty"`
+		Functions            []llms.FunctionDefinition `json:"functions,omitempty"`
+		FunctionCallBehavior llms.FunctionCallBehavior `json:"function_call,omitempty"`
+	}{
+		Provider:             provider,
+		Messages:             newInteraction(messages, nil).Messages,
+		Model:                opts.Model,
+		MaxTokens:            opts.MaxTokens,
+		Temperature:          opts.Temperature,
+		StopWords:            opts.StopWords,
+		TopK:                 opts.TopK,
+		TopP:                 opts.TopP,
+		Seed:                 opts.Seed,
+		MinLength:            opts.MinLength,
+		MaxLength:            opts.MaxLength,
+		N:                    opts.N,
+		RepetitionPenalty:    opts.RepetitionPenalty,
+		FrequencyPenalty:     opts.FrequencyPenalty,
+		PresencePenalty:      opts.PresencePenalty,
+		Functions:            opts.Functions,
+		FunctionCallBehavior: opts.FunctionCallBehavior,
+	}
+	// Only function parameters could fail to encode; they then do not
+	// count towards the key.
+	data, _ := json.Marshal(request)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// OpenCache opens the cache described by spec: "memory" for an in-memory
+// cache, or the path of a SQLite file, optionally prefixed with
+// "sqlite:". An empty spec returns a nil cache.
+func OpenCache(spec string) (framework.LLMCache, error) {
+	switch {
+	case spec == "":
+		return nil, nil
+	case spec == "memory":
+		return NewMemoryCache(), nil
+	}
+	cache, err := OpenSQLiteCache(strings.TrimPrefix(spec, "sqlite:"))
+	if err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// MemoryCache is an LLM cache held in memory. It is safe for concurrent
+// use.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+	now     func() time.Time
+}
+
+type memoryCacheEntry struct {
+	response string
+	// expires is zero for entries that do not expire.
+	expires time.Time
+}
+
+// NewMemoryCache creates an empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: map[string]memoryCacheEntry{}, now: time.Now}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) (string, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return "", false, nil
+	}
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return "", false, nil
+	}
+	return entry.response, true, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key, response string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := memoryCacheEntry{response: response}
+	if ttl > 0 {
+		entry.expires = c.now().Add(ttl)
+	}
+	c.entries[key] = entry
+	return nil
+}
+
+// SQLiteCache is an LLM cache in a SQLite file, which keeps responses
+// across runs and processes.
+type SQLiteCache struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// OpenSQLiteCache opens the SQLite cache at path, creating it if needed.
+func OpenSQLiteCache(path string) (*SQLiteCache, error) {
+	if path == "" {
+		return nil, errors.New("missing cache file")
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS llm_cache (
+	key TEXT PRIMARY KEY,
+	response TEXT NOT NULL,
+	expires_at INTEGER NOT NULL
+)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create LLM cache %s: %w", path, err)
+	}
+	return &SQLiteCache{db: db, now: time.Now}, nil
+}
+
+// Close closes the cache file.
+func (c *SQLiteCache) Close() error {
+	return c.db.Close()
+}
+
+func (c *SQLiteCache) Get(ctx context.Context, key string) (string, bool, error) {
+	var response string
+	var expiresAt int64
+	err := c.db.QueryRowContext(ctx, "SELECT response, expires_at FROM llm_cache WHERE key = ?", key).Scan(&response, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if expiresAt != 0 && c.now().UnixNano() >= expiresAt {
+		_, err := c.db.ExecContext(ctx, "DELETE FROM llm_cache WHERE key = ? AND expires_at = ?", key, expiresAt)
+		return "", false, err
+	}
+	return response, true, nil
+}
+
+func (c *SQLiteCache) Set(ctx context.Context, key, response string, ttl time.Duration) error {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = c.now().Add(ttl).UnixNano()
+	}
+	_, err := c.db.ExecContext(ctx, "INSERT OR REPLACE INTO llm_cache(key, response, expires_at) VALUES(?, ?, ?)", key, response, expiresAt)
+	return err
+}
+
+var (
+	_ framework.LLMCache = (*MemoryCache)(nil)
+	_ framework.LLMCache = (*SQLiteCache)(nil)
+)
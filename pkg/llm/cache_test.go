@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+
+	"go-workflow/pkg/framework"
+)
+
+func TestCacheKey(t *testing.T) {
+	hi := []schema.ChatMessage{schema.HumanChatMessage{Content: "Hi"}}
+	key := CacheKey("openai", hi, llms.WithModel("gpt-4o"), llms.WithTemperature(0.2))
+	if again := CacheKey("openai", []schema.ChatMessage{schema.HumanChatMessage{Content: "Hi"}}, llms.WithModel("gpt-4o"), llms.WithTemperature(0.2)); again != key {
+		t.Error("expected identical requests to have the same key")
+	}
+	others := map[string]string{
+		"provider":    CacheKey("anthropic", hi, llms.WithModel("gpt-4o"), llms.WithTemperature(0.2)),
+		"model":       CacheKey("openai", hi, llms.WithModel("gpt-4o-mini"), llms.WithTemperature(0.2)),
+		"temperature": CacheKey("openai", hi, llms.WithModel("gpt-4o"), llms.WithTemperature(0.7)),
+		"role":        CacheKey("openai", []schema.ChatMessage{schema.SystemChatMessage{Content: "Hi"}}, llms.WithModel("gpt-4o"), llms.WithTemperature(0.2)),
+		"content":     CacheKey("openai", []schema.ChatMessage{schema.HumanChatMessage{Content: "Hello"}}, llms.WithModel("gpt-4o"), llms.WithTemperature(0.2)),
+	}
+	for name, other := range others {
+		if other == key {
+			t.Errorf("expected another %s to change the key", name)
+		}
+	}
+	// Streaming does not change the response.
+	streaming := llms.WithStreamingFunc(func(context.Context, []byte) error { return nil })
+	if CacheKey("openai", hi, llms.WithModel("gpt-4o"), llms.WithTemperature(0.2), streaming) != key {
+		t.Error("expected a streaming function not to change the key")
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	cache := NewMemoryCache()
+	testCache(t, cache, func(d time.Duration) {
+		now := cache.now()
+		cache.now = func() time.Time { return now.Add(d) }
+	})
+}
+
+func TestSQLiteCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	cache, err := OpenSQLiteCache(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer cache.Close()
+	testCache(t, cache, func(d time.Duration) {
+		now := cache.now()
+		cache.now = func() time.Time { return now.Add(d) }
+	})
+
+	// Responses outlive the process that cached them.
+	ctx := context.Background()
+	if err := cache.Set(ctx, "kept", "response", 0); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenCache("sqlite:" + path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer reopened.(*SQLiteCache).Close()
+	if resp, ok, err := reopened.Get(ctx, "kept"); err != nil || !ok || resp != "response" {
+		t.Errorf("expected the response to be kept, got %q, %v, %v", resp, ok, err)
+	}
+}
+
+// testCache checks the behavior shared by all caches; advance moves the
+// clock of cache forward.
+func testCache(t *testing.T, cache framework.LLMCache, advance func(time.Duration)) {
+	t.Helper()
+	ctx := context.Background()
+	if _, ok, err := cache.Get(ctx, "missing"); ok || err != nil {
+		t.Errorf("expected a miss, got %v, %v", ok, err)
+	}
+	if err := cache.Set(ctx, "short", "first", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Set(ctx, "forever", "second", 0); err != nil {
+		t.Fatal(err)
+	}
+	if resp, ok, err := cache.Get(ctx, "short"); err != nil || !ok || resp != "first" {
+		t.Errorf("expected the cached response, got %q, %v, %v", resp, ok, err)
+	}
+	if err := cache.Set(ctx, "short", "replaced", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _, _ := cache.Get(ctx, "short"); resp != "replaced" {
+		t.Errorf("expected the response to be replaced, got %q", resp)
+	}
+
+	advance(2 * time.Minute)
+	if _, ok, err := cache.Get(ctx, "short"); ok || err != nil {
+		t.Errorf("expected the response to expire, got %v, %v", ok, err)
+	}
+	if resp, ok, _ := cache.Get(ctx, "forever"); !ok || resp != "second" {
+		t.Errorf("expected a response without TTL to be kept, got %q, %v", resp, ok)
+	}
+}
+
+func TestOpenCache(t *testing.T) {
+	if cache, err := OpenCache(""); cache != nil || err != nil {
+		t.Errorf("expected no cache, got %v, %v", cache, err)
+	}
+	if cache, err := OpenCache("memory"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if _, ok := cache.(*MemoryCache); !ok {
+		t.Errorf("expected a memory cache, got %T", cache)
+	}
+	if _, err := OpenCache(filepath.Join(t.TempDir(), "missing", "cache.db")); err == nil {
+		t.Error("expected an error for a file in a missing directory")
+	}
+}
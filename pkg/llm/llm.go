@@ -0,0 +1,133 @@
+// Package llm builds the clients of LLM providers by name, so every AI node
+// of a workflow can use its own provider and model.
+//
+// The providers openai, anthropic, ollama, mock and replay are registered by
+// this package; others can be added with Register.
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"go-workflow/pkg/framework"
+)
+
+// Config holds what a provider needs to build a client.
+type Config struct {
+	APIKey string
+	// URL is the address of the provider: the API base URL, the Ollama
+	// server, or the recording file of the replay provider.
+	URL string
+	// Model is the default model of the client. Nodes can choose another
+	// one per request.
+	Model string
+	// EmbeddingModel is the model the client creates embeddings with, if
+	// the provider supports them.
+	EmbeddingModel string
+}
+
+// Provider builds the clients of an LLM provider.
+type Provider struct {
+	// APIKeyEnv, URLEnv, ModelEnv and EmbeddingModelEnv name the
+	// environment variables NewClientFactory reads the Config of the
+	// provider from.
+	APIKeyEnv         string
+	URLEnv            string
+	ModelEnv          string
+	EmbeddingModelEnv string
+	// New builds a client. Clients of providers that support embeddings
+	// also implement framework.LangChainEmbedder.
+	New func(config Config) (framework.LangChainChatClient, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]Provider{}
+)
+
+// Register makes a provider available under name, replacing any provider
+// registered under the same name.
+func Register(name string, provider Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[name] = provider
+}
+
+// Providers returns the names of the registered providers, sorted.
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Registered reports whether a provider is registered under name.
+func Registered(name string) bool {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	_, ok := providers[name]
+	return ok
+}
+
+// New builds a client of the named provider.
+func New(name string, config Config) (framework.LangChainChatClient, error) {
+	providersMu.RLock()
+	provider, ok := providers[name]
+	providersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown LLM provider %q", name)
+	}
+	client, err := provider.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s client: %w", name, err)
+	}
+	return client, nil
+}
+
+// ConfigFromEnv reads the Config of the named provider from env.
+func ConfigFromEnv(name string, env map[string]string) Config {
+	providersMu.RLock()
+	provider := providers[name]
+	providersMu.RUnlock()
+	var config Config
+	if provider.APIKeyEnv != "" {
+		config.APIKey = env[provider.APIKeyEnv]
+	}
+	if provider.URLEnv != "" {
+		config.URL = env[provider.URLEnv]
+	}
+	if provider.ModelEnv != "" {
+		config.Model = env[provider.ModelEnv]
+	}
+	if provider.EmbeddingModelEnv != "" {
+		config.EmbeddingModel = env[provider.EmbeddingModelEnv]
+	}
+	return config
+}
+
+// NewClientFactory returns a framework.LLMClientFactory that builds the
+// client of a provider from the credentials in env the first time it is
+// asked for, and reuses it afterwards.
+func NewClientFactory(env map[string]string) framework.LLMClientFactory {
+	var mu sync.Mutex
+	clients := map[string]framework.LangChainChatClient{}
+	return func(ctx context.Context, name string) (framework.LangChainChatClient, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if client, ok := clients[name]; ok {
+			return client, nil
+		}
+		client, err := New(name, ConfigFromEnv(name, env))
+		if err != nil {
+			return nil, err
+		}
+		clients[name] = client
+		return client, nil
+	}
+}
@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tmc/langchaingo/schema"
+
+	"go-workflow/pkg/framework"
+)
+
+func TestProviders(t *testing.T) {
+	names := strings.Join(Providers(), ",")
+	for _, want := range []string{"anthropic", "mock", "ollama", "openai", "replay"} {
+		if !strings.Contains(names, want) || !Registered(want) {
+			t.Errorf("expected provider %s to be registered, got %s", want, names)
+		}
+	}
+	if _, err := New("gemini", Config{}); err == nil || !strings.Contains(err.Error(), `unknown LLM provider "gemini"`) {
+		t.Errorf("expected an unknown provider error, got %v", err)
+	}
+	if _, err := New("openai", Config{}); err == nil || !strings.Contains(err.Error(), "missing API key") {
+		t.Errorf("expected a missing API key error, got %v", err)
+	}
+	if _, err := New("openai", Config{APIKey: "sk-test", Model: "gpt-4o"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := New("anthropic", Config{APIKey: "key"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	env := map[string]string{"OPENAI_API_KEY": "sk-test", "OPENAI_BASE_URL": "http://localhost:8080/v1", "OPENAI_MODEL": "gpt-4o", "OPENAI_EMBEDDING_MODEL": "text-embedding-3-small"}
+	want := Config{APIKey: "sk-test", URL: "http://localhost:8080/v1", Model: "gpt-4o", EmbeddingModel: "text-embedding-3-small"}
+	if got := ConfigFromEnv("openai", env); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got := ConfigFromEnv("mock", env); got != (Config{}) {
+		t.Errorf("expected an empty config for mock, got %+v", got)
+	}
+}
+
+func TestNewClientFactory(t *testing.T) {
+	builds := 0
+	Register("counting", Provider{
+		APIKeyEnv: "COUNTING_KEY",
+		New: func(config Config) (framework.LangChainChatClient, error) {
+			if config.APIKey != "secret" {
+				return nil, errors.New("wrong key")
+			}
+			builds++
+			return mockClient{}, nil
+		},
+	})
+	factory := NewClientFactory(map[string]string{"COUNTING_KEY": "secret"})
+	for i := 0; i < 2; i++ {
+		if _, err := factory(context.Background(), "counting"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if builds != 1 {
+		t.Errorf("expected the client to be built once, got %d", builds)
+	}
+	if _, err := factory(context.Background(), "unknown"); err == nil {
+		t.Error("expected an error for an unknown provider")
+	}
+}
+
+func TestMockProvider(t *testing.T) {
+	client, err := New("mock", Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.GenerateFromMessages(context.Background(), []schema.ChatMessage{
+		schema.SystemChatMessage{Content: "system"},
+		schema.HumanChatMessage{Content: `Payload: {"a":1}`},
+	})
+	if err != nil || resp != `Payload: {"a":1}` {
+		t.Errorf("expected the last message back, got %q, %v", resp, err)
+	}
+}
+
+func TestMockProvider_Embeddings(t *testing.T) {
+	client, err := New("mock", Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedder, ok := client.(framework.LangChainEmbedder)
+	if !ok {
+		t.Fatal("expected the mock client to create embeddings")
+	}
+	vectors, err := embedder.CreateEmbedding(context.Background(), []string{"Senior Go developer", "senior go developer", "Pastry chef"})
+	if err != nil || len(vectors) != 3 {
+		t.Fatalf("expected 3 vectors, got %d, %v", len(vectors), err)
+	}
+	if !reflect.DeepEqual(vectors[0], vectors[1]) {
+		t.Error("expected texts differing in case to have the same embedding")
+	}
+	if reflect.DeepEqual(vectors[0], vectors[2]) {
+		t.Error("expected different texts to have different embeddings")
+	}
+}
@@ -0,0 +1,243 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/anthropic"
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+	"github.com/tmc/langchaingo/schema"
+
+	"go-workflow/pkg/framework"
+)
+
+func init() {
+	Register("openai", Provider{
+		APIKeyEnv:         "OPENAI_API_KEY",
+		URLEnv:            "OPENAI_BASE_URL",
+		ModelEnv:          "OPENAI_MODEL",
+		EmbeddingModelEnv: "OPENAI_EMBEDDING_MODEL",
+		New:               newOpenAI,
+	})
+	Register("anthropic", Provider{
+		APIKeyEnv: "ANTHROPIC_API_KEY",
+		ModelEnv:  "ANTHROPIC_MODEL",
+		New:       newAnthropic,
+	})
+	Register("ollama", Provider{
+		URLEnv:            "OLLAMA_HOST",
+		ModelEnv:          "OLLAMA_MODEL",
+		EmbeddingModelEnv: "OLLAMA_EMBEDDING_MODEL",
+		New:               newOllama,
+	})
+	Register("mock", Provider{
+		New: func(Config) (framework.LangChainChatClient, error) { return mockClient{}, nil },
+	})
+	Register("replay", Provider{
+		URLEnv: "LLM_REPLAY_FILE",
+		New:    newReplay,
+	})
+}
+
+func newOpenAI(config Config) (framework.LangChainChatClient, error) {
+	if config.APIKey == "" {
+		return nil, errors.New("missing API key")
+	}
+	opts := []openai.Option{openai.WithToken(config.APIKey)}
+	if config.URL != "" {
+		opts = append(opts, openai.WithBaseURL(config.URL))
+	}
+	if config.Model != "" {
+		opts = append(opts, openai.WithModel(config.Model))
+	}
+	if config.EmbeddingModel != "" {
+		opts = append(opts, openai.WithEmbeddingModel(config.EmbeddingModel))
+	}
+	chat, err := openai.NewChat(opts...)
+	if err != nil {
+		return nil, err
+	}
+	model := config.Model
+	if model == "" {
+		// The default of the OpenAI client.
+		model = "gpt-3.5-turbo"
+	}
+	return chatClient{chat: chat, model: model, embedder: chat}, nil
+}
+
+func newAnthropic(config Config) (framework.LangChainChatClient, error) {
+	if config.APIKey == "" {
+		return nil, errors.New("missing API key")
+	}
+	opts := []anthropic.Option{anthropic.WithToken(config.APIKey)}
+	if config.Model != "" {
+		opts = append(opts, anthropic.WithModel(config.Model))
+	}
+	client, err := anthropic.New(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return anthropicClient{client}, nil
+}
+
+func newOllama(config Config) (framework.LangChainChatClient, error) {
+	newChat := func(model string) (*ollama.Chat, error) {
+		var opts []ollama.Option
+		if config.URL != "" {
+			opts = append(opts, ollama.WithServerURL(config.URL))
+		}
+		if model != "" {
+			opts = append(opts, ollama.WithModel(model))
+		}
+		return ollama.NewChat(ollama.WithLLMOptions(opts...))
+	}
+	chat, err := newChat(config.Model)
+	if err != nil {
+		return nil, err
+	}
+	client := chatClient{chat: chat, model: config.Model, embedder: chat}
+	// Ollama embeds with the model of the client, so embeddings with
+	// another model need a client of their own.
+	if config.EmbeddingModel != "" {
+		if client.embedder, err = newChat(config.EmbeddingModel); err != nil {
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// FromChatLLM adapts a LangChain chat model to framework.LangChainChatClient.
+// The client reports the token usage the model returns, and creates
+// embeddings if the model can.
+func FromChatLLM(chat llms.ChatLLM) framework.LangChainChatClient {
+	embedder, _ := chat.(framework.LangChainEmbedder)
+	return chatClient{chat: chat, embedder: embedder}
+}
+
+type chatClient struct {
+	chat llms.ChatLLM
+	// model is the default model of chat, used to label its usage.
+	model    string
+	embedder framework.LangChainEmbedder
+}
+
+func (c chatClient) CreateEmbedding(ctx context.Context, texts []string) ([][]float32, error) {
+	if c.embedder == nil {
+		return nil, errors.New("the model cannot create embeddings")
+	}
+	return c.embedder.CreateEmbedding(ctx, texts)
+}
+
+func (c chatClient) GenerateFromSinglePrompt(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return c.GenerateFromMessages(ctx, []schema.ChatMessage{schema.HumanChatMessage{Content: prompt}}, options...)
+}
+
+func (c chatClient) GenerateFromMessages(ctx context.Context, messages []schema.ChatMessage, options ...llms.CallOption) (string, error) {
+	resp, _, err := c.GenerateWithUsage(ctx, messages, options...)
+	return resp, err
+}
+
+// GenerateWithUsage reads the token counts OpenAI and Ollama return with a
+// generation; they are estimated if the model returns none.
+func (c chatClient) GenerateWithUsage(ctx context.Context, messages []schema.ChatMessage, options ...llms.CallOption) (string, framework.LLMUsage, error) {
+	generations, err := c.chat.Generate(ctx, [][]schema.ChatMessage{messages}, options...)
+	if err != nil {
+		return "", framework.LLMUsage{}, err
+	}
+	if len(generations) == 0 || generations[0].Message == nil {
+		return "", framework.LLMUsage{}, errors.New("empty response")
+	}
+	gen := generations[0]
+	model := c.model
+	if m := callModel(options); m != "" {
+		model = m
+	}
+	prompt, okPrompt := gen.GenerationInfo["PromptTokens"].(int)
+	completion, okCompletion := gen.GenerationInfo["CompletionTokens"].(int)
+	if !okPrompt || !okCompletion {
+		return gen.Message.Content, EstimateUsage(model, messages, gen.Message.Content), nil
+	}
+	return gen.Message.Content, framework.LLMUsage{Model: model, PromptTokens: prompt, CompletionTokens: completion}, nil
+}
+
+// callModel returns the model selected by call options.
+func callModel(options []llms.CallOption) string {
+	var opts llms.CallOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
+	return opts.Model
+}
+
+// anthropicClient sends chats to the Anthropic completion API, which takes
+// the conversation as alternating Human and Assistant turns.
+type anthropicClient struct {
+	llm llms.LLM
+}
+
+func (c anthropicClient) GenerateFromSinglePrompt(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return c.GenerateFromMessages(ctx, []schema.ChatMessage{schema.HumanChatMessage{Content: prompt}}, options...)
+}
+
+func (c anthropicClient) GenerateFromMessages(ctx context.Context, messages []schema.ChatMessage, options ...llms.CallOption) (string, error) {
+	var b strings.Builder
+	for _, m := range messages {
+		switch m.GetType() {
+		case schema.ChatMessageTypeSystem:
+			b.WriteString(m.GetContent())
+		case schema.ChatMessageTypeAI:
+			b.WriteString("\n\nAssistant: " + m.GetContent())
+		default:
+			b.WriteString("\n\nHuman: " + m.GetContent())
+		}
+	}
+	b.WriteString("\n\nAssistant:")
+	resp, err := c.llm.Call(ctx, b.String(), options...)
+	return strings.TrimSpace(resp), err
+}
+
+// mockClient answers every request with its last message, so a workflow
+// can be tried without credentials: openaiNode gets the record back. Its
+// embeddings count the words and character trigrams of a text, so texts
+// that share words are similar.
+type mockClient struct{}
+
+// mockDimensions is the length of the embeddings of mockClient.
+const mockDimensions = 256
+
+func (mockClient) CreateEmbedding(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, mockDimensions)
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			vector[mockFeature(word)] += 2
+			padded := " " + word + " "
+			for j := 0; j+3 <= len(padded); j++ {
+				vector[mockFeature(padded[j:j+3])]++
+			}
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+func mockFeature(s string) int {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return int(h.Sum32() % mockDimensions)
+}
+
+func (mockClient) GenerateFromSinglePrompt(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return prompt, nil
+}
+
+func (mockClient) GenerateFromMessages(ctx context.Context, messages []schema.ChatMessage, options ...llms.CallOption) (string, error) {
+	if len(messages) == 0 {
+		return "", nil
+	}
+	return messages[len(messages)-1].GetContent(), nil
+}
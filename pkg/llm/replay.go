@@ -0,0 +1,202 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+
+	"go-workflow/pkg/framework"
+)
+
+// Message is a chat message of a recorded request.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Interaction is a request to an LLM and the response it got, with the
+// tokens it used if the client reported them.
+type Interaction struct {
+	Model            string    `json:"model,omitempty"`
+	Messages         []Message `json:"messages"`
+	Response         string    `json:"response"`
+	PromptTokens     int       `json:"prompt_tokens,omitempty"`
+	CompletionTokens int       `json:"completion_tokens,omitempty"`
+}
+
+// LoadRecording reads interactions saved by a Recorder.
+func LoadRecording(path string) ([]Interaction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var interactions []Interaction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("invalid recording %s: %w", path, err)
+	}
+	return interactions, nil
+}
+
+// SaveRecording writes interactions to path as JSON.
+func SaveRecording(path string, interactions []Interaction) error {
+	data, err := json.MarshalIndent(interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func newReplay(config Config) (framework.LangChainChatClient, error) {
+	if config.URL == "" {
+		return nil, errors.New("missing recording file")
+	}
+	interactions, err := LoadRecording(config.URL)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayClient(interactions...), nil
+}
+
+// ReplayClient is a deterministic client for tests. It answers a request
+// with the response recorded for the same model and messages; requests that
+// were recorded several times get their responses in recorded order. A
+// request that was not recorded fails. The usage of interactions recorded
+// without one is estimated.
+type ReplayClient struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayClient creates a ReplayClient replaying interactions.
+func NewReplayClient(interactions ...Interaction) *ReplayClient {
+	return &ReplayClient{interactions: interactions, used: make([]bool, len(interactions))}
+}
+
+func (c *ReplayClient) GenerateFromSinglePrompt(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return c.GenerateFromMessages(ctx, []schema.ChatMessage{schema.HumanChatMessage{Content: prompt}}, options...)
+}
+
+func (c *ReplayClient) GenerateFromMessages(ctx context.Context, messages []schema.ChatMessage, options ...llms.CallOption) (string, error) {
+	resp, _, err := c.GenerateWithUsage(ctx, messages, options...)
+	return resp, err
+}
+
+func (c *ReplayClient) GenerateWithUsage(ctx context.Context, messages []schema.ChatMessage, options ...llms.CallOption) (string, framework.LLMUsage, error) {
+	request := newInteraction(messages, options)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, interaction := range c.interactions {
+		if c.used[i] || interaction.Model != request.Model || !reflect.DeepEqual(interaction.Messages, request.Messages) {
+			continue
+		}
+		c.used[i] = true
+		if interaction.PromptTokens == 0 && interaction.CompletionTokens == 0 {
+			return interaction.Response, EstimateUsage(request.Model, messages, interaction.Response), nil
+		}
+		return interaction.Response, framework.LLMUsage{
+			Model:            request.Model,
+			PromptTokens:     interaction.PromptTokens,
+			CompletionTokens: interaction.CompletionTokens,
+		}, nil
+	}
+	data, _ := json.Marshal(request)
+	return "", framework.LLMUsage{}, fmt.Errorf("no recorded response for request %s", data)
+}
+
+// Recorder is a client that passes requests on to another client and
+// records them with their responses, e.g. to save them for a ReplayClient.
+type Recorder struct {
+	Client framework.LangChainClient
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+func (r *Recorder) GenerateFromSinglePrompt(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return r.GenerateFromMessages(ctx, []schema.ChatMessage{schema.HumanChatMessage{Content: prompt}}, options...)
+}
+
+func (r *Recorder) GenerateFromMessages(ctx context.Context, messages []schema.ChatMessage, options ...llms.CallOption) (string, error) {
+	resp, _, err := r.GenerateWithUsage(ctx, messages, options...)
+	return resp, err
+}
+
+// GenerateWithUsage records the usage reported by the client, and
+// estimates it for clients that do not report it.
+func (r *Recorder) GenerateWithUsage(ctx context.Context, messages []schema.ChatMessage, options ...llms.CallOption) (string, framework.LLMUsage, error) {
+	var resp string
+	var usage framework.LLMUsage
+	var err error
+	reported := false
+	switch client := r.Client.(type) {
+	case framework.LLMUsageClient:
+		resp, usage, err = client.GenerateWithUsage(ctx, messages, options...)
+		reported = true
+	case framework.LangChainChatClient:
+		resp, err = client.GenerateFromMessages(ctx, messages, options...)
+	default:
+		if len(messages) != 1 {
+			return "", framework.LLMUsage{}, errors.New("the recorded client cannot send chats")
+		}
+		resp, err = r.Client.GenerateFromSinglePrompt(ctx, messages[0].GetContent(), options...)
+	}
+	if err != nil {
+		return "", framework.LLMUsage{}, err
+	}
+	interaction := newInteraction(messages, options)
+	interaction.Response = resp
+	if reported {
+		interaction.PromptTokens = usage.PromptTokens
+		interaction.CompletionTokens = usage.CompletionTokens
+	} else {
+		usage = EstimateUsage(interaction.Model, messages, resp)
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, interaction)
+	r.mu.Unlock()
+	return resp, usage, nil
+}
+
+// Interactions returns the interactions recorded so far.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.interactions...)
+}
+
+func newInteraction(messages []schema.ChatMessage, options []llms.CallOption) Interaction {
+	var opts llms.CallOptions
+	for _, opt := range options {
+		opt(&opts)
+	}
+	interaction := Interaction{Model: opts.Model, Messages: make([]Message, 0, len(messages))}
+	for _, m := range messages {
+		interaction.Messages = append(interaction.Messages, Message{Role: messageRole(m), Content: m.GetContent()})
+	}
+	return interaction
+}
+
+func messageRole(m schema.ChatMessage) string {
+	switch m.GetType() {
+	case schema.ChatMessageTypeSystem:
+		return "system"
+	case schema.ChatMessageTypeAI:
+		return "assistant"
+	case schema.ChatMessageTypeHuman:
+		return "user"
+	}
+	return string(m.GetType())
+}
+
+var (
+	_ framework.LLMUsageClient = (*Recorder)(nil)
+	_ framework.LLMUsageClient = (*ReplayClient)(nil)
+)
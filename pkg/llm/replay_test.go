@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+)
+
+func TestReplayClient(t *testing.T) {
+	messages := []Message{{Role: "system", Content: "Be brief."}, {Role: "user", Content: "Hi"}}
+	client := NewReplayClient(
+		Interaction{Model: "gpt-4o", Messages: messages, Response: "first"},
+		Interaction{Model: "gpt-4o", Messages: messages, Response: "second"},
+	)
+	chat := []schema.ChatMessage{schema.SystemChatMessage{Content: "Be brief."}, schema.HumanChatMessage{Content: "Hi"}}
+	ctx := context.Background()
+
+	for _, want := range []string{"first", "second"} {
+		resp, err := client.GenerateFromMessages(ctx, chat, llms.WithModel("gpt-4o"))
+		if err != nil || resp != want {
+			t.Errorf("expected %q, got %q, %v", want, resp, err)
+		}
+	}
+	if _, err := client.GenerateFromMessages(ctx, chat, llms.WithModel("gpt-4o")); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("expected the recording to be used up, got %v", err)
+	}
+	if _, err := client.GenerateFromMessages(ctx, chat); err == nil {
+		t.Error("expected an error for a request with another model")
+	}
+}
+
+func TestRecorderAndReplayProvider(t *testing.T) {
+	mock, _ := New("mock", Config{})
+	recorder := &Recorder{Client: mock}
+	ctx := context.Background()
+	if _, err := recorder.GenerateFromSinglePrompt(ctx, "hello", llms.WithModel("small")); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "recording.json")
+	if err := SaveRecording(path, recorder.Interactions()); err != nil {
+		t.Fatal(err)
+	}
+	replay, err := NewClientFactory(map[string]string{"LLM_REPLAY_FILE": path})(ctx, "replay")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := replay.GenerateFromSinglePrompt(ctx, "hello", llms.WithModel("small"))
+	if err != nil || resp != "hello" {
+		t.Errorf("expected the recorded response, got %q, %v", resp, err)
+	}
+
+	if _, err := New("replay", Config{}); err == nil {
+		t.Error("expected an error without a recording file")
+	}
+}
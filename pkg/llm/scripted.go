@@ -0,0 +1,50 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+
+	"go-workflow/pkg/framework"
+)
+
+// ScriptedClient is a mock LLM for tests of nodes that hold a conversation,
+// such as aiAgent. It answers requests with its responses in order, whatever
+// they ask, and records the requests so tests can check what the node sent.
+// A request after the last response fails.
+type ScriptedClient struct {
+	mu        sync.Mutex
+	responses []string
+	requests  [][]Message
+}
+
+// NewScriptedClient creates a ScriptedClient answering with responses.
+func NewScriptedClient(responses ...string) *ScriptedClient {
+	return &ScriptedClient{responses: responses}
+}
+
+func (c *ScriptedClient) GenerateFromSinglePrompt(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return c.GenerateFromMessages(ctx, []schema.ChatMessage{schema.HumanChatMessage{Content: prompt}}, options...)
+}
+
+func (c *ScriptedClient) GenerateFromMessages(ctx context.Context, messages []schema.ChatMessage, options ...llms.CallOption) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, newInteraction(messages, options).Messages)
+	if len(c.requests) > len(c.responses) {
+		return "", fmt.Errorf("scripted client has no response for request %d", len(c.requests))
+	}
+	return c.responses[len(c.requests)-1], nil
+}
+
+// Requests returns the messages of the requests answered so far.
+func (c *ScriptedClient) Requests() [][]Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]Message(nil), c.requests...)
+}
+
+var _ framework.LangChainChatClient = (*ScriptedClient)(nil)
@@ -0,0 +1,27 @@
+package llm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tmc/langchaingo/schema"
+)
+
+func TestScriptedClient(t *testing.T) {
+	client := NewScriptedClient("first", "second")
+	ctx := context.Background()
+
+	for _, want := range []string{"first", "second"} {
+		got, err := client.GenerateFromMessages(ctx, []schema.ChatMessage{schema.HumanChatMessage{Content: "question"}})
+		if err != nil || got != want {
+			t.Fatalf("expected %q, got %q, %v", want, got, err)
+		}
+	}
+	if _, err := client.GenerateFromSinglePrompt(ctx, "question"); err == nil {
+		t.Error("expected an error once the responses are used up")
+	}
+	requests := client.Requests()
+	if len(requests) != 3 || requests[0][0] != (Message{Role: "user", Content: "question"}) {
+		t.Errorf("unexpected requests %+v", requests)
+	}
+}
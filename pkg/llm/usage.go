@@ -0,0 +1,118 @@
+package llm
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	"github.com/tmc/langchaingo/schema"
+
+	"go-workflow/pkg/framework"
+)
+
+// Price is what a model charges, in US dollars per million tokens.
+type Price struct {
+	Prompt     float64
+	Completion float64
+}
+
+var (
+	pricesMu sync.RWMutex
+	// prices are matched by the longest prefix of the model name, so they
+	// cover dated snapshots such as gpt-4o-2024-08-06.
+	prices = map[string]Price{
+		"gpt-4o":            {Prompt: 2.50, Completion: 10.00},
+		"gpt-4o-mini":       {Prompt: 0.15, Completion: 0.60},
+		"gpt-4-turbo":       {Prompt: 10.00, Completion: 30.00},
+		"gpt-4":             {Prompt: 30.00, Completion: 60.00},
+		"gpt-3.5-turbo":     {Prompt: 0.50, Completion: 1.50},
+		"claude-3-5-sonnet": {Prompt: 3.00, Completion: 15.00},
+		"claude-3-5-haiku":  {Prompt: 0.80, Completion: 4.00},
+		"claude-3-opus":     {Prompt: 15.00, Completion: 75.00},
+		"claude-3-sonnet":   {Prompt: 3.00, Completion: 15.00},
+		"claude-3-haiku":    {Prompt: 0.25, Completion: 1.25},
+		"claude-2":          {Prompt: 8.00, Completion: 24.00},
+	}
+)
+
+// SetPrice sets the price of the models whose names start with model.
+func SetPrice(model string, price Price) {
+	pricesMu.Lock()
+	defer pricesMu.Unlock()
+	prices[model] = price
+}
+
+// Cost returns the price of usage in US dollars, or 0 if the price of its
+// model is unknown, as for local models.
+func Cost(usage framework.LLMUsage) float64 {
+	pricesMu.RLock()
+	defer pricesMu.RUnlock()
+	var price Price
+	matched := ""
+	for prefix, p := range prices {
+		if strings.HasPrefix(usage.Model, prefix) && len(prefix) > len(matched) {
+			price, matched = p, prefix
+		}
+	}
+	return (float64(usage.PromptTokens)*price.Prompt + float64(usage.CompletionTokens)*price.Completion) / 1e6
+}
+
+const (
+	// defaultEncoding counts the tokens of models tiktoken does not know.
+	defaultEncoding = "cl100k_base"
+	// charsPerToken approximates the count when no encoding can be loaded:
+	// tiktoken downloads its encodings on first use.
+	charsPerToken = 4
+	// tokensPerMessage is what OpenAI adds to every chat message, and
+	// tokensPerReply what it adds to prime the answer.
+	tokensPerMessage = 4
+	tokensPerReply   = 3
+)
+
+var (
+	encodingsMu sync.Mutex
+	// encodings caches encodings by name; nil if loading failed.
+	encodings = map[string]*tiktoken.Tiktoken{}
+)
+
+// CountTokens returns the number of tokens of text for model, using the
+// tokenizer of OpenAI models for all models. If the tokenizer cannot be
+// loaded the count is approximated from the length of text.
+func CountTokens(model, text string) int {
+	if enc := encodingFor(model); enc != nil {
+		return len(enc.Encode(text, nil, nil))
+	}
+	return (len([]rune(text)) + charsPerToken - 1) / charsPerToken
+}
+
+func encodingFor(model string) *tiktoken.Tiktoken {
+	name, ok := tiktoken.MODEL_TO_ENCODING[model]
+	if !ok {
+		name = defaultEncoding
+		matched := ""
+		for prefix, encoding := range tiktoken.MODEL_PREFIX_TO_ENCODING {
+			if strings.HasPrefix(model, prefix) && len(prefix) > len(matched) {
+				name, matched = encoding, prefix
+			}
+		}
+	}
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	enc, ok := encodings[name]
+	if !ok {
+		enc, _ = tiktoken.GetEncoding(name)
+		encodings[name] = enc
+	}
+	return enc
+}
+
+// EstimateUsage estimates the usage of a chat request to model that got
+// response, for clients that do not report it.
+func EstimateUsage(model string, messages []schema.ChatMessage, response string) framework.LLMUsage {
+	usage := framework.LLMUsage{Model: model, PromptTokens: tokensPerReply}
+	for _, m := range messages {
+		usage.PromptTokens += tokensPerMessage + CountTokens(model, m.GetContent())
+	}
+	usage.CompletionTokens = CountTokens(model, response)
+	return usage
+}
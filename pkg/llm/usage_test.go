@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+
+	"go-workflow/pkg/framework"
+)
+
+func TestCost(t *testing.T) {
+	tests := []struct {
+		model string
+		want  float64
+	}{
+		{"gpt-4o", 2.50 + 10.00},
+		// The longest prefix wins over gpt-4o.
+		{"gpt-4o-mini-2024-07-18", 0.15 + 0.60},
+		{"claude-3-haiku-20240307", 0.25 + 1.25},
+		{"llama3", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		got := Cost(framework.LLMUsage{Model: tt.model, PromptTokens: 1e6, CompletionTokens: 1e6})
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%q: expected $%v, got $%v", tt.model, tt.want, got)
+		}
+	}
+
+	SetPrice("llama3", Price{Prompt: 1, Completion: 2})
+	defer SetPrice("llama3", Price{})
+	if got := Cost(framework.LLMUsage{Model: "llama3", PromptTokens: 500000, CompletionTokens: 500000}); math.Abs(got-1.5) > 1e-9 {
+		t.Errorf("expected the set price to be used, got $%v", got)
+	}
+}
+
+func TestEstimateUsage(t *testing.T) {
+	messages := []schema.ChatMessage{
+		schema.SystemChatMessage{Content: "Classify the lead."},
+		schema.HumanChatMessage{Content: "Payload: {\"name\": \"Ada\"}"},
+	}
+	usage := EstimateUsage("gpt-4o", messages, "{\"score\": 9}")
+	if usage.Model != "gpt-4o" {
+		t.Errorf("expected model gpt-4o, got %q", usage.Model)
+	}
+	// Every message costs at least its overhead.
+	if usage.PromptTokens <= 2*tokensPerMessage+tokensPerReply || usage.CompletionTokens <= 0 {
+		t.Errorf("expected positive token counts, got %+v", usage)
+	}
+	if CountTokens("gpt-4o", "") != 0 {
+		t.Error("expected no tokens for empty text")
+	}
+}
+
+func TestReplayClient_Usage(t *testing.T) {
+	ctx := context.Background()
+	recorded := &Recorder{Client: NewReplayClient(Interaction{
+		Model:            "gpt-4o",
+		Messages:         []Message{{Role: "user", Content: "Hi"}},
+		Response:         "Hello",
+		PromptTokens:     9,
+		CompletionTokens: 2,
+	})}
+	resp, usage, err := recorded.GenerateWithUsage(ctx, []schema.ChatMessage{schema.HumanChatMessage{Content: "Hi"}}, llms.WithModel("gpt-4o"))
+	if err != nil || resp != "Hello" {
+		t.Fatalf("expected the recorded response, got %q, %v", resp, err)
+	}
+	want := framework.LLMUsage{Model: "gpt-4o", PromptTokens: 9, CompletionTokens: 2}
+	if usage != want {
+		t.Errorf("expected usage %+v, got %+v", want, usage)
+	}
+	// The recorder keeps the usage the replayed client reported.
+	if got := recorded.Interactions()[0]; got.PromptTokens != 9 || got.CompletionTokens != 2 {
+		t.Errorf("expected the usage to be recorded, got %+v", got)
+	}
+}
@@ -0,0 +1,465 @@
+package nodes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template/parse"
+
+	"go-workflow/pkg/framework"
+)
+
+const (
+	// DefaultAgentMaxIterations is how many responses an aiAgent gets from
+	// the model for a record, unless maxIterations is set.
+	DefaultAgentMaxIterations = 5
+	// maxAgentDepth limits agents that call workflows whose agents call
+	// workflows in turn.
+	maxAgentDepth = 3
+	// maxToolResultLength limits the tool results sent to the model;
+	// longer results are truncated.
+	maxToolResultLength = 8000
+)
+
+// AgentTool is a tool of an aiAgent: a node of the workflow, or another
+// workflow loaded through ctx.LoadWorkflow. The tool runs on the record the
+// agent works on, with the arguments of the model set on it, and the model
+// gets the records the tool emits.
+type AgentTool struct {
+	// Name is what the model calls the tool; it defaults to the node or
+	// workflow.
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Node        string `yaml:"node"`
+	Workflow    string `yaml:"workflow"`
+	// Parameters is the JSON schema of the arguments. For nodes it
+	// defaults to the record fields the node reads, taken from its
+	// parameters: those named by parameters such as urlKey or field, and
+	// those used in templates, such as {{.company}} in a prompt.
+	Parameters map[string]interface{} `yaml:"parameters"`
+}
+
+func (t AgentTool) name() string {
+	switch {
+	case t.Name != "":
+		return t.Name
+	case t.Node != "":
+		return t.Node
+	}
+	return t.Workflow
+}
+
+// ValidateAgentTools checks that every tool has either a node or a workflow
+// and a name of its own.
+func ValidateAgentTools(tools []AgentTool) error {
+	names := map[string]bool{}
+	for i, tool := range tools {
+		if (tool.Node == "") == (tool.Workflow == "") {
+			return fmt.Errorf("tool %d needs either a node or a workflow", i+1)
+		}
+		name := tool.name()
+		if strings.ContainsAny(name, " \t\n\"") {
+			return fmt.Errorf("invalid tool name %q", name)
+		}
+		if names[name] {
+			return fmt.Errorf("duplicate tool %s", name)
+		}
+		names[name] = true
+		if tool.Parameters != nil {
+			if err := ValidateJSONSchema(tool.Parameters); err != nil {
+				return fmt.Errorf("tool %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// AIAgentNode runs an LLM in a loop for every record: the model either
+// calls one of the tools, and gets its result, or gives the final answer,
+// which is stored like the response of an OpenAINode. The prompts, the
+// provider and the output options are those of the embedded OpenAINode.
+// Every step is recorded with ctx.AddTrace.
+type AIAgentNode struct {
+	OpenAINode
+	Tools []AgentTool
+	// MaxIterations is how many responses the model gets for a record,
+	// DefaultAgentMaxIterations if zero. The node fails if the model has
+	// not answered by then.
+	MaxIterations int
+
+	// nodes and fields are set by BindNodes: the node of each node tool
+	// and the record fields it reads, mapped to the parameter naming them.
+	nodes  map[string]framework.Node
+	fields map[string]map[string]string
+}
+
+// NewAIAgentNode creates a new AIAgentNode.
+func NewAIAgentNode(systemPrompt string, tools []AgentTool) *AIAgentNode {
+	return &AIAgentNode{OpenAINode: OpenAINode{SystemPrompt: systemPrompt}, Tools: tools}
+}
+
+// agentAction is a model response: a tool call or the final answer.
+type agentAction struct {
+	Tool      string                 `json:"tool"`
+	Arguments map[string]interface{} `json:"arguments"`
+	Answer    json.RawMessage        `json:"answer"`
+}
+
+// BindNodes binds the node tools to the nodes of the workflow.
+func (n *AIAgentNode) BindNodes(nodes map[string]framework.Node, defs map[string]*framework.NodeDef) error {
+	n.nodes = map[string]framework.Node{}
+	n.fields = map[string]map[string]string{}
+	for _, tool := range n.Tools {
+		if tool.Node == "" {
+			continue
+		}
+		node, ok := nodes[tool.Node]
+		if !ok {
+			return fmt.Errorf("tool %s uses unknown node %s", tool.name(), tool.Node)
+		}
+		if node == framework.Node(n) {
+			return fmt.Errorf("tool %s cannot be the agent itself", tool.name())
+		}
+		n.nodes[tool.name()] = node
+		if def := defs[tool.Node]; def != nil {
+			n.fields[tool.name()] = recordFields(def.Params)
+		}
+	}
+	return nil
+}
+
+func (n *AIAgentNode) Execute(ctx *framework.Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+	var out []map[string]interface{}
+	for _, rec := range inputs {
+		answer, err := n.run(ctx, rec)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, n.merge(rec, answer))
+	}
+	return out, nil
+}
+
+// run holds the conversation about one record and returns the answer.
+func (n *AIAgentNode) run(ctx *framework.Context, rec map[string]interface{}) (interface{}, error) {
+	messages, err := n.agentMessages(rec, ctx.Env)
+	if err != nil {
+		return nil, err
+	}
+	maxIterations := n.MaxIterations
+	if maxIterations == 0 {
+		maxIterations = DefaultAgentMaxIterations
+	}
+	for step := 1; step <= maxIterations; step++ {
+		if err := ctx.CheckLLMBudget(); err != nil {
+			return nil, err
+		}
+		resp, err := n.generate(ctx, messages)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, PromptMessage{Role: RoleAssistant, Content: resp})
+
+		action, err := n.parseAction(resp)
+		if err != nil {
+			ctx.AddTrace(framework.TraceStep{Step: step, Type: framework.TraceInvalid, Output: resp, Error: err.Error()})
+			messages = append(messages, PromptMessage{Role: RoleUser, Content: fmt.Sprintf("Your previous response was not valid: %v\n\nRespond again with a tool call or the answer, as JSON only.", err)})
+			continue
+		}
+		if action.Tool == "" {
+			var answer interface{}
+			json.Unmarshal(action.Answer, &answer)
+			ctx.AddTrace(framework.TraceStep{Step: step, Type: framework.TraceAnswer, Output: answer})
+			return answer, nil
+		}
+
+		if ctx.Logger != nil {
+			ctx.Logger.Infof("agent %s step %d: calling %s", ctx.Node, step, action.Tool)
+		}
+		outputs, err := n.callTool(ctx, rec, action)
+		trace := framework.TraceStep{Step: step, Type: framework.TraceToolCall, Tool: action.Tool, Input: action.Arguments, Output: outputs}
+		var result string
+		if err != nil {
+			// The model is told about failing tools, except when the run
+			// has to stop.
+			if errors.Is(err, framework.ErrBudgetExceeded) || ctx.Ctx.Err() != nil {
+				return nil, err
+			}
+			trace.Error = err.Error()
+			result = fmt.Sprintf("Tool %s failed: %v", action.Tool, err)
+		} else {
+			result = fmt.Sprintf("Result of %s: %s", action.Tool, toolResult(outputs))
+		}
+		ctx.AddTrace(trace)
+		messages = append(messages, PromptMessage{Role: RoleUser, Content: result})
+	}
+	return nil, fmt.Errorf("agent did not answer within %d iterations", maxIterations)
+}
+
+// agentMessages renders the prompt for a record and adds the tools and how
+// to call them to the system prompt.
+func (n *AIAgentNode) agentMessages(rec map[string]interface{}, env map[string]string) ([]PromptMessage, error) {
+	// The output schema applies to the answer, not to every response.
+	base := n.OpenAINode
+	base.OutputSchema = nil
+	messages, err := base.messages(rec, env)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	b.WriteString("You can use these tools:\n")
+	for _, tool := range n.Tools {
+		parameters, _ := json.Marshal(n.toolParameters(tool, env))
+		fmt.Fprintf(&b, "- %s", tool.name())
+		if tool.Description != "" {
+			fmt.Fprintf(&b, ": %s", tool.Description)
+		}
+		fmt.Fprintf(&b, "\n  Arguments: %s\n", parameters)
+	}
+	b.WriteString("\nTo call a tool, respond only with JSON: {\"tool\": \"<name>\", \"arguments\": {...}}. You will get its result in the next message.\n")
+	b.WriteString("When you have the final answer, respond only with JSON: {\"answer\": <answer>}.")
+	if n.OutputSchema != nil {
+		outputSchema, _ := json.Marshal(n.OutputSchema)
+		fmt.Fprintf(&b, " The answer must match this JSON schema: %s", outputSchema)
+	} else if n.OutputKey == "" {
+		b.WriteString(" The answer must be a JSON object.")
+	}
+
+	if len(messages) > 0 && messages[0].Role == RoleSystem {
+		messages[0].Content += "\n\n" + b.String()
+		return messages, nil
+	}
+	return append([]PromptMessage{{Role: RoleSystem, Content: b.String()}}, messages...), nil
+}
+
+// toolParameters returns the JSON schema of the arguments of a tool. Record
+// fields a node reads that are set in the environment are left out.
+func (n *AIAgentNode) toolParameters(tool AgentTool, env map[string]string) map[string]interface{} {
+	if tool.Parameters != nil {
+		return tool.Parameters
+	}
+	properties := map[string]interface{}{}
+	for field, param := range n.fields[tool.name()] {
+		if _, ok := env[field]; ok {
+			continue
+		}
+		properties[field] = map[string]interface{}{"description": fmt.Sprintf("read by the %s parameter", param)}
+	}
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// parseAction decodes a model response into a tool call or an answer.
+func (n *AIAgentNode) parseAction(resp string) (agentAction, error) {
+	var action agentAction
+	if err := json.Unmarshal([]byte(extractJSON(resp)), &action); err != nil {
+		return action, err
+	}
+	if action.Tool != "" {
+		for _, tool := range n.Tools {
+			if tool.name() == action.Tool {
+				return action, nil
+			}
+		}
+		names := make([]string, 0, len(n.Tools))
+		for _, tool := range n.Tools {
+			names = append(names, tool.name())
+		}
+		return action, fmt.Errorf("unknown tool %q, use one of %s", action.Tool, strings.Join(names, ", "))
+	}
+	if action.Answer == nil {
+		return action, errors.New(`expected {"tool": ...} or {"answer": ...}`)
+	}
+	var answer interface{}
+	if err := json.Unmarshal(action.Answer, &answer); err != nil {
+		return action, err
+	}
+	return action, n.check(answer)
+}
+
+// callTool runs a tool on the record with the arguments of the model set on
+// it and returns the records the tool emits.
+func (n *AIAgentNode) callTool(ctx *framework.Context, rec map[string]interface{}, action agentAction) ([]map[string]interface{}, error) {
+	input := make(map[string]interface{}, len(rec)+len(action.Arguments))
+	for k, v := range rec {
+		input[k] = v
+	}
+	for k, v := range action.Arguments {
+		input[k] = v
+	}
+	for _, tool := range n.Tools {
+		if tool.name() != action.Tool {
+			continue
+		}
+		if tool.Workflow != "" {
+			return n.runWorkflow(ctx, tool.Workflow, input)
+		}
+		node, ok := n.nodes[tool.name()]
+		if !ok {
+			return nil, fmt.Errorf("tool %s is not bound to node %s", tool.name(), tool.Node)
+		}
+		agent := ctx.Node
+		ctx.Node = tool.Node
+		defer func() { ctx.Node = agent }()
+		return node.Execute(ctx, []map[string]interface{}{input})
+	}
+	return nil, fmt.Errorf("unknown tool %s", action.Tool)
+}
+
+type agentDepthKey struct{}
+
+// runWorkflow runs another workflow on input and returns its outputs. Its
+// nodes run as part of the agent, so they report no node statuses.
+func (n *AIAgentNode) runWorkflow(ctx *framework.Context, name string, input map[string]interface{}) ([]map[string]interface{}, error) {
+	if ctx.LoadWorkflow == nil {
+		return nil, errors.New("no workflow loader available")
+	}
+	depth, _ := ctx.Ctx.Value(agentDepthKey{}).(int)
+	if depth >= maxAgentDepth {
+		return nil, fmt.Errorf("workflow %s not run: agents are nested more than %d deep", name, maxAgentDepth)
+	}
+	def, err := ctx.LoadWorkflow(ctx.Ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workflow %s: %w", name, err)
+	}
+	wf, err := framework.BuildWorkflow(def, ctx.NodeTypes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build workflow %s: %w", name, err)
+	}
+	sub := *ctx
+	sub.Ctx = context.WithValue(ctx.Ctx, agentDepthKey{}, depth+1)
+	sub.NodeDone = nil
+	if sub.AWS != nil {
+		if sub.AWS, err = sub.AWS.WithProfiles(def.AWSProfiles); err != nil {
+			return nil, fmt.Errorf("workflow %s: %w", name, err)
+		}
+	}
+	if sub.SQL != nil {
+		if sub.SQL, err = sub.SQL.WithDatabases(def.Databases); err != nil {
+			return nil, fmt.Errorf("workflow %s: %w", name, err)
+		}
+	}
+	return wf.RunOutputs(&sub, def.StartNode(), []map[string]interface{}{input})
+}
+
+// toolResult formats the records a tool emitted for the model.
+func toolResult(outputs []map[string]interface{}) string {
+	if outputs == nil {
+		outputs = []map[string]interface{}{}
+	}
+	data, err := json.Marshal(outputs)
+	if err != nil {
+		return fmt.Sprintf("%v", outputs)
+	}
+	if len(data) > maxToolResultLength {
+		return string(data[:maxToolResultLength]) + "... (truncated)"
+	}
+	return string(data)
+}
+
+// recordFields returns the record fields a node reads according to its
+// parameters, mapped to the parameter naming them: the values of parameters
+// such as urlKey, vectorField or field, and the fields used in templates.
+func recordFields(params map[string]interface{}) map[string]string {
+	fields := map[string]string{}
+	for _, param := range sortedKeys(params) {
+		value := params[param]
+		if text, ok := value.(string); ok && text != "" && param != "outputKey" &&
+			(param == "field" || strings.HasSuffix(param, "Key") || strings.HasSuffix(param, "Field")) {
+			if _, seen := fields[text]; !seen {
+				fields[text] = param
+			}
+		}
+		collectTemplateFields(param, value, fields)
+	}
+	return fields
+}
+
+// collectTemplateFields adds the fields used by the templates in a
+// parameter value, which may be nested in maps and lists.
+func collectTemplateFields(param string, value interface{}, fields map[string]string) {
+	switch value := value.(type) {
+	case string:
+		for _, field := range templateFields(value) {
+			if _, seen := fields[field]; !seen {
+				fields[field] = param
+			}
+		}
+	case map[string]interface{}:
+		for _, key := range sortedKeys(value) {
+			collectTemplateFields(param+"."+key, value[key], fields)
+		}
+	case []interface{}:
+		for _, item := range value {
+			collectTemplateFields(param, item, fields)
+		}
+	}
+}
+
+// templateFields returns the fields a template uses, such as company in
+// {{.company}}. Fields inside range and with blocks are relative to another
+// value and are left out.
+func templateFields(text string) []string {
+	if !strings.Contains(text, "{{") {
+		return nil
+	}
+	tree := parse.New("fields")
+	tree.Mode = parse.SkipFuncCheck
+	if _, err := tree.Parse(text, "", "", map[string]*parse.Tree{}); err != nil {
+		return nil
+	}
+	var fields []string
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch node := node.(type) {
+		case *parse.ListNode:
+			if node == nil {
+				return
+			}
+			for _, child := range node.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(node.Pipe)
+		case *parse.PipeNode:
+			if node == nil {
+				return
+			}
+			for _, cmd := range node.Cmds {
+				for _, arg := range cmd.Args {
+					walk(arg)
+				}
+			}
+		case *parse.ChainNode:
+			walk(node.Node)
+		case *parse.FieldNode:
+			fields = append(fields, node.Ident[0])
+		case *parse.IfNode:
+			walk(node.Pipe)
+			walk(node.List)
+			walk(node.ElseList)
+		case *parse.RangeNode:
+			walk(node.Pipe)
+			walk(node.ElseList)
+		case *parse.WithNode:
+			walk(node.Pipe)
+			walk(node.ElseList)
+		}
+	}
+	walk(tree.Root)
+	return fields
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var _ framework.NodeBinder = (*AIAgentNode)(nil)
@@ -0,0 +1,264 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+
+	"go-workflow/pkg/framework"
+	"go-workflow/pkg/llm"
+)
+
+// lookupNode is a tool node that looks up the size of a company.
+func lookupNode() *CodeNode {
+	return NewCodeNode(func(inputs []map[string]interface{}) []map[string]interface{} {
+		var out []map[string]interface{}
+		for _, rec := range inputs {
+			out = append(out, map[string]interface{}{"company": rec["company"], "employees": 50})
+		}
+		return out
+	})
+}
+
+func newAgent(t *testing.T, tools []AgentTool, nodes map[string]framework.Node, defs map[string]*framework.NodeDef) *AIAgentNode {
+	t.Helper()
+	if err := ValidateAgentTools(tools); err != nil {
+		t.Fatalf("invalid tools: %v", err)
+	}
+	agent := NewAIAgentNode("You research companies.", tools)
+	if err := agent.BindNodes(nodes, defs); err != nil {
+		t.Fatalf("BindNodes failed: %v", err)
+	}
+	return agent
+}
+
+func TestAIAgentNode_CallsNodeTool(t *testing.T) {
+	client := llm.NewScriptedClient(
+		`{"tool": "lookup", "arguments": {"company": "acme"}}`,
+		`{"answer": {"size": "small"}}`,
+	)
+	var trace []framework.TraceStep
+	ctx := &framework.Context{
+		Ctx:       context.Background(),
+		LangChain: client,
+		Node:      "agent",
+		Env:       map[string]string{"token": "secret"},
+		Trace:     func(step framework.TraceStep) { trace = append(trace, step) },
+	}
+	defs := map[string]*framework.NodeDef{"lookupCompany": {Type: "httpRequest", Params: map[string]interface{}{
+		"urlKey":  "url",
+		"headers": map[string]interface{}{"Authorization": "Bearer {{.token}}", "X-Company": "{{.company}}"},
+	}}}
+	agent := newAgent(t, []AgentTool{{Name: "lookup", Node: "lookupCompany", Description: "Looks up a company."}},
+		map[string]framework.Node{"lookupCompany": lookupNode()}, defs)
+
+	out, err := agent.Execute(ctx, []map[string]interface{}{{"id": 1}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(out, []map[string]interface{}{{"id": 1, "size": "small"}}) {
+		t.Errorf("expected the answer merged into the record, got %v", out)
+	}
+
+	requests := client.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	system := requests[0][0].Content
+	if !strings.HasPrefix(system, "You research companies.") || !strings.Contains(system, "- lookup: Looks up a company.") {
+		t.Errorf("expected the tools in the system prompt, got %q", system)
+	}
+	// The tool takes the fields its node reads, except those set in the
+	// environment.
+	if !strings.Contains(system, `"company":{"description":"read by the headers.X-Company parameter"}`) ||
+		!strings.Contains(system, `"url":{"description":"read by the urlKey parameter"}`) || strings.Contains(system, `"token"`) {
+		t.Errorf("expected the parameters of the lookup node, got %q", system)
+	}
+	last := requests[1][len(requests[1])-1]
+	if last.Role != RoleUser || last.Content != `Result of lookup: [{"company":"acme","employees":50}]` {
+		t.Errorf("expected the tool result to be sent to the model, got %+v", last)
+	}
+
+	if len(trace) != 2 {
+		t.Fatalf("expected 2 trace steps, got %+v", trace)
+	}
+	if trace[0].Node != "agent" || trace[0].Step != 1 || trace[0].Type != framework.TraceToolCall || trace[0].Tool != "lookup" ||
+		!reflect.DeepEqual(trace[0].Input, map[string]interface{}{"company": "acme"}) {
+		t.Errorf("unexpected tool call step %+v", trace[0])
+	}
+	if trace[1].Step != 2 || trace[1].Type != framework.TraceAnswer || !reflect.DeepEqual(trace[1].Output, map[string]interface{}{"size": "small"}) {
+		t.Errorf("unexpected answer step %+v", trace[1])
+	}
+	if ctx.Node != "agent" {
+		t.Errorf("expected the context to name the agent after the tool call, got %s", ctx.Node)
+	}
+}
+
+func TestAIAgentNode_InvalidResponsesAndToolErrors(t *testing.T) {
+	client := llm.NewScriptedClient(
+		`I should look this up.`,
+		`{"tool": "search", "arguments": {}}`,
+		`{"tool": "failing", "arguments": {}}`,
+		`{"answer": "unknown"}`,
+	)
+	var trace []framework.TraceStep
+	ctx := &framework.Context{
+		Ctx:       context.Background(),
+		LangChain: client,
+		Trace:     func(step framework.TraceStep) { trace = append(trace, step) },
+	}
+	failing := NewCodeNode(nil)
+	agent := newAgent(t, []AgentTool{{Node: "failing"}}, map[string]framework.Node{"failing": failing}, nil)
+	agent.OutputKey = "size"
+
+	out, err := agent.Execute(ctx, []map[string]interface{}{{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out[0]["size"] != "unknown" {
+		t.Errorf("expected the answer under the output key, got %v", out)
+	}
+	types := make([]string, 0, len(trace))
+	for _, step := range trace {
+		types = append(types, step.Type)
+	}
+	if !reflect.DeepEqual(types, []string{framework.TraceInvalid, framework.TraceInvalid, framework.TraceToolCall, framework.TraceAnswer}) {
+		t.Errorf("unexpected trace %v", types)
+	}
+	if !strings.Contains(trace[1].Error, `unknown tool "search"`) || trace[2].Error != "code node has no function" {
+		t.Errorf("expected the errors in the trace, got %+v", trace)
+	}
+	requests := client.Requests()
+	if last := requests[3][len(requests[3])-1].Content; last != "Tool failing failed: code node has no function" {
+		t.Errorf("expected the tool error to be sent to the model, got %q", last)
+	}
+}
+
+func TestAIAgentNode_MaxIterations(t *testing.T) {
+	call := `{"tool": "lookup", "arguments": {"company": "acme"}}`
+	ctx := &framework.Context{Ctx: context.Background(), LangChain: llm.NewScriptedClient(call, call, call)}
+	agent := newAgent(t, []AgentTool{{Name: "lookup", Node: "lookupCompany"}}, map[string]framework.Node{"lookupCompany": lookupNode()}, nil)
+	agent.MaxIterations = 2
+
+	_, err := agent.Execute(ctx, []map[string]interface{}{{}})
+	if err == nil || !strings.Contains(err.Error(), "within 2 iterations") {
+		t.Errorf("expected the agent to stop after 2 iterations, got %v", err)
+	}
+}
+
+func TestAIAgentNode_OutputSchema(t *testing.T) {
+	client := llm.NewScriptedClient(`{"answer": {"size": 3}}`, `{"answer": {"size": "small"}}`)
+	ctx := &framework.Context{Ctx: context.Background(), LangChain: client}
+	agent := newAgent(t, []AgentTool{{Name: "lookup", Node: "lookupCompany"}}, map[string]framework.Node{"lookupCompany": lookupNode()}, nil)
+	agent.OutputSchema = map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"size": map[string]interface{}{"type": "string"}},
+	}
+
+	out, err := agent.Execute(ctx, []map[string]interface{}{{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out[0]["size"] != "small" {
+		t.Errorf("expected the valid answer, got %v", out)
+	}
+	if system := client.Requests()[0][0].Content; !strings.Contains(system, `The answer must match this JSON schema: {"properties"`) {
+		t.Errorf("expected the output schema in the system prompt, got %q", system)
+	}
+}
+
+func TestAIAgentNode_Budget(t *testing.T) {
+	// usageChat never answers, so only the budget stops the agent.
+	ctx := &framework.Context{
+		Ctx:       context.Background(),
+		LangChain: &usageChat{usage: framework.LLMUsage{PromptTokens: 60}},
+		RunUsage:  framework.NewUsageMeter("run", framework.Budget{MaxTokens: 100}),
+	}
+	agent := newAgent(t, []AgentTool{{Name: "lookup", Node: "lookupCompany"}}, map[string]framework.Node{"lookupCompany": lookupNode()}, nil)
+
+	_, err := agent.Execute(ctx, []map[string]interface{}{{}})
+	if !errors.Is(err, framework.ErrBudgetExceeded) {
+		t.Errorf("expected the budget to stop the agent, got %v", err)
+	}
+}
+
+func TestAIAgentNode_WorkflowTool(t *testing.T) {
+	// The workflow is built with the node types of the run.
+	nodeTypes := framework.NewRegistry()
+	if err := nodeTypes.Register(framework.NodeTypeInfo{Type: "testAgentLookup"}, func(nodeDef *yaml.Node) (framework.Node, error) {
+		return lookupNode(), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	client := llm.NewScriptedClient(
+		`{"tool": "research", "arguments": {"company": "acme"}}`,
+		`{"answer": {"done": true}}`,
+	)
+	var loaded []string
+	ctx := &framework.Context{
+		Ctx:       context.Background(),
+		LangChain: client,
+		Logger:    zap.NewNop().Sugar(),
+		Metrics:   framework.NewMetrics(prometheus.NewRegistry()),
+		NodeTypes: nodeTypes,
+		LoadWorkflow: func(ctx context.Context, name string) (*framework.WorkflowDef, error) {
+			loaded = append(loaded, name)
+			return framework.LoadWorkflowDefFromYAMLString(`
+start: lookup
+nodes:
+  lookup:
+    type: testAgentLookup
+`)
+		},
+	}
+	agent := newAgent(t, []AgentTool{{Name: "research", Workflow: "company-research"}}, nil, nil)
+
+	if _, err := agent.Execute(ctx, []map[string]interface{}{{}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, []string{"company-research"}) {
+		t.Errorf("expected the workflow to be loaded, got %v", loaded)
+	}
+	second := client.Requests()[1]
+	if last := second[len(second)-1].Content; last != `Result of research: [{"company":"acme","employees":50}]` {
+		t.Errorf("expected the outputs of the workflow, got %q", last)
+	}
+}
+
+func TestAIAgentNode_BindNodes(t *testing.T) {
+	agent := NewAIAgentNode("", []AgentTool{{Node: "missing"}})
+	if err := agent.BindNodes(map[string]framework.Node{}, nil); err == nil {
+		t.Error("expected an error for an unknown tool node")
+	}
+	self := NewAIAgentNode("", []AgentTool{{Node: "agent"}})
+	if err := self.BindNodes(map[string]framework.Node{"agent": self}, nil); err == nil {
+		t.Error("expected an error for an agent using itself as a tool")
+	}
+}
+
+func TestValidateAgentTools(t *testing.T) {
+	tests := map[string][]AgentTool{
+		"neither node nor workflow": {{Name: "lookup"}},
+		"node and workflow":         {{Node: "lookup", Workflow: "research"}},
+		"duplicate name":            {{Node: "lookup"}, {Name: "lookup", Workflow: "research"}},
+		"invalid name":              {{Name: "look up", Node: "lookup"}},
+	}
+	for name, tools := range tests {
+		if err := ValidateAgentTools(tools); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestTemplateFields(t *testing.T) {
+	fields := templateFields(`{{.company}} {{if .country}}in {{.country}}{{end}} {{range .people}}{{.name}}{{end}} {{json record}}`)
+	if !reflect.DeepEqual(fields, []string{"company", "country", "country", "people"}) {
+		t.Errorf("unexpected fields %v", fields)
+	}
+}
@@ -0,0 +1,162 @@
+package nodes
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/google/uuid"
+
+	"go-workflow/pkg/framework"
+)
+
+// setCodeHelpers defines the helpers available to the code of a CodeNode:
+//
+//   - console.log, info, warn, error and debug write to the log and record
+//     TraceConsole steps.
+//   - dates.now, parse, format, add and diff work with dates given as
+//     RFC 3339 strings, Date objects or milliseconds since the epoch; they
+//     return RFC 3339 strings, and layouts are Go layouts such as
+//     "2006-01-02".
+//   - crypto.md5, sha1, sha256, sha512 and hmacSha256 return hex digests,
+//     and crypto.randomUUID a random UUID.
+func setCodeHelpers(ctx *framework.Context, vm *goja.Runtime) error {
+	if err := vm.Set("console", codeConsole(ctx, vm)); err != nil {
+		return err
+	}
+	if err := vm.Set("dates", codeDates(vm)); err != nil {
+		return err
+	}
+	return vm.Set("crypto", codeCrypto())
+}
+
+// codeConsole returns the console object of a CodeNode.
+func codeConsole(ctx *framework.Context, vm *goja.Runtime) *goja.Object {
+	console := vm.NewObject()
+	step := 0
+	for _, level := range []string{"log", "info", "warn", "error", "debug"} {
+		level := level
+		console.Set(level, func(call goja.FunctionCall) goja.Value {
+			message := consoleMessage(call.Arguments)
+			if ctx.Logger != nil {
+				switch level {
+				case "warn":
+					ctx.Logger.Warnf("code %s: %s", ctx.Node, message)
+				case "error":
+					ctx.Logger.Errorf("code %s: %s", ctx.Node, message)
+				case "debug":
+					ctx.Logger.Debugf("code %s: %s", ctx.Node, message)
+				default:
+					ctx.Logger.Infof("code %s: %s", ctx.Node, message)
+				}
+			}
+			step++
+			ctx.AddTrace(framework.TraceStep{Step: step, Type: framework.TraceConsole, Output: map[string]interface{}{"level": level, "message": message}})
+			return goja.Undefined()
+		})
+	}
+	return console
+}
+
+// codeDates returns the dates helper of a CodeNode.
+func codeDates(vm *goja.Runtime) map[string]interface{} {
+	return map[string]interface{}{
+		"now": func() string {
+			return time.Now().UTC().Format(time.RFC3339Nano)
+		},
+		"parse": func(value goja.Value, layout string) (string, error) {
+			t, err := codeTime(value, layout)
+			if err != nil {
+				return "", err
+			}
+			return t.Format(time.RFC3339Nano), nil
+		},
+		"format": func(value goja.Value, layout string) (string, error) {
+			if layout == "" {
+				layout = time.RFC3339
+			}
+			t, err := codeTime(value, "")
+			if err != nil {
+				return "", err
+			}
+			return t.Format(layout), nil
+		},
+		"add": func(value goja.Value, duration string) (string, error) {
+			t, err := codeTime(value, "")
+			if err != nil {
+				return "", err
+			}
+			d, err := time.ParseDuration(duration)
+			if err != nil {
+				return "", err
+			}
+			return t.Add(d).Format(time.RFC3339Nano), nil
+		},
+		"diff": func(a, b goja.Value) (int64, error) {
+			ta, err := codeTime(a, "")
+			if err != nil {
+				return 0, err
+			}
+			tb, err := codeTime(b, "")
+			if err != nil {
+				return 0, err
+			}
+			return ta.Sub(tb).Milliseconds(), nil
+		},
+	}
+}
+
+// codeTime converts a date given to the dates helper. Strings are parsed
+// with layout, or as RFC 3339 timestamps or dates if it is empty.
+func codeTime(value goja.Value, layout string) (time.Time, error) {
+	if value == nil || goja.IsUndefined(value) || goja.IsNull(value) {
+		return time.Time{}, fmt.Errorf("no date given")
+	}
+	switch v := value.Export().(type) {
+	case time.Time:
+		return v.UTC(), nil
+	case int64:
+		return time.UnixMilli(v).UTC(), nil
+	case float64:
+		return time.UnixMilli(int64(v)).UTC(), nil
+	case string:
+		if layout != "" {
+			return time.Parse(layout, v)
+		}
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, nil
+		}
+		return time.Parse("2006-01-02", v)
+	}
+	return time.Time{}, fmt.Errorf("invalid date %s", value.String())
+}
+
+// codeCrypto returns the crypto helper of a CodeNode.
+func codeCrypto() map[string]interface{} {
+	digest := func(newHash func() hash.Hash) func(string) string {
+		return func(data string) string {
+			h := newHash()
+			h.Write([]byte(data))
+			return hex.EncodeToString(h.Sum(nil))
+		}
+	}
+	return map[string]interface{}{
+		"md5":    digest(md5.New),
+		"sha1":   digest(sha1.New),
+		"sha256": digest(sha256.New),
+		"sha512": digest(sha512.New),
+		"hmacSha256": func(key, data string) string {
+			return digest(func() hash.Hash { return hmac.New(sha256.New, []byte(key)) })(data)
+		},
+		"randomUUID": func() string {
+			return uuid.New().String()
+		},
+	}
+}
@@ -1,10 +1,305 @@
 package nodes
 
-import "go-workflow/pkg/framework"
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "strings"
+    "time"
 
-// CodeNode applies a transform function
-type CodeNode struct{ Fn func([]map[string]interface{}) []map[string]interface{} }
+    "github.com/dop251/goja"
+
+    "go-workflow/pkg/framework"
+)
+
+// Modes of CodeNode.
+const (
+    // CodeAllItems runs the code once with all records as items.
+    CodeAllItems = "all"
+    // CodeEachItem runs the code once for every record, as item.
+    CodeEachItem = "each"
+)
+
+// DefaultCodeTimeout is how long the code of a CodeNode may run per
+// execution, unless Timeout is set.
+const DefaultCodeTimeout = 10 * time.Second
+
+// codePrelude defines the n8n style $input accessor on top of items and
+// item.
+const codePrelude = `var $input = {
+    all: function () { return items.map(function (json) { return {json: json}; }); },
+    first: function () { return items.length ? {json: items[0]} : undefined; },
+    last: function () { return items.length ? {json: items[items.length - 1]} : undefined; },
+    get item() { return typeof item === "undefined" ? undefined : {json: item}; }
+};`
+
+var codePreludeProgram = goja.MustCompile("prelude", codePrelude, false)
+
+// CodeNode transforms records with Fn, with a registered Go Function, or
+// with JavaScript Code run in an embedded interpreter.
+//
+// The code is the body of a function. In CodeAllItems mode it sees the
+// records as items and returns an array of records; in CodeEachItem mode it
+// sees a record as item (also $json, with its position as $index) and
+// returns a record, an array of records or null to drop it. If it returns
+// nothing, items or item are emitted, including changes made to them. n8n
+// style {json: {...}} records and $input are understood as well.
+//
+// The helpers console, dates and crypto are available, see setCodeHelpers.
+// Console output goes to the log and, as TraceConsole steps, to the run
+// history.
+type CodeNode struct {
+    Fn func([]map[string]interface{}) []map[string]interface{}
+    // Function is a function registered with framework.RegisterFunction,
+    // bound to the parameters of the node.
+    Function framework.FunctionCall
+    Code     string
+    // Mode is CodeAllItems or CodeEachItem; CodeAllItems if empty.
+    Mode string
+    // Timeout limits how long the code runs per execution,
+    // DefaultCodeTimeout if zero.
+    Timeout time.Duration
+
+    program *goja.Program
+}
+
+// NewCodeNode creates a new CodeNode wrapping fn.
+func NewCodeNode(fn func([]map[string]interface{}) []map[string]interface{}) *CodeNode {
+    return &CodeNode{Fn: fn}
+}
+
+// NewJSCodeNode creates a new CodeNode running JavaScript code in mode.
+func NewJSCodeNode(code, mode string) (*CodeNode, error) {
+    n := &CodeNode{Code: code, Mode: mode}
+    if err := ValidateCodeNode(n); err != nil {
+        return nil, err
+    }
+    return n, nil
+}
+
+// ValidateCodeNode checks the mode and limits of a CodeNode and compiles
+// its code, so syntax errors are reported when the workflow is loaded.
+func ValidateCodeNode(n *CodeNode) error {
+    switch n.Mode {
+    case "", CodeAllItems, CodeEachItem:
+    default:
+        return fmt.Errorf("unknown mode %q; use all or each", n.Mode)
+    }
+    if n.Timeout < 0 {
+        return errors.New("timeout must not be negative")
+    }
+    if n.Code == "" {
+        if n.Fn == nil && n.Function == nil {
+            return errors.New("code or function is required")
+        }
+        return nil
+    }
+    if n.Function != nil {
+        return errors.New("code and function are exclusive")
+    }
+    program, err := compileCode(n.Code)
+    if err != nil {
+        return err
+    }
+    n.program = program
+    return nil
+}
+
+// compileCode compiles code as the body of a function, keeping its line
+// numbers.
+func compileCode(code string) (*goja.Program, error) {
+    program, err := goja.Compile("code", "(function () {"+code+"\n})()", false)
+    if err != nil {
+        return nil, fmt.Errorf("invalid code: %w", err)
+    }
+    return program, nil
+}
 
 func (n *CodeNode) Execute(ctx *framework.Context, input []map[string]interface{}) ([]map[string]interface{}, error) {
-    return n.Fn(input), nil
-}
\ No newline at end of file
+    if n.Fn != nil {
+        return n.Fn(input), nil
+    }
+    if n.Function != nil {
+        return n.Function(ctx, input)
+    }
+    if n.Code == "" {
+        return nil, fmt.Errorf("code node has no function")
+    }
+    program := n.program
+    if program == nil {
+        var err error
+        if program, err = compileCode(n.Code); err != nil {
+            return nil, err
+        }
+    }
+
+    vm := goja.New()
+    if err := setCodeHelpers(ctx, vm); err != nil {
+        return nil, err
+    }
+    items, err := codeItems(vm, input)
+    if err != nil {
+        return nil, err
+    }
+    if err := vm.Set("items", items); err != nil {
+        return nil, err
+    }
+    if _, err := vm.RunProgram(codePreludeProgram); err != nil {
+        return nil, err
+    }
+
+    stop := n.limit(ctx.Ctx, vm)
+    defer stop()
+    if n.Mode != CodeEachItem {
+        result, err := vm.RunProgram(program)
+        if err != nil {
+            return nil, codeError(err)
+        }
+        if goja.IsUndefined(result) {
+            result = vm.Get("items")
+        }
+        return codeRecords(result)
+    }
+
+    var outputs []map[string]interface{}
+    for i := range input {
+        item := items.Get(fmt.Sprint(i))
+        vm.Set("item", item)
+        vm.Set("$json", item)
+        vm.Set("$index", i)
+        result, err := vm.RunProgram(program)
+        if err != nil {
+            return nil, codeError(err)
+        }
+        if goja.IsUndefined(result) {
+            result = item
+        }
+        records, err := codeRecords(result)
+        if err != nil {
+            return nil, fmt.Errorf("item %d: %w", i, err)
+        }
+        outputs = append(outputs, records...)
+    }
+    return outputs, nil
+}
+
+// Errors that interrupt the code of a CodeNode.
+var (
+    errCodeTimeout  = errors.New("code timed out")
+    errCodeCanceled = errors.New("code canceled")
+)
+
+// limit interrupts vm when the code runs longer than Timeout or ctx is
+// canceled, until stop is called.
+func (n *CodeNode) limit(ctx context.Context, vm *goja.Runtime) (stop func()) {
+    timeout := n.Timeout
+    if timeout == 0 {
+        timeout = DefaultCodeTimeout
+    }
+    if ctx == nil {
+        ctx = context.Background()
+    }
+    timer := time.NewTimer(timeout)
+
+    done := make(chan struct{})
+    go func() {
+        defer timer.Stop()
+        select {
+        case <-done:
+        case <-ctx.Done():
+            vm.Interrupt(errCodeCanceled)
+        case <-timer.C:
+            vm.Interrupt(fmt.Errorf("%w after %s", errCodeTimeout, timeout))
+        }
+    }()
+    return func() { close(done) }
+}
+
+// codeError unwraps the error of an interrupted run.
+func codeError(err error) error {
+    var interrupted *goja.InterruptedError
+    if errors.As(err, &interrupted) {
+        if cause, ok := interrupted.Value().(error); ok {
+            return cause
+        }
+    }
+    return err
+}
+
+// codeItems converts records to a JavaScript array of plain objects, so
+// the code cannot modify the input records.
+func codeItems(vm *goja.Runtime, records []map[string]interface{}) (*goja.Object, error) {
+    if records == nil {
+        records = []map[string]interface{}{}
+    }
+    data, err := json.Marshal(records)
+    if err != nil {
+        return nil, fmt.Errorf("records cannot be passed to code: %w", err)
+    }
+    parse, _ := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+    items, err := parse(goja.Undefined(), vm.ToValue(string(data)))
+    if err != nil {
+        return nil, err
+    }
+    return items.ToObject(vm), nil
+}
+
+// codeRecords converts the result of code to records: an array is a list
+// of records, an object a record, and null no record.
+func codeRecords(result goja.Value) ([]map[string]interface{}, error) {
+    if result == nil || goja.IsNull(result) || goja.IsUndefined(result) {
+        return nil, nil
+    }
+    var values []interface{}
+    switch v := result.Export().(type) {
+    case []interface{}:
+        values = v
+    case map[string]interface{}:
+        values = []interface{}{v}
+    default:
+        return nil, fmt.Errorf("code must return an object or an array of objects, got %s", result.String())
+    }
+    records := make([]map[string]interface{}, 0, len(values))
+    for _, value := range values {
+        rec, ok := value.(map[string]interface{})
+        if !ok {
+            return nil, fmt.Errorf("code must return objects, got %v", value)
+        }
+        records = append(records, n8nRecord(rec))
+    }
+    return records, nil
+}
+
+// n8nRecord unwraps an n8n style {json: {...}} item, which may also carry
+// binary data and paired item references.
+func n8nRecord(rec map[string]interface{}) map[string]interface{} {
+    inner, ok := rec["json"].(map[string]interface{})
+    if !ok {
+        return rec
+    }
+    for key := range rec {
+        if key != "json" && key != "binary" && key != "pairedItem" {
+            return rec
+        }
+    }
+    return inner
+}
+
+// consoleMessage formats the arguments of a console call like a browser:
+// strings as they are and other values as JSON, separated by spaces.
+func consoleMessage(args []goja.Value) string {
+    parts := make([]string, len(args))
+    for i, arg := range args {
+        if _, ok := arg.Export().(string); ok || goja.IsUndefined(arg) {
+            parts[i] = arg.String()
+            continue
+        }
+        if data, err := json.Marshal(arg.Export()); err == nil {
+            parts[i] = string(data)
+        } else {
+            parts[i] = arg.String()
+        }
+    }
+    return strings.Join(parts, " ")
+}
@@ -2,8 +2,11 @@ package nodes
 
 import (
 	"context"
+	"errors"
 	"go-workflow/pkg/framework"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestCodeNode_Execute(t *testing.T) {
@@ -35,3 +38,129 @@ func TestCodeNode_Execute(t *testing.T) {
 		t.Errorf("expected transformed to be true, got %v", out[0]["transformed"])
 	}
 }
+
+func TestCodeNode_Execute_NoFunction(t *testing.T) {
+	node := NewCodeNode(nil)
+	if _, err := node.Execute(&framework.Context{Ctx: context.Background()}, nil); err == nil {
+		t.Fatal("expected an error from a code node without a function")
+	}
+}
+
+func TestCodeNode_JavaScript(t *testing.T) {
+	node, err := NewJSCodeNode(`
+const out = items.filter(item => item.score > 1).map(item => ({...item, name: item.name.toUpperCase()}));
+out.push({total: items.length});
+return out;`, "")
+	if err != nil {
+		t.Fatalf("invalid node: %v", err)
+	}
+	inputs := []map[string]interface{}{{"name": "ann", "score": 2}, {"name": "bob", "score": 1}}
+	out, err := node.Execute(&framework.Context{Ctx: context.Background()}, inputs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []map[string]interface{}{{"name": "ANN", "score": int64(2)}, {"total": int64(2)}}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("expected %v, got %v", want, out)
+	}
+	if inputs[0]["name"] != "ann" {
+		t.Error("expected the input records not to be modified")
+	}
+
+	// Without a result, the items are emitted with their changes.
+	node, _ = NewJSCodeNode(`for (const item of items) { item.seen = true; }`, CodeAllItems)
+	out, err = node.Execute(&framework.Context{Ctx: context.Background()}, inputs)
+	if err != nil || len(out) != 2 || out[1]["seen"] != true {
+		t.Errorf("expected the changed items, got %v, %v", out, err)
+	}
+}
+
+func TestCodeNode_EachItem(t *testing.T) {
+	node, err := NewJSCodeNode(`
+if ($json.skip) return null;
+if ($index === 2) return [{json: {n: 1}}, {json: {n: 2}}];
+item.index = $index;`, CodeEachItem)
+	if err != nil {
+		t.Fatalf("invalid node: %v", err)
+	}
+	out, err := node.Execute(&framework.Context{Ctx: context.Background()}, []map[string]interface{}{{"id": "a"}, {"skip": true}, {"id": "c"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []map[string]interface{}{{"id": "a", "index": int64(0)}, {"n": int64(1)}, {"n": int64(2)}}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("expected %v, got %v", want, out)
+	}
+}
+
+func TestCodeNode_N8nInput(t *testing.T) {
+	node, _ := NewJSCodeNode(`return $input.all().map(i => ({json: {first: $input.first().json.id, id: i.json.id}}));`, "")
+	out, err := node.Execute(&framework.Context{Ctx: context.Background()}, []map[string]interface{}{{"id": "a"}, {"id": "b"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(out, []map[string]interface{}{{"first": "a", "id": "a"}, {"first": "a", "id": "b"}}) {
+		t.Errorf("expected unwrapped n8n items, got %v", out)
+	}
+}
+
+func TestCodeNode_Helpers(t *testing.T) {
+	var trace []framework.TraceStep
+	ctx := &framework.Context{Ctx: context.Background(), Node: "hash", Trace: func(step framework.TraceStep) { trace = append(trace, step) }}
+	node, _ := NewJSCodeNode(`
+console.log("hashing", items.length, {id: item.id});
+return {
+	sha256: crypto.sha256(item.id),
+	hmac: crypto.hmacSha256("key", item.id),
+	uuid: crypto.randomUUID().length,
+	due: dates.add(item.created, "48h"),
+	day: dates.format(item.created, "2006-01-02"),
+	age: dates.diff("2024-03-02T00:00:00Z", item.created),
+};`, CodeEachItem)
+	out, err := node.Execute(ctx, []map[string]interface{}{{"id": "abc", "created": "2024-03-01T00:00:00Z"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]interface{}{
+		"sha256": "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"hmac":   "9c196e32dc0175f86f4b1cb89289d6619de6bee699e4c378e68309ed97a1a6ab",
+		"uuid":   int64(36),
+		"due":    "2024-03-03T00:00:00Z",
+		"day":    "2024-03-01",
+		"age":    int64(24 * 60 * 60 * 1000),
+	}
+	if !reflect.DeepEqual(out, []map[string]interface{}{want}) {
+		t.Errorf("expected %v, got %v", want, out)
+	}
+	if len(trace) != 1 || trace[0].Type != framework.TraceConsole || trace[0].Node != "hash" ||
+		!reflect.DeepEqual(trace[0].Output, map[string]interface{}{"level": "log", "message": `hashing 1 {"id":"abc"}`}) {
+		t.Errorf("expected the console output in the trace, got %+v", trace)
+	}
+}
+
+func TestCodeNode_Limits(t *testing.T) {
+	node := &CodeNode{Code: `while (true) {}`, Timeout: 50 * time.Millisecond}
+	if _, err := node.Execute(&framework.Context{Ctx: context.Background()}, nil); !errors.Is(err, errCodeTimeout) {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := node.Execute(&framework.Context{Ctx: canceled}, nil); !errors.Is(err, errCodeCanceled) {
+		t.Errorf("expected the canceled context to stop the code, got %v", err)
+	}
+}
+
+func TestValidateCodeNode(t *testing.T) {
+	tests := map[string]*CodeNode{
+		"no code":          {},
+		"syntax error":     {Code: "return {"},
+		"unknown mode":     {Code: "return items;", Mode: "batch"},
+		"negative timeout": {Code: "return items;", Timeout: -time.Second},
+	}
+	for name, node := range tests {
+		if err := ValidateCodeNode(node); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
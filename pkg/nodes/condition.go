@@ -0,0 +1,164 @@
+package nodes
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Condition operations. An empty Operation means OpEqual.
+const (
+	OpEqual        = "equal"
+	OpNotEqual     = "notEqual"
+	OpContains     = "contains"
+	OpNotContains  = "notContains"
+	OpStartsWith   = "startsWith"
+	OpEndsWith     = "endsWith"
+	OpRegex        = "regex"
+	OpLarger       = "larger"
+	OpLargerEqual  = "largerEqual"
+	OpSmaller      = "smaller"
+	OpSmallerEqual = "smallerEqual"
+	OpIsEmpty      = "isEmpty"
+	OpIsNotEmpty   = "isNotEmpty"
+)
+
+// Condition defines a condition for routing.
+type Condition struct {
+	Field     string
+	Value     interface{}
+	Operation string
+}
+
+// Match reports whether record satisfies the condition. Numbers compare by
+// value regardless of their Go type, so a YAML int matches a JSON float64.
+func (c Condition) Match(record map[string]interface{}) bool {
+	val, ok := record[c.Field]
+	switch c.Operation {
+	case OpIsEmpty:
+		return !ok || isEmptyValue(val)
+	case OpIsNotEmpty:
+		return ok && !isEmptyValue(val)
+	}
+	if !ok {
+		return c.Operation == OpNotEqual
+	}
+
+	switch c.Operation {
+	case "", OpEqual:
+		return valuesEqual(val, c.Value)
+	case OpNotEqual:
+		return !valuesEqual(val, c.Value)
+	case OpContains:
+		return strings.Contains(fmt.Sprint(val), fmt.Sprint(c.Value))
+	case OpNotContains:
+		return !strings.Contains(fmt.Sprint(val), fmt.Sprint(c.Value))
+	case OpStartsWith:
+		return strings.HasPrefix(fmt.Sprint(val), fmt.Sprint(c.Value))
+	case OpEndsWith:
+		return strings.HasSuffix(fmt.Sprint(val), fmt.Sprint(c.Value))
+	case OpRegex:
+		matched, err := regexp.MatchString(fmt.Sprint(c.Value), fmt.Sprint(val))
+		return err == nil && matched
+	case OpLarger, OpLargerEqual, OpSmaller, OpSmallerEqual:
+		a, okA := toFloat(val)
+		b, okB := toFloat(c.Value)
+		if !okA || !okB {
+			return false
+		}
+		switch c.Operation {
+		case OpLarger:
+			return a > b
+		case OpLargerEqual:
+			return a >= b
+		case OpSmaller:
+			return a < b
+		default:
+			return a <= b
+		}
+	}
+	return false
+}
+
+// MatchConditions reports whether record satisfies all conditions, or any
+// of them if combine is "any".
+func MatchConditions(conditions []Condition, combine string, record map[string]interface{}) bool {
+	if combine == "any" {
+		for _, cond := range conditions {
+			if cond.Match(record) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, cond := range conditions {
+		if !cond.Match(record) {
+			return false
+		}
+	}
+	return len(conditions) > 0
+}
+
+// ValidateCondition checks that a condition's operation is known.
+func ValidateCondition(c Condition) error {
+	switch c.Operation {
+	case "", OpEqual, OpNotEqual, OpContains, OpNotContains, OpStartsWith, OpEndsWith,
+		OpLarger, OpLargerEqual, OpSmaller, OpSmallerEqual, OpIsEmpty, OpIsNotEmpty:
+		return nil
+	case OpRegex:
+		if _, err := regexp.Compile(fmt.Sprint(c.Value)); err != nil {
+			return fmt.Errorf("condition on %s: invalid regex: %w", c.Field, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("condition on %s: unknown operation %q", c.Field, c.Operation)
+}
+
+// valuesEqual compares numbers by value and everything else deeply. Strings
+// are never compared as numbers.
+func valuesEqual(a, b interface{}) bool {
+	_, aIsString := a.(string)
+	_, bIsString := b.(string)
+	if !aIsString && !bIsString {
+		fa, okA := toFloat(a)
+		fb, okB := toFloat(b)
+		if okA && okB {
+			return fa == fb
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// toFloat converts numbers, and strings holding numbers, to float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() == 0
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package nodes
+
+import "testing"
+
+func TestCondition_Match(t *testing.T) {
+	record := map[string]interface{}{
+		"name":  "Ada Lovelace",
+		"age":   float64(36),
+		"tags":  []interface{}{},
+		"count": "12",
+	}
+	tests := []struct {
+		cond Condition
+		want bool
+	}{
+		{Condition{Field: "name", Value: "Ada Lovelace"}, true},
+		{Condition{Field: "age", Value: 36}, true},
+		{Condition{Field: "age", Value: "36"}, false},
+		{Condition{Field: "age", Value: 36, Operation: OpNotEqual}, false},
+		{Condition{Field: "missing", Value: 1, Operation: OpNotEqual}, true},
+		{Condition{Field: "name", Value: "Love", Operation: OpContains}, true},
+		{Condition{Field: "name", Value: "Love", Operation: OpNotContains}, false},
+		{Condition{Field: "name", Value: "Ada", Operation: OpStartsWith}, true},
+		{Condition{Field: "name", Value: "lace", Operation: OpEndsWith}, true},
+		{Condition{Field: "name", Value: "^Ada\\s", Operation: OpRegex}, true},
+		{Condition{Field: "age", Value: 30, Operation: OpLarger}, true},
+		{Condition{Field: "age", Value: 36, Operation: OpLargerEqual}, true},
+		{Condition{Field: "age", Value: 36, Operation: OpSmaller}, false},
+		{Condition{Field: "count", Value: 12, Operation: OpSmallerEqual}, true},
+		{Condition{Field: "tags", Operation: OpIsEmpty}, true},
+		{Condition{Field: "missing", Operation: OpIsEmpty}, true},
+		{Condition{Field: "name", Operation: OpIsNotEmpty}, true},
+		{Condition{Field: "name", Operation: "bogus"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.cond.Match(record); got != tt.want {
+			t.Errorf("%+v: expected %v, got %v", tt.cond, tt.want, got)
+		}
+	}
+}
+
+func TestMatchConditions(t *testing.T) {
+	record := map[string]interface{}{"a": 1, "b": 2}
+	matchA := Condition{Field: "a", Value: 1}
+	matchB := Condition{Field: "b", Value: 3}
+	if MatchConditions([]Condition{matchA, matchB}, "all", record) {
+		t.Error("expected all to fail when one condition fails")
+	}
+	if !MatchConditions([]Condition{matchA, matchB}, "any", record) {
+		t.Error("expected any to pass when one condition passes")
+	}
+	if MatchConditions(nil, "", record) {
+		t.Error("expected no conditions not to match")
+	}
+}
+
+func TestValidateCondition(t *testing.T) {
+	if err := ValidateCondition(Condition{Field: "a", Operation: OpLarger}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateCondition(Condition{Field: "a", Operation: "bogus"}); err == nil {
+		t.Error("expected an error for an unknown operation")
+	}
+	if err := ValidateCondition(Condition{Field: "a", Value: "(", Operation: OpRegex}); err == nil {
+		t.Error("expected an error for an invalid regex")
+	}
+}
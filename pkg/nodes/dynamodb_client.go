@@ -0,0 +1,128 @@
+package nodes
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"go-workflow/pkg/framework"
+)
+
+// DynamoDBCredentials selects the client of a DynamoDB node: the one of the
+// named AWS profile of ctx.AWS, or one created from credentials in the
+// fields of each record. Without either, or without
+// ctx.DynamoDBClientFactory, nodes use ctx.DynamoDBClient.
+//
+// Profiles keep secrets out of records; the record fields remain for
+// existing workflows.
+type DynamoDBCredentials struct {
+	AWSProfile            string
+	AWSRegionKey          string
+	AWSAccessKeyIDKey     string
+	AWSSecretAccessKeyKey string
+}
+
+// ValidateDynamoDBCredentials checks that a node selects either a profile
+// or credential fields.
+func ValidateDynamoDBCredentials(c DynamoDBCredentials) error {
+	if c.AWSProfile != "" && (c.AWSRegionKey != "" || c.AWSAccessKeyIDKey != "" || c.AWSSecretAccessKeyKey != "") {
+		return errors.New("use either awsProfile or the AWS credential keys")
+	}
+	return nil
+}
+
+// client returns the client for a record: the one of the profile, one
+// created from the credentials in the record, or ctx.DynamoDBClient.
+// Clients created from records are kept in clients by credentials, so a
+// node creates one per set of credentials; ctx.AWS caches profile clients.
+func (c DynamoDBCredentials) client(ctx *framework.Context, rec map[string]interface{}, clients map[string]framework.DynamoDBAPI) (framework.DynamoDBAPI, error) {
+	if c.AWSProfile != "" {
+		if ctx.AWS == nil {
+			return nil, fmt.Errorf("no AWS profiles available for profile %s", c.AWSProfile)
+		}
+		return ctx.AWS.DynamoDB(ctx.Ctx, c.AWSProfile)
+	}
+	if c.AWSRegionKey != "" && c.AWSAccessKeyIDKey != "" && c.AWSSecretAccessKeyKey != "" && ctx.DynamoDBClientFactory != nil {
+		region, ok := rec[c.AWSRegionKey].(string)
+		if !ok {
+			return nil, fmt.Errorf("AWS region not found or not a string in input record for key %s", c.AWSRegionKey)
+		}
+
+		accessKeyID, ok := rec[c.AWSAccessKeyIDKey].(string)
+		if !ok {
+			return nil, fmt.Errorf("AWS access key ID not found or not a string in input record for key %s", c.AWSAccessKeyIDKey)
+		}
+
+		secretAccessKey, ok := rec[c.AWSSecretAccessKeyKey].(string)
+		if !ok {
+			return nil, fmt.Errorf("AWS secret access key not found or not a string in input record for key %s", c.AWSSecretAccessKeyKey)
+		}
+		key := c.key(rec)
+		if client, ok := clients[key]; ok {
+			return client, nil
+		}
+		client, err := ctx.DynamoDBClientFactory(ctx.Ctx, region, accessKeyID, secretAccessKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create DynamoDB client: %w", err)
+		}
+		clients[key] = client
+		return client, nil
+	} else if ctx.DynamoDBClient != nil {
+		return ctx.DynamoDBClient, nil
+	}
+	return nil, errors.New("no DynamoDB client or factory available")
+}
+
+// key identifies the AWS credentials of a record.
+func (c DynamoDBCredentials) key(rec map[string]interface{}) string {
+	return fmt.Sprintf("%v\x00%v\x00%v", rec[c.AWSRegionKey], rec[c.AWSAccessKeyIDKey], rec[c.AWSSecretAccessKeyKey])
+}
+
+// expressionAttributes renders the placeholders of DynamoDB expressions for
+// a record. String values are templates, see renderPrompt.
+func expressionAttributes(names map[string]string, values map[string]interface{}, rec map[string]interface{}, env map[string]string) (map[string]string, map[string]types.AttributeValue, error) {
+	copied := make(map[string]string, len(names))
+	for name, attribute := range names {
+		copied[name] = attribute
+	}
+	rendered, err := renderValues(values, rec, env)
+	if err != nil {
+		return nil, nil, fmt.Errorf("expression value %w", err)
+	}
+	attributes, err := attributevalue.MarshalMap(rendered)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid expression values: %w", err)
+	}
+	return copied, attributes, nil
+}
+
+// renderValues renders the string values of a map of placeholders or key
+// attributes as templates for a record.
+func renderValues(values map[string]interface{}, rec map[string]interface{}, env map[string]string) (map[string]interface{}, error) {
+	rendered := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		if text, ok := value.(string); ok {
+			var err error
+			if value, err = renderPrompt(text, rec, env); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		rendered[name] = value
+	}
+	return rendered, nil
+}
+
+// validateValueTemplates checks the string values of a map of placeholders
+// or key attributes.
+func validateValueTemplates(values map[string]interface{}) error {
+	for name, value := range values {
+		if text, ok := value.(string); ok {
+			if err := ValidatePromptTemplate(text); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package nodes
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// How DynamoDB nodes write empty strings.
+const (
+	// EmptyStringsKeep writes empty strings as they are; DynamoDB accepts
+	// them except in keys.
+	EmptyStringsKeep = "keep"
+	// EmptyStringsNull writes them as NULL.
+	EmptyStringsNull = "null"
+	// EmptyStringsOmit leaves them out of the item, or of the list or map
+	// that holds them.
+	EmptyStringsOmit = "omit"
+)
+
+// How DynamoDB nodes write floating-point numbers.
+const (
+	// FloatsNumber writes them as numbers (N). NaN and infinities cannot
+	// be written.
+	FloatsNumber = "number"
+	// FloatsString writes them as strings (S) in their shortest decimal
+	// form, e.g. to keep them out of numeric sort keys.
+	FloatsString = "string"
+)
+
+// DynamoDBItemOptions select the fields of a record that are written to a
+// DynamoDB item and how they are converted. Values are marshaled like
+// attributevalue.MarshalMap does: strings, numbers, booleans, nil, lists and
+// maps become S, N, BOOL, NULL, L and M attributes.
+type DynamoDBItemOptions struct {
+	// Fields lists the fields written; all fields if empty.
+	Fields []string
+	// Exclude lists fields that are not written.
+	Exclude []string
+	// Rename maps fields to the attribute names they are written as.
+	Rename map[string]string
+	// EmptyStrings is one of the EmptyStrings constants,
+	// EmptyStringsKeep if empty.
+	EmptyStrings string
+	// Floats is one of the Floats constants, FloatsNumber if empty.
+	Floats string
+}
+
+// ValidateDynamoDBItemOptions checks the empty string and float handling
+// of DynamoDB item options.
+func ValidateDynamoDBItemOptions(opts DynamoDBItemOptions) error {
+	switch opts.EmptyStrings {
+	case "", EmptyStringsKeep, EmptyStringsNull, EmptyStringsOmit:
+	default:
+		return fmt.Errorf("unknown emptyStrings %q; use keep, null or omit", opts.EmptyStrings)
+	}
+	switch opts.Floats {
+	case "", FloatsNumber, FloatsString:
+	default:
+		return fmt.Errorf("unknown floats %q; use number or string", opts.Floats)
+	}
+	renamed := map[string]string{}
+	for from, to := range opts.Rename {
+		if to == "" {
+			return fmt.Errorf("field %s is renamed to an empty name", from)
+		}
+		if other, ok := renamed[to]; ok {
+			return fmt.Errorf("fields %s and %s are both renamed to %s", other, from, to)
+		}
+		renamed[to] = from
+	}
+	return nil
+}
+
+// item converts a record to a DynamoDB item. Fields in skip, such as the
+// table name and credentials a node reads from the record, are not written
+// unless Fields lists them.
+func (o DynamoDBItemOptions) item(rec map[string]interface{}, skip ...string) (map[string]types.AttributeValue, error) {
+	excluded := map[string]bool{}
+	for _, field := range skip {
+		excluded[field] = true
+	}
+	if len(o.Fields) > 0 {
+		excluded = map[string]bool{}
+	}
+	for _, field := range o.Exclude {
+		excluded[field] = true
+	}
+	fields := o.Fields
+	if len(fields) == 0 {
+		fields = make([]string, 0, len(rec))
+		for field := range rec {
+			fields = append(fields, field)
+		}
+	}
+
+	values := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		value, ok := rec[field]
+		if !ok || excluded[field] {
+			continue
+		}
+		value, keep, err := o.convert(value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field, err)
+		}
+		if !keep {
+			continue
+		}
+		name := field
+		if to, ok := o.Rename[field]; ok {
+			name = to
+		}
+		values[name] = value
+	}
+	return attributevalue.MarshalMap(values)
+}
+
+// convert applies the empty string and float handling to a value and the
+// values nested in it. It reports false for values that are left out.
+func (o DynamoDBItemOptions) convert(value interface{}) (interface{}, bool, error) {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return v, true, nil
+		}
+		switch o.EmptyStrings {
+		case EmptyStringsNull:
+			return nil, true, nil
+		case EmptyStringsOmit:
+			return nil, false, nil
+		}
+		return v, true, nil
+	case float64:
+		return o.convertFloat(v)
+	case float32:
+		return o.convertFloat(float64(v))
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			item, keep, err := o.convert(item)
+			if err != nil {
+				return nil, false, fmt.Errorf("%s: %w", key, err)
+			}
+			if keep {
+				converted[key] = item
+			}
+		}
+		return converted, true, nil
+	case []interface{}:
+		converted := make([]interface{}, 0, len(v))
+		for i, item := range v {
+			item, keep, err := o.convert(item)
+			if err != nil {
+				return nil, false, fmt.Errorf("item %d: %w", i, err)
+			}
+			if keep {
+				converted = append(converted, item)
+			}
+		}
+		return converted, true, nil
+	}
+	return value, true, nil
+}
+
+func (o DynamoDBItemOptions) convertFloat(f float64) (interface{}, bool, error) {
+	if o.Floats == FloatsString {
+		return strconv.FormatFloat(f, 'g', -1, 64), true, nil
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, false, fmt.Errorf("%v cannot be written as a DynamoDB number", f)
+	}
+	return f, true, nil
+}
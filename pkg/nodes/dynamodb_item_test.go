@@ -0,0 +1,140 @@
+package nodes
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"go-workflow/pkg/framework"
+)
+
+func TestDynamoDBUpsert_MarshalsAllTypes(t *testing.T) {
+	var item map[string]types.AttributeValue
+	ctx := &framework.Context{
+		Ctx: context.Background(),
+		DynamoDBClient: &mockDynamoDBClient{putItem: func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+			item = params.Item
+			return &dynamodb.PutItemOutput{}, nil
+		}},
+	}
+	node := NewDynamoDBUpsert("table", "", "", "")
+
+	_, err := node.Execute(ctx, []map[string]interface{}{{
+		"table":    "contacts",
+		"id":       "c1",
+		"score":    8.5,
+		"count":    3,
+		"active":   true,
+		"deleted":  nil,
+		"tags":     []interface{}{"lead", 2.0},
+		"company":  map[string]interface{}{"name": "Acme", "size": 50.0},
+		"embedded": []float32{0.5, 1},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]types.AttributeValue{
+		"id":      &types.AttributeValueMemberS{Value: "c1"},
+		"score":   &types.AttributeValueMemberN{Value: "8.5"},
+		"count":   &types.AttributeValueMemberN{Value: "3"},
+		"active":  &types.AttributeValueMemberBOOL{Value: true},
+		"deleted": &types.AttributeValueMemberNULL{Value: true},
+		"tags": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			&types.AttributeValueMemberS{Value: "lead"},
+			&types.AttributeValueMemberN{Value: "2"},
+		}},
+		"company": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"name": &types.AttributeValueMemberS{Value: "Acme"},
+			"size": &types.AttributeValueMemberN{Value: "50"},
+		}},
+		"embedded": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+			&types.AttributeValueMemberN{Value: "0.5"},
+			&types.AttributeValueMemberN{Value: "1"},
+		}},
+	}
+	if !reflect.DeepEqual(item, want) {
+		t.Errorf("unexpected item:\n got %#v\nwant %#v", item, want)
+	}
+}
+
+func TestDynamoDBItemOptions(t *testing.T) {
+	rec := map[string]interface{}{
+		"table":  "contacts",
+		"id":     "c1",
+		"name":   "",
+		"score":  0.25,
+		"notes":  []interface{}{"", "call back"},
+		"secret": "s3cr3t",
+	}
+	tests := map[string]struct {
+		opts DynamoDBItemOptions
+		want map[string]types.AttributeValue
+	}{
+		"fields and rename": {
+			opts: DynamoDBItemOptions{Fields: []string{"id", "table"}, Rename: map[string]string{"id": "pk"}},
+			want: map[string]types.AttributeValue{
+				"pk":    &types.AttributeValueMemberS{Value: "c1"},
+				"table": &types.AttributeValueMemberS{Value: "contacts"},
+			},
+		},
+		"exclude, empty strings as null and floats as strings": {
+			opts: DynamoDBItemOptions{Exclude: []string{"secret", "notes"}, EmptyStrings: EmptyStringsNull, Floats: FloatsString},
+			want: map[string]types.AttributeValue{
+				"id":    &types.AttributeValueMemberS{Value: "c1"},
+				"name":  &types.AttributeValueMemberNULL{Value: true},
+				"score": &types.AttributeValueMemberS{Value: "0.25"},
+			},
+		},
+		"omit empty strings": {
+			opts: DynamoDBItemOptions{Exclude: []string{"secret", "score"}, EmptyStrings: EmptyStringsOmit},
+			want: map[string]types.AttributeValue{
+				"id": &types.AttributeValueMemberS{Value: "c1"},
+				"notes": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+					&types.AttributeValueMemberS{Value: "call back"},
+				}},
+			},
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			item, err := tt.opts.item(rec, "table")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(item, tt.want) {
+				t.Errorf("unexpected item:\n got %#v\nwant %#v", item, tt.want)
+			}
+		})
+	}
+}
+
+func TestDynamoDBItemOptions_NaN(t *testing.T) {
+	_, err := DynamoDBItemOptions{}.item(map[string]interface{}{"score": map[string]interface{}{"value": math.NaN()}})
+	if err == nil || !strings.Contains(err.Error(), "score: value: NaN cannot be written") {
+		t.Errorf("expected an error for NaN, got %v", err)
+	}
+	item, err := DynamoDBItemOptions{Floats: FloatsString}.item(map[string]interface{}{"score": math.Inf(1)})
+	if err != nil || !reflect.DeepEqual(item["score"], &types.AttributeValueMemberS{Value: "+Inf"}) {
+		t.Errorf("expected infinity as a string, got %v, %v", item, err)
+	}
+}
+
+func TestValidateDynamoDBItemOptions(t *testing.T) {
+	for name, opts := range map[string]DynamoDBItemOptions{
+		"empty strings": {EmptyStrings: "drop"},
+		"floats":        {Floats: "decimal"},
+		"rename clash":  {Rename: map[string]string{"a": "x", "b": "x"}},
+	} {
+		if err := ValidateDynamoDBItemOptions(opts); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if err := ValidateDynamoDBItemOptions(DynamoDBItemOptions{EmptyStrings: EmptyStringsOmit, Floats: FloatsNumber}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,368 @@
+package nodes
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"go-workflow/pkg/framework"
+)
+
+// DefaultDynamoDBItemField is the field DynamoDBGet stores items under.
+const DefaultDynamoDBItemField = "item"
+
+// DynamoDBTable selects the table a DynamoDB read node reads for a record,
+// and the credentials it reads with.
+type DynamoDBTable struct {
+	// TableName is a template rendered for each record, see renderPrompt.
+	// If it is empty, the table name is read from the TableNameKey field.
+	TableName    string
+	TableNameKey string
+	DynamoDBCredentials
+}
+
+// ValidateDynamoDBTable checks that the table of a read node is set, and
+// its credentials.
+func ValidateDynamoDBTable(t DynamoDBTable) error {
+	if err := ValidateDynamoDBCredentials(t.DynamoDBCredentials); err != nil {
+		return err
+	}
+	if t.TableName == "" && t.TableNameKey == "" {
+		return errors.New("table or tableNameKey is required")
+	}
+	if t.TableName != "" && t.TableNameKey != "" {
+		return errors.New("use either table or tableNameKey")
+	}
+	if t.TableName != "" {
+		if err := ValidatePromptTemplate(t.TableName); err != nil {
+			return fmt.Errorf("table: %w", err)
+		}
+	}
+	return nil
+}
+
+// table returns the table name for a record.
+func (t DynamoDBTable) table(ctx *framework.Context, rec map[string]interface{}) (string, error) {
+	if t.TableName != "" {
+		return renderPrompt(t.TableName, rec, ctx.Env)
+	}
+	tableName, ok := rec[t.TableNameKey].(string)
+	if !ok {
+		return "", fmt.Errorf("table name not found or not a string in input record for key %s", t.TableNameKey)
+	}
+	return tableName, nil
+}
+
+// DynamoDBGet reads the item of every record by its key with GetItem, and
+// stores it under OutputKey. The records whose item was found are emitted
+// on output 0 and the others, unchanged, on output 1; Execute returns all.
+type DynamoDBGet struct {
+	DynamoDBTable
+	// Key holds the attributes of the item key. String values are
+	// templates rendered for each record, see renderPrompt.
+	Key map[string]interface{}
+	// ProjectionExpression selects the attributes read, with placeholders
+	// from ExpressionNames; all if empty.
+	ProjectionExpression string
+	ExpressionNames      map[string]string
+	ConsistentRead       bool
+	// OutputKey is the field the item is stored under,
+	// DefaultDynamoDBItemField if empty.
+	OutputKey string
+}
+
+// ValidateDynamoDBGet checks the table and key of a DynamoDBGet.
+func ValidateDynamoDBGet(n *DynamoDBGet) error {
+	if err := ValidateDynamoDBTable(n.DynamoDBTable); err != nil {
+		return err
+	}
+	if len(n.Key) == 0 {
+		return errors.New("key is required")
+	}
+	if err := validateValueTemplates(n.Key); err != nil {
+		return fmt.Errorf("key %w", err)
+	}
+	return nil
+}
+
+// Execute reads the items of the records and returns all records.
+func (n *DynamoDBGet) Execute(ctx *framework.Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+	ports, err := n.ExecutePorts(ctx, inputs)
+	if err != nil {
+		return nil, err
+	}
+	return append(ports[0], ports[1]...), nil
+}
+
+// ExecutePorts returns the records whose item was found on output 0 and
+// the others on output 1.
+func (n *DynamoDBGet) ExecutePorts(ctx *framework.Context, inputs []map[string]interface{}) ([][]map[string]interface{}, error) {
+	outputKey := n.OutputKey
+	if outputKey == "" {
+		outputKey = DefaultDynamoDBItemField
+	}
+	clients := map[string]framework.DynamoDBAPI{}
+	ports := make([][]map[string]interface{}, 2)
+	for _, rec := range inputs {
+		tableName, err := n.table(ctx, rec)
+		if err != nil {
+			return nil, err
+		}
+		client, err := n.client(ctx, rec, clients)
+		if err != nil {
+			return nil, err
+		}
+		key, err := renderValues(n.Key, rec, ctx.Env)
+		if err != nil {
+			return nil, fmt.Errorf("key %w", err)
+		}
+		keyItem, err := attributevalue.MarshalMap(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key: %w", err)
+		}
+		input := &dynamodb.GetItemInput{TableName: &tableName, Key: keyItem, ConsistentRead: aws.Bool(n.ConsistentRead)}
+		if n.ProjectionExpression != "" {
+			input.ProjectionExpression = &n.ProjectionExpression
+		}
+		if len(n.ExpressionNames) > 0 {
+			input.ExpressionAttributeNames = n.ExpressionNames
+		}
+		out, err := client.GetItem(ctx.Ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		if out.Item == nil {
+			ports[1] = append(ports[1], rec)
+			continue
+		}
+		item, err := plainItem(out.Item)
+		if err != nil {
+			return nil, err
+		}
+		result := make(map[string]interface{}, len(rec)+1)
+		for k, v := range rec {
+			result[k] = v
+		}
+		result[outputKey] = item
+		ports[0] = append(ports[0], result)
+	}
+	return ports, nil
+}
+
+// DynamoDBSearch holds the options DynamoDBQuery and DynamoDBScan share.
+// They read the items for every record, following LastEvaluatedKey over
+// all pages.
+type DynamoDBSearch struct {
+	DynamoDBTable
+	// IndexName reads a secondary index instead of the table.
+	IndexName string
+	// FilterExpression drops the items read that do not match it.
+	FilterExpression     string
+	ProjectionExpression string
+	// ExpressionNames and ExpressionValues are the placeholders of the
+	// expressions, e.g. "#s" and ":status". String values are templates
+	// rendered for each record, see renderPrompt.
+	ExpressionNames  map[string]string
+	ExpressionValues map[string]interface{}
+	ConsistentRead   bool
+	// Limit is the most items read for a record; all if zero.
+	Limit int
+	// OutputKey, if set, stores the items of a record as a list under
+	// this field of the record. Otherwise every item is emitted as a
+	// record of its own.
+	OutputKey string
+}
+
+// ValidateDynamoDBSearch checks the table, limit and placeholders of a
+// query or scan.
+func ValidateDynamoDBSearch(s DynamoDBSearch) error {
+	if err := ValidateDynamoDBTable(s.DynamoDBTable); err != nil {
+		return err
+	}
+	if s.Limit < 0 {
+		return errors.New("limit must not be negative")
+	}
+	if err := validateValueTemplates(s.ExpressionValues); err != nil {
+		return fmt.Errorf("expression value %w", err)
+	}
+	return nil
+}
+
+// dynamoDBPage reads the page of items that starts at start, with at most
+// limit items if it is set, and returns them with the key the next page
+// starts at.
+type dynamoDBPage func(client framework.DynamoDBAPI, input dynamoDBPageInput) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error)
+
+// dynamoDBPageInput holds the request options of a page.
+type dynamoDBPageInput struct {
+	table  string
+	names  map[string]string
+	values map[string]types.AttributeValue
+	start  map[string]types.AttributeValue
+	limit  *int32
+}
+
+// search reads the items of every record page by page.
+func (s DynamoDBSearch) search(ctx *framework.Context, inputs []map[string]interface{}, page dynamoDBPage) ([]map[string]interface{}, error) {
+	clients := map[string]framework.DynamoDBAPI{}
+	var outputs []map[string]interface{}
+	for _, rec := range inputs {
+		tableName, err := s.table(ctx, rec)
+		if err != nil {
+			return nil, err
+		}
+		client, err := s.client(ctx, rec, clients)
+		if err != nil {
+			return nil, err
+		}
+		names, values, err := expressionAttributes(s.ExpressionNames, s.ExpressionValues, rec, ctx.Env)
+		if err != nil {
+			return nil, err
+		}
+		input := dynamoDBPageInput{table: tableName}
+		if len(names) > 0 {
+			input.names = names
+		}
+		if len(values) > 0 {
+			input.values = values
+		}
+
+		var items []map[string]types.AttributeValue
+		for {
+			if s.Limit > 0 {
+				input.limit = aws.Int32(int32(s.Limit - len(items)))
+			}
+			pageItems, next, err := page(client, input)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, pageItems...)
+			if len(next) == 0 || (s.Limit > 0 && len(items) >= s.Limit) {
+				break
+			}
+			input.start = next
+		}
+		if s.Limit > 0 && len(items) > s.Limit {
+			items = items[:s.Limit]
+		}
+
+		plain := make([]map[string]interface{}, 0, len(items))
+		for _, item := range items {
+			converted, err := plainItem(item)
+			if err != nil {
+				return nil, err
+			}
+			plain = append(plain, converted)
+		}
+		if s.OutputKey == "" {
+			outputs = append(outputs, plain...)
+			continue
+		}
+		list := make([]interface{}, len(plain))
+		for i, item := range plain {
+			list[i] = item
+		}
+		result := make(map[string]interface{}, len(rec)+1)
+		for k, v := range rec {
+			result[k] = v
+		}
+		result[s.OutputKey] = list
+		outputs = append(outputs, result)
+	}
+	return outputs, nil
+}
+
+// DynamoDBQuery reads the items matching KeyConditionExpression with Query.
+type DynamoDBQuery struct {
+	DynamoDBSearch
+	KeyConditionExpression string
+	// Descending reads the items in descending sort key order.
+	Descending bool
+}
+
+// ValidateDynamoDBQuery checks a DynamoDBQuery.
+func ValidateDynamoDBQuery(n *DynamoDBQuery) error {
+	if n.KeyConditionExpression == "" {
+		return errors.New("keyConditionExpression is required")
+	}
+	return ValidateDynamoDBSearch(n.DynamoDBSearch)
+}
+
+// Execute queries the items for every record.
+func (n *DynamoDBQuery) Execute(ctx *framework.Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+	return n.search(ctx, inputs, func(client framework.DynamoDBAPI, page dynamoDBPageInput) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+		input := &dynamodb.QueryInput{
+			TableName:                 &page.table,
+			KeyConditionExpression:    &n.KeyConditionExpression,
+			ExpressionAttributeNames:  page.names,
+			ExpressionAttributeValues: page.values,
+			ExclusiveStartKey:         page.start,
+			Limit:                     page.limit,
+			ConsistentRead:            aws.Bool(n.ConsistentRead),
+			ScanIndexForward:          aws.Bool(!n.Descending),
+		}
+		if n.IndexName != "" {
+			input.IndexName = &n.IndexName
+		}
+		if n.FilterExpression != "" {
+			input.FilterExpression = &n.FilterExpression
+		}
+		if n.ProjectionExpression != "" {
+			input.ProjectionExpression = &n.ProjectionExpression
+		}
+		out, err := client.Query(ctx.Ctx, input)
+		if err != nil {
+			return nil, nil, err
+		}
+		return out.Items, out.LastEvaluatedKey, nil
+	})
+}
+
+// DynamoDBScan reads all items of a table or index with Scan.
+type DynamoDBScan struct {
+	DynamoDBSearch
+}
+
+// Execute scans the table for every record.
+func (n *DynamoDBScan) Execute(ctx *framework.Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+	return n.search(ctx, inputs, func(client framework.DynamoDBAPI, page dynamoDBPageInput) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+		input := &dynamodb.ScanInput{
+			TableName:                 &page.table,
+			ExpressionAttributeNames:  page.names,
+			ExpressionAttributeValues: page.values,
+			ExclusiveStartKey:         page.start,
+			Limit:                     page.limit,
+			ConsistentRead:            aws.Bool(n.ConsistentRead),
+		}
+		if n.IndexName != "" {
+			input.IndexName = &n.IndexName
+		}
+		if n.FilterExpression != "" {
+			input.FilterExpression = &n.FilterExpression
+		}
+		if n.ProjectionExpression != "" {
+			input.ProjectionExpression = &n.ProjectionExpression
+		}
+		out, err := client.Scan(ctx.Ctx, input)
+		if err != nil {
+			return nil, nil, err
+		}
+		return out.Items, out.LastEvaluatedKey, nil
+	})
+}
+
+// plainItem converts a DynamoDB item back to a record: numbers become
+// float64, lists []interface{} and maps map[string]interface{}.
+func plainItem(item map[string]types.AttributeValue) (map[string]interface{}, error) {
+	var rec map[string]interface{}
+	if err := attributevalue.UnmarshalMap(item, &rec); err != nil {
+		return nil, fmt.Errorf("failed to convert DynamoDB item: %w", err)
+	}
+	return rec, nil
+}
+
+var _ framework.PortNode = (*DynamoDBGet)(nil)
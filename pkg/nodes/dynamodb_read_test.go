@@ -0,0 +1,229 @@
+package nodes
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"go-workflow/pkg/framework"
+)
+
+// contactItems are the items of a fake contacts table.
+func contactItems(t *testing.T, n int) []map[string]types.AttributeValue {
+	t.Helper()
+	var items []map[string]types.AttributeValue
+	for i := 0; i < n; i++ {
+		item, err := attributevalue.MarshalMap(map[string]interface{}{
+			"pk": "company#acme", "sk": "contact#" + strconv.Itoa(i), "name": "Contact " + strconv.Itoa(i), "tags": []interface{}{"lead"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// page returns the items of a fake table in pages of pageSize, like Query
+// and Scan do, with the offset of the next page as its start key.
+func page(items []map[string]types.AttributeValue, start map[string]types.AttributeValue, limit *int32, pageSize int) ([]map[string]types.AttributeValue, map[string]types.AttributeValue) {
+	offset := 0
+	if start != nil {
+		offset, _ = strconv.Atoi(start["offset"].(*types.AttributeValueMemberN).Value)
+	}
+	if limit != nil && int(*limit) < pageSize {
+		pageSize = int(*limit)
+	}
+	end := offset + pageSize
+	if end >= len(items) {
+		return items[offset:], nil
+	}
+	return items[offset:end], map[string]types.AttributeValue{"offset": &types.AttributeValueMemberN{Value: strconv.Itoa(end)}}
+}
+
+func TestDynamoDBGet(t *testing.T) {
+	var requests []*dynamodb.GetItemInput
+	client := &mockDynamoDBClient{
+		getItem: func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+			requests = append(requests, params)
+			if params.Key["email"].(*types.AttributeValueMemberS).Value != "ann@example.com" {
+				return &dynamodb.GetItemOutput{}, nil
+			}
+			return &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+				"email":   &types.AttributeValueMemberS{Value: "ann@example.com"},
+				"invites": &types.AttributeValueMemberN{Value: "2"},
+			}}, nil
+		},
+	}
+	ctx := &framework.Context{Ctx: context.Background(), DynamoDBClient: client, Env: map[string]string{"CONTACTS_TABLE": "contacts"}}
+	node := &DynamoDBGet{
+		DynamoDBTable: DynamoDBTable{TableName: "{{.CONTACTS_TABLE}}"},
+		Key:           map[string]interface{}{"email": "{{.email}}"},
+		OutputKey:     "contact",
+	}
+	if err := ValidateDynamoDBGet(node); err != nil {
+		t.Fatalf("invalid node: %v", err)
+	}
+
+	inputs := []map[string]interface{}{{"email": "ann@example.com"}, {"email": "bob@example.com"}}
+	ports, err := node.ExecutePorts(ctx, inputs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]interface{}{"email": "ann@example.com", "contact": map[string]interface{}{"email": "ann@example.com", "invites": float64(2)}}
+	if len(ports[0]) != 1 || !reflect.DeepEqual(ports[0][0], want) {
+		t.Errorf("expected the found item on output 0, got %v", ports[0])
+	}
+	if !reflect.DeepEqual(ports[1], inputs[1:]) {
+		t.Errorf("expected the record without item on output 1, got %v", ports[1])
+	}
+	if *requests[0].TableName != "contacts" {
+		t.Errorf("expected the rendered table name, got %s", *requests[0].TableName)
+	}
+	if _, ok := inputs[0]["contact"]; ok {
+		t.Error("expected the input record not to be modified")
+	}
+
+	out, err := node.Execute(ctx, inputs)
+	if err != nil || len(out) != 2 {
+		t.Errorf("expected Execute to return all records, got %v, %v", out, err)
+	}
+}
+
+func TestDynamoDBQuery_Pagination(t *testing.T) {
+	items := contactItems(t, 5)
+	var requests []*dynamodb.QueryInput
+	factoryCalls := 0
+	client := &mockDynamoDBClient{
+		query: func(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+			requests = append(requests, params)
+			pageItems, next := page(items, params.ExclusiveStartKey, params.Limit, 2)
+			return &dynamodb.QueryOutput{Items: pageItems, LastEvaluatedKey: next}, nil
+		},
+	}
+	ctx := &framework.Context{
+		Ctx: context.Background(),
+		DynamoDBClientFactory: func(ctx context.Context, region, accessKeyID, secretAccessKey string) (framework.DynamoDBAPI, error) {
+			factoryCalls++
+			return client, nil
+		},
+	}
+	node := &DynamoDBQuery{
+		DynamoDBSearch: DynamoDBSearch{
+			DynamoDBTable: DynamoDBTable{
+				TableNameKey:        "table",
+				DynamoDBCredentials: DynamoDBCredentials{AWSRegionKey: "region", AWSAccessKeyIDKey: "keyID", AWSSecretAccessKeyKey: "secret"},
+			},
+			IndexName:        "by-company",
+			FilterExpression: "attribute_exists(#n)",
+			ExpressionNames:  map[string]string{"#n": "name"},
+			ExpressionValues: map[string]interface{}{":pk": "company#{{.company}}"},
+		},
+		KeyConditionExpression: "pk = :pk",
+		Descending:             true,
+	}
+	if err := ValidateDynamoDBQuery(node); err != nil {
+		t.Fatalf("invalid node: %v", err)
+	}
+
+	rec := map[string]interface{}{"table": "contacts", "region": "eu-west-1", "keyID": "id", "secret": "secret", "company": "acme"}
+	out, err := node.Execute(ctx, []map[string]interface{}{rec, rec})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out) != 10 || out[4]["sk"] != "contact#4" || !reflect.DeepEqual(out[0]["tags"], []interface{}{"lead"}) {
+		t.Errorf("expected all pages of items as records, got %v", out)
+	}
+	if len(requests) != 6 || factoryCalls != 1 {
+		t.Errorf("expected 3 pages per record with one client, got %d requests and %d clients", len(requests), factoryCalls)
+	}
+	first := requests[0]
+	if *first.IndexName != "by-company" || *first.KeyConditionExpression != "pk = :pk" || *first.ScanIndexForward ||
+		!reflect.DeepEqual(first.ExpressionAttributeValues[":pk"], &types.AttributeValueMemberS{Value: "company#acme"}) {
+		t.Errorf("unexpected query %+v", first)
+	}
+}
+
+func TestDynamoDBScan_LimitAndOutputKey(t *testing.T) {
+	items := contactItems(t, 5)
+	client := &mockDynamoDBClient{
+		scan: func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+			pageItems, next := page(items, params.ExclusiveStartKey, params.Limit, 2)
+			return &dynamodb.ScanOutput{Items: pageItems, LastEvaluatedKey: next}, nil
+		},
+	}
+	ctx := &framework.Context{Ctx: context.Background(), DynamoDBClient: client}
+	node := &DynamoDBScan{DynamoDBSearch{DynamoDBTable: DynamoDBTable{TableName: "contacts"}, Limit: 3, OutputKey: "contacts"}}
+
+	out, err := node.Execute(ctx, []map[string]interface{}{{"id": 1}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out) != 1 || out[0]["id"] != 1 {
+		t.Fatalf("expected the input record, got %v", out)
+	}
+	contacts, _ := out[0]["contacts"].([]interface{})
+	if len(contacts) != 3 || contacts[2].(map[string]interface{})["sk"] != "contact#2" {
+		t.Errorf("expected the first 3 items under the output key, got %v", out[0]["contacts"])
+	}
+}
+
+func TestValidateDynamoDBRead(t *testing.T) {
+	table := DynamoDBTable{TableName: "contacts"}
+	if err := ValidateDynamoDBGet(&DynamoDBGet{DynamoDBTable: table}); err == nil {
+		t.Error("expected an error for a get without key")
+	}
+	if err := ValidateDynamoDBGet(&DynamoDBGet{Key: map[string]interface{}{"pk": "a"}}); err == nil {
+		t.Error("expected an error for a get without table")
+	}
+	if err := ValidateDynamoDBQuery(&DynamoDBQuery{DynamoDBSearch: DynamoDBSearch{DynamoDBTable: table}}); err == nil {
+		t.Error("expected an error for a query without key condition")
+	}
+	if err := ValidateDynamoDBSearch(DynamoDBSearch{DynamoDBTable: table, Limit: -1}); err == nil {
+		t.Error("expected an error for a negative limit")
+	}
+	if err := ValidateDynamoDBTable(DynamoDBTable{TableName: "contacts", TableNameKey: "table"}); err == nil {
+		t.Error("expected an error for both table and tableNameKey")
+	}
+}
+
+func TestDynamoDBCredentials_Profile(t *testing.T) {
+	var profiles []framework.AWSProfile
+	client := &mockDynamoDBClient{}
+	ctx := &framework.Context{
+		Ctx: context.Background(),
+		AWS: framework.NewAWSClients(map[string]framework.AWSProfile{"contacts": {Region: "eu-west-1"}}, nil,
+			func(ctx context.Context, p framework.AWSProfile) (framework.DynamoDBAPI, error) {
+				profiles = append(profiles, p)
+				return client, nil
+			}),
+	}
+	get := &DynamoDBGet{DynamoDBTable: DynamoDBTable{TableName: "contacts", DynamoDBCredentials: DynamoDBCredentials{AWSProfile: "contacts"}}, Key: map[string]interface{}{"pk": "a"}}
+	upsert := NewDynamoDBUpsert("table", "", "", "")
+	upsert.AWSProfile = "contacts"
+
+	for i := 0; i < 2; i++ {
+		if _, err := get.Execute(ctx, []map[string]interface{}{{}, {}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := upsert.Execute(ctx, []map[string]interface{}{{"table": "contacts", "pk": "a"}}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(profiles) != 1 || profiles[0].Region != "eu-west-1" {
+		t.Errorf("expected one client for the profile, got %v", profiles)
+	}
+
+	get.DynamoDBCredentials.AWSProfile = "unknown"
+	if _, err := get.Execute(ctx, []map[string]interface{}{{}}); err == nil || err.Error() != `unknown AWS profile "unknown"` {
+		t.Errorf("expected an error for an unknown profile, got %v", err)
+	}
+	if err := ValidateDynamoDBCredentials(DynamoDBCredentials{AWSProfile: "contacts", AWSRegionKey: "region"}); err == nil {
+		t.Error("expected an error for a profile and credential keys")
+	}
+}
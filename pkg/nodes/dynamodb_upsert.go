@@ -3,63 +3,398 @@ package nodes
 import (
     "errors"
     "fmt"
+    "sort"
+    "strings"
+    "time"
+
     "go-workflow/pkg/framework"
     "github.com/aws/aws-sdk-go-v2/service/dynamodb"
     "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+// Write modes of DynamoDBUpsert.
+const (
+    // DynamoDBPut replaces every item with PutItem.
+    DynamoDBPut = "put"
+    // DynamoDBBatch writes items with BatchWriteItem, 25 per request.
+    // Of the records with the same key, only the last is written.
+    DynamoDBBatch = "batch"
+    // DynamoDBUpdate merges the attributes of every record into its item
+    // with UpdateItem, creating the item if needed.
+    DynamoDBUpdate = "update"
+)
+
+const (
+    // dynamoDBBatchSize is the most items BatchWriteItem accepts.
+    dynamoDBBatchSize = 25
+    // DefaultDynamoDBMaxRetries is how often unprocessed batch items are
+    // retried, unless maxRetries is set.
+    DefaultDynamoDBMaxRetries = 5
+    // NoDynamoDBRetries as MaxRetries disables the retries of unprocessed
+    // batch items.
+    NoDynamoDBRetries = -1
+)
+
+// dynamoDBRetryDelay is the wait before the first retry of unprocessed
+// batch items; it doubles with every retry.
+var dynamoDBRetryDelay = 100 * time.Millisecond
+
 // DynamoDBUpsert writes items
+//
+// Every record is written as an item, converted as the embedded
+// DynamoDBItemOptions select. The fields holding the table name and AWS
+// credentials are not written unless the options list them.
+//
+// Records whose ConditionExpression does not hold are not written; they
+// are emitted on output 1 and the written records on output 0.
 type DynamoDBUpsert struct{
     TableNameKey        string
     AWSRegionKey        string
     AWSAccessKeyIDKey   string
     AWSSecretAccessKeyKey string
+    // AWSProfile names the AWS profile of ctx.AWS the node writes with,
+    // instead of credentials read from the records.
+    AWSProfile string
+    DynamoDBItemOptions
+    // Mode is DynamoDBPut, DynamoDBBatch or DynamoDBUpdate; DynamoDBPut
+    // if empty.
+    Mode string
+    // KeyFields are the attributes of the item key, which UpdateItem
+    // needs and batch writes deduplicate records by.
+    KeyFields []string
+    // UpdateExpression replaces the update of DynamoDBUpdate, which sets
+    // every attribute of the item but the key.
+    UpdateExpression string
+    // ConditionExpression must hold for a put or update to be written.
+    ConditionExpression string
+    // ExpressionNames and ExpressionValues are the placeholders of the
+    // expressions, e.g. "#s" and ":expected". String values are templates
+    // rendered for each record, see renderPrompt.
+    ExpressionNames  map[string]string
+    ExpressionValues map[string]interface{}
+    // MaxRetries is how often unprocessed batch items are retried,
+    // DefaultDynamoDBMaxRetries if zero and none if NoDynamoDBRetries.
+    MaxRetries int
+}
+
+// NewDynamoDBUpsert creates a new DynamoDBUpsert reading the table name and
+// optional AWS credentials from the given record keys.
+func NewDynamoDBUpsert(tableNameKey, awsRegionKey, awsAccessKeyIDKey, awsSecretAccessKeyKey string) *DynamoDBUpsert {
+    return &DynamoDBUpsert{
+        TableNameKey:          tableNameKey,
+        AWSRegionKey:          awsRegionKey,
+        AWSAccessKeyIDKey:     awsAccessKeyIDKey,
+        AWSSecretAccessKeyKey: awsSecretAccessKeyKey,
+    }
+}
+
+// ValidateDynamoDBWrite checks the credentials and mode of a DynamoDBUpsert
+// and the options the mode needs.
+func ValidateDynamoDBWrite(n *DynamoDBUpsert) error {
+    if err := ValidateDynamoDBCredentials(n.credentials()); err != nil {
+        return err
+    }
+    switch n.Mode {
+    case "", DynamoDBPut:
+    case DynamoDBBatch:
+        if n.ConditionExpression != "" {
+            return errors.New("batch writes cannot have a conditionExpression")
+        }
+        if len(n.KeyFields) == 0 {
+            return errors.New("batch mode requires keyFields")
+        }
+    case DynamoDBUpdate:
+        if len(n.KeyFields) == 0 {
+            return errors.New("update mode requires keyFields")
+        }
+    default:
+        return fmt.Errorf("unknown mode %q; use put, batch or update", n.Mode)
+    }
+    if n.UpdateExpression != "" && n.Mode != DynamoDBUpdate {
+        return errors.New("updateExpression requires update mode")
+    }
+    if n.MaxRetries < NoDynamoDBRetries {
+        return fmt.Errorf("maxRetries must be %d or more", NoDynamoDBRetries)
+    }
+    if err := validateValueTemplates(n.ExpressionValues); err != nil {
+        return fmt.Errorf("expression value %w", err)
+    }
+    return nil
 }
 
+// Execute writes the records and returns those that were written.
 func (n *DynamoDBUpsert) Execute(ctx *framework.Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+    ports, err := n.ExecutePorts(ctx, inputs)
+    if err != nil {
+        return nil, err
+    }
+    return ports[0], nil
+}
+
+// ExecutePorts returns the written records on output 0 and those whose
+// condition failed on output 1.
+func (n *DynamoDBUpsert) ExecutePorts(ctx *framework.Context, inputs []map[string]interface{}) ([][]map[string]interface{}, error) {
+    // Clients are created once per set of credentials.
+    clients := map[string]framework.DynamoDBAPI{}
+    if n.Mode == DynamoDBBatch {
+        if err := n.batchWrite(ctx, inputs, clients); err != nil {
+            return nil, err
+        }
+        return [][]map[string]interface{}{inputs, nil}, nil
+    }
+
+    ports := make([][]map[string]interface{}, 2)
     for _, rec := range inputs {
-        tableName, ok := rec[n.TableNameKey].(string)
-        if !ok {
-            return nil, fmt.Errorf("table name not found or not a string in input record for key %s", n.TableNameKey)
+        written, err := n.write(ctx, rec, clients)
+        if err != nil {
+            return nil, err
         }
+        if written {
+            ports[0] = append(ports[0], rec)
+        } else {
+            ports[1] = append(ports[1], rec)
+        }
+    }
+    return ports, nil
+}
 
-        var client framework.DynamoDBPutItemAPI
-        if n.AWSRegionKey != "" && n.AWSAccessKeyIDKey != "" && n.AWSSecretAccessKeyKey != "" && ctx.DynamoDBClientFactory != nil {
-            region, ok := rec[n.AWSRegionKey].(string)
-            if !ok {
-                return nil, fmt.Errorf("AWS region not found or not a string in input record for key %s", n.AWSRegionKey)
-            }
+// write puts or updates the item of a record. It reports false if the
+// condition of the write did not hold.
+func (n *DynamoDBUpsert) write(ctx *framework.Context, rec map[string]interface{}, clients map[string]framework.DynamoDBAPI) (bool, error) {
+    tableName, ok := rec[n.TableNameKey].(string)
+    if !ok {
+        return false, fmt.Errorf("table name not found or not a string in input record for key %s", n.TableNameKey)
+    }
+    client, err := n.credentials().client(ctx, rec, clients)
+    if err != nil {
+        return false, err
+    }
+    item, err := n.item(rec, n.TableNameKey, n.AWSRegionKey, n.AWSAccessKeyIDKey, n.AWSSecretAccessKeyKey)
+    if err != nil {
+        return false, fmt.Errorf("failed to convert record to a DynamoDB item: %w", err)
+    }
+    names, values, err := expressionAttributes(n.ExpressionNames, n.ExpressionValues, rec, ctx.Env)
+    if err != nil {
+        return false, err
+    }
+    var condition *string
+    if n.ConditionExpression != "" {
+        condition = &n.ConditionExpression
+    }
 
-            accessKeyID, ok := rec[n.AWSAccessKeyIDKey].(string)
-            if !ok {
-                return nil, fmt.Errorf("AWS access key ID not found or not a string in input record for key %s", n.AWSAccessKeyIDKey)
-            }
+    if n.Mode == DynamoDBUpdate {
+        input, err := n.updateInput(tableName, item, names, values)
+        if err != nil {
+            return false, err
+        }
+        input.ConditionExpression = condition
+        _, err = client.UpdateItem(ctx.Ctx, input)
+        return conditionResult(ctx, err)
+    }
+    input := &dynamodb.PutItemInput{TableName: &tableName, Item: item, ConditionExpression: condition}
+    if len(names) > 0 {
+        input.ExpressionAttributeNames = names
+    }
+    if len(values) > 0 {
+        input.ExpressionAttributeValues = values
+    }
+    _, err = client.PutItem(ctx.Ctx, input)
+    return conditionResult(ctx, err)
+}
+
+// conditionResult turns the error of a conditional write into its
+// outcome: a failed condition is not an error.
+func conditionResult(ctx *framework.Context, err error) (bool, error) {
+    var failed *types.ConditionalCheckFailedException
+    if errors.As(err, &failed) {
+        if ctx.Logger != nil {
+            ctx.Logger.Infof("DynamoDB condition failed, item not written: %v", err)
+        }
+        return false, nil
+    }
+    return err == nil, err
+}
 
-            secretAccessKey, ok := rec[n.AWSSecretAccessKeyKey].(string)
-            if !ok {
-                return nil, fmt.Errorf("AWS secret access key not found or not a string in input record for key %s", n.AWSSecretAccessKeyKey)
+// updateInput builds the UpdateItem request of an item: the key attributes
+// identify it, and the other attributes are set unless UpdateExpression
+// replaces that.
+func (n *DynamoDBUpsert) updateInput(tableName string, item map[string]types.AttributeValue, names map[string]string, values map[string]types.AttributeValue) (*dynamodb.UpdateItemInput, error) {
+    key := map[string]types.AttributeValue{}
+    for _, field := range n.KeyFields {
+        value, ok := item[field]
+        if !ok {
+            return nil, fmt.Errorf("key attribute %s not found in item", field)
+        }
+        key[field] = value
+    }
+    expression := n.UpdateExpression
+    if expression == "" {
+        attributes := make([]string, 0, len(item))
+        for attribute := range item {
+            if _, isKey := key[attribute]; !isKey {
+                attributes = append(attributes, attribute)
             }
-            var err error
-            client, err = ctx.DynamoDBClientFactory(ctx.Ctx, region, accessKeyID, secretAccessKey)
-            if err != nil {
-                return nil, fmt.Errorf("failed to create DynamoDB client: %w", err)
+        }
+        if len(attributes) == 0 {
+            return nil, errors.New("item has no attributes to update besides its key")
+        }
+        sort.Strings(attributes)
+        sets := make([]string, 0, len(attributes))
+        next := 0
+        for _, attribute := range attributes {
+            // Placeholders of ExpressionNames and ExpressionValues are
+            // skipped, so that the generated ones do not replace them.
+            var name, value string
+            for {
+                name, value = fmt.Sprintf("#attr%d", next), fmt.Sprintf(":attr%d", next)
+                next++
+                _, nameTaken := names[name]
+                _, valueTaken := values[value]
+                if !nameTaken && !valueTaken {
+                    break
+                }
             }
-        } else if ctx.DynamoDBClient != nil {
-            client = ctx.DynamoDBClient
-        } else {
-            return nil, errors.New("no DynamoDB client or factory available")
+            names[name] = attribute
+            values[value] = item[attribute]
+            sets = append(sets, name+" = "+value)
+        }
+        expression = "SET " + strings.Join(sets, ", ")
+    }
+    input := &dynamodb.UpdateItemInput{TableName: &tableName, Key: key, UpdateExpression: &expression}
+    if len(names) > 0 {
+        input.ExpressionAttributeNames = names
+    }
+    if len(values) > 0 {
+        input.ExpressionAttributeValues = values
+    }
+    return input, nil
+}
+
+// batchWrite writes the records with BatchWriteItem, grouped by client and
+// table, retrying the items DynamoDB leaves unprocessed. BatchWriteItem
+// rejects requests that write an item twice, so a record replaces the
+// write of an earlier one with the same key.
+func (n *DynamoDBUpsert) batchWrite(ctx *framework.Context, inputs []map[string]interface{}, clients map[string]framework.DynamoDBAPI) error {
+    type batch struct {
+        client framework.DynamoDBAPI
+        table  string
+        writes []types.WriteRequest
+        // byKey maps item keys to their index in writes.
+        byKey map[string]int
+    }
+    var batches []*batch
+    byTarget := map[string]*batch{}
+    for _, rec := range inputs {
+        tableName, ok := rec[n.TableNameKey].(string)
+        if !ok {
+            return fmt.Errorf("table name not found or not a string in input record for key %s", n.TableNameKey)
+        }
+        client, err := n.credentials().client(ctx, rec, clients)
+        if err != nil {
+            return err
+        }
+        item, err := n.item(rec, n.TableNameKey, n.AWSRegionKey, n.AWSAccessKeyIDKey, n.AWSSecretAccessKeyKey)
+        if err != nil {
+            return fmt.Errorf("failed to convert record to a DynamoDB item: %w", err)
+        }
+        key, err := n.itemKey(item)
+        if err != nil {
+            return err
+        }
+        target := n.credentials().key(rec) + "\x00" + tableName
+        b, ok := byTarget[target]
+        if !ok {
+            b = &batch{client: client, table: tableName, byKey: map[string]int{}}
+            byTarget[target] = b
+            batches = append(batches, b)
         }
+        write := types.WriteRequest{PutRequest: &types.PutRequest{Item: item}}
+        if i, ok := b.byKey[key]; ok {
+            b.writes[i] = write
+            continue
+        }
+        b.byKey[key] = len(b.writes)
+        b.writes = append(b.writes, write)
+    }
 
-        item := map[string]types.AttributeValue{}
-        for k, v := range rec {
-            if s, ok := v.(string); ok {
-                item[k] = &types.AttributeValueMemberS{Value: s}
+    for _, b := range batches {
+        for start := 0; start < len(b.writes); start += dynamoDBBatchSize {
+            end := start + dynamoDBBatchSize
+            if end > len(b.writes) {
+                end = len(b.writes)
+            }
+            if err := n.writeBatch(ctx, b.client, map[string][]types.WriteRequest{b.table: b.writes[start:end]}); err != nil {
+                return err
             }
         }
-        _, err := client.PutItem(ctx.Ctx, &dynamodb.PutItemInput{TableName: &tableName, Item: item})
+    }
+    return nil
+}
+
+// itemKey returns a string identifying the key of an item, made of the
+// KeyFields attributes, which DynamoDB only allows to be strings, numbers
+// or binary.
+func (n *DynamoDBUpsert) itemKey(item map[string]types.AttributeValue) (string, error) {
+    var key strings.Builder
+    for _, field := range n.KeyFields {
+        switch value := item[field].(type) {
+        case *types.AttributeValueMemberS:
+            fmt.Fprintf(&key, "S%q", value.Value)
+        case *types.AttributeValueMemberN:
+            fmt.Fprintf(&key, "N%q", value.Value)
+        case *types.AttributeValueMemberB:
+            fmt.Fprintf(&key, "B%q", value.Value)
+        case nil:
+            return "", fmt.Errorf("key attribute %s not found in item", field)
+        default:
+            return "", fmt.Errorf("key attribute %s is not a string, number or binary", field)
+        }
+    }
+    return key.String(), nil
+}
+
+// writeBatch sends a BatchWriteItem request and retries its unprocessed
+// items with exponential backoff.
+func (n *DynamoDBUpsert) writeBatch(ctx *framework.Context, client framework.DynamoDBAPI, items map[string][]types.WriteRequest) error {
+    maxRetries := n.MaxRetries
+    switch maxRetries {
+    case 0:
+        maxRetries = DefaultDynamoDBMaxRetries
+    case NoDynamoDBRetries:
+        maxRetries = 0
+    }
+    delay := dynamoDBRetryDelay
+    for attempt := 0; ; attempt++ {
+        out, err := client.BatchWriteItem(ctx.Ctx, &dynamodb.BatchWriteItemInput{RequestItems: items})
         if err != nil {
-            return nil, err
+            return err
+        }
+        items = out.UnprocessedItems
+        unprocessed := 0
+        for _, writes := range items {
+            unprocessed += len(writes)
+        }
+        if unprocessed == 0 {
+            return nil
+        }
+        if attempt >= maxRetries {
+            return fmt.Errorf("%d items not written after %d retries", unprocessed, maxRetries)
         }
+        if ctx.Logger != nil {
+            ctx.Logger.Warnf("retrying %d unprocessed DynamoDB items", unprocessed)
+        }
+        select {
+        case <-time.After(delay):
+        case <-ctx.Ctx.Done():
+            return ctx.Ctx.Err()
+        }
+        delay *= 2
     }
-    return inputs, nil
-}
\ No newline at end of file
+}
+
+// credentials returns the record fields of the AWS credentials.
+func (n *DynamoDBUpsert) credentials() DynamoDBCredentials {
+    return DynamoDBCredentials{AWSProfile: n.AWSProfile, AWSRegionKey: n.AWSRegionKey, AWSAccessKeyIDKey: n.AWSAccessKeyIDKey, AWSSecretAccessKeyKey: n.AWSSecretAccessKeyKey}
+}
+
+var _ framework.PortNode = (*DynamoDBUpsert)(nil)
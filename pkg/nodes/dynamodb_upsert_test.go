@@ -4,15 +4,23 @@ import (
 	"context"
 	"errors"
 	"go-workflow/pkg/framework"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 // mockDynamoDBClient is a mock implementation of the DynamoDB client for testing.
 type mockDynamoDBClient struct {
-	putItem func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	putItem        func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	updateItem     func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	batchWriteItem func(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	getItem        func(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	query          func(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	scan           func(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
 }
 
 func (m *mockDynamoDBClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
@@ -22,6 +30,41 @@ func (m *mockDynamoDBClient) PutItem(ctx context.Context, params *dynamodb.PutIt
 	return &dynamodb.PutItemOutput{}, nil
 }
 
+func (m *mockDynamoDBClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	if m.updateItem != nil {
+		return m.updateItem(ctx, params, optFns...)
+	}
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func (m *mockDynamoDBClient) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	if m.batchWriteItem != nil {
+		return m.batchWriteItem(ctx, params, optFns...)
+	}
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+func (m *mockDynamoDBClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	if m.getItem != nil {
+		return m.getItem(ctx, params, optFns...)
+	}
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func (m *mockDynamoDBClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if m.query != nil {
+		return m.query(ctx, params, optFns...)
+	}
+	return &dynamodb.QueryOutput{}, nil
+}
+
+func (m *mockDynamoDBClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if m.scan != nil {
+		return m.scan(ctx, params, optFns...)
+	}
+	return &dynamodb.ScanOutput{}, nil
+}
+
 func TestDynamoDBUpsert_Execute(t *testing.T) {
 	node := &DynamoDBUpsert{
 		TableNameKey:        "tableName",
@@ -72,6 +115,11 @@ func TestDynamoDBUpsert_Execute(t *testing.T) {
 	if capturedInput.Item["id"].(*types.AttributeValueMemberS).Value != "123" {
 		t.Errorf("expected id to be 123, got %s", capturedInput.Item["id"].(*types.AttributeValueMemberS).Value)
 	}
+	for _, key := range []string{"tableName", "awsRegion", "awsAccessKeyID", "awsSecretAccessKey"} {
+		if _, ok := capturedInput.Item[key]; ok {
+			t.Errorf("expected %s not to be written into the item", key)
+		}
+	}
 }
 
 func TestDynamoDBUpsert_Execute_PutItemError(t *testing.T) {
@@ -253,3 +301,227 @@ func TestDynamoDBUpsert_Execute_DefaultClient(t *testing.T) {
 		t.Errorf("expected table name test-table, got %s", *capturedInput.TableName)
 	}
 }
+
+func TestDynamoDBUpsert_Batch(t *testing.T) {
+	defer func(delay time.Duration) { dynamoDBRetryDelay = delay }(dynamoDBRetryDelay)
+	dynamoDBRetryDelay = time.Millisecond
+
+	var sizes []int
+	retried := false
+	factoryCalls := 0
+	mockClient := &mockDynamoDBClient{
+		batchWriteItem: func(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+			writes := params.RequestItems["test-table"]
+			sizes = append(sizes, len(writes))
+			if !retried {
+				// The first request leaves its last two items unprocessed.
+				retried = true
+				return &dynamodb.BatchWriteItemOutput{UnprocessedItems: map[string][]types.WriteRequest{"test-table": writes[len(writes)-2:]}}, nil
+			}
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+	ctx := &framework.Context{
+		Ctx: context.Background(),
+		DynamoDBClientFactory: func(ctx context.Context, region, accessKeyID, secretAccessKey string) (framework.DynamoDBPutItemAPI, error) {
+			factoryCalls++
+			return mockClient, nil
+		},
+	}
+	node := NewDynamoDBUpsert("tableName", "awsRegion", "awsAccessKeyID", "awsSecretAccessKey")
+	node.Mode = DynamoDBBatch
+	node.KeyFields = []string{"id"}
+
+	var inputs []map[string]interface{}
+	for i := 0; i < 30; i++ {
+		inputs = append(inputs, map[string]interface{}{
+			"tableName": "test-table", "awsRegion": "us-east-1", "awsAccessKeyID": "key", "awsSecretAccessKey": "secret", "id": i,
+		})
+	}
+	out, err := node.Execute(ctx, inputs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out) != 30 {
+		t.Errorf("expected all records to be returned, got %d", len(out))
+	}
+	if !reflect.DeepEqual(sizes, []int{25, 2, 5}) {
+		t.Errorf("expected chunks of 25 and a retry of the unprocessed items, got %v", sizes)
+	}
+	if factoryCalls != 1 {
+		t.Errorf("expected one client for the same credentials, got %d", factoryCalls)
+	}
+}
+
+func TestDynamoDBUpsert_BatchRetriesExhausted(t *testing.T) {
+	defer func(delay time.Duration) { dynamoDBRetryDelay = delay }(dynamoDBRetryDelay)
+	dynamoDBRetryDelay = time.Millisecond
+
+	calls := 0
+	mockClient := &mockDynamoDBClient{
+		batchWriteItem: func(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+			calls++
+			return &dynamodb.BatchWriteItemOutput{UnprocessedItems: params.RequestItems}, nil
+		},
+	}
+	ctx := &framework.Context{Ctx: context.Background(), DynamoDBClient: mockClient}
+	node := NewDynamoDBUpsert("tableName", "", "", "")
+	node.Mode = DynamoDBBatch
+	node.KeyFields = []string{"id"}
+	node.MaxRetries = 2
+
+	_, err := node.Execute(ctx, []map[string]interface{}{{"tableName": "test-table", "id": 1}})
+	if err == nil || err.Error() != "1 items not written after 2 retries" {
+		t.Errorf("expected the retries to be exhausted, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 requests, got %d", calls)
+	}
+
+	calls = 0
+	node.MaxRetries = NoDynamoDBRetries
+	_, err = node.Execute(ctx, []map[string]interface{}{{"tableName": "test-table", "id": 1}})
+	if err == nil || err.Error() != "1 items not written after 0 retries" {
+		t.Errorf("expected no retries, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 request without retries, got %d", calls)
+	}
+}
+
+func TestDynamoDBUpsert_BatchDuplicateKeys(t *testing.T) {
+	var requests [][]types.WriteRequest
+	mockClient := &mockDynamoDBClient{
+		batchWriteItem: func(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+			requests = append(requests, params.RequestItems["test-table"])
+			return &dynamodb.BatchWriteItemOutput{}, nil
+		},
+	}
+	ctx := &framework.Context{Ctx: context.Background(), DynamoDBClient: mockClient}
+	node := NewDynamoDBUpsert("tableName", "", "", "")
+	node.Mode = DynamoDBBatch
+	node.KeyFields = []string{"pk", "sk"}
+
+	inputs := []map[string]interface{}{
+		{"tableName": "test-table", "pk": "a", "sk": 1, "name": "first"},
+		{"tableName": "test-table", "pk": "a", "sk": 2, "name": "other"},
+		{"tableName": "test-table", "pk": "a", "sk": 1, "name": "last"},
+	}
+	if _, err := node.Execute(ctx, inputs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(requests) != 1 || len(requests[0]) != 2 {
+		t.Fatalf("expected one request with two items, got %v", requests)
+	}
+	if name := requests[0][0].PutRequest.Item["name"]; !reflect.DeepEqual(name, &types.AttributeValueMemberS{Value: "last"}) {
+		t.Errorf("expected the last record of a key to be written, got %v", name)
+	}
+
+	if _, err := node.Execute(ctx, []map[string]interface{}{{"tableName": "test-table", "pk": "a"}}); err == nil {
+		t.Error("expected an error for a record without its key")
+	}
+}
+
+func TestDynamoDBUpsert_Update(t *testing.T) {
+	var captured *dynamodb.UpdateItemInput
+	mockClient := &mockDynamoDBClient{
+		updateItem: func(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+			captured = params
+			return &dynamodb.UpdateItemOutput{}, nil
+		},
+	}
+	ctx := &framework.Context{Ctx: context.Background(), DynamoDBClient: mockClient}
+	node := NewDynamoDBUpsert("tableName", "", "", "")
+	node.Mode = DynamoDBUpdate
+	node.KeyFields = []string{"pk"}
+	node.ConditionExpression = "attribute_not_exists(#s) OR #s <> :status"
+	node.ExpressionNames = map[string]string{"#s": "status"}
+	node.ExpressionValues = map[string]interface{}{":status": "{{.status}}"}
+
+	_, err := node.Execute(ctx, []map[string]interface{}{{"tableName": "test-table", "pk": "a", "name": "Acme", "status": "active"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if captured == nil {
+		t.Fatal("UpdateItem was not called")
+	}
+	if !reflect.DeepEqual(captured.Key, map[string]types.AttributeValue{"pk": &types.AttributeValueMemberS{Value: "a"}}) {
+		t.Errorf("unexpected key %v", captured.Key)
+	}
+	if *captured.UpdateExpression != "SET #attr0 = :attr0, #attr1 = :attr1" {
+		t.Errorf("unexpected update expression %s", *captured.UpdateExpression)
+	}
+	if !reflect.DeepEqual(captured.ExpressionAttributeNames, map[string]string{"#s": "status", "#attr0": "name", "#attr1": "status"}) {
+		t.Errorf("unexpected names %v", captured.ExpressionAttributeNames)
+	}
+	if v := captured.ExpressionAttributeValues[":status"]; !reflect.DeepEqual(v, &types.AttributeValueMemberS{Value: "active"}) {
+		t.Errorf("expected the rendered expression value, got %v", v)
+	}
+	if *captured.ConditionExpression != node.ConditionExpression {
+		t.Errorf("unexpected condition %s", *captured.ConditionExpression)
+	}
+
+	// Generated placeholders skip those the expressions already use.
+	node.ConditionExpression = "#attr0 <> :attr1"
+	node.ExpressionNames = map[string]string{"#attr0": "status"}
+	node.ExpressionValues = map[string]interface{}{":attr1": "closed"}
+	if _, err := node.Execute(ctx, []map[string]interface{}{{"tableName": "test-table", "pk": "a", "name": "Acme", "status": "active"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *captured.UpdateExpression != "SET #attr2 = :attr2, #attr3 = :attr3" {
+		t.Errorf("unexpected update expression %s", *captured.UpdateExpression)
+	}
+	if !reflect.DeepEqual(captured.ExpressionAttributeNames, map[string]string{"#attr0": "status", "#attr2": "name", "#attr3": "status"}) {
+		t.Errorf("unexpected names %v", captured.ExpressionAttributeNames)
+	}
+	if v := captured.ExpressionAttributeValues[":attr1"]; !reflect.DeepEqual(v, &types.AttributeValueMemberS{Value: "closed"}) {
+		t.Errorf("expected the user's value placeholder to be kept, got %v", v)
+	}
+}
+
+func TestDynamoDBUpsert_ConditionFailed(t *testing.T) {
+	mockClient := &mockDynamoDBClient{
+		putItem: func(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+			if params.Item["id"].(*types.AttributeValueMemberS).Value == "old" {
+				return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+			}
+			return &dynamodb.PutItemOutput{}, nil
+		},
+	}
+	ctx := &framework.Context{Ctx: context.Background(), DynamoDBClient: mockClient}
+	node := NewDynamoDBUpsert("tableName", "", "", "")
+	node.ConditionExpression = "attribute_not_exists(id)"
+
+	inputs := []map[string]interface{}{{"tableName": "t", "id": "new"}, {"tableName": "t", "id": "old"}}
+	ports, err := node.ExecutePorts(ctx, inputs)
+	if err != nil {
+		t.Fatalf("expected a failed condition not to be an error, got %v", err)
+	}
+	if !reflect.DeepEqual(ports[0], inputs[:1]) || !reflect.DeepEqual(ports[1], inputs[1:]) {
+		t.Errorf("expected the written and failed records on outputs 0 and 1, got %v", ports)
+	}
+	out, _ := node.Execute(ctx, inputs)
+	if !reflect.DeepEqual(out, inputs[:1]) {
+		t.Errorf("expected Execute to return the written records, got %v", out)
+	}
+}
+
+func TestValidateDynamoDBWrite(t *testing.T) {
+	tests := map[string]*DynamoDBUpsert{
+		"unknown mode":            {Mode: "delete"},
+		"batch with condition":    {Mode: DynamoDBBatch, ConditionExpression: "attribute_not_exists(id)"},
+		"update without key":      {Mode: DynamoDBUpdate},
+		"expression without mode": {UpdateExpression: "SET a = :a"},
+		"batch without key":       {Mode: DynamoDBBatch},
+		"negative retries":        {Mode: DynamoDBBatch, KeyFields: []string{"id"}, MaxRetries: -2},
+		"invalid value template":  {ExpressionValues: map[string]interface{}{":a": "{{.a"}},
+	}
+	for name, node := range tests {
+		if err := ValidateDynamoDBWrite(node); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if err := ValidateDynamoDBWrite(&DynamoDBUpsert{Mode: DynamoDBUpdate, KeyFields: []string{"pk"}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package nodes
+
+import (
+	"errors"
+	"fmt"
+
+	"go-workflow/pkg/framework"
+)
+
+// Defaults of embeddings nodes.
+const (
+	DefaultEmbeddingField     = "embedding"
+	DefaultEmbeddingBatchSize = 100
+)
+
+// EmbeddingsNode adds the embedding vector of a text field to every record,
+// for vectorStore nodes to index and search.
+type EmbeddingsNode struct {
+	// Field holds the text to embed.
+	Field string
+	// OutputKey is the field the vector is stored under, "embedding" if
+	// empty.
+	OutputKey string
+	// Provider names the client to use from ctx.LLMClientFactory; if empty,
+	// ctx.LangChain is used. The client must create embeddings.
+	Provider string
+	// BatchSize is how many texts are sent per request,
+	// DefaultEmbeddingBatchSize if zero.
+	BatchSize int
+}
+
+// NewEmbeddingsNode creates a new EmbeddingsNode embedding field.
+func NewEmbeddingsNode(field string) *EmbeddingsNode {
+	return &EmbeddingsNode{Field: field}
+}
+
+// Execute embeds the text field of the input records in batches.
+func (n *EmbeddingsNode) Execute(ctx *framework.Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+	embedder, err := n.embedder(ctx)
+	if err != nil {
+		return nil, err
+	}
+	texts := make([]string, len(inputs))
+	for i, rec := range inputs {
+		text, ok := rec[n.Field].(string)
+		if !ok {
+			return nil, fmt.Errorf("record %d has no text field %q", i, n.Field)
+		}
+		texts[i] = text
+	}
+
+	batchSize := n.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultEmbeddingBatchSize
+	}
+	outputKey := n.OutputKey
+	if outputKey == "" {
+		outputKey = DefaultEmbeddingField
+	}
+	out := make([]map[string]interface{}, 0, len(inputs))
+	for start := 0; start < len(texts); start += batchSize {
+		end := start + batchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		vectors, err := embedder.CreateEmbedding(ctx.Ctx, texts[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to create embeddings: %w", err)
+		}
+		if len(vectors) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", end-start, len(vectors))
+		}
+		for i, vector := range vectors {
+			rec := make(map[string]interface{}, len(inputs[start+i])+1)
+			for k, v := range inputs[start+i] {
+				rec[k] = v
+			}
+			rec[outputKey] = vector
+			out = append(out, rec)
+		}
+	}
+	return out, nil
+}
+
+func (n *EmbeddingsNode) embedder(ctx *framework.Context) (framework.LangChainEmbedder, error) {
+	var client framework.LangChainClient = ctx.LangChain
+	if n.Provider != "" {
+		if ctx.LLMClientFactory == nil {
+			return nil, errors.New("no LLM client factory available")
+		}
+		var err error
+		if client, err = ctx.LLMClientFactory(ctx.Ctx, n.Provider); err != nil {
+			return nil, err
+		}
+	}
+	if client == nil {
+		return nil, errors.New("no LLM client available")
+	}
+	embedder, ok := client.(framework.LangChainEmbedder)
+	if !ok {
+		return nil, errors.New("the LLM client cannot create embeddings")
+	}
+	return embedder, nil
+}
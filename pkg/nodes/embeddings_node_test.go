@@ -0,0 +1,105 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go-workflow/pkg/framework"
+	"go-workflow/pkg/llm"
+)
+
+// fakeEmbedder embeds a text as its length and counts its requests.
+type fakeEmbedder struct {
+	fakeLangChainClient
+	batches [][]string
+	err     error
+}
+
+func (f *fakeEmbedder) CreateEmbedding(ctx context.Context, texts []string) ([][]float32, error) {
+	f.batches = append(f.batches, texts)
+	if f.err != nil {
+		return nil, f.err
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = []float32{float32(len(text)), 1}
+	}
+	return vectors, nil
+}
+
+func TestEmbeddingsNode(t *testing.T) {
+	embedder := &fakeEmbedder{}
+	ctx := &framework.Context{Ctx: context.Background(), LangChain: embedder}
+	node := NewEmbeddingsNode("bio")
+	node.BatchSize = 2
+	inputs := []map[string]interface{}{{"bio": "a"}, {"bio": "bb"}, {"bio": "ccc"}}
+
+	out, err := node.Execute(ctx, inputs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(embedder.batches) != 2 || len(embedder.batches[0]) != 2 || len(embedder.batches[1]) != 1 {
+		t.Errorf("expected batches of 2 and 1 texts, got %v", embedder.batches)
+	}
+	for i, rec := range out {
+		vector, ok := rec["embedding"].([]float32)
+		if !ok || vector[0] != float32(i+1) {
+			t.Errorf("record %d: expected the embedding of its bio, got %v", i, rec)
+		}
+	}
+	if _, ok := inputs[0]["embedding"]; ok {
+		t.Error("expected the input records not to be modified")
+	}
+}
+
+func TestEmbeddingsNode_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     *framework.Context
+		inputs  []map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:    "missing field",
+			ctx:     &framework.Context{Ctx: context.Background(), LangChain: &fakeEmbedder{}},
+			inputs:  []map[string]interface{}{{"name": "Ada"}},
+			wantErr: `record 0 has no text field "bio"`,
+		},
+		{
+			name:    "client without embeddings",
+			ctx:     &framework.Context{Ctx: context.Background(), LangChain: &fakeLangChainClient{}},
+			inputs:  []map[string]interface{}{{"bio": "a"}},
+			wantErr: "cannot create embeddings",
+		},
+		{
+			name:    "provider error",
+			ctx:     &framework.Context{Ctx: context.Background(), LangChain: &fakeEmbedder{err: errors.New("rate limited")}},
+			inputs:  []map[string]interface{}{{"bio": "a"}},
+			wantErr: "rate limited",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewEmbeddingsNode("bio").Execute(tt.ctx, tt.inputs)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestEmbeddingsNode_Provider(t *testing.T) {
+	ctx := &framework.Context{Ctx: context.Background(), LLMClientFactory: llm.NewClientFactory(nil)}
+	node := NewEmbeddingsNode("bio")
+	node.Provider = "mock"
+	node.OutputKey = "vector"
+	out, err := node.Execute(ctx, []map[string]interface{}{{"bio": "Go developer"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vector, ok := out[0]["vector"].([]float32); !ok || len(vector) == 0 {
+		t.Errorf("expected a vector from the mock provider, got %v", out[0])
+	}
+}
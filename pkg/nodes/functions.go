@@ -0,0 +1,92 @@
+package nodes
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"go-workflow/pkg/framework"
+)
+
+// Built-in functions that codeNode nodes run by name; noderegistry
+// registers them with framework.RegisterFunction.
+
+// SliceParams are the parameters of SliceFunction.
+type SliceParams struct {
+	Offset int `yaml:"offset" doc:"Number of records to skip."`
+	Limit  int `yaml:"limit" doc:"Maximum number of records to keep; all if zero."`
+}
+
+// Validate checks that offset and limit are not negative.
+func (p *SliceParams) Validate() error {
+	if p.Offset < 0 || p.Limit < 0 {
+		return errors.New("offset and limit must not be negative")
+	}
+	return nil
+}
+
+// SliceFunction keeps up to Limit records, starting at Offset.
+func SliceFunction(ctx *framework.Context, p SliceParams, items []map[string]interface{}) ([]map[string]interface{}, error) {
+	if p.Offset >= len(items) {
+		return []map[string]interface{}{}, nil
+	}
+	items = items[p.Offset:]
+	if p.Limit > 0 && p.Limit < len(items) {
+		items = items[:p.Limit]
+	}
+	return items, nil
+}
+
+// TopParams are the parameters of TopFunction.
+type TopParams struct {
+	Field     string `yaml:"field" required:"true" doc:"Field to sort the records by."`
+	Limit     int    `yaml:"limit" required:"true" doc:"Number of records to keep."`
+	Ascending bool   `yaml:"ascending" doc:"Keep the lowest values instead of the highest."`
+}
+
+// Validate checks the field and limit.
+func (p *TopParams) Validate() error {
+	if p.Field == "" {
+		return errors.New("field is required")
+	}
+	if p.Limit <= 0 {
+		return errors.New("limit must be positive")
+	}
+	return nil
+}
+
+// TopFunction keeps the Limit records with the highest values of Field, or
+// the lowest if Ascending. Numbers are compared as numbers and other values
+// as text; records without the field come last.
+func TopFunction(ctx *framework.Context, p TopParams, items []map[string]interface{}) ([]map[string]interface{}, error) {
+	sorted := append([]map[string]interface{}(nil), items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, okA := sorted[i][p.Field]
+		b, okB := sorted[j][p.Field]
+		if !okA || !okB {
+			return okA
+		}
+		if p.Ascending {
+			return lessValue(a, b)
+		}
+		return lessValue(b, a)
+	})
+	if p.Limit < len(sorted) {
+		sorted = sorted[:p.Limit]
+	}
+	return sorted, nil
+}
+
+// lessValue orders numbers before other values, numbers by value and other
+// values by their text.
+func lessValue(a, b interface{}) bool {
+	fa, okA := toFloat(a)
+	fb, okB := toFloat(b)
+	if okA && okB {
+		return fa < fb
+	}
+	if okA != okB {
+		return okA
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
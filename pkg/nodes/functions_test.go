@@ -0,0 +1,58 @@
+package nodes
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go-workflow/pkg/framework"
+)
+
+func TestSliceFunction(t *testing.T) {
+	items := []map[string]interface{}{{"id": 1}, {"id": 2}, {"id": 3}}
+	ctx := &framework.Context{Ctx: context.Background()}
+	out, _ := SliceFunction(ctx, SliceParams{Offset: 1, Limit: 1}, items)
+	if !reflect.DeepEqual(out, items[1:2]) {
+		t.Errorf("expected the second record, got %v", out)
+	}
+	out, _ = SliceFunction(ctx, SliceParams{Offset: 5}, items)
+	if len(out) != 0 {
+		t.Errorf("expected no records past the end, got %v", out)
+	}
+	if err := (&SliceParams{Limit: -1}).Validate(); err == nil {
+		t.Error("expected an error for a negative limit")
+	}
+}
+
+func TestTopFunction(t *testing.T) {
+	items := []map[string]interface{}{{"id": "a", "score": 2}, {"id": "b"}, {"id": "c", "score": 9.5}, {"id": "d", "score": "7"}}
+	ctx := &framework.Context{Ctx: context.Background()}
+	out, _ := TopFunction(ctx, TopParams{Field: "score", Limit: 2}, items)
+	if len(out) != 2 || out[0]["id"] != "c" || out[1]["id"] != "d" {
+		t.Errorf("expected the two highest scores, got %v", out)
+	}
+	out, _ = TopFunction(ctx, TopParams{Field: "score", Limit: 10, Ascending: true}, items)
+	if len(out) != 4 || out[0]["id"] != "a" || out[3]["id"] != "b" {
+		t.Errorf("expected the lowest scores first and the record without score last, got %v", out)
+	}
+	if items[0]["id"] != "a" || items[2]["id"] != "c" {
+		t.Error("expected the input not to be reordered")
+	}
+}
+
+func TestCodeNode_Function(t *testing.T) {
+	node := &CodeNode{Function: func(ctx *framework.Context, items []map[string]interface{}) ([]map[string]interface{}, error) {
+		return SliceFunction(ctx, SliceParams{Limit: 1}, items)
+	}}
+	if err := ValidateCodeNode(node); err != nil {
+		t.Fatalf("invalid node: %v", err)
+	}
+	out, err := node.Execute(&framework.Context{Ctx: context.Background()}, []map[string]interface{}{{"id": 1}, {"id": 2}})
+	if err != nil || len(out) != 1 {
+		t.Errorf("expected the function to run, got %v, %v", out, err)
+	}
+	node.Code = "return items;"
+	if err := ValidateCodeNode(node); err == nil {
+		t.Error("expected an error for code and function")
+	}
+}
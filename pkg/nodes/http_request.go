@@ -9,11 +9,20 @@ import (
 )
 
 // HTTPRequest performs templated HTTP calls
+//
+// The URL, method, headers and body are read from the fields of each record
+// named by the *Key settings. A setting whose key is empty falls back to the
+// static value (URL, Method, Headers, Body); the method defaults to GET.
 type HTTPRequest struct {
     URLKey      string
     MethodKey   string
     HeadersKey  string
     BodyKey     string
+
+    URL     string
+    Method  string
+    Headers map[string]string
+    Body    interface{}
 }
 
 func NewHTTPRequest(urlKey, methodKey, headersKey, bodyKey string) *HTTPRequest {
@@ -28,21 +37,36 @@ func NewHTTPRequest(urlKey, methodKey, headersKey, bodyKey string) *HTTPRequest
 func (n *HTTPRequest) Execute(ctx *framework.Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
     var out []map[string]interface{}
     for _, rec := range inputs {
-        urlStr, ok := rec[n.URLKey].(string)
-        if !ok {
-            return nil, fmt.Errorf("URL not found or not a string in input record for key %s", n.URLKey)
+        urlStr := n.URL
+        if n.URLKey != "" || urlStr == "" {
+            var ok bool
+            urlStr, ok = rec[n.URLKey].(string)
+            if !ok {
+                return nil, fmt.Errorf("URL not found or not a string in input record for key %s", n.URLKey)
+            }
+        }
+        methodStr := n.Method
+        if n.MethodKey != "" {
+            var ok bool
+            methodStr, ok = rec[n.MethodKey].(string)
+            if !ok {
+                return nil, fmt.Errorf("Method not found or not a string in input record for key %s", n.MethodKey)
+            }
         }
-        methodStr, ok := rec[n.MethodKey].(string)
-        if !ok {
-            return nil, fmt.Errorf("Method not found or not a string in input record for key %s", n.MethodKey)
+        if methodStr == "" {
+            methodStr = "GET"
         }
 
         var bodyBytes []byte
+        bodyContent := n.Body
         if n.BodyKey != "" {
-            bodyContent, ok := rec[n.BodyKey]
+            var ok bool
+            bodyContent, ok = rec[n.BodyKey]
             if !ok {
                 return nil, fmt.Errorf("Body not found in input record for key %s", n.BodyKey)
             }
+        }
+        if bodyContent != nil {
             var err error
             bodyBytes, err = json.Marshal(bodyContent)
             if err != nil {
@@ -56,6 +80,9 @@ func (n *HTTPRequest) Execute(ctx *framework.Context, inputs []map[string]interf
         }
 
         req.Header.Set("Content-Type", "application/json")
+        for k, v := range n.Headers {
+            req.Header.Set(k, v)
+        }
 
         if n.HeadersKey != "" {
             headers, ok := rec[n.HeadersKey].(map[string]interface{})
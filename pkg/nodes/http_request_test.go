@@ -34,8 +34,8 @@ func TestHTTPRequest_Execute(t *testing.T) {
 
 	inputs := []map[string]interface{}{
 		{
-			"url":    server.URL + "/test",
-			"method": "POST",
+			"url":     server.URL + "/test",
+			"method":  "POST",
 			"headers": map[string]interface{}{"X-Test-Header": "test-value"},
 			"body":    map[string]string{"key": "testValue"},
 		},
@@ -180,11 +180,47 @@ func TestHTTPRequest_Execute_UnmarshallableBody(t *testing.T) {
 		{
 			"url":    "http://example.com",
 			"method": "POST",
-			"body":    make(chan int), // Channels cannot be marshalled to JSON
+			"body":   make(chan int), // Channels cannot be marshalled to JSON
 		},
 	}
 	_, err := node.Execute(ctx, inputs)
 	if err == nil || err.Error() != "failed to marshal body content: json: unsupported type: chan int" {
 		t.Errorf("expected error for unmarshallable body, got %v", err)
 	}
-}
\ No newline at end of file
+}
+
+func TestHTTPRequest_Execute_StaticRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" {
+			t.Errorf("expected method PUT, got %s", r.Method)
+		}
+		if r.Header.Get("X-Static") != "yes" {
+			t.Errorf("expected static header, got %q", r.Header.Get("X-Static"))
+		}
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["fixed"] != "value" {
+			t.Errorf("expected static body, got %v", body)
+		}
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	node := NewHTTPRequest("", "", "", "")
+	node.URL = server.URL
+	node.Method = "PUT"
+	node.Headers = map[string]string{"X-Static": "yes"}
+	node.Body = map[string]interface{}{"fixed": "value"}
+
+	ctx := &framework.Context{
+		Ctx:        context.Background(),
+		HTTPClient: retryablehttp.NewClient(),
+	}
+	out, err := node.Execute(ctx, []map[string]interface{}{{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out) != 1 || out[0]["status"] != "ok" {
+		t.Errorf("unexpected output: %v", out)
+	}
+}
@@ -0,0 +1,39 @@
+package nodes
+
+import (
+	"go-workflow/pkg/framework"
+)
+
+// IfNode splits records into those that match its conditions (output 0)
+// and those that do not (output 1).
+type IfNode struct {
+	Conditions []Condition
+	Combine    string // "all" (default) or "any"
+}
+
+// NewIfNode creates a new IfNode.
+func NewIfNode(conditions []Condition, combine string) *IfNode {
+	return &IfNode{Conditions: conditions, Combine: combine}
+}
+
+// Execute returns the records that match, i.e. the true branch.
+func (n *IfNode) Execute(ctx *framework.Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+	ports, err := n.ExecutePorts(ctx, inputs)
+	if err != nil {
+		return nil, err
+	}
+	return ports[0], nil
+}
+
+// ExecutePorts returns the matching records on output 0 and the rest on output 1.
+func (n *IfNode) ExecutePorts(ctx *framework.Context, inputs []map[string]interface{}) ([][]map[string]interface{}, error) {
+	ports := make([][]map[string]interface{}, 2)
+	for _, input := range inputs {
+		if MatchConditions(n.Conditions, n.Combine, input) {
+			ports[0] = append(ports[0], input)
+		} else {
+			ports[1] = append(ports[1], input)
+		}
+	}
+	return ports, nil
+}
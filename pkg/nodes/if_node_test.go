@@ -0,0 +1,40 @@
+package nodes
+
+import (
+	"context"
+	"testing"
+
+	"go-workflow/pkg/framework"
+)
+
+func TestIfNode_ExecutePorts(t *testing.T) {
+	ctx := &framework.Context{Ctx: context.Background()}
+	node := NewIfNode([]Condition{{Field: "age", Value: 18, Operation: OpLargerEqual}}, "")
+
+	inputs := []map[string]interface{}{
+		{"id": 1, "age": 17},
+		{"id": 2, "age": 18},
+		{"id": 3},
+	}
+	ports, err := node.ExecutePorts(ctx, inputs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ports) != 2 {
+		t.Fatalf("expected 2 outputs, got %d", len(ports))
+	}
+	if len(ports[0]) != 1 || ports[0][0]["id"] != 2 {
+		t.Errorf("expected only id 2 on the true output, got %v", ports[0])
+	}
+	if len(ports[1]) != 2 {
+		t.Errorf("expected 2 records on the false output, got %v", ports[1])
+	}
+
+	out, err := node.Execute(ctx, inputs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out) != 1 || out[0]["id"] != 2 {
+		t.Errorf("expected Execute to return the true output, got %v", out)
+	}
+}
@@ -0,0 +1,237 @@
+package nodes
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// jsonSchemaTypes are the JSON schema types ValidateAgainstSchema knows.
+var jsonSchemaTypes = map[string]bool{
+	"object":  true,
+	"array":   true,
+	"string":  true,
+	"number":  true,
+	"integer": true,
+	"boolean": true,
+	"null":    true,
+}
+
+// ValidateJSONSchema checks that schema is a JSON schema
+// ValidateAgainstSchema can apply: known types, object properties and
+// array items that are schemas themselves, and patterns that compile.
+//
+// The supported keywords are type, enum, const, properties, required,
+// additionalProperties, items, minItems, maxItems, minLength, maxLength,
+// pattern, minimum and maximum. Other keywords, such as description, are
+// ignored.
+func ValidateJSONSchema(schema map[string]interface{}) error {
+	return validateJSONSchema(schema, "")
+}
+
+func validateJSONSchema(schema map[string]interface{}, path string) error {
+	for _, t := range schemaTypes(schema) {
+		if !jsonSchemaTypes[t] {
+			return fmt.Errorf("schema%s: unknown type %q", path, t)
+		}
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("schema%s: invalid pattern: %w", path, err)
+		}
+	}
+	if properties, ok := schema["properties"]; ok {
+		properties, ok := properties.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("schema%s: properties must be an object", path)
+		}
+		for name, property := range properties {
+			property, ok := property.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("schema%s.%s: must be an object", path, name)
+			}
+			if err := validateJSONSchema(property, path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+		if err := validateJSONSchema(additional, path+".*"); err != nil {
+			return err
+		}
+	}
+	if items, ok := schema["items"]; ok {
+		items, ok := items.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("schema%s: items must be an object", path)
+		}
+		if err := validateJSONSchema(items, path+"[]"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateAgainstSchema reports the first way value, as decoded by
+// encoding/json, does not match schema. Errors name the offending field,
+// e.g. "$.contacts[2].email: is required", so they can be sent back to a
+// model to correct its answer.
+func ValidateAgainstSchema(schema map[string]interface{}, value interface{}) error {
+	return validateAgainstSchema(schema, value, "$")
+}
+
+func validateAgainstSchema(schema map[string]interface{}, value interface{}, path string) error {
+	if types := schemaTypes(schema); len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			if hasSchemaType(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonTypeOf(value))
+		}
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if valuesEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not one of %v", path, value, enum)
+		}
+	}
+	if c, ok := schema["const"]; ok && !valuesEqual(c, value) {
+		return fmt.Errorf("%s: must be %v", path, c)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return validateObject(schema, v, path)
+	case []interface{}:
+		if n, ok := toFloat(schema["minItems"]); ok && float64(len(v)) < n {
+			return fmt.Errorf("%s: must have at least %v items", path, n)
+		}
+		if n, ok := toFloat(schema["maxItems"]); ok && float64(len(v)) > n {
+			return fmt.Errorf("%s: must have at most %v items", path, n)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateAgainstSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		length := len([]rune(v))
+		if n, ok := toFloat(schema["minLength"]); ok && float64(length) < n {
+			return fmt.Errorf("%s: must be at least %v characters long", path, n)
+		}
+		if n, ok := toFloat(schema["maxLength"]); ok && float64(length) > n {
+			return fmt.Errorf("%s: must be at most %v characters long", path, n)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%s: invalid pattern: %w", path, err)
+			}
+			if !re.MatchString(v) {
+				return fmt.Errorf("%s: %q does not match %s", path, v, pattern)
+			}
+		}
+	case float64:
+		if n, ok := toFloat(schema["minimum"]); ok && v < n {
+			return fmt.Errorf("%s: must be at least %v", path, n)
+		}
+		if n, ok := toFloat(schema["maximum"]); ok && v > n {
+			return fmt.Errorf("%s: must be at most %v", path, n)
+		}
+	}
+	return nil
+}
+
+func validateObject(schema map[string]interface{}, object map[string]interface{}, path string) error {
+	required, _ := schema["required"].([]interface{})
+	for _, name := range required {
+		name, _ := name.(string)
+		if _, ok := object[name]; !ok {
+			return fmt.Errorf("%s.%s: is required", path, name)
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if property, ok := properties[name].(map[string]interface{}); ok {
+			if err := validateAgainstSchema(property, object[name], path+"."+name); err != nil {
+				return err
+			}
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fmt.Errorf("%s.%s: is not allowed", path, name)
+			}
+		case map[string]interface{}:
+			if err := validateAgainstSchema(additional, object[name], path+"."+name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// schemaTypes returns the type of a schema, which may be a single type or a
+// list of types.
+func schemaTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, v := range t {
+			s, _ := v.(string)
+			types = append(types, s)
+		}
+		return types
+	}
+	return nil
+}
+
+func hasSchemaType(value interface{}, t string) bool {
+	if t == "integer" {
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	}
+	return jsonTypeOf(value) == t
+}
+
+// jsonTypeOf names the JSON type of a value decoded by encoding/json.
+func jsonTypeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return fmt.Sprintf("%T", value)
+}
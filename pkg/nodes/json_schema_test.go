@@ -0,0 +1,99 @@
+package nodes
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+const contactSchemaYAML = `
+type: object
+required: [name, score]
+additionalProperties: false
+properties:
+  name:
+    type: string
+    minLength: 1
+  score:
+    type: integer
+    minimum: 0
+    maximum: 10
+  tags:
+    type: array
+    maxItems: 2
+    items:
+      type: string
+      enum: [lead, customer]
+  email:
+    type: [string, "null"]
+    pattern: "@"
+`
+
+func loadSchema(t *testing.T, src string) map[string]interface{} {
+	t.Helper()
+	var schema map[string]interface{}
+	if err := yaml.Unmarshal([]byte(src), &schema); err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func TestValidateAgainstSchema(t *testing.T) {
+	schema := loadSchema(t, contactSchemaYAML)
+	if err := ValidateJSONSchema(schema); err != nil {
+		t.Fatalf("unexpected schema error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{"valid", `{"name": "Ada", "score": 7, "tags": ["lead"], "email": null}`, ""},
+		{"missing required", `{"name": "Ada"}`, "$.score: is required"},
+		{"wrong type", `{"name": "Ada", "score": "high"}`, "$.score: expected integer, got string"},
+		{"not an integer", `{"name": "Ada", "score": 7.5}`, "$.score: expected integer, got number"},
+		{"above maximum", `{"name": "Ada", "score": 11}`, "$.score: must be at most 10"},
+		{"too short", `{"name": "", "score": 1}`, "$.name: must be at least 1 characters long"},
+		{"not in enum", `{"name": "Ada", "score": 1, "tags": ["vip"]}`, "$.tags[0]: vip is not one of [lead customer]"},
+		{"too many items", `{"name": "Ada", "score": 1, "tags": ["lead", "lead", "lead"]}`, "$.tags: must have at most 2 items"},
+		{"pattern", `{"name": "Ada", "score": 1, "email": "ada"}`, `$.email: "ada" does not match @`},
+		{"additional property", `{"name": "Ada", "score": 1, "age": 36}`, "$.age: is not allowed"},
+		{"not an object", `[1, 2]`, "$: expected object, got array"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.json), &value); err != nil {
+				t.Fatal(err)
+			}
+			err := ValidateAgainstSchema(schema, value)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateJSONSchema_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"type: text":                          `unknown type "text"`,
+		"properties: [name]":                  "properties must be an object",
+		"properties: {name: {type: date}}":    `schema.name: unknown type "date"`,
+		"items: {type: string, pattern: '('}": "schema[]: invalid pattern",
+	}
+	for src, want := range tests {
+		err := ValidateJSONSchema(loadSchema(t, src))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected error containing %q, got %v", src, want, err)
+		}
+	}
+}
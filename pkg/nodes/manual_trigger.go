@@ -5,6 +5,11 @@ import "go-workflow/pkg/framework"
 // ManualTrigger seeds initial data
 type ManualTrigger struct{ Payload []map[string]interface{} }
 
+// NewManualTrigger creates a new ManualTrigger seeded with payload.
+func NewManualTrigger(payload []map[string]interface{}) *ManualTrigger {
+    return &ManualTrigger{Payload: payload}
+}
+
 func (n *ManualTrigger) Execute(ctx *framework.Context, input []map[string]interface{}) ([]map[string]interface{}, error) {
     return n.Payload, nil
 }
\ No newline at end of file
@@ -2,9 +2,15 @@ package nodes
 
 import (
     "encoding/json"
+    "errors"
     "fmt"
+    "strings"
+    "time"
 
     "go-workflow/pkg/framework"
+    "go-workflow/pkg/llm"
+    "github.com/tmc/langchaingo/llms"
+    "github.com/tmc/langchaingo/schema"
 )
 
 func toJSON(m map[string]interface{}) string {
@@ -12,22 +18,366 @@ func toJSON(m map[string]interface{}) string {
     return string(b)
 }
 
-// OpenAINode wraps a LangChain chat completion
-type OpenAINode struct{ SystemPrompt string }
+// DefaultMaxRepairs is how often an openaiNode with an output schema asks
+// the model to correct an invalid response, unless maxRepairs is set.
+const DefaultMaxRepairs = 2
+
+// OpenAINode wraps a LangChain chat completion. For every record the model
+// is sent the system prompt, the few-shot examples, the messages and the
+// user prompt, which defaults to the record as JSON, and answers with JSON
+// whose fields are merged into the record. All prompts are templates that
+// can use the fields of the record and the environment, see renderPrompt.
+type OpenAINode struct {
+    SystemPrompt string
+    // UserPrompt is the last message, sent after Messages. If empty, it is
+    // "Payload: " followed by the record as JSON.
+    UserPrompt string
+    // SystemPromptFile and UserPromptFile, like the File of Messages, hold
+    // the prompts instead; ReadFiles reads them.
+    SystemPromptFile string
+    UserPromptFile   string
+    Messages         []PromptMessage
+    Examples   []PromptExample
+    // Provider names the client to use from ctx.LLMClientFactory; if empty,
+    // ctx.LangChain is used. Fallbacks are tried in order when it fails.
+    Provider  string
+    Fallbacks []LLMTarget
+    // Model, Temperature and MaxTokens are passed to the client as call
+    // options when they are set.
+    Model       string
+    Temperature *float64
+    MaxTokens   int
+    // OutputSchema is a JSON schema the response must match. It is added to
+    // the prompt, and responses that do not match it are sent back to the
+    // model with the validation error, up to MaxRepairs times.
+    OutputSchema map[string]interface{}
+    // OutputKey is the field the response is stored under. If empty, the
+    // response must be an object and its fields are merged into the record.
+    OutputKey string
+    // MaxRepairs is how many times an invalid response is sent back to the
+    // model for correction before the node fails.
+    MaxRepairs int
+    // NoCache keeps the responses of the node out of ctx.LLMCache, and
+    // CacheTTL is how long they are cached otherwise, llm.DefaultCacheTTL
+    // if zero.
+    NoCache  bool
+    CacheTTL time.Duration
+}
+
+// LLMTarget is an LLM provider and the model to ask there. An empty model
+// selects the provider's default.
+type LLMTarget struct {
+    Provider string `yaml:"provider"`
+    Model    string `yaml:"model"`
+}
+
+// NewOpenAINode creates a new OpenAINode.
+func NewOpenAINode(systemPrompt string) *OpenAINode {
+    return &OpenAINode{SystemPrompt: systemPrompt}
+}
+
+// ReadFiles reads the prompt files of the node relative to dir, see
+// ReadPromptFile, and checks their templates.
+func (n *OpenAINode) ReadFiles(dir string) error {
+    var err error
+    if n.SystemPrompt, err = ReadPromptFile(dir, n.SystemPrompt, n.SystemPromptFile); err != nil {
+        return err
+    }
+    if n.UserPrompt, err = ReadPromptFile(dir, n.UserPrompt, n.UserPromptFile); err != nil {
+        return err
+    }
+    n.SystemPromptFile, n.UserPromptFile = "", ""
+    for _, prompt := range []string{n.SystemPrompt, n.UserPrompt} {
+        if err := ValidatePromptTemplate(prompt); err != nil {
+            return err
+        }
+    }
+    for i, message := range n.Messages {
+        if n.Messages[i].Content, err = ReadPromptFile(dir, message.Content, message.File); err != nil {
+            return err
+        }
+        n.Messages[i].File = ""
+        if err := ValidatePromptMessage(n.Messages[i]); err != nil {
+            return err
+        }
+    }
+    return nil
+}
 
 func (n *OpenAINode) Execute(ctx *framework.Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
     var out []map[string]interface{}
     for _, rec := range inputs {
-        prompt := fmt.Sprintf("%s\n\nPayload: %s", n.SystemPrompt, toJSON(rec))
-        resp, err := ctx.LangChain.GenerateFromSinglePrompt(ctx.Ctx, prompt)
+        parsed, err := n.complete(ctx, rec)
         if err != nil {
             return nil, err
         }
-        var parsed map[string]interface{}
-        if err := json.Unmarshal([]byte(resp), &parsed); err != nil {
+        out = append(out, n.merge(rec, parsed))
+    }
+    return out, nil
+}
+
+// merge returns a copy of rec with a parsed response stored under the
+// output key, or with its fields merged in.
+func (n *OpenAINode) merge(rec map[string]interface{}, parsed interface{}) map[string]interface{} {
+    merged := make(map[string]interface{}, len(rec)+1)
+    for k, v := range rec {
+        merged[k] = v
+    }
+    if n.OutputKey != "" {
+        merged[n.OutputKey] = parsed
+    } else {
+        // check only accepts objects without an output key.
+        for k, v := range parsed.(map[string]interface{}) {
+            merged[k] = v
+        }
+    }
+    return merged
+}
+
+// complete asks the model about one record and returns its parsed response,
+// asking it to repair responses that are not valid.
+func (n *OpenAINode) complete(ctx *framework.Context, rec map[string]interface{}) (interface{}, error) {
+    messages, err := n.messages(rec, ctx.Env)
+    if err != nil {
+        return nil, err
+    }
+    for attempt := 0; ; attempt++ {
+        if err := ctx.CheckLLMBudget(); err != nil {
+            return nil, err
+        }
+        resp, err := n.generate(ctx, messages)
+        if err != nil {
             return nil, err
         }
-        out = append(out, parsed)
+        parsed, err := n.parse(resp)
+        if err == nil {
+            return parsed, nil
+        }
+        if attempt >= n.MaxRepairs {
+            return nil, fmt.Errorf("invalid model response: %w", err)
+        }
+        messages = append(messages,
+            PromptMessage{Role: RoleAssistant, Content: resp},
+            PromptMessage{Role: RoleUser, Content: fmt.Sprintf("Your previous response was not valid: %v\n\nRespond again with the corrected JSON only.", err)},
+        )
     }
-    return out, nil
-}
\ No newline at end of file
+}
+
+// generate sends the messages to the provider of the node, and to its
+// fallbacks in turn if that fails. The usage of the answered request is
+// recorded, which fails if it exceeds a budget.
+func (n *OpenAINode) generate(ctx *framework.Context, messages []PromptMessage) (string, error) {
+    targets := append([]LLMTarget{{Provider: n.Provider, Model: n.Model}}, n.Fallbacks...)
+    var errs []error
+    for _, target := range targets {
+        resp, usage, err := n.generateWith(ctx, target, messages)
+        if err == nil {
+            usage.Cost = llm.Cost(usage)
+            if err := ctx.RecordLLMUsage(usage); err != nil {
+                return "", err
+            }
+            return resp, nil
+        }
+        if len(targets) == 1 {
+            return "", err
+        }
+        if ctx.Logger != nil {
+            ctx.Logger.Warnf("LLM provider %s failed: %v", target.Provider, err)
+        }
+        errs = append(errs, fmt.Errorf("%s: %w", target.Provider, err))
+    }
+    return "", errors.Join(errs...)
+}
+
+// generateWith answers the messages from ctx.LLMCache, or sends them to
+// target and caches the response. Cached responses use no tokens.
+func (n *OpenAINode) generateWith(ctx *framework.Context, target LLMTarget, messages []PromptMessage) (string, framework.LLMUsage, error) {
+    options := n.callOptions(target)
+    cache := ctx.LLMCache
+    if n.NoCache {
+        cache = nil
+    }
+    var key string
+    if cache != nil {
+        key = llm.CacheKey(target.Provider, chatMessages(messages), options...)
+        if resp, ok := n.cached(ctx, cache, key); ok {
+            if ctx.Metrics != nil {
+                ctx.Metrics.LLMCacheHits.WithLabelValues(ctx.Workflow, ctx.Node, target.Model).Inc()
+            }
+            return resp, framework.LLMUsage{Model: target.Model}, nil
+        }
+    }
+
+    resp, usage, err := n.send(ctx, target, messages, options)
+    if err != nil || cache == nil {
+        return resp, usage, err
+    }
+    ttl := n.CacheTTL
+    if ttl == 0 {
+        ttl = llm.DefaultCacheTTL
+    }
+    if err := cache.Set(ctx.Ctx, key, resp, ttl); err != nil && ctx.Logger != nil {
+        ctx.Logger.Warnf("failed to cache LLM response: %v", err)
+    }
+    return resp, usage, nil
+}
+
+// cached looks a request up in the cache, unless the run bypasses it. A
+// failing cache is treated as a miss.
+func (n *OpenAINode) cached(ctx *framework.Context, cache framework.LLMCache, key string) (string, bool) {
+    if ctx.LLMCacheBypass {
+        return "", false
+    }
+    resp, ok, err := cache.Get(ctx.Ctx, key)
+    if err != nil {
+        if ctx.Logger != nil {
+            ctx.Logger.Warnf("failed to read LLM cache: %v", err)
+        }
+        return "", false
+    }
+    return resp, ok
+}
+
+// callOptions returns the call options of a request to target.
+func (n *OpenAINode) callOptions(target LLMTarget) []llms.CallOption {
+    var options []llms.CallOption
+    if target.Model != "" {
+        options = append(options, llms.WithModel(target.Model))
+    }
+    if n.Temperature != nil {
+        options = append(options, llms.WithTemperature(*n.Temperature))
+    }
+    if n.MaxTokens > 0 {
+        options = append(options, llms.WithMaxTokens(n.MaxTokens))
+    }
+    return options
+}
+
+// send sends the messages to the client of target, as a chat if the client
+// supports it and as a single prompt otherwise. It returns the usage the
+// client reports, or an estimate.
+func (n *OpenAINode) send(ctx *framework.Context, target LLMTarget, messages []PromptMessage, options []llms.CallOption) (string, framework.LLMUsage, error) {
+    client := ctx.LangChain
+    if target.Provider != "" {
+        if ctx.LLMClientFactory == nil {
+            return "", framework.LLMUsage{}, errors.New("no LLM client factory available")
+        }
+        var err error
+        if client, err = ctx.LLMClientFactory(ctx.Ctx, target.Provider); err != nil {
+            return "", framework.LLMUsage{}, err
+        }
+    }
+    if client == nil {
+        return "", framework.LLMUsage{}, errors.New("no LLM client available")
+    }
+    switch c := client.(type) {
+    case framework.LLMUsageClient:
+        return c.GenerateWithUsage(ctx.Ctx, chatMessages(messages), options...)
+    case framework.LangChainChatClient:
+        chat := chatMessages(messages)
+        resp, err := c.GenerateFromMessages(ctx.Ctx, chat, options...)
+        return resp, llm.EstimateUsage(target.Model, chat, resp), err
+    }
+    prompt := flattenPrompt(messages)
+    resp, err := client.GenerateFromSinglePrompt(ctx.Ctx, prompt, options...)
+    return resp, llm.EstimateUsage(target.Model, []schema.ChatMessage{schema.HumanChatMessage{Content: prompt}}, resp), err
+}
+
+// messages renders the prompt for a record.
+func (n *OpenAINode) messages(rec map[string]interface{}, env map[string]string) ([]PromptMessage, error) {
+    var messages []PromptMessage
+    system, err := renderPrompt(n.SystemPrompt, rec, env)
+    if err != nil {
+        return nil, err
+    }
+    if n.OutputSchema != nil {
+        outputSchema, _ := json.Marshal(n.OutputSchema)
+        system += fmt.Sprintf("\n\nRespond only with JSON matching this JSON schema: %s", outputSchema)
+    }
+    if system != "" {
+        messages = append(messages, PromptMessage{Role: RoleSystem, Content: system})
+    }
+
+    for _, example := range n.Examples {
+        input, err := promptJSON(example.Input)
+        if err != nil {
+            return nil, err
+        }
+        output, err := promptJSON(example.Output)
+        if err != nil {
+            return nil, err
+        }
+        messages = append(messages,
+            PromptMessage{Role: RoleUser, Content: input},
+            PromptMessage{Role: RoleAssistant, Content: output},
+        )
+    }
+
+    for _, m := range n.Messages {
+        content, err := renderPrompt(m.Content, rec, env)
+        if err != nil {
+            return nil, err
+        }
+        messages = append(messages, PromptMessage{Role: m.Role, Content: content})
+    }
+
+    userPrompt := n.UserPrompt
+    if userPrompt == "" {
+        userPrompt = defaultUserPrompt
+    }
+    user, err := renderPrompt(userPrompt, rec, env)
+    if err != nil {
+        return nil, err
+    }
+    return append(messages, PromptMessage{Role: RoleUser, Content: user}), nil
+}
+
+// parse decodes the JSON in a response and checks it.
+func (n *OpenAINode) parse(resp string) (interface{}, error) {
+    var parsed interface{}
+    if err := json.Unmarshal([]byte(extractJSON(resp)), &parsed); err != nil {
+        return nil, err
+    }
+    if err := n.check(parsed); err != nil {
+        return nil, err
+    }
+    return parsed, nil
+}
+
+// check checks a parsed response against the output schema, and that it is
+// an object if it is merged into the record.
+func (n *OpenAINode) check(parsed interface{}) error {
+    if n.OutputSchema != nil {
+        if err := ValidateAgainstSchema(n.OutputSchema, parsed); err != nil {
+            return err
+        }
+    }
+    if _, ok := parsed.(map[string]interface{}); !ok && n.OutputKey == "" {
+        return fmt.Errorf("expected a JSON object, got %s", jsonTypeOf(parsed))
+    }
+    return nil
+}
+
+// extractJSON returns the JSON in a model response: the content of the
+// first code fence if there is one, otherwise the text from the first
+// opening to the last closing bracket, which drops any preamble.
+func extractJSON(resp string) string {
+    resp = strings.TrimSpace(resp)
+    if start := strings.Index(resp, "```"); start >= 0 {
+        body := resp[start+3:]
+        // Skip the language tag, e.g. ```json.
+        if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+            body = body[nl+1:]
+        }
+        if end := strings.Index(body, "```"); end >= 0 {
+            body = body[:end]
+        }
+        return strings.TrimSpace(body)
+    }
+    start := strings.IndexAny(resp, "{[")
+    end := strings.LastIndexAny(resp, "}]")
+    if start < 0 || end < start {
+        return resp
+    }
+    return resp[start : end+1]
+}
@@ -5,9 +5,12 @@ import (
     "encoding/json"
     "testing"
     "errors"
+    "strings"
 
     "go-workflow/pkg/framework"
+    "go-workflow/pkg/llm"
     "github.com/tmc/langchaingo/llms"
+    "github.com/tmc/langchaingo/schema"
 )
 
 // fakeLangChainClient implements the minimal interface
@@ -80,6 +83,327 @@ func TestOpenAINode_InvalidJSONResponse(t *testing.T) {
     }
 }
 
+func TestOpenAINode_MergesIntoRecord(t *testing.T) {
+    node := &OpenAINode{SystemPrompt: "classify", OutputKey: "classification"}
+    ctx := &framework.Context{
+        Ctx: context.Background(),
+        LangChain: &fakeLangChainClient{
+            generateFromSinglePrompt: func(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+                return "Sure! Here is the result:\n```json\n{\"label\": \"lead\"}\n```", nil
+            },
+        },
+    }
+    out, err := node.Execute(ctx, []map[string]interface{}{{"name": "Ada"}})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    classification, _ := out[0]["classification"].(map[string]interface{})
+    if out[0]["name"] != "Ada" || classification["label"] != "lead" {
+        t.Errorf("expected the response under classification next to the record, got %v", out[0])
+    }
+}
+
+func TestOpenAINode_OutputSchemaRepair(t *testing.T) {
+    schema := map[string]interface{}{
+        "type":     "object",
+        "required": []interface{}{"score"},
+        "properties": map[string]interface{}{
+            "score": map[string]interface{}{"type": "integer"},
+        },
+    }
+    var prompts []string
+    responses := []string{`{"score": "high"}`, `Corrected: {"score": 9}`}
+    node := &OpenAINode{SystemPrompt: "score", OutputSchema: schema, MaxRepairs: DefaultMaxRepairs}
+    ctx := &framework.Context{
+        Ctx: context.Background(),
+        LangChain: &fakeLangChainClient{
+            generateFromSinglePrompt: func(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+                prompts = append(prompts, prompt)
+                return responses[len(prompts)-1], nil
+            },
+        },
+    }
+    out, err := node.Execute(ctx, []map[string]interface{}{{"name": "Ada"}})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if out[0]["name"] != "Ada" || out[0]["score"] != float64(9) {
+        t.Errorf("expected the repaired response merged into the record, got %v", out[0])
+    }
+    if len(prompts) != 2 {
+        t.Fatalf("expected one repair, got %d prompts", len(prompts))
+    }
+    if !strings.Contains(prompts[0], `JSON schema: {"properties"`) {
+        t.Errorf("expected the schema in the prompt, got %q", prompts[0])
+    }
+    if !strings.Contains(prompts[1], "$.score: expected integer, got string") || !strings.Contains(prompts[1], `{"score": "high"}`) {
+        t.Errorf("expected the validation error and response in the repair prompt, got %q", prompts[1])
+    }
+}
+
+func TestOpenAINode_OutputSchemaRepairsExhausted(t *testing.T) {
+    calls := 0
+    node := &OpenAINode{
+        SystemPrompt: "score",
+        OutputSchema: map[string]interface{}{"type": "object", "required": []interface{}{"score"}},
+        MaxRepairs:   1,
+    }
+    ctx := &framework.Context{
+        Ctx: context.Background(),
+        LangChain: &fakeLangChainClient{
+            generateFromSinglePrompt: func(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+                calls++
+                return `{}`, nil
+            },
+        },
+    }
+    _, err := node.Execute(ctx, []map[string]interface{}{{"name": "Ada"}})
+    if err == nil || !strings.Contains(err.Error(), "$.score: is required") {
+        t.Fatalf("expected a validation error, got %v", err)
+    }
+    if calls != 2 {
+        t.Errorf("expected the request and one repair, got %d calls", calls)
+    }
+}
+
+// fakeChatClient records the messages and call options it is sent.
+type fakeChatClient struct {
+    fakeLangChainClient
+    messages []schema.ChatMessage
+    options  llms.CallOptions
+}
+
+func (f *fakeChatClient) GenerateFromMessages(ctx context.Context, messages []schema.ChatMessage, options ...llms.CallOption) (string, error) {
+    f.messages = messages
+    for _, opt := range options {
+        opt(&f.options)
+    }
+    return `{"subject": "Hello"}`, nil
+}
+
+func TestOpenAINode_ChatMessages(t *testing.T) {
+    temperature := 0.3
+    node := &OpenAINode{
+        SystemPrompt: "You write outreach for {{.SENDER}}.",
+        Examples: []PromptExample{
+            {Input: map[string]interface{}{"first_name": "Bob"}, Output: map[string]interface{}{"subject": "Hi Bob"}},
+        },
+        Messages:    []PromptMessage{{Role: RoleUser, Content: "Keep it short."}},
+        UserPrompt:  "Write to {{.first_name}}.",
+        Model:       "gpt-4o-mini",
+        Temperature: &temperature,
+        MaxTokens:   200,
+    }
+    client := &fakeChatClient{}
+    ctx := &framework.Context{
+        Ctx:       context.Background(),
+        LangChain: client,
+        Env:       map[string]string{"SENDER": "Acme"},
+    }
+    out, err := node.Execute(ctx, []map[string]interface{}{{"first_name": "Ada"}})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if out[0]["first_name"] != "Ada" || out[0]["subject"] != "Hello" {
+        t.Errorf("unexpected output: %v", out[0])
+    }
+
+    want := []struct {
+        typ     schema.ChatMessageType
+        content string
+    }{
+        {schema.ChatMessageTypeSystem, "You write outreach for Acme."},
+        {schema.ChatMessageTypeHuman, `{"first_name":"Bob"}`},
+        {schema.ChatMessageTypeAI, `{"subject":"Hi Bob"}`},
+        {schema.ChatMessageTypeHuman, "Keep it short."},
+        {schema.ChatMessageTypeHuman, "Write to Ada."},
+    }
+    if len(client.messages) != len(want) {
+        t.Fatalf("expected %d messages, got %v", len(want), client.messages)
+    }
+    for i, w := range want {
+        if client.messages[i].GetType() != w.typ || client.messages[i].GetContent() != w.content {
+            t.Errorf("message %d: expected %s %q, got %s %q", i, w.typ, w.content, client.messages[i].GetType(), client.messages[i].GetContent())
+        }
+    }
+    if client.options.Model != "gpt-4o-mini" || client.options.Temperature != 0.3 || client.options.MaxTokens != 200 {
+        t.Errorf("unexpected call options: %+v", client.options)
+    }
+}
+
+func TestOpenAINode_MissingTemplateField(t *testing.T) {
+    node := &OpenAINode{UserPrompt: "Write to {{.first_name}}."}
+    ctx := &framework.Context{Ctx: context.Background(), LangChain: &fakeChatClient{}}
+    if _, err := node.Execute(ctx, []map[string]interface{}{{"name": "Ada"}}); err == nil {
+        t.Fatal("expected an error for a missing template field")
+    }
+}
+
+func TestOpenAINode_ProviderFallback(t *testing.T) {
+    var providers []string
+    backup := &fakeChatClient{}
+    failing := &fakeLangChainClient{
+        generateFromSinglePrompt: func(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+            return "", errors.New("rate limited")
+        },
+    }
+    node := &OpenAINode{
+        Provider:  "primary",
+        Model:     "big",
+        Fallbacks: []LLMTarget{{Provider: "backup", Model: "small"}},
+    }
+    ctx := &framework.Context{
+        Ctx: context.Background(),
+        LLMClientFactory: func(ctx context.Context, provider string) (framework.LangChainChatClient, error) {
+            providers = append(providers, provider)
+            if provider == "primary" {
+                return failingChat{failing}, nil
+            }
+            return backup, nil
+        },
+    }
+    out, err := node.Execute(ctx, []map[string]interface{}{{"name": "Ada"}})
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if out[0]["subject"] != "Hello" {
+        t.Errorf("expected the fallback response, got %v", out[0])
+    }
+    if backup.options.Model != "small" {
+        t.Errorf("expected the fallback model, got %q", backup.options.Model)
+    }
+    if strings.Join(providers, ",") != "primary,backup" {
+        t.Errorf("expected the providers in order, got %v", providers)
+    }
+
+    node.Fallbacks = nil
+    if _, err := node.Execute(ctx, []map[string]interface{}{{"name": "Ada"}}); err == nil || err.Error() != "rate limited" {
+        t.Errorf("expected the provider error without fallbacks, got %v", err)
+    }
+    if _, err := (&OpenAINode{Provider: "primary"}).Execute(&framework.Context{Ctx: context.Background()}, []map[string]interface{}{{}}); err == nil {
+        t.Error("expected an error without a client factory")
+    }
+}
+
+// failingChat is a chat client whose requests go to a single-prompt fake.
+type failingChat struct {
+    *fakeLangChainClient
+}
+
+func (f failingChat) GenerateFromMessages(ctx context.Context, messages []schema.ChatMessage, options ...llms.CallOption) (string, error) {
+    return f.GenerateFromSinglePrompt(ctx, "", options...)
+}
+
+// usageChat is a chat client that reports the same usage for every request.
+type usageChat struct {
+    fakeChatClient
+    usage framework.LLMUsage
+    calls int
+}
+
+func (f *usageChat) GenerateWithUsage(ctx context.Context, messages []schema.ChatMessage, options ...llms.CallOption) (string, framework.LLMUsage, error) {
+    f.calls++
+    resp, err := f.GenerateFromMessages(ctx, messages, options...)
+    return resp, f.usage, err
+}
+
+func TestOpenAINode_Budget(t *testing.T) {
+    client := &usageChat{usage: framework.LLMUsage{Model: "gpt-4o", PromptTokens: 8, CompletionTokens: 2}}
+    ctx := &framework.Context{
+        Ctx:           context.Background(),
+        LangChain:     client,
+        RunUsage:      framework.NewUsageMeter("run", framework.Budget{MaxTokens: 20}),
+        WorkflowUsage: framework.NewUsageMeter("workflow", framework.Budget{}),
+    }
+    node := &OpenAINode{SystemPrompt: "Write a subject line."}
+    _, err := node.Execute(ctx, []map[string]interface{}{{"id": 1}, {"id": 2}, {"id": 3}})
+    if !errors.Is(err, framework.ErrBudgetExceeded) {
+        t.Fatalf("expected the run budget to stop the node, got %v", err)
+    }
+    if client.calls != 2 {
+        t.Errorf("expected no request after the budget was used up, got %d requests", client.calls)
+    }
+    usage := ctx.WorkflowUsage.Usage()
+    if usage.TotalTokens() != 20 || usage.Cost <= 0 {
+        t.Errorf("expected 20 tokens with a gpt-4o price, got %+v", usage)
+    }
+}
+
+func TestOpenAINode_EstimatesUsage(t *testing.T) {
+    ctx := &framework.Context{
+        Ctx:       context.Background(),
+        LangChain: &fakeChatClient{},
+        RunUsage:  framework.NewUsageMeter("run", framework.Budget{}),
+    }
+    node := &OpenAINode{SystemPrompt: "Write a subject line.", Model: "gpt-4o"}
+    if _, err := node.Execute(ctx, []map[string]interface{}{{"id": 1}}); err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    usage := ctx.RunUsage.Usage()
+    if usage.PromptTokens == 0 || usage.CompletionTokens == 0 {
+        t.Errorf("expected the usage of a client without usage reports to be estimated, got %+v", usage)
+    }
+}
+
+func TestOpenAINode_Cache(t *testing.T) {
+    client := &usageChat{usage: framework.LLMUsage{Model: "gpt-4o", PromptTokens: 8, CompletionTokens: 2}}
+    ctx := &framework.Context{
+        Ctx:       context.Background(),
+        LangChain: client,
+        LLMCache:  llm.NewMemoryCache(),
+        RunUsage:  framework.NewUsageMeter("run", framework.Budget{}),
+    }
+    node := &OpenAINode{SystemPrompt: "Write a subject line.", Model: "gpt-4o"}
+    input := []map[string]interface{}{{"id": 1}}
+    run := func() {
+        t.Helper()
+        out, err := node.Execute(ctx, input)
+        if err != nil || out[0]["subject"] != "Hello" {
+            t.Fatalf("expected the response to be merged, got %v, %v", out, err)
+        }
+    }
+
+    run()
+    run()
+    if client.calls != 1 {
+        t.Errorf("expected the second run to be answered from the cache, got %d requests", client.calls)
+    }
+    if got := ctx.RunUsage.Usage().TotalTokens(); got != 10 {
+        t.Errorf("expected cached responses to use no tokens, got %d", got)
+    }
+
+    ctx.LLMCacheBypass = true
+    run()
+    if client.calls != 2 {
+        t.Errorf("expected the bypass to send the request, got %d requests", client.calls)
+    }
+
+    ctx.LLMCacheBypass = false
+    node.NoCache = true
+    input = []map[string]interface{}{{"id": 2}}
+    run()
+    node.NoCache = false
+    run()
+    if client.calls != 4 {
+        t.Errorf("expected noCache to keep the response out of the cache, got %d requests", client.calls)
+    }
+}
+
+func TestExtractJSON(t *testing.T) {
+    tests := map[string]string{
+        `{"a": 1}`:                             `{"a": 1}`,
+        "```json\n{\"a\": 1}\n```":             `{"a": 1}`,
+        "Here you go: {\"a\": 1}. Anything else?": `{"a": 1}`,
+        `[1, 2]`:                               `[1, 2]`,
+        `no json`:                              `no json`,
+    }
+    for in, want := range tests {
+        if got := extractJSON(in); got != want {
+            t.Errorf("extractJSON(%q) = %q, want %q", in, got, want)
+        }
+    }
+}
+
 func TestToJSON(t *testing.T) {
     input := map[string]interface{}{"foo": "bar"}
     expected := `{"foo":"bar"}`
@@ -0,0 +1,80 @@
+package nodes
+
+import (
+	"fmt"
+
+	"go-workflow/pkg/framework"
+)
+
+// PluginNode runs a node type implemented by a plugin program, passing it
+// the parameters of the node and the records.
+type PluginNode struct {
+	Plugin *framework.Plugin
+	Type   string
+	Config map[string]interface{}
+}
+
+// ValidatePluginNode checks the parameters of a PluginNode against those
+// its plugin describes: required ones must be given, and all must have the
+// described JSON type.
+func ValidatePluginNode(n *PluginNode) error {
+	for _, nodeType := range n.Plugin.Nodes {
+		if nodeType.Type != n.Type {
+			continue
+		}
+		for _, param := range nodeType.Params {
+			value, ok := n.Config[param.Name]
+			if !ok {
+				if param.Required {
+					return fmt.Errorf("%s is required", param.Name)
+				}
+				continue
+			}
+			if !hasJSONType(value, param.Type) {
+				return fmt.Errorf("%s must be of type %s", param.Name, param.Type)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("plugin %s has no node type %s", n.Plugin.Path, n.Type)
+}
+
+// Execute sends the records to the plugin and returns its output records.
+func (n *PluginNode) Execute(ctx *framework.Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+	return n.Plugin.Execute(ctx, n.Type, n.Config, inputs)
+}
+
+// hasJSONType reports whether a decoded YAML value has a JSON type. Values
+// of unknown types are accepted.
+func hasJSONType(value interface{}, jsonType string) bool {
+	if value == nil {
+		return true
+	}
+	switch jsonType {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "integer":
+		switch v := value.(type) {
+		case int, int64:
+			return true
+		case float64:
+			return v == float64(int64(v))
+		}
+		return false
+	case "number":
+		_, ok := toFloat(value)
+		_, isString := value.(string)
+		return ok && !isString
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	}
+	return true
+}
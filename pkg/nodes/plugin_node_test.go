@@ -0,0 +1,34 @@
+package nodes
+
+import (
+	"testing"
+
+	"go-workflow/pkg/framework"
+)
+
+func TestValidatePluginNode(t *testing.T) {
+	plugin := &framework.Plugin{Path: "plugins/crm", Nodes: []framework.PluginNodeType{{
+		Type: "crmLookup",
+		Params: []framework.FunctionParam{
+			{Name: "object", Type: "string", Required: true},
+			{Name: "limit", Type: "integer"},
+			{Name: "fields", Type: "array"},
+		},
+	}}}
+	valid := &PluginNode{Plugin: plugin, Type: "crmLookup", Config: map[string]interface{}{"object": "contact", "limit": 10, "fields": []interface{}{"email"}, "extra": true}}
+	if err := ValidatePluginNode(valid); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	tests := map[string]*PluginNode{
+		"unknown type":     {Plugin: plugin, Type: "crmWrite", Config: map[string]interface{}{"object": "contact"}},
+		"missing required": {Plugin: plugin, Type: "crmLookup", Config: map[string]interface{}{"limit": 10}},
+		"wrong type":       {Plugin: plugin, Type: "crmLookup", Config: map[string]interface{}{"object": "contact", "limit": 1.5}},
+		"not an array":     {Plugin: plugin, Type: "crmLookup", Config: map[string]interface{}{"object": "contact", "fields": "email"}},
+	}
+	for name, node := range tests {
+		if err := ValidatePluginNode(node); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
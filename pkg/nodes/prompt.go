@@ -0,0 +1,163 @@
+package nodes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/tmc/langchaingo/schema"
+)
+
+// Roles of prompt messages.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// defaultUserPrompt sends the record, as openaiNode always has.
+const defaultUserPrompt = "Payload: {{json record}}"
+
+// PromptMessage is a message of a chat prompt. Its content is a template
+// rendered for each record, see renderPrompt.
+type PromptMessage struct {
+	Role    string `yaml:"role"`
+	Content string `yaml:"content"`
+	// File holds the content instead, see ReadPromptFile.
+	File string `yaml:"file"`
+}
+
+// PromptExample is a few-shot example: a user message and the answer the
+// model should give to it. Values other than strings are sent as JSON.
+type PromptExample struct {
+	Input  interface{} `yaml:"input"`
+	Output interface{} `yaml:"output"`
+}
+
+// ReadPromptFile returns the prompt held in file, or content if file is
+// empty. Relative paths are resolved against dir, the directory of the
+// workflow definition; without one, as for workflows uploaded to the API
+// server, no file can be read.
+func ReadPromptFile(dir, content, file string) (string, error) {
+	if file == "" {
+		return content, nil
+	}
+	if content != "" {
+		return "", fmt.Errorf("prompt file %s given together with an inline prompt", file)
+	}
+	if dir == "" {
+		return "", fmt.Errorf("prompt file %s cannot be read: the workflow definition is not a file; give the prompt inline", file)
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read prompt file: %w", err)
+	}
+	return string(data), nil
+}
+
+// ValidatePromptMessage checks the role and template of a prompt message.
+func ValidatePromptMessage(m PromptMessage) error {
+	switch m.Role {
+	case RoleSystem, RoleUser, RoleAssistant:
+	default:
+		return fmt.Errorf("unknown prompt message role %q; use system, user or assistant", m.Role)
+	}
+	return ValidatePromptTemplate(m.Content)
+}
+
+// ValidatePromptTemplate checks that text is a valid prompt template.
+func ValidatePromptTemplate(text string) error {
+	_, err := parsePrompt(text, nil, nil)
+	return err
+}
+
+// renderPrompt renders a prompt template for a record. The fields of the
+// record, and the environment variables of the run where no field has the
+// same name, are available as {{.name}}; a name that is neither fails the
+// node instead of sending "<no value>" to the model. The functions record
+// and env return all fields and variables, and json formats a value as
+// JSON, e.g. {{json record}}.
+func renderPrompt(text string, rec map[string]interface{}, env map[string]string) (string, error) {
+	tmpl, err := parsePrompt(text, rec, env)
+	if err != nil {
+		return "", err
+	}
+	data := make(map[string]interface{}, len(env)+len(rec))
+	for k, v := range env {
+		data[k] = v
+	}
+	for k, v := range rec {
+		data[k] = v
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
+	}
+	return b.String(), nil
+}
+
+func parsePrompt(text string, rec map[string]interface{}, env map[string]string) (*template.Template, error) {
+	tmpl, err := template.New("prompt").Option("missingkey=error").Funcs(template.FuncMap{
+		"record": func() map[string]interface{} { return rec },
+		"env":    func() map[string]string { return env },
+		"json":   promptJSON,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func promptJSON(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+// chatMessages converts prompt messages to LangChain chat messages.
+func chatMessages(messages []PromptMessage) []schema.ChatMessage {
+	out := make([]schema.ChatMessage, 0, len(messages))
+	for _, m := range messages {
+		switch m.Role {
+		case RoleSystem:
+			out = append(out, schema.SystemChatMessage{Content: m.Content})
+		case RoleAssistant:
+			out = append(out, schema.AIChatMessage{Content: m.Content})
+		default:
+			out = append(out, schema.HumanChatMessage{Content: m.Content})
+		}
+	}
+	return out
+}
+
+// flattenPrompt joins prompt messages into a single prompt for clients that
+// cannot chat. Messages are only prefixed with their role if the model has
+// to tell its own answers apart, i.e. with examples or repairs.
+func flattenPrompt(messages []PromptMessage) string {
+	labeled := false
+	for _, m := range messages {
+		if m.Role == RoleAssistant {
+			labeled = true
+		}
+	}
+	parts := make([]string, 0, len(messages))
+	for _, m := range messages {
+		switch {
+		case !labeled || m.Role == RoleSystem:
+			parts = append(parts, m.Content)
+		case m.Role == RoleAssistant:
+			parts = append(parts, "Assistant: "+m.Content)
+		default:
+			parts = append(parts, "User: "+m.Content)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,81 @@
+package nodes
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderPrompt(t *testing.T) {
+	rec := map[string]interface{}{"first_name": "Ada", "company": map[string]interface{}{"name": "Analytical Engines"}}
+	env := map[string]string{"SENDER": "Grace", "first_name": "shadowed"}
+
+	got, err := renderPrompt("Hi {{.first_name}} at {{.company.name}}, {{.SENDER}} here. {{json record}}", rec, env)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `Hi Ada at Analytical Engines, Grace here. {"company":{"name":"Analytical Engines"},"first_name":"Ada"}`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got, _ := renderPrompt(`{{index env "SENDER"}}`, rec, env); got != "Grace" {
+		t.Errorf("expected env to return the environment, got %q", got)
+	}
+	if _, err := renderPrompt("Hi {{.last_name}}", rec, env); err == nil {
+		t.Error("expected an error for a missing field")
+	}
+	if err := ValidatePromptTemplate("Hi {{.first_name"); err == nil {
+		t.Error("expected an error for an invalid template")
+	}
+}
+
+func TestValidatePromptMessage(t *testing.T) {
+	if err := ValidatePromptMessage(PromptMessage{Role: RoleAssistant, Content: "{{.x}}"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidatePromptMessage(PromptMessage{Role: "tool", Content: "hi"}); err == nil || !strings.Contains(err.Error(), "unknown prompt message role") {
+		t.Errorf("expected an unknown role error, got %v", err)
+	}
+}
+
+func TestReadPromptFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "prompts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "prompts", "outreach.tmpl")
+	if err := os.WriteFile(path, []byte("Write to {{.first_name}}."), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ReadPromptFile(dir, "", "prompts/outreach.tmpl"); err != nil || got != "Write to {{.first_name}}." {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if got, err := ReadPromptFile(t.TempDir(), "", path); err != nil || got != "Write to {{.first_name}}." {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if got, err := ReadPromptFile("", "inline", ""); err != nil || got != "inline" {
+		t.Errorf("got %q, %v", got, err)
+	}
+	if _, err := ReadPromptFile(dir, "inline", path); err == nil {
+		t.Error("expected an error for both an inline prompt and a file")
+	}
+	if _, err := ReadPromptFile(dir, "", "missing.tmpl"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	if _, err := ReadPromptFile("", "", path); err == nil {
+		t.Error("expected an error for a file without a definition directory")
+	}
+}
+
+func TestFlattenPrompt(t *testing.T) {
+	plain := []PromptMessage{{Role: RoleSystem, Content: "sys"}, {Role: RoleUser, Content: "hi"}}
+	if got := flattenPrompt(plain); got != "sys\n\nhi" {
+		t.Errorf("got %q", got)
+	}
+	chat := append(plain, PromptMessage{Role: RoleAssistant, Content: "hello"}, PromptMessage{Role: RoleUser, Content: "again"})
+	if got := flattenPrompt(chat); got != "sys\n\nUser: hi\n\nAssistant: hello\n\nUser: again" {
+		t.Errorf("got %q", got)
+	}
+}
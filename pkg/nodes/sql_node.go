@@ -0,0 +1,366 @@
+package nodes
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go-workflow/pkg/framework"
+)
+
+// Modes of SQLExecute.
+const (
+	// SQLExec runs the statement once for every record.
+	SQLExec = "exec"
+	// SQLInsert inserts the records into a table, several per statement.
+	SQLInsert = "insert"
+	// SQLUpsert inserts the records, and updates the rows whose
+	// ConflictColumns match instead.
+	SQLUpsert = "upsert"
+)
+
+// DefaultSQLBatchSize is how many records SQLExecute inserts per
+// statement, unless batchSize is set.
+const DefaultSQLBatchSize = 100
+
+// sqlIdentifier matches the table and column names SQL nodes accept,
+// optionally qualified by a schema. They are not quoted, as quoting differs
+// between databases.
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// SQLQuery runs a query against a named database of ctx.SQL for every
+// record, binding record fields to its "?" placeholders, and emits the
+// rows as records.
+type SQLQuery struct {
+	Database string
+	Query    string
+	// Params are the record fields bound to the placeholders, in order.
+	Params []string
+	// OutputKey, if set, stores the rows of a record as a list under this
+	// field of the record. Otherwise every row is emitted as a record of
+	// its own.
+	OutputKey string
+}
+
+// ValidateSQLQuery checks the database and query of a SQLQuery.
+func ValidateSQLQuery(n *SQLQuery) error {
+	if n.Database == "" {
+		return errors.New("database is required")
+	}
+	if n.Query == "" {
+		return errors.New("query is required")
+	}
+	return nil
+}
+
+// Execute runs the query for every record.
+func (n *SQLQuery) Execute(ctx *framework.Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+	db, err := sqlDB(ctx, n.Database)
+	if err != nil {
+		return nil, err
+	}
+	query := db.Rebind(n.Query)
+	var outputs []map[string]interface{}
+	for _, rec := range inputs {
+		args, err := sqlParams(rec, n.Params)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := db.QueryContext(ctx.Ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		records, err := sqlRecords(rows)
+		if err != nil {
+			return nil, err
+		}
+		if n.OutputKey == "" {
+			outputs = append(outputs, records...)
+			continue
+		}
+		list := make([]interface{}, len(records))
+		for i, row := range records {
+			list[i] = row
+		}
+		result := make(map[string]interface{}, len(rec)+1)
+		for k, v := range rec {
+			result[k] = v
+		}
+		result[n.OutputKey] = list
+		outputs = append(outputs, result)
+	}
+	return outputs, nil
+}
+
+// SQLExecute writes the records to a named database of ctx.SQL: it runs
+// Statement for every record, or inserts or upserts them into Table in
+// batches. All writes of an execution run in one transaction, so either
+// all records are written or none. The records are passed on.
+type SQLExecute struct {
+	Database string
+	// Mode is SQLExec, SQLInsert or SQLUpsert; SQLExec if empty.
+	Mode string
+	// Statement and Params are the statement of SQLExec and the record
+	// fields bound to its "?" placeholders, in order.
+	Statement string
+	Params    []string
+	// Table and Columns are the table of SQLInsert and SQLUpsert and the
+	// record fields written to the columns of the same names. Missing
+	// fields are written as NULL.
+	Table   string
+	Columns []string
+	// ConflictColumns are the unique columns that identify the row an
+	// upsert updates.
+	ConflictColumns []string
+	// BatchSize is how many records are inserted per statement,
+	// DefaultSQLBatchSize if zero.
+	BatchSize int
+	// OutputKey, if set, stores the rows affected by the statement of a
+	// record under this field, in SQLExec mode.
+	OutputKey string
+}
+
+// ValidateSQLExecute checks the mode of a SQLExecute and the options it
+// needs.
+func ValidateSQLExecute(n *SQLExecute) error {
+	if n.Database == "" {
+		return errors.New("database is required")
+	}
+	if n.BatchSize < 0 {
+		return errors.New("batchSize must not be negative")
+	}
+	switch n.Mode {
+	case "", SQLExec:
+		if n.Statement == "" {
+			return errors.New("statement is required")
+		}
+		return nil
+	case SQLInsert, SQLUpsert:
+	default:
+		return fmt.Errorf("unknown mode %q; use exec, insert or upsert", n.Mode)
+	}
+	if n.Statement != "" {
+		return fmt.Errorf("%s mode builds its statement; use table and columns", n.Mode)
+	}
+	if !sqlIdentifier.MatchString(n.Table) {
+		return fmt.Errorf("invalid table name %q", n.Table)
+	}
+	if len(n.Columns) == 0 {
+		return errors.New("columns are required")
+	}
+	for _, column := range append(append([]string{}, n.Columns...), n.ConflictColumns...) {
+		if !sqlIdentifier.MatchString(column) || strings.Contains(column, ".") {
+			return fmt.Errorf("invalid column name %q", column)
+		}
+	}
+	if n.Mode == SQLUpsert && len(n.ConflictColumns) == 0 {
+		return errors.New("upsert mode requires conflictColumns")
+	}
+	return nil
+}
+
+// Execute writes the records in one transaction and returns them.
+func (n *SQLExecute) Execute(ctx *framework.Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+	db, err := sqlDB(ctx, n.Database)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.BeginTx(ctx.Ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	outputs := inputs
+	if n.Mode == SQLInsert || n.Mode == SQLUpsert {
+		err = n.insert(ctx, db, tx, inputs)
+	} else {
+		outputs, err = n.exec(ctx, db, tx, inputs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// exec runs the statement for every record.
+func (n *SQLExecute) exec(ctx *framework.Context, db *framework.SQLDB, tx *sql.Tx, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+	stmt, err := tx.PrepareContext(ctx.Ctx, db.Rebind(n.Statement))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	outputs := make([]map[string]interface{}, 0, len(inputs))
+	for _, rec := range inputs {
+		args, err := sqlParams(rec, n.Params)
+		if err != nil {
+			return nil, err
+		}
+		res, err := stmt.ExecContext(ctx.Ctx, args...)
+		if err != nil {
+			return nil, err
+		}
+		if n.OutputKey == "" {
+			outputs = append(outputs, rec)
+			continue
+		}
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		result := make(map[string]interface{}, len(rec)+1)
+		for k, v := range rec {
+			result[k] = v
+		}
+		result[n.OutputKey] = affected
+		outputs = append(outputs, result)
+	}
+	return outputs, nil
+}
+
+// insert inserts or upserts the records in batches of BatchSize.
+func (n *SQLExecute) insert(ctx *framework.Context, db *framework.SQLDB, tx *sql.Tx, inputs []map[string]interface{}) error {
+	batchSize := n.BatchSize
+	if batchSize == 0 {
+		batchSize = DefaultSQLBatchSize
+	}
+	for start := 0; start < len(inputs); start += batchSize {
+		end := start + batchSize
+		if end > len(inputs) {
+			end = len(inputs)
+		}
+		batch := inputs[start:end]
+		args := make([]interface{}, 0, len(batch)*len(n.Columns))
+		for _, rec := range batch {
+			for _, column := range n.Columns {
+				value, err := sqlValue(rec[column])
+				if err != nil {
+					return fmt.Errorf("column %s: %w", column, err)
+				}
+				args = append(args, value)
+			}
+		}
+		if _, err := tx.ExecContext(ctx.Ctx, db.Rebind(n.insertStatement(db.Driver, len(batch))), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertStatement builds the INSERT statement of a batch of rows, with the
+// upsert clause of the driver.
+func (n *SQLExecute) insertStatement(driver string, rows int) string {
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(n.Columns)), ", ") + ")"
+	values := make([]string, rows)
+	for i := range values {
+		values[i] = row
+	}
+	statement := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", n.Table, strings.Join(n.Columns, ", "), strings.Join(values, ", "))
+	if n.Mode != SQLUpsert {
+		return statement
+	}
+
+	conflict := map[string]bool{}
+	for _, column := range n.ConflictColumns {
+		conflict[column] = true
+	}
+	var updates []string
+	for _, column := range n.Columns {
+		if conflict[column] {
+			continue
+		}
+		if driver == framework.SQLDriverMySQL {
+			updates = append(updates, fmt.Sprintf("%s = VALUES(%s)", column, column))
+		} else {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", column, column))
+		}
+	}
+	if driver == framework.SQLDriverMySQL {
+		if len(updates) == 0 {
+			// MySQL has no DO NOTHING; a no-op update keeps the row.
+			updates = append(updates, fmt.Sprintf("%s = %s", n.ConflictColumns[0], n.ConflictColumns[0]))
+		}
+		return statement + " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	}
+	statement += fmt.Sprintf(" ON CONFLICT (%s)", strings.Join(n.ConflictColumns, ", "))
+	if len(updates) == 0 {
+		return statement + " DO NOTHING"
+	}
+	return statement + " DO UPDATE SET " + strings.Join(updates, ", ")
+}
+
+// sqlDB returns the pool of a named database.
+func sqlDB(ctx *framework.Context, name string) (*framework.SQLDB, error) {
+	if ctx.SQL == nil {
+		return nil, fmt.Errorf("no SQL databases available for database %s", name)
+	}
+	return ctx.SQL.DB(ctx.Ctx, name)
+}
+
+// sqlParams returns the values of the parameter fields of a record.
+func sqlParams(rec map[string]interface{}, params []string) ([]interface{}, error) {
+	args := make([]interface{}, 0, len(params))
+	for _, field := range params {
+		value, ok := rec[field]
+		if !ok {
+			return nil, fmt.Errorf("parameter field %s not found in input record", field)
+		}
+		value, err := sqlValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", field, err)
+		}
+		args = append(args, value)
+	}
+	return args, nil
+}
+
+// sqlValue converts a record value to a statement argument: lists and
+// objects are written as JSON.
+func sqlValue(value interface{}) (interface{}, error) {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+	return value, nil
+}
+
+// sqlRecords reads and closes rows, converting every row to a record by
+// column name. Text and blob columns become strings.
+func sqlRecords(rows *sql.Rows) ([]map[string]interface{}, error) {
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var records []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		rec := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				rec[column] = string(b)
+			} else {
+				rec[column] = values[i]
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,159 @@
+package nodes
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"go-workflow/pkg/framework"
+)
+
+// sqlContext returns a context with the SQLite database "contacts", which
+// has a contacts table.
+func sqlContext(t *testing.T) *framework.Context {
+	t.Helper()
+	databases := framework.NewSQLDatabases(map[string]framework.SQLDatabase{
+		"contacts": {Driver: framework.SQLDriverSQLite, DSN: filepath.Join(t.TempDir(), "contacts.db")},
+	}, nil)
+	t.Cleanup(func() { databases.Close() })
+	ctx := &framework.Context{Ctx: context.Background(), SQL: databases}
+	db, err := databases.DB(ctx.Ctx, "contacts")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	if _, err := db.Exec(`CREATE TABLE contacts (email TEXT PRIMARY KEY, name TEXT, invites INTEGER NOT NULL DEFAULT 0, tags TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	return ctx
+}
+
+func TestSQLExecute_InsertAndQuery(t *testing.T) {
+	ctx := sqlContext(t)
+	insert := &SQLExecute{Database: "contacts", Mode: SQLInsert, Table: "contacts", Columns: []string{"email", "name", "tags"}, BatchSize: 2}
+	if err := ValidateSQLExecute(insert); err != nil {
+		t.Fatalf("invalid node: %v", err)
+	}
+	inputs := []map[string]interface{}{
+		{"email": "ann@example.com", "name": "Ann", "tags": []interface{}{"lead"}},
+		{"email": "bob@example.com", "name": "Bob"},
+		{"email": "cid@example.com", "name": "Cid"},
+	}
+	out, err := insert.Execute(ctx, inputs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(out, inputs) {
+		t.Errorf("expected the records to be passed on, got %v", out)
+	}
+
+	query := &SQLQuery{Database: "contacts", Query: "SELECT email, name, tags FROM contacts WHERE name <> ? ORDER BY email", Params: []string{"skip"}}
+	rows, err := query.Execute(ctx, []map[string]interface{}{{"skip": "Cid"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []map[string]interface{}{
+		{"email": "ann@example.com", "name": "Ann", "tags": `["lead"]`},
+		{"email": "bob@example.com", "name": "Bob", "tags": nil},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("expected the rows as records, got %v", rows)
+	}
+
+	query.Params = nil
+	query.Query = "SELECT count(*) AS n FROM contacts"
+	query.OutputKey = "count"
+	rows, err = query.Execute(ctx, []map[string]interface{}{{"id": 1}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(rows, []map[string]interface{}{{"id": 1, "count": []interface{}{map[string]interface{}{"n": int64(3)}}}}) {
+		t.Errorf("expected the rows under the output key, got %v", rows)
+	}
+}
+
+func TestSQLExecute_Upsert(t *testing.T) {
+	ctx := sqlContext(t)
+	upsert := &SQLExecute{Database: "contacts", Mode: SQLUpsert, Table: "contacts", Columns: []string{"email", "name"}, ConflictColumns: []string{"email"}}
+	if err := ValidateSQLExecute(upsert); err != nil {
+		t.Fatalf("invalid node: %v", err)
+	}
+	if _, err := upsert.Execute(ctx, []map[string]interface{}{{"email": "ann@example.com", "name": "Ann"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := upsert.Execute(ctx, []map[string]interface{}{{"email": "ann@example.com", "name": "Ann Smith"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	rows, err := (&SQLQuery{Database: "contacts", Query: "SELECT name FROM contacts"}).Execute(ctx, []map[string]interface{}{{}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(rows, []map[string]interface{}{{"name": "Ann Smith"}}) {
+		t.Errorf("expected the row to be updated, got %v", rows)
+	}
+}
+
+func TestSQLExecute_ExecTransaction(t *testing.T) {
+	ctx := sqlContext(t)
+	insert := &SQLExecute{Database: "contacts", Statement: "INSERT INTO contacts (email, name) VALUES (?, ?)", Params: []string{"email", "name"}, OutputKey: "inserted"}
+	if err := ValidateSQLExecute(insert); err != nil {
+		t.Fatalf("invalid node: %v", err)
+	}
+	out, err := insert.Execute(ctx, []map[string]interface{}{{"email": "ann@example.com", "name": "Ann"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out[0]["inserted"] != int64(1) {
+		t.Errorf("expected the affected rows, got %v", out)
+	}
+
+	// The second record fails on the primary key, so the first is not
+	// written either.
+	_, err = insert.Execute(ctx, []map[string]interface{}{{"email": "bob@example.com", "name": "Bob"}, {"email": "ann@example.com", "name": "Ann"}})
+	if err == nil {
+		t.Fatal("expected a constraint error")
+	}
+	rows, err := (&SQLQuery{Database: "contacts", Query: "SELECT email FROM contacts"}).Execute(ctx, []map[string]interface{}{{}})
+	if err != nil || len(rows) != 1 {
+		t.Errorf("expected the failed execution to be rolled back, got %v, %v", rows, err)
+	}
+
+	if _, err := insert.Execute(ctx, []map[string]interface{}{{"email": "cid@example.com"}}); err == nil || !strings.Contains(err.Error(), "parameter field name not found") {
+		t.Errorf("expected an error for a missing parameter field, got %v", err)
+	}
+}
+
+func TestSQLExecute_InsertStatement(t *testing.T) {
+	upsert := &SQLExecute{Mode: SQLUpsert, Table: "contacts", Columns: []string{"email", "name"}, ConflictColumns: []string{"email"}}
+	if got := upsert.insertStatement(framework.SQLDriverPostgres, 2); got != "INSERT INTO contacts (email, name) VALUES (?, ?), (?, ?) ON CONFLICT (email) DO UPDATE SET name = excluded.name" {
+		t.Errorf("unexpected postgres upsert %q", got)
+	}
+	if got := upsert.insertStatement(framework.SQLDriverMySQL, 1); got != "INSERT INTO contacts (email, name) VALUES (?, ?) ON DUPLICATE KEY UPDATE name = VALUES(name)" {
+		t.Errorf("unexpected mysql upsert %q", got)
+	}
+	upsert.Columns = []string{"email"}
+	if got := upsert.insertStatement(framework.SQLDriverSQLite, 1); got != "INSERT INTO contacts (email) VALUES (?) ON CONFLICT (email) DO NOTHING" {
+		t.Errorf("unexpected upsert without updates %q", got)
+	}
+}
+
+func TestValidateSQLExecute(t *testing.T) {
+	tests := map[string]*SQLExecute{
+		"no database":          {Statement: "DELETE FROM contacts"},
+		"no statement":         {Database: "db"},
+		"unknown mode":         {Database: "db", Mode: "merge"},
+		"invalid table":        {Database: "db", Mode: SQLInsert, Table: "contacts; DROP TABLE x", Columns: []string{"email"}},
+		"invalid column":       {Database: "db", Mode: SQLInsert, Table: "contacts", Columns: []string{"e mail"}},
+		"no columns":           {Database: "db", Mode: SQLInsert, Table: "contacts"},
+		"upsert without key":   {Database: "db", Mode: SQLUpsert, Table: "contacts", Columns: []string{"email"}},
+		"statement and insert": {Database: "db", Mode: SQLInsert, Table: "contacts", Columns: []string{"email"}, Statement: "SELECT 1"},
+	}
+	for name, node := range tests {
+		if err := ValidateSQLExecute(node); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
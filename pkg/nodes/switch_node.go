@@ -1,23 +1,38 @@
 package nodes
 
 import (
+	"sort"
+
 	"go-workflow/pkg/framework"
 )
 
-// Condition defines a condition for routing.
-type Condition struct {
-	Field string
-	Value interface{}
+// SwitchRule routes records matching its conditions to output port Output.
+type SwitchRule struct {
+	Conditions []Condition
+	Combine    string // "all" (default) or "any"
+	Output     int
 }
 
 // SwitchNode routes items into different branches based on conditions.
+//
+// Connected by port, a SwitchNode sends each record to the output of the
+// first matching rule. Without Rules, Conditions are used instead, with one
+// output per branch in branch name order.
 type SwitchNode struct {
 	Conditions map[string]Condition // Map of output branch name to condition
+	Rules      []SwitchRule         // Ordered rules used for port routing
+	// FallbackOutput receives records no rule matched; -1 drops them.
+	FallbackOutput int
 }
 
 // NewSwitchNode creates a new SwitchNode.
 func NewSwitchNode(conditions map[string]Condition) *SwitchNode {
-	return &SwitchNode{Conditions: conditions}
+	return &SwitchNode{Conditions: conditions, FallbackOutput: -1}
+}
+
+// NewSwitchNodeWithRules creates a SwitchNode that routes by ordered rules.
+func NewSwitchNodeWithRules(rules []SwitchRule, fallbackOutput int) *SwitchNode {
+	return &SwitchNode{Rules: rules, FallbackOutput: fallbackOutput}
 }
 
 // Execute routes the input records based on the defined conditions.
@@ -29,7 +44,7 @@ func (n *SwitchNode) Execute(ctx *framework.Context, inputs []map[string]interfa
 	for _, input := range inputs {
 		routed := false
 		for branchName, cond := range n.Conditions {
-			if val, ok := input[cond.Field]; ok && val == cond.Value {
+			if cond.Match(input) {
 				branchOutputs[branchName] = append(branchOutputs[branchName], input)
 				routed = true
 				break // Route to the first matching branch
@@ -51,3 +66,48 @@ func (n *SwitchNode) Execute(ctx *framework.Context, inputs []map[string]interfa
 
 	return outputs, nil
 }
+
+// ExecutePorts routes each record to the output of the first matching rule,
+// or to FallbackOutput if none matches.
+func (n *SwitchNode) ExecutePorts(ctx *framework.Context, inputs []map[string]interface{}) ([][]map[string]interface{}, error) {
+	rules := n.rules()
+	var ports [][]map[string]interface{}
+	emit := func(port int, record map[string]interface{}) {
+		for len(ports) <= port {
+			ports = append(ports, nil)
+		}
+		ports[port] = append(ports[port], record)
+	}
+
+	for _, input := range inputs {
+		routed := false
+		for _, rule := range rules {
+			if MatchConditions(rule.Conditions, rule.Combine, input) {
+				emit(rule.Output, input)
+				routed = true
+				break
+			}
+		}
+		if !routed && n.FallbackOutput >= 0 {
+			emit(n.FallbackOutput, input)
+		}
+	}
+	return ports, nil
+}
+
+// rules returns Rules, or one rule per entry of Conditions in branch name order.
+func (n *SwitchNode) rules() []SwitchRule {
+	if len(n.Rules) > 0 {
+		return n.Rules
+	}
+	branches := make([]string, 0, len(n.Conditions))
+	for branch := range n.Conditions {
+		branches = append(branches, branch)
+	}
+	sort.Strings(branches)
+	rules := make([]SwitchRule, len(branches))
+	for i, branch := range branches {
+		rules[i] = SwitchRule{Conditions: []Condition{n.Conditions[branch]}, Output: i}
+	}
+	return rules
+}
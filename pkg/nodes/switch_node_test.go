@@ -131,3 +131,52 @@ func TestSwitchNode_Execute(t *testing.T) {
 		}
 	})
 }
+
+func TestSwitchNode_ExecutePorts(t *testing.T) {
+	ctx := &framework.Context{Ctx: context.Background()}
+	inputs := []map[string]interface{}{
+		{"id": 1, "type": "email"},
+		{"id": 2, "type": "sms"},
+		{"id": 3, "type": "push"},
+		{"id": 4, "priority": 9},
+	}
+
+	t.Run("ordered rules with fallback", func(t *testing.T) {
+		node := NewSwitchNodeWithRules([]SwitchRule{
+			{Conditions: []Condition{{Field: "type", Value: "sms"}}, Output: 1},
+			{Conditions: []Condition{{Field: "type", Value: "email"}, {Field: "priority", Value: 5, Operation: OpLarger}}, Combine: "any", Output: 0},
+		}, 2)
+
+		ports, err := node.ExecutePorts(ctx, inputs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(ports) != 3 {
+			t.Fatalf("expected 3 outputs, got %d", len(ports))
+		}
+		if len(ports[0]) != 2 || ports[0][0]["id"] != 1 || ports[0][1]["id"] != 4 {
+			t.Errorf("unexpected output 0: %v", ports[0])
+		}
+		if len(ports[1]) != 1 || ports[1][0]["id"] != 2 {
+			t.Errorf("unexpected output 1: %v", ports[1])
+		}
+		if len(ports[2]) != 1 || ports[2][0]["id"] != 3 {
+			t.Errorf("unexpected fallback output: %v", ports[2])
+		}
+	})
+
+	t.Run("conditions in branch name order", func(t *testing.T) {
+		node := NewSwitchNode(map[string]Condition{
+			"b_sms":   {Field: "type", Value: "sms"},
+			"a_email": {Field: "type", Value: "email"},
+		})
+
+		ports, err := node.ExecutePorts(ctx, inputs)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(ports) != 2 || ports[0][0]["id"] != 1 || ports[1][0]["id"] != 2 {
+			t.Errorf("expected email on output 0 and sms on output 1, got %v", ports)
+		}
+	})
+}
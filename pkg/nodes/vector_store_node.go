@@ -0,0 +1,256 @@
+package nodes
+
+import (
+	"errors"
+	"fmt"
+
+	"go-workflow/pkg/framework"
+)
+
+// Operations of vectorStore nodes.
+const (
+	// VectorUpsert adds the records to the index and passes them on.
+	VectorUpsert = "upsert"
+	// VectorQuery adds the documents most similar to each record to it.
+	VectorQuery = "query"
+	// VectorDedupe drops records similar to a document of the index, and
+	// adds the others to it.
+	VectorDedupe = "dedupe"
+)
+
+// Defaults of vectorStore nodes.
+const (
+	DefaultVectorTopK         = 5
+	DefaultVectorMatchesField = "matches"
+	DefaultVectorIDField      = "id"
+	// DefaultDedupeScore is the similarity from which dedupe treats
+	// records as duplicates.
+	DefaultDedupeScore = 0.95
+)
+
+// VectorStoreNode upserts records into, or searches, an index of
+// ctx.VectorStore by the vectors an EmbeddingsNode added to them.
+type VectorStoreNode struct {
+	Operation string
+	Index     string
+	// VectorField holds the vector of a record, "embedding" if empty.
+	VectorField string
+	// IDField holds the document ID of a record, "id" if empty. Records
+	// with the ID of a document replace it.
+	IDField string
+	// MetadataFields are stored with the vector; all fields but the vector
+	// if empty.
+	MetadataFields []string
+	// TopK limits the matches of a query, DefaultVectorTopK if zero.
+	TopK int
+	// MinScore is the lowest cosine similarity of a match. Dedupe uses
+	// DefaultDedupeScore if it is zero.
+	MinScore float64
+	// Filter restricts matches to documents whose metadata has these
+	// values. String values are templates rendered for each record, see
+	// renderPrompt.
+	Filter map[string]interface{}
+	// OutputKey is the field query stores the matches under, "matches" if
+	// empty.
+	OutputKey string
+}
+
+// NewVectorStoreNode creates a new VectorStoreNode.
+func NewVectorStoreNode(operation, index string) *VectorStoreNode {
+	return &VectorStoreNode{Operation: operation, Index: index}
+}
+
+// ValidateVectorOperation checks the operation of a vectorStore node.
+func ValidateVectorOperation(operation string) error {
+	switch operation {
+	case VectorUpsert, VectorQuery, VectorDedupe:
+		return nil
+	}
+	return fmt.Errorf("unknown vector store operation %q; use upsert, query or dedupe", operation)
+}
+
+// Execute runs the operation for the input records.
+func (n *VectorStoreNode) Execute(ctx *framework.Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+	if ctx.VectorStore == nil {
+		return nil, errors.New("no vector store available")
+	}
+	switch n.Operation {
+	case VectorUpsert:
+		docs := make([]framework.VectorDocument, 0, len(inputs))
+		for _, rec := range inputs {
+			doc, err := n.document(rec)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, doc)
+		}
+		if err := ctx.VectorStore.Upsert(ctx.Ctx, n.Index, docs); err != nil {
+			return nil, fmt.Errorf("failed to upsert into %s: %w", n.Index, err)
+		}
+		return inputs, nil
+	case VectorQuery:
+		return n.query(ctx, inputs)
+	case VectorDedupe:
+		return n.dedupe(ctx, inputs)
+	}
+	return nil, ValidateVectorOperation(n.Operation)
+}
+
+func (n *VectorStoreNode) query(ctx *framework.Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+	outputKey := n.OutputKey
+	if outputKey == "" {
+		outputKey = DefaultVectorMatchesField
+	}
+	topK := n.TopK
+	if topK <= 0 {
+		topK = DefaultVectorTopK
+	}
+	out := make([]map[string]interface{}, 0, len(inputs))
+	for _, rec := range inputs {
+		query, err := n.vectorQuery(ctx, rec, topK, n.MinScore)
+		if err != nil {
+			return nil, err
+		}
+		found, err := ctx.VectorStore.Query(ctx.Ctx, n.Index, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s: %w", n.Index, err)
+		}
+		matches := make([]interface{}, 0, len(found))
+		for _, m := range found {
+			matches = append(matches, map[string]interface{}{
+				"id":       m.ID,
+				"score":    m.Score,
+				"metadata": m.Metadata,
+			})
+		}
+		merged := make(map[string]interface{}, len(rec)+1)
+		for k, v := range rec {
+			merged[k] = v
+		}
+		merged[outputKey] = matches
+		out = append(out, merged)
+	}
+	return out, nil
+}
+
+// dedupe keeps the records that have no similar document in the index and
+// adds each kept record to the index right away, so the records of one run
+// are also compared with each other.
+func (n *VectorStoreNode) dedupe(ctx *framework.Context, inputs []map[string]interface{}) ([]map[string]interface{}, error) {
+	minScore := n.MinScore
+	if minScore == 0 {
+		minScore = DefaultDedupeScore
+	}
+	var out []map[string]interface{}
+	for _, rec := range inputs {
+		doc, err := n.document(rec)
+		if err != nil {
+			return nil, err
+		}
+		query, err := n.vectorQuery(ctx, rec, 1, minScore)
+		if err != nil {
+			return nil, err
+		}
+		found, err := ctx.VectorStore.Query(ctx.Ctx, n.Index, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s: %w", n.Index, err)
+		}
+		if len(found) > 0 {
+			if ctx.Logger != nil {
+				ctx.Logger.Debugf("dropping %s as a duplicate of %s (similarity %.3f)", doc.ID, found[0].ID, found[0].Score)
+			}
+			continue
+		}
+		if err := ctx.VectorStore.Upsert(ctx.Ctx, n.Index, []framework.VectorDocument{doc}); err != nil {
+			return nil, fmt.Errorf("failed to upsert into %s: %w", n.Index, err)
+		}
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func (n *VectorStoreNode) vectorQuery(ctx *framework.Context, rec map[string]interface{}, topK int, minScore float64) (framework.VectorQuery, error) {
+	vector, err := n.vector(rec)
+	if err != nil {
+		return framework.VectorQuery{}, err
+	}
+	query := framework.VectorQuery{Vector: vector, TopK: topK, MinScore: minScore}
+	if len(n.Filter) > 0 {
+		query.Filter = make(map[string]interface{}, len(n.Filter))
+		for key, value := range n.Filter {
+			if text, ok := value.(string); ok {
+				if value, err = renderPrompt(text, rec, ctx.Env); err != nil {
+					return framework.VectorQuery{}, fmt.Errorf("filter %s: %w", key, err)
+				}
+			}
+			query.Filter[key] = value
+		}
+	}
+	return query, nil
+}
+
+// document returns the document of a record.
+func (n *VectorStoreNode) document(rec map[string]interface{}) (framework.VectorDocument, error) {
+	idField := n.IDField
+	if idField == "" {
+		idField = DefaultVectorIDField
+	}
+	id, ok := rec[idField]
+	if !ok || id == nil {
+		return framework.VectorDocument{}, fmt.Errorf("record has no ID field %q", idField)
+	}
+	vector, err := n.vector(rec)
+	if err != nil {
+		return framework.VectorDocument{}, err
+	}
+	metadata := make(map[string]interface{})
+	if len(n.MetadataFields) > 0 {
+		for _, field := range n.MetadataFields {
+			if v, ok := rec[field]; ok {
+				metadata[field] = v
+			}
+		}
+	} else {
+		for k, v := range rec {
+			if k != n.vectorField() {
+				metadata[k] = v
+			}
+		}
+	}
+	return framework.VectorDocument{ID: fmt.Sprint(id), Vector: vector, Metadata: metadata}, nil
+}
+
+func (n *VectorStoreNode) vectorField() string {
+	if n.VectorField == "" {
+		return DefaultEmbeddingField
+	}
+	return n.VectorField
+}
+
+// vector returns the vector of a record, which is a []float32 from an
+// EmbeddingsNode or a list of numbers from JSON input.
+func (n *VectorStoreNode) vector(rec map[string]interface{}) ([]float32, error) {
+	switch v := rec[n.vectorField()].(type) {
+	case []float32:
+		return v, nil
+	case []float64:
+		vector := make([]float32, len(v))
+		for i, x := range v {
+			vector[i] = float32(x)
+		}
+		return vector, nil
+	case []interface{}:
+		vector := make([]float32, len(v))
+		for i, x := range v {
+			f, ok := toFloat(x)
+			if !ok {
+				return nil, fmt.Errorf("field %q is not a vector of numbers", n.vectorField())
+			}
+			vector[i] = float32(f)
+		}
+		return vector, nil
+	case nil:
+		return nil, fmt.Errorf("record has no vector field %q", n.vectorField())
+	}
+	return nil, fmt.Errorf("field %q is not a vector", n.vectorField())
+}
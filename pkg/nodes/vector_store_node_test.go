@@ -0,0 +1,144 @@
+package nodes
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go-workflow/pkg/framework"
+	"go-workflow/pkg/llm"
+	"go-workflow/pkg/vectorstore"
+)
+
+// embed adds mock embeddings of the text field to records.
+func embed(t *testing.T, ctx *framework.Context, records ...map[string]interface{}) []map[string]interface{} {
+	t.Helper()
+	node := NewEmbeddingsNode("text")
+	node.Provider = "mock"
+	out, err := node.Execute(ctx, records)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return out
+}
+
+func newVectorContext() *framework.Context {
+	return &framework.Context{
+		Ctx:              context.Background(),
+		LLMClientFactory: llm.NewClientFactory(nil),
+		VectorStore:      vectorstore.NewMemoryStore(),
+		Env:              map[string]string{},
+	}
+}
+
+func TestVectorStoreNode_UpsertAndQuery(t *testing.T) {
+	ctx := newVectorContext()
+	docs := embed(t, ctx,
+		map[string]interface{}{"id": 1, "text": "refund policy for annual plans", "product": "billing"},
+		map[string]interface{}{"id": 2, "text": "resetting a forgotten password", "product": "accounts"},
+		map[string]interface{}{"id": 3, "text": "refund of monthly plans", "product": "legacy"},
+	)
+	upsert := NewVectorStoreNode(VectorUpsert, "faq")
+	if out, err := upsert.Execute(ctx, docs); err != nil || len(out) != 3 {
+		t.Fatalf("expected the records to pass, got %d, %v", len(out), err)
+	}
+
+	query := NewVectorStoreNode(VectorQuery, "faq")
+	query.TopK = 2
+	questions := embed(t, ctx, map[string]interface{}{"text": "what is the refund policy for annual plans", "area": "billing"})
+	out, err := query.Execute(ctx, questions)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	matches := out[0]["matches"].([]interface{})
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 matches, got %v", matches)
+	}
+	best := matches[0].(map[string]interface{})
+	if best["id"] != "1" || best["metadata"].(map[string]interface{})["text"] != "refund policy for annual plans" {
+		t.Errorf("expected the annual refund policy first, got %v", best)
+	}
+	if _, ok := best["metadata"].(map[string]interface{})["embedding"]; ok {
+		t.Error("expected the vector not to be stored as metadata")
+	}
+
+	// The filter is a template rendered for each record.
+	query.Filter = map[string]interface{}{"product": "{{.area}}"}
+	out, err = query.Execute(ctx, questions)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if matches := out[0]["matches"].([]interface{}); len(matches) != 1 || matches[0].(map[string]interface{})["id"] != "1" {
+		t.Errorf("expected only the billing document, got %v", matches)
+	}
+}
+
+func TestVectorStoreNode_Dedupe(t *testing.T) {
+	ctx := newVectorContext()
+	node := NewVectorStoreNode(VectorDedupe, "leads")
+	node.MetadataFields = []string{"text"}
+
+	first := embed(t, ctx,
+		map[string]interface{}{"id": "a", "text": "Ada Lovelace, CTO at Analytical Engines"},
+		map[string]interface{}{"id": "b", "text": "ada lovelace, cto at analytical engines"},
+		map[string]interface{}{"id": "c", "text": "Grace Hopper, Rear Admiral, US Navy"},
+	)
+	out, err := node.Execute(ctx, first)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out) != 2 || out[0]["id"] != "a" || out[1]["id"] != "c" {
+		t.Errorf("expected the second spelling of Ada to be dropped, got %v", out)
+	}
+
+	// A later run is compared with the leads kept before.
+	out, err = node.Execute(ctx, embed(t, ctx,
+		map[string]interface{}{"id": "d", "text": "Grace Hopper, Rear Admiral, US Navy"},
+		map[string]interface{}{"id": "e", "text": "Alan Turing, mathematician"},
+	))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(out) != 1 || out[0]["id"] != "e" {
+		t.Errorf("expected only the new lead, got %v", out)
+	}
+}
+
+func TestVectorStoreNode_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     *framework.Context
+		node    *VectorStoreNode
+		inputs  []map[string]interface{}
+		wantErr string
+	}{
+		{"no store", &framework.Context{Ctx: context.Background()}, NewVectorStoreNode(VectorUpsert, "faq"), nil, "no vector store"},
+		{"missing ID", newVectorContext(), NewVectorStoreNode(VectorUpsert, "faq"), []map[string]interface{}{{"embedding": []float32{1}}}, `no ID field "id"`},
+		{"missing vector", newVectorContext(), NewVectorStoreNode(VectorQuery, "faq"), []map[string]interface{}{{"id": 1}}, `no vector field "embedding"`},
+		{"invalid vector", newVectorContext(), NewVectorStoreNode(VectorQuery, "faq"), []map[string]interface{}{{"embedding": []interface{}{"x"}}}, "not a vector of numbers"},
+		{"unknown operation", newVectorContext(), NewVectorStoreNode("delete", "faq"), nil, "unknown vector store operation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.node.Execute(tt.ctx, tt.inputs)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestVectorStoreNode_JSONVectors(t *testing.T) {
+	ctx := newVectorContext()
+	upsert := NewVectorStoreNode(VectorUpsert, "points")
+	if _, err := upsert.Execute(ctx, []map[string]interface{}{{"id": "x", "embedding": []interface{}{1.0, 0.0}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := NewVectorStoreNode(VectorQuery, "points").Execute(ctx, []map[string]interface{}{{"embedding": []float64{2, 0}}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if matches := out[0]["matches"].([]interface{}); len(matches) != 1 || matches[0].(map[string]interface{})["score"].(float64) < 0.999 {
+		t.Errorf("expected x to match, got %v", matches)
+	}
+}
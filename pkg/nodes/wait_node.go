@@ -9,6 +9,11 @@ import (
 // WaitNode waits up to MaxSeconds randomly
 type WaitNode struct{ MaxSeconds int }
 
+// NewWaitNode creates a new WaitNode.
+func NewWaitNode(maxSeconds int) *WaitNode {
+    return &WaitNode{MaxSeconds: maxSeconds}
+}
+
 func (n *WaitNode) Execute(ctx *framework.Context, input []map[string]interface{}) ([]map[string]interface{}, error) {
     time.Sleep(time.Duration(rand.Intn(n.MaxSeconds)) * time.Second)
     return input, nil
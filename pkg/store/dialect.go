@@ -0,0 +1,36 @@
+package store
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect identifies the SQL flavour of a database backing a store.
+type Dialect string
+
+const (
+	// DialectSQLite is SQLite through github.com/mattn/go-sqlite3.
+	DialectSQLite Dialect = "sqlite3"
+	// DialectPostgres is PostgreSQL through github.com/lib/pq.
+	DialectPostgres Dialect = "postgres"
+)
+
+// Rebind rewrites the "?" placeholders used throughout this package into
+// the placeholder style of the dialect.
+func (d Dialect) Rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString("$")
+			sb.WriteString(strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
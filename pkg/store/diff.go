@@ -0,0 +1,111 @@
+package store
+
+import (
+	"strings"
+)
+
+// DiffLine is one line of a definition diff. Op is "+" for a line only in
+// the newer definition, "-" for a line only in the older one and " " for a
+// line both share.
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// maxDiffCells bounds the table of the longest common subsequence, which
+// has a cell for every pair of changed lines, to about 8 MB.
+const maxDiffCells = 1 << 20
+
+// DiffDefinitions computes a line-based diff between two workflow definitions
+// using the longest common subsequence of their lines. Lines the two share
+// at the start and end are kept as they are; if too many lines remain in
+// between, see maxDiffCells, they are all removed and added instead.
+func DiffDefinitions(from, to string) []DiffLine {
+	a := splitLines(from)
+	b := splitLines(to)
+
+	var diff []DiffLine
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		diff = append(diff, DiffLine{Op: " ", Text: a[0]})
+		a, b = a[1:], b[1:]
+	}
+	shared := 0
+	for shared < len(a) && shared < len(b) && a[len(a)-1-shared] == b[len(b)-1-shared] {
+		shared++
+	}
+	suffix := a[len(a)-shared:]
+	a, b = a[:len(a)-shared], b[:len(b)-shared]
+	if (len(a)+1)*(len(b)+1) > maxDiffCells {
+		diff = appendLines(diff, "-", a)
+		diff = appendLines(diff, "+", b)
+	} else {
+		diff = appendLCSDiff(diff, a, b)
+	}
+	return appendLines(diff, " ", suffix)
+}
+
+// appendLCSDiff appends the diff of a and b, by their longest common
+// subsequence, to diff.
+func appendLCSDiff(diff []DiffLine, a, b []string) []DiffLine {
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, DiffLine{Op: " ", Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, DiffLine{Op: "-", Text: a[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{Op: "+", Text: b[j]})
+			j++
+		}
+	}
+	diff = appendLines(diff, "-", a[i:])
+	return appendLines(diff, "+", b[j:])
+}
+
+// appendLines appends lines to diff with the same op.
+func appendLines(diff []DiffLine, op string, lines []string) []DiffLine {
+	for _, line := range lines {
+		diff = append(diff, DiffLine{Op: op, Text: line})
+	}
+	return diff
+}
+
+// FormatDiff renders a diff in the familiar "+"/"-" prefixed text form.
+func FormatDiff(diff []DiffLine) string {
+	var sb strings.Builder
+	for _, line := range diff {
+		sb.WriteString(line.Op)
+		sb.WriteString(line.Text)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
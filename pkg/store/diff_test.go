@@ -0,0 +1,54 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDiffDefinitions(t *testing.T) {
+	from := "nodes:\n  a:\n    type: setNode\nconnections: {}\n"
+	to := "nodes:\n  a:\n    type: dedupeNode\nconnections: {}\n"
+
+	diff := DiffDefinitions(from, to)
+	expected := " nodes:\n   a:\n-    type: setNode\n+    type: dedupeNode\n connections: {}\n"
+	if got := FormatDiff(diff); got != expected {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", got, expected)
+	}
+}
+
+func TestDiffDefinitions_Empty(t *testing.T) {
+	diff := DiffDefinitions("", "a\nb\n")
+	if len(diff) != 2 || diff[0].Op != "+" || diff[1].Op != "+" {
+		t.Errorf("expected two added lines, got %+v", diff)
+	}
+
+	if diff := DiffDefinitions("same\n", "same\n"); len(diff) != 1 || diff[0].Op != " " {
+		t.Errorf("expected one unchanged line, got %+v", diff)
+	}
+}
+
+func TestDiffDefinitions_Large(t *testing.T) {
+	var from, to strings.Builder
+	from.WriteString("nodes:\n")
+	to.WriteString("nodes:\n")
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&from, "  old%d: {}\n", i)
+		fmt.Fprintf(&to, "  new%d: {}\n", i)
+	}
+	from.WriteString("connections: {}\n")
+	to.WriteString("connections: {}\n")
+
+	diff := DiffDefinitions(from.String(), to.String())
+	if len(diff) != 4002 {
+		t.Fatalf("expected 4002 lines, got %d", len(diff))
+	}
+	if diff[0] != (DiffLine{Op: " ", Text: "nodes:"}) || diff[len(diff)-1] != (DiffLine{Op: " ", Text: "connections: {}"}) {
+		t.Errorf("expected the shared first and last lines to be kept, got %+v and %+v", diff[0], diff[len(diff)-1])
+	}
+	// Too many changed lines for the longest common subsequence: the old
+	// ones are all removed, then the new ones added.
+	if diff[1].Op != "-" || diff[2000].Op != "-" || diff[2001].Op != "+" || diff[4000].Op != "+" {
+		t.Errorf("expected the old lines removed and the new ones added, got %+v", diff[1999:2003])
+	}
+}
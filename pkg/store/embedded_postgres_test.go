@@ -0,0 +1,44 @@
+//go:build embeddedpostgres
+
+package store_test
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+)
+
+// TestEmbeddedPostgresStoreConformance runs the conformance suite, with
+// the Postgres dialect and migrations, against an embedded Postgres server.
+// Its binaries are downloaded on the first run and cached:
+//
+//	go test -tags embeddedpostgres ./pkg/store/...
+func TestEmbeddedPostgresStoreConformance(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	port := uint32(listener.Addr().(*net.TCPAddr).Port)
+	listener.Close()
+
+	dir := t.TempDir()
+	config := embeddedpostgres.DefaultConfig().
+		Port(port).
+		Database("workflows").
+		RuntimePath(filepath.Join(dir, "runtime")).
+		DataPath(filepath.Join(dir, "data")).
+		Logger(nil)
+	postgres := embeddedpostgres.NewDatabase(config)
+	if err := postgres.Start(); err != nil {
+		t.Fatalf("failed to start embedded Postgres: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := postgres.Stop(); err != nil {
+			t.Errorf("failed to stop embedded Postgres: %v", err)
+		}
+	})
+
+	runPostgresConformance(t, config.GetConnectionURL()+"?sslmode=disable")
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Sentinel errors returned by every WorkflowStore implementation. Callers
+// should test for them with errors.Is; the returned errors carry more detail.
+var (
+	// ErrNotFound is returned when a workflow, version or run does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write violates a uniqueness constraint,
+	// e.g. a duplicate workflow name or a concurrently recorded version.
+	ErrConflict = errors.New("conflict")
+)
+
+// notFound wraps ErrNotFound with a description of what was looked up.
+func notFound(format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %w", fmt.Sprintf(format, args...), ErrNotFound)
+}
+
+// conflict wraps ErrConflict with a description of the conflicting write.
+func conflict(format string, args ...interface{}) error {
+	return fmt.Errorf("%s: %w", fmt.Sprintf(format, args...), ErrConflict)
+}
+
+// isUniqueViolation reports whether err is a unique or primary key violation
+// reported by one of the supported database drivers.
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	return false
+}
+
+// wrapWriteError turns a failed write into an ErrConflict if it violated a
+// uniqueness constraint, or a plain error otherwise.
+func wrapWriteError(msg string, err error) error {
+	if isUniqueViolation(err) {
+		return fmt.Errorf("%s: %w: %v", msg, ErrConflict, err)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// wrapLookupError turns a failed single-row lookup into an ErrNotFound if
+// there was no row, or a plain error otherwise.
+func wrapLookupError(what string, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound("%s", what)
+	}
+	return fmt.Errorf("failed to scan %s: %w", what, err)
+}
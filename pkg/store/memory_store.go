@@ -0,0 +1,460 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore implements WorkflowStore in memory. It is safe for concurrent
+// use and behaves like the SQL stores, including name uniqueness and
+// ordering, which makes it suitable for tests and for services that embed
+// the engine without a database. Nothing is persisted.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data *memoryData
+}
+
+// NewMemoryStore creates a new, empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: newMemoryData()}
+}
+
+// Init prepares the store; it only fails if ctx is done.
+func (s *MemoryStore) Init(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data == nil {
+		s.data = newMemoryData()
+	}
+	return ctx.Err()
+}
+
+// WithTx runs fn against the store and keeps its changes only if fn returns
+// nil; otherwise they are undone, so a rollback costs as much as the
+// changes, not the size of the store. The store is locked while fn runs, so
+// fn must only use tx, not s.
+func (s *MemoryStore) WithTx(ctx context.Context, fn func(tx Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &memoryTx{data: s.data, undo: &[]func(){}}
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+	return nil
+}
+
+// write runs fn under the write lock.
+func (s *MemoryStore) write(fn func(tx *memoryTx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(&memoryTx{data: s.data})
+}
+
+// read runs fn under the read lock.
+func (s *MemoryStore) read(fn func(tx *memoryTx) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn(&memoryTx{data: s.data})
+}
+
+// SaveWorkflow saves a new workflow definition as version 1.
+func (s *MemoryStore) SaveWorkflow(ctx context.Context, workflow *Workflow) error {
+	return s.write(func(tx *memoryTx) error { return tx.SaveWorkflow(ctx, workflow) })
+}
+
+// UpdateWorkflow records workflow.Definition as a new version and makes it the
+// active one. workflow.Version is set to the new version number.
+func (s *MemoryStore) UpdateWorkflow(ctx context.Context, workflow *Workflow) error {
+	return s.write(func(tx *memoryTx) error { return tx.UpdateWorkflow(ctx, workflow) })
+}
+
+// GetWorkflow retrieves a workflow definition by ID.
+func (s *MemoryStore) GetWorkflow(ctx context.Context, id string) (workflow *Workflow, err error) {
+	err = s.read(func(tx *memoryTx) error {
+		workflow, err = tx.GetWorkflow(ctx, id)
+		return err
+	})
+	return workflow, err
+}
+
+// GetWorkflowByName retrieves a workflow definition by name.
+func (s *MemoryStore) GetWorkflowByName(ctx context.Context, name string) (workflow *Workflow, err error) {
+	err = s.read(func(tx *memoryTx) error {
+		workflow, err = tx.GetWorkflowByName(ctx, name)
+		return err
+	})
+	return workflow, err
+}
+
+// ListWorkflows lists all stored workflow definitions in the order they were saved.
+func (s *MemoryStore) ListWorkflows(ctx context.Context) (workflows []*Workflow, err error) {
+	err = s.read(func(tx *memoryTx) error {
+		workflows, err = tx.ListWorkflows(ctx)
+		return err
+	})
+	return workflows, err
+}
+
+// ListVersions lists every recorded version of a workflow, oldest first.
+func (s *MemoryStore) ListVersions(ctx context.Context, workflowID string) (versions []*WorkflowVersion, err error) {
+	err = s.read(func(tx *memoryTx) error {
+		versions, err = tx.ListVersions(ctx, workflowID)
+		return err
+	})
+	return versions, err
+}
+
+// GetVersion retrieves a single version of a workflow.
+func (s *MemoryStore) GetVersion(ctx context.Context, workflowID string, version int) (v *WorkflowVersion, err error) {
+	err = s.read(func(tx *memoryTx) error {
+		v, err = tx.GetVersion(ctx, workflowID, version)
+		return err
+	})
+	return v, err
+}
+
+// ActivateVersion makes an existing version the live definition of a workflow.
+func (s *MemoryStore) ActivateVersion(ctx context.Context, workflowID string, version int) (workflow *Workflow, err error) {
+	err = s.write(func(tx *memoryTx) error {
+		workflow, err = tx.ActivateVersion(ctx, workflowID, version)
+		return err
+	})
+	return workflow, err
+}
+
+// SaveRun records the start of a workflow run.
+func (s *MemoryStore) SaveRun(ctx context.Context, run *WorkflowRun) error {
+	return s.write(func(tx *memoryTx) error { return tx.SaveRun(ctx, run) })
+}
+
+// UpdateRun updates the status, error and finish time of a workflow run.
+func (s *MemoryStore) UpdateRun(ctx context.Context, run *WorkflowRun) error {
+	return s.write(func(tx *memoryTx) error { return tx.UpdateRun(ctx, run) })
+}
+
+// GetRun retrieves a workflow run by ID.
+func (s *MemoryStore) GetRun(ctx context.Context, id string) (run *WorkflowRun, err error) {
+	err = s.read(func(tx *memoryTx) error {
+		run, err = tx.GetRun(ctx, id)
+		return err
+	})
+	return run, err
+}
+
+// ListRuns lists the runs of a workflow, most recent first.
+func (s *MemoryStore) ListRuns(ctx context.Context, workflowID string) (runs []*WorkflowRun, err error) {
+	err = s.read(func(tx *memoryTx) error {
+		runs, err = tx.ListRuns(ctx, workflowID)
+		return err
+	})
+	return runs, err
+}
+
+// memoryData is the state of a MemoryStore. Records are stored by value and
+// copied on the way in and out, so callers never share them.
+type memoryData struct {
+	workflows map[string]Workflow
+	order     []string          // workflow IDs in the order they were saved
+	names     map[string]string // name -> workflow ID
+	versions  map[string][]WorkflowVersion
+	runs      map[string]WorkflowRun
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		workflows: map[string]Workflow{},
+		names:     map[string]string{},
+		versions:  map[string][]WorkflowVersion{},
+		runs:      map[string]WorkflowRun{},
+	}
+}
+
+// memoryTx implements Tx on memoryData. It does no locking; MemoryStore
+// holds the lock for it.
+type memoryTx struct {
+	data *memoryData
+	// undo, in a transaction of WithTx, collects functions that revert
+	// the changes made so far.
+	undo *[]func()
+}
+
+// onRollback records how to revert a change, if tx is a transaction.
+func (tx *memoryTx) onRollback(revert func()) {
+	if tx.undo != nil {
+		*tx.undo = append(*tx.undo, revert)
+	}
+}
+
+// rollback reverts the changes of tx, latest first.
+func (tx *memoryTx) rollback() {
+	for i := len(*tx.undo) - 1; i >= 0; i-- {
+		(*tx.undo)[i]()
+	}
+	*tx.undo = nil
+}
+
+// setWorkflow stores a workflow, or removes it if workflow is nil.
+func (tx *memoryTx) setWorkflow(id string, workflow *Workflow) {
+	prev, existed := tx.data.workflows[id]
+	tx.onRollback(func() {
+		if existed {
+			tx.data.workflows[id] = prev
+		} else {
+			delete(tx.data.workflows, id)
+		}
+	})
+	if workflow == nil {
+		delete(tx.data.workflows, id)
+	} else {
+		tx.data.workflows[id] = *workflow
+	}
+}
+
+// setName maps a workflow name to its ID, or removes it if id is empty.
+func (tx *memoryTx) setName(name, id string) {
+	prev, existed := tx.data.names[name]
+	tx.onRollback(func() {
+		if existed {
+			tx.data.names[name] = prev
+		} else {
+			delete(tx.data.names, name)
+		}
+	})
+	if id == "" {
+		delete(tx.data.names, name)
+	} else {
+		tx.data.names[name] = id
+	}
+}
+
+// addVersion records a new version of a workflow.
+func (tx *memoryTx) addVersion(v WorkflowVersion) {
+	prev, existed := tx.data.versions[v.WorkflowID]
+	tx.onRollback(func() {
+		if existed {
+			tx.data.versions[v.WorkflowID] = prev
+		} else {
+			delete(tx.data.versions, v.WorkflowID)
+		}
+	})
+	tx.data.versions[v.WorkflowID] = append(prev, v)
+}
+
+// setRun stores a run.
+func (tx *memoryTx) setRun(run WorkflowRun) {
+	prev, existed := tx.data.runs[run.ID]
+	tx.onRollback(func() {
+		if existed {
+			tx.data.runs[run.ID] = prev
+		} else {
+			delete(tx.data.runs, run.ID)
+		}
+	})
+	tx.data.runs[run.ID] = run
+}
+
+func (tx *memoryTx) SaveWorkflow(ctx context.Context, workflow *Workflow) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, ok := tx.data.workflows[workflow.ID]; ok {
+		return conflict("workflow %s already exists", workflow.ID)
+	}
+	if _, ok := tx.data.names[workflow.Name]; ok {
+		return conflict("workflow named %q already exists", workflow.Name)
+	}
+
+	now := time.Now().UTC()
+	workflow.Version = 1
+	stored := *workflow
+	stored.CreatedAt = now
+	tx.setWorkflow(workflow.ID, &stored)
+	saved := len(tx.data.order)
+	tx.onRollback(func() { tx.data.order = tx.data.order[:saved] })
+	tx.data.order = append(tx.data.order, workflow.ID)
+	tx.setName(workflow.Name, workflow.ID)
+	tx.addVersion(WorkflowVersion{WorkflowID: workflow.ID, Version: 1, Definition: workflow.Definition, CreatedAt: now})
+	return nil
+}
+
+func (tx *memoryTx) UpdateWorkflow(ctx context.Context, workflow *Workflow) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stored, ok := tx.data.workflows[workflow.ID]
+	if !ok {
+		return notFound("workflow %s", workflow.ID)
+	}
+	if id, ok := tx.data.names[workflow.Name]; ok && id != workflow.ID {
+		return conflict("workflow named %q already exists", workflow.Name)
+	}
+
+	versions := tx.data.versions[workflow.ID]
+	next := versions[len(versions)-1].Version + 1
+	tx.addVersion(WorkflowVersion{WorkflowID: workflow.ID, Version: next, Definition: workflow.Definition, CreatedAt: time.Now().UTC()})
+
+	tx.setName(stored.Name, "")
+	tx.setName(workflow.Name, workflow.ID)
+	stored.Name = workflow.Name
+	stored.Definition = workflow.Definition
+	stored.Version = next
+	tx.setWorkflow(workflow.ID, &stored)
+
+	workflow.Version = next
+	return nil
+}
+
+func (tx *memoryTx) GetWorkflow(ctx context.Context, id string) (*Workflow, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	workflow, ok := tx.data.workflows[id]
+	if !ok {
+		return nil, notFound("workflow %s", id)
+	}
+	return &workflow, nil
+}
+
+func (tx *memoryTx) GetWorkflowByName(ctx context.Context, name string) (*Workflow, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	id, ok := tx.data.names[name]
+	if !ok {
+		return nil, notFound("workflow named %q", name)
+	}
+	return tx.GetWorkflow(ctx, id)
+}
+
+func (tx *memoryTx) ListWorkflows(ctx context.Context) ([]*Workflow, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var workflows []*Workflow
+	for _, id := range tx.data.order {
+		workflow := tx.data.workflows[id]
+		workflows = append(workflows, &workflow)
+	}
+	return workflows, nil
+}
+
+func (tx *memoryTx) ListVersions(ctx context.Context, workflowID string) ([]*WorkflowVersion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var versions []*WorkflowVersion
+	for _, v := range tx.data.versions[workflowID] {
+		v := v
+		versions = append(versions, &v)
+	}
+	return versions, nil
+}
+
+func (tx *memoryTx) GetVersion(ctx context.Context, workflowID string, version int) (*WorkflowVersion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for _, v := range tx.data.versions[workflowID] {
+		if v.Version == version {
+			return &v, nil
+		}
+	}
+	return nil, notFound("workflow %s version %d", workflowID, version)
+}
+
+func (tx *memoryTx) ActivateVersion(ctx context.Context, workflowID string, version int) (*Workflow, error) {
+	v, err := tx.GetVersion(ctx, workflowID, version)
+	if err != nil {
+		return nil, err
+	}
+	workflow := tx.data.workflows[workflowID]
+	workflow.Definition = v.Definition
+	workflow.Version = v.Version
+	tx.setWorkflow(workflowID, &workflow)
+	return &workflow, nil
+}
+
+func (tx *memoryTx) SaveRun(ctx context.Context, run *WorkflowRun) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, ok := tx.data.runs[run.ID]; ok {
+		return conflict("workflow run %s already exists", run.ID)
+	}
+	if _, ok := tx.data.workflows[run.WorkflowID]; !ok {
+		return notFound("workflow %s", run.WorkflowID)
+	}
+	tx.setRun(copyRun(run))
+	return nil
+}
+
+func (tx *memoryTx) UpdateRun(ctx context.Context, run *WorkflowRun) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stored, ok := tx.data.runs[run.ID]
+	if !ok {
+		return notFound("workflow run %s", run.ID)
+	}
+	updated := copyRun(run)
+	stored.Status = updated.Status
+	stored.Error = updated.Error
+	stored.FinishedAt = updated.FinishedAt
+	stored.NodeStatuses = updated.NodeStatuses
+	stored.Trace = updated.Trace
+	tx.setRun(stored)
+	return nil
+}
+
+func (tx *memoryTx) GetRun(ctx context.Context, id string) (*WorkflowRun, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	run, ok := tx.data.runs[id]
+	if !ok {
+		return nil, notFound("workflow run %s", id)
+	}
+	copied := copyRun(&run)
+	return &copied, nil
+}
+
+func (tx *memoryTx) ListRuns(ctx context.Context, workflowID string) ([]*WorkflowRun, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var runs []*WorkflowRun
+	for _, run := range tx.data.runs {
+		if run.WorkflowID == workflowID {
+			copied := copyRun(&run)
+			runs = append(runs, &copied)
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	return runs, nil
+}
+
+// copyRun returns a copy of run that does not share its FinishedAt.
+func copyRun(run *WorkflowRun) WorkflowRun {
+	copied := *run
+	if run.FinishedAt != nil {
+		finishedAt := *run.FinishedAt
+		copied.FinishedAt = &finishedAt
+	}
+	if run.NodeStatuses != nil {
+		copied.NodeStatuses = make(map[string]string, len(run.NodeStatuses))
+		for node, status := range run.NodeStatuses {
+			copied.NodeStatuses[node] = status
+		}
+	}
+	if run.Trace != nil {
+		copied.Trace = append([]TraceStep(nil), run.Trace...)
+	}
+	return copied
+}
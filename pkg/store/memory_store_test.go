@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestMemoryStore_Concurrent(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	if err := s.SaveWorkflow(ctx, &Workflow{ID: "shared", Name: "shared", Definition: "v1"}); err != nil {
+		t.Fatalf("SaveWorkflow failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("workflow_%d", i)
+			if err := s.SaveWorkflow(ctx, &Workflow{ID: name, Name: name, Definition: "v1"}); err != nil {
+				t.Errorf("SaveWorkflow failed: %v", err)
+			}
+			err := s.WithTx(ctx, func(tx Tx) error {
+				wf, err := tx.GetWorkflow(ctx, "shared")
+				if err != nil {
+					return err
+				}
+				wf.Definition = fmt.Sprintf("update %d", i)
+				return tx.UpdateWorkflow(ctx, wf)
+			})
+			if err != nil {
+				t.Errorf("WithTx failed: %v", err)
+			}
+			if _, err := s.ListWorkflows(ctx); err != nil {
+				t.Errorf("ListWorkflows failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	versions, err := s.ListVersions(ctx, "shared")
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != 21 {
+		t.Errorf("expected 21 versions, got %d", len(versions))
+	}
+	workflows, err := s.ListWorkflows(ctx)
+	if err != nil {
+		t.Fatalf("ListWorkflows failed: %v", err)
+	}
+	if len(workflows) != 21 || workflows[0].ID != "shared" {
+		t.Errorf("expected 21 workflows in save order, got %d starting with %s", len(workflows), workflows[0].ID)
+	}
+}
+
+func TestMemoryStore_ReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	wf := &Workflow{ID: "copy", Name: "copy", Definition: "original"}
+	if err := s.SaveWorkflow(ctx, wf); err != nil {
+		t.Fatalf("SaveWorkflow failed: %v", err)
+	}
+	wf.Definition = "changed by caller"
+
+	got, err := s.GetWorkflow(ctx, "copy")
+	if err != nil {
+		t.Fatalf("GetWorkflow failed: %v", err)
+	}
+	got.Name = "also changed by caller"
+
+	got, err = s.GetWorkflow(ctx, "copy")
+	if err != nil {
+		t.Fatalf("GetWorkflow failed: %v", err)
+	}
+	if got.Definition != "original" || got.Name != "copy" {
+		t.Errorf("stored workflow was changed through a caller's pointer: %+v", got)
+	}
+
+	// The old name is released when a workflow is renamed.
+	got.Name = "renamed"
+	if err := s.UpdateWorkflow(ctx, got); err != nil {
+		t.Fatalf("UpdateWorkflow failed: %v", err)
+	}
+	if _, err := s.GetWorkflowByName(ctx, "copy"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for the old name, got %v", err)
+	}
+	if err := s.SaveWorkflow(ctx, &Workflow{ID: "reuse", Name: "copy", Definition: "x"}); err != nil {
+		t.Errorf("expected the old name to be free again: %v", err)
+	}
+}
+
+func TestMemoryStore_TxRollbackRestoresEverything(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	if err := s.SaveWorkflow(ctx, &Workflow{ID: "kept", Name: "kept", Definition: "v1"}); err != nil {
+		t.Fatalf("SaveWorkflow failed: %v", err)
+	}
+	if err := s.SaveRun(ctx, &WorkflowRun{ID: "run", WorkflowID: "kept", Version: 1, Status: "running"}); err != nil {
+		t.Fatalf("SaveRun failed: %v", err)
+	}
+
+	failed := errors.New("failed")
+	err := s.WithTx(ctx, func(tx Tx) error {
+		if err := tx.SaveWorkflow(ctx, &Workflow{ID: "dropped", Name: "dropped", Definition: "v1"}); err != nil {
+			return err
+		}
+		if err := tx.UpdateWorkflow(ctx, &Workflow{ID: "kept", Name: "renamed", Definition: "v2"}); err != nil {
+			return err
+		}
+		if err := tx.UpdateRun(ctx, &WorkflowRun{ID: "run", WorkflowID: "kept", Version: 1, Status: "success"}); err != nil {
+			return err
+		}
+		if err := tx.SaveRun(ctx, &WorkflowRun{ID: "new-run", WorkflowID: "kept", Version: 2, Status: "running"}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expected WithTx to return the callback's error, got %v", err)
+	}
+
+	if _, err := s.GetWorkflowByName(ctx, "kept"); err != nil {
+		t.Errorf("old name was not restored: %v", err)
+	}
+	if _, err := s.GetWorkflowByName(ctx, "renamed"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for the new name, got %v", err)
+	}
+	workflows, err := s.ListWorkflows(ctx)
+	if err != nil {
+		t.Fatalf("ListWorkflows failed: %v", err)
+	}
+	if len(workflows) != 1 || workflows[0].ID != "kept" {
+		t.Errorf("expected only the kept workflow, got %d", len(workflows))
+	}
+	run, err := s.GetRun(ctx, "run")
+	if err != nil {
+		t.Fatalf("GetRun failed: %v", err)
+	}
+	if run.Status != "running" {
+		t.Errorf("run update was kept after rollback: %s", run.Status)
+	}
+	if _, err := s.GetRun(ctx, "new-run"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("run saved in a rolled back transaction was kept: %v", err)
+	}
+
+	// The rolled back workflow's ID is free again.
+	if err := s.SaveWorkflow(ctx, &Workflow{ID: "dropped", Name: "dropped", Definition: "v1"}); err != nil {
+		t.Errorf("SaveWorkflow after rollback failed: %v", err)
+	}
+}
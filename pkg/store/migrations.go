@@ -0,0 +1,371 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Migration is a single, ordered schema change. Versions must be unique and
+// are applied in ascending order; a migration is never edited once released,
+// further changes get a new version instead.
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// MigrationStatus reports whether a known migration has been applied.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// sqliteMigrations is the schema history of SQLiteStore. Version 1 matches
+// the table created by releases before migrations existed, so existing
+// workflows.db files are picked up and upgraded in place.
+var sqliteMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_workflows",
+		SQL: `CREATE TABLE IF NOT EXISTS workflows (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	definition TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);`,
+	},
+	{
+		Version: 2,
+		Name:    "create_workflow_versions",
+		SQL: `ALTER TABLE workflows ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+CREATE TABLE workflow_versions (
+	workflow_id TEXT NOT NULL REFERENCES workflows(id),
+	version INTEGER NOT NULL,
+	definition TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (workflow_id, version)
+);
+INSERT INTO workflow_versions(workflow_id, version, definition, created_at)
+	SELECT id, 1, definition, created_at FROM workflows;`,
+	},
+	{
+		Version: 3,
+		Name:    "create_workflow_runs",
+		SQL: `CREATE TABLE workflow_runs (
+	id TEXT PRIMARY KEY,
+	workflow_id TEXT NOT NULL REFERENCES workflows(id),
+	version INTEGER NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT NOT NULL DEFAULT '',
+	started_at DATETIME NOT NULL,
+	finished_at DATETIME
+);
+CREATE INDEX workflow_runs_workflow_id ON workflow_runs(workflow_id, started_at);`,
+	},
+	{
+		Version: 4,
+		Name:    "add_run_node_statuses",
+		SQL:     `ALTER TABLE workflow_runs ADD COLUMN node_statuses TEXT NOT NULL DEFAULT '{}';`,
+	},
+	{
+		Version: 5,
+		Name:    "add_run_trace",
+		SQL:     `ALTER TABLE workflow_runs ADD COLUMN trace TEXT NOT NULL DEFAULT '[]';`,
+	},
+	{
+		// created_at only has whole seconds, so workflows are listed by a
+		// sequence number instead. SQLite cannot add an autoincrement
+		// column, so the table is rebuilt with one.
+		Version: 6,
+		Name:    "add_workflow_seq",
+		SQL: `CREATE TABLE workflows_seq (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	id TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL UNIQUE,
+	definition TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	version INTEGER NOT NULL DEFAULT 1
+);
+INSERT INTO workflows_seq(id, name, definition, created_at, version)
+	SELECT id, name, definition, created_at, version FROM workflows ORDER BY created_at, rowid;
+DROP TABLE workflows;
+ALTER TABLE workflows_seq RENAME TO workflows;`,
+	},
+}
+
+// postgresMigrations is the schema history of PostgresStore. It must list
+// the same versions and names as sqliteMigrations so every backend goes
+// through the same schema changes.
+var postgresMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_workflows",
+		SQL: `CREATE TABLE IF NOT EXISTS workflows (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	definition TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);`,
+	},
+	{
+		Version: 2,
+		Name:    "create_workflow_versions",
+		SQL: `ALTER TABLE workflows ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+CREATE TABLE workflow_versions (
+	workflow_id TEXT NOT NULL REFERENCES workflows(id),
+	version INTEGER NOT NULL,
+	definition TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (workflow_id, version)
+);
+INSERT INTO workflow_versions(workflow_id, version, definition, created_at)
+	SELECT id, 1, definition, created_at FROM workflows;`,
+	},
+	{
+		Version: 3,
+		Name:    "create_workflow_runs",
+		SQL: `CREATE TABLE workflow_runs (
+	id TEXT PRIMARY KEY,
+	workflow_id TEXT NOT NULL REFERENCES workflows(id),
+	version INTEGER NOT NULL,
+	status TEXT NOT NULL,
+	error TEXT NOT NULL DEFAULT '',
+	started_at TIMESTAMPTZ NOT NULL,
+	finished_at TIMESTAMPTZ
+);
+CREATE INDEX workflow_runs_workflow_id ON workflow_runs(workflow_id, started_at);`,
+	},
+	{
+		Version: 4,
+		Name:    "add_run_node_statuses",
+		SQL:     `ALTER TABLE workflow_runs ADD COLUMN node_statuses TEXT NOT NULL DEFAULT '{}';`,
+	},
+	{
+		Version: 5,
+		Name:    "add_run_trace",
+		SQL:     `ALTER TABLE workflow_runs ADD COLUMN trace TEXT NOT NULL DEFAULT '[]';`,
+	},
+	{
+		// Existing workflows are numbered in the order they were created
+		// before the column becomes a BIGSERIAL.
+		Version: 6,
+		Name:    "add_workflow_seq",
+		SQL: `ALTER TABLE workflows ADD COLUMN seq BIGINT;
+UPDATE workflows SET seq = numbered.n
+	FROM (SELECT id, row_number() OVER (ORDER BY created_at, id) AS n FROM workflows) numbered
+	WHERE workflows.id = numbered.id;
+CREATE SEQUENCE workflows_seq_seq OWNED BY workflows.seq;
+SELECT setval('workflows_seq_seq', COALESCE((SELECT MAX(seq) FROM workflows), 0) + 1, false);
+ALTER TABLE workflows ALTER COLUMN seq SET DEFAULT nextval('workflows_seq_seq'), ALTER COLUMN seq SET NOT NULL;
+CREATE UNIQUE INDEX workflows_seq ON workflows(seq);`,
+	},
+}
+
+// MigrationsFor returns the migrations applied by the store of a dialect.
+func MigrationsFor(dialect Dialect) []Migration {
+	if dialect == DialectPostgres {
+		return append([]Migration(nil), postgresMigrations...)
+	}
+	return append([]Migration(nil), sqliteMigrations...)
+}
+
+// Migrator applies migrations to a database and records them in the
+// schema_migrations table.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	migrations []Migration
+}
+
+// NewMigrator creates a Migrator for a database of the given dialect.
+// Migrations are sorted by version.
+func NewMigrator(db *sql.DB, dialect Dialect, migrations []Migration) *Migrator {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return &Migrator{db: db, dialect: dialect, migrations: sorted}
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// tableExists reports whether the schema_migrations table exists, without
+// creating it.
+func (m *Migrator) tableExists(ctx context.Context) (bool, error) {
+	query := "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'"
+	if m.dialect == DialectPostgres {
+		query = "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = 'schema_migrations'"
+	}
+	var count int
+	if err := m.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return false, fmt.Errorf("failed to look up schema_migrations table: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (m *Migrator) validate() error {
+	for i, migration := range m.migrations {
+		if migration.Version <= 0 {
+			return fmt.Errorf("migration %q has invalid version %d", migration.Name, migration.Version)
+		}
+		if i > 0 && m.migrations[i-1].Version == migration.Version {
+			return fmt.Errorf("duplicate migration version %d", migration.Version)
+		}
+	}
+	return nil
+}
+
+// applied returns when each applied migration was applied. A database
+// without a schema_migrations table has none.
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	exists, err := m.tableExists(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return map[int]time.Time{}, nil
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations row: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Status lists every known migration and whether it has been applied. It
+// fails if the database has migrations this binary does not know about,
+// which means it was migrated by a newer release. It only reads the
+// database.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[int]bool{}
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, MigrationStatus{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+	}
+	for version := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("database has unknown migration version %d; was it migrated by a newer release?", version)
+		}
+	}
+	return statuses, nil
+}
+
+// Pending lists the migrations that Up would apply, in order. Like Status,
+// it only reads the database.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, status.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies all pending migrations in a single transaction and returns the
+// ones applied. If any migration fails, none of them are recorded. On
+// Postgres the transaction holds an advisory lock, so replicas starting at
+// the same time apply each migration only once.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if m.dialect == DialectPostgres {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+			return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		// Another replica may have migrated while we waited for the lock.
+		pending, err = pendingInTx(ctx, tx, m.dialect, pending)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, migration := range pending {
+		if _, err := tx.ExecContext(ctx, migration.SQL); err != nil {
+			return nil, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+		_, err := tx.ExecContext(ctx, m.dialect.Rebind("INSERT INTO schema_migrations(version, name, applied_at) VALUES(?, ?, ?)"),
+			migration.Version, migration.Name, time.Now().UTC())
+		if err != nil {
+			return nil, fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit migrations: %w", err)
+	}
+
+	return pending, nil
+}
+
+// migrationLockID is the Postgres advisory lock key serializing migrations.
+const migrationLockID = 7482951
+
+func pendingInTx(ctx context.Context, tx *sql.Tx, dialect Dialect, candidates []Migration) ([]Migration, error) {
+	var pending []Migration
+	for _, migration := range candidates {
+		var applied int
+		err := tx.QueryRowContext(ctx, dialect.Rebind("SELECT COUNT(*) FROM schema_migrations WHERE version = ?"), migration.Version).Scan(&applied)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query schema_migrations: %w", err)
+		}
+		if applied == 0 {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+)
+
+func openTestDB(t *testing.T, dbPath string) *sql.DB {
+	t.Helper()
+	os.Remove(dbPath)
+	t.Cleanup(func() { os.Remove(dbPath) })
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMigrator_Up(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, "test_migrations.db")
+	migrator := NewMigrator(db, DialectSQLite, MigrationsFor(DialectSQLite))
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	if len(applied) != len(sqliteMigrations) {
+		t.Errorf("expected %d migrations applied, got %d", len(sqliteMigrations), len(applied))
+	}
+
+	// Running again is a no-op.
+	applied, err = migrator.Up(ctx)
+	if err != nil {
+		t.Fatalf("second Up failed: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("expected no migrations on second run, got %d", len(applied))
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	for _, s := range statuses {
+		if !s.Applied || s.AppliedAt.IsZero() {
+			t.Errorf("expected migration %d to be applied, got %+v", s.Version, s)
+		}
+	}
+}
+
+func TestMigrator_StatusIsReadOnly(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, "test_status_migrations.db")
+	migrator := NewMigrator(db, DialectSQLite, MigrationsFor(DialectSQLite))
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	for _, s := range statuses {
+		if s.Applied {
+			t.Errorf("expected migration %d to be pending, got %+v", s.Version, s)
+		}
+	}
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		t.Fatalf("Pending failed: %v", err)
+	}
+	if len(pending) != len(sqliteMigrations) {
+		t.Errorf("expected %d pending migrations, got %d", len(sqliteMigrations), len(pending))
+	}
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Errorf("expected Status and Pending not to create tables, got %d", tables)
+	}
+}
+
+func TestMigrator_UpgradesLegacyDatabase(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "test_legacy_workflows.db"
+	db := openTestDB(t, dbPath)
+
+	// The schema created by releases before migrations existed.
+	_, err := db.Exec(`CREATE TABLE workflows (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		definition TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO workflows(id, name, definition) VALUES('legacy', 'legacy_workflow', 'legacy_definition');`)
+	if err != nil {
+		t.Fatalf("failed to create legacy schema: %v", err)
+	}
+
+	store := NewSQLiteStore(dbPath)
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("Init failed on legacy database: %v", err)
+	}
+
+	workflow, err := store.GetWorkflow(ctx, "legacy")
+	if err != nil {
+		t.Fatalf("GetWorkflow failed: %v", err)
+	}
+	if workflow.Version != 1 || workflow.Definition != "legacy_definition" {
+		t.Errorf("unexpected legacy workflow after migration: %+v", workflow)
+	}
+
+	versions, err := store.ListVersions(ctx, "legacy")
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != 1 || versions[0].Definition != "legacy_definition" {
+		t.Errorf("expected legacy definition to be backfilled as version 1, got %+v", versions)
+	}
+}
+
+func TestMigrator_FailureRollsBack(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, "test_failed_migrations.db")
+	migrator := NewMigrator(db, DialectSQLite, []Migration{
+		{Version: 1, Name: "create_a", SQL: "CREATE TABLE a (id INTEGER)"},
+		{Version: 2, Name: "broken", SQL: "CREATE TABLE"},
+	})
+
+	if _, err := migrator.Up(ctx); err == nil {
+		t.Fatal("expected an error from a broken migration, but got nil")
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		t.Fatalf("Pending failed: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Errorf("expected both migrations to remain pending, got %d", len(pending))
+	}
+	var name string
+	if err := db.QueryRow("SELECT name FROM sqlite_master WHERE name = 'a'").Scan(&name); err != sql.ErrNoRows {
+		t.Errorf("expected table a to be rolled back, got %v", err)
+	}
+}
+
+func TestMigrator_InvalidMigrations(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t, "test_invalid_migrations.db")
+
+	duplicate := NewMigrator(db, DialectSQLite, []Migration{
+		{Version: 1, Name: "one", SQL: "SELECT 1"},
+		{Version: 1, Name: "also_one", SQL: "SELECT 1"},
+	})
+	if _, err := duplicate.Up(ctx); err == nil {
+		t.Error("expected an error for duplicate versions, but got nil")
+	}
+
+	if _, err := NewMigrator(db, DialectSQLite, []Migration{{Version: 1, Name: "one", SQL: "SELECT 1"}, {Version: 2, Name: "two", SQL: "SELECT 1"}}).Up(ctx); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	older := NewMigrator(db, DialectSQLite, []Migration{{Version: 1, Name: "one", SQL: "SELECT 1"}})
+	if _, err := older.Status(ctx); err == nil {
+		t.Error("expected an error for a database migrated by a newer release, but got nil")
+	}
+}
+
+func TestMigrationsMatchAcrossDialects(t *testing.T) {
+	sqlite := MigrationsFor(DialectSQLite)
+	postgres := MigrationsFor(DialectPostgres)
+	if len(sqlite) != len(postgres) {
+		t.Fatalf("expected the same number of migrations, got %d for SQLite and %d for Postgres", len(sqlite), len(postgres))
+	}
+	for i := range sqlite {
+		if sqlite[i].Version != postgres[i].Version || sqlite[i].Name != postgres[i].Name {
+			t.Errorf("migration %d differs: SQLite %d %s, Postgres %d %s", i, sqlite[i].Version, sqlite[i].Name, postgres[i].Version, postgres[i].Name)
+		}
+	}
+}
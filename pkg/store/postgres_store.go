@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// PostgresOptions configures the connection pool and query deadline of a
+// PostgresStore.
+type PostgresOptions struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	QueryTimeout    time.Duration
+}
+
+// DefaultPostgresOptions are used for any option left at zero.
+var DefaultPostgresOptions = PostgresOptions{
+	MaxOpenConns:    10,
+	MaxIdleConns:    5,
+	ConnMaxLifetime: 30 * time.Minute,
+	QueryTimeout:    30 * time.Second,
+}
+
+// PostgresStore implements WorkflowStore for PostgreSQL, so that several API
+// server replicas can share one database.
+type PostgresStore struct {
+	sqlStore
+	dsn     string
+	options PostgresOptions
+}
+
+// NewPostgresStore creates a new PostgresStore for a postgres:// DSN. The
+// pool can be tuned through the DSN query parameters pool_max_conns,
+// pool_max_idle_conns, pool_max_conn_lifetime and query_timeout (durations
+// in time.ParseDuration form); they are removed before connecting.
+func NewPostgresStore(dsn string) (*PostgresStore, error) {
+	dsn, options, err := parsePostgresDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return NewPostgresStoreWithOptions(dsn, options), nil
+}
+
+// NewPostgresStoreWithOptions creates a new PostgresStore with explicit pool options.
+func NewPostgresStoreWithOptions(dsn string, options PostgresOptions) *PostgresStore {
+	if options.MaxOpenConns == 0 {
+		options.MaxOpenConns = DefaultPostgresOptions.MaxOpenConns
+	}
+	if options.MaxIdleConns == 0 {
+		options.MaxIdleConns = DefaultPostgresOptions.MaxIdleConns
+	}
+	if options.ConnMaxLifetime == 0 {
+		options.ConnMaxLifetime = DefaultPostgresOptions.ConnMaxLifetime
+	}
+	if options.QueryTimeout == 0 {
+		options.QueryTimeout = DefaultPostgresOptions.QueryTimeout
+	}
+	return &PostgresStore{
+		sqlStore: sqlStore{dialect: DialectPostgres, queryTimeout: options.QueryTimeout},
+		dsn:      dsn,
+		options:  options,
+	}
+}
+
+// Init connects to the database and applies any pending schema migrations.
+func (s *PostgresStore) Init(ctx context.Context) error {
+	var err error
+	s.db, err = sql.Open("postgres", s.dsn)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	s.db.SetMaxOpenConns(s.options.MaxOpenConns)
+	s.db.SetMaxIdleConns(s.options.MaxIdleConns)
+	s.db.SetConnMaxLifetime(s.options.ConnMaxLifetime)
+
+	pingCtx, cancel := s.context(ctx)
+	defer cancel()
+	if err := s.db.PingContext(pingCtx); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if _, err := NewMigrator(s.db, DialectPostgres, MigrationsFor(DialectPostgres)).Up(ctx); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	return nil
+}
+
+func parsePostgresDSN(dsn string) (string, PostgresOptions, error) {
+	var options PostgresOptions
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "", options, fmt.Errorf("invalid postgres DSN: %w", err)
+	}
+
+	query := u.Query()
+	intParam := func(name string, dst *int) error {
+		if v := query.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %w", name, v, err)
+			}
+			*dst = n
+		}
+		query.Del(name)
+		return nil
+	}
+	durationParam := func(name string, dst *time.Duration) error {
+		if v := query.Get(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %w", name, v, err)
+			}
+			*dst = d
+		}
+		query.Del(name)
+		return nil
+	}
+	if err := intParam("pool_max_conns", &options.MaxOpenConns); err != nil {
+		return "", options, err
+	}
+	if err := intParam("pool_max_idle_conns", &options.MaxIdleConns); err != nil {
+		return "", options, err
+	}
+	if err := durationParam("pool_max_conn_lifetime", &options.ConnMaxLifetime); err != nil {
+		return "", options, err
+	}
+	if err := durationParam("query_timeout", &options.QueryTimeout); err != nil {
+		return "", options, err
+	}
+
+	u.RawQuery = query.Encode()
+	return u.String(), options, nil
+}
@@ -0,0 +1,440 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// querier is the subset of *sql.DB and *sql.Tx the store's queries use.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// sqlStore implements WorkflowStore on top of database/sql. Queries are
+// written with "?" placeholders and rebound for the store's dialect.
+type sqlStore struct {
+	db           *sql.DB
+	tx           *sql.Tx // set on the store handed to a WithTx callback
+	dialect      Dialect
+	queryTimeout time.Duration
+}
+
+// q rebinds a query for the store's dialect.
+func (s *sqlStore) q(query string) string {
+	return s.dialect.Rebind(query)
+}
+
+// conn returns the transaction the store is bound to, or the database.
+func (s *sqlStore) conn() querier {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+// context returns the context a single store operation runs under, bounded
+// by the store's query timeout if one is set.
+func (s *sqlStore) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.queryTimeout > 0 {
+		return context.WithTimeout(ctx, s.queryTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// inTx runs fn in the store's transaction if it is bound to one, or in a new
+// transaction that is committed if fn succeeds.
+func (s *sqlStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// WithTx runs fn in a database transaction. The transaction is committed if
+// fn returns nil and rolled back otherwise.
+func (s *sqlStore) WithTx(ctx context.Context, fn func(tx Tx) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(&sqlStore{db: s.db, tx: tx, dialect: s.dialect, queryTimeout: s.queryTimeout}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying database.
+func (s *sqlStore) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// SaveWorkflow saves a new workflow definition to the database as version 1.
+func (s *sqlStore) SaveWorkflow(ctx context.Context, workflow *Workflow) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, s.q("INSERT INTO workflows(id, name, definition, version) VALUES(?, ?, ?, 1)"), workflow.ID, workflow.Name, workflow.Definition)
+		if err != nil {
+			return wrapWriteError("failed to save workflow", err)
+		}
+		_, err = tx.ExecContext(ctx, s.q("INSERT INTO workflow_versions(workflow_id, version, definition) VALUES(?, 1, ?)"), workflow.ID, workflow.Definition)
+		if err != nil {
+			return wrapWriteError("failed to record workflow version", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	workflow.Version = 1
+	return nil
+}
+
+// UpdateWorkflow records workflow.Definition as a new version and makes it the
+// active one. workflow.Version is set to the new version number.
+func (s *sqlStore) UpdateWorkflow(ctx context.Context, workflow *Workflow) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	var next int
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var latest int
+		err := tx.QueryRowContext(ctx, s.q("SELECT COALESCE(MAX(version), 0) FROM workflow_versions WHERE workflow_id = ?"), workflow.ID).Scan(&latest)
+		if err != nil {
+			return fmt.Errorf("failed to query latest version: %w", err)
+		}
+		if latest == 0 {
+			return notFound("workflow %s", workflow.ID)
+		}
+		next = latest + 1
+
+		_, err = tx.ExecContext(ctx, s.q("INSERT INTO workflow_versions(workflow_id, version, definition) VALUES(?, ?, ?)"), workflow.ID, next, workflow.Definition)
+		if err != nil {
+			return wrapWriteError("failed to record workflow version", err)
+		}
+		_, err = tx.ExecContext(ctx, s.q("UPDATE workflows SET name = ?, definition = ?, version = ? WHERE id = ?"), workflow.Name, workflow.Definition, next, workflow.ID)
+		if err != nil {
+			return wrapWriteError("failed to update workflow", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	workflow.Version = next
+	return nil
+}
+
+// GetWorkflow retrieves a workflow definition by ID.
+func (s *sqlStore) GetWorkflow(ctx context.Context, id string) (*Workflow, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	what := fmt.Sprintf("workflow %s", id)
+	row := s.conn().QueryRowContext(ctx, s.q("SELECT id, name, definition, version, created_at FROM workflows WHERE id = ?"), id)
+
+	workflow := &Workflow{}
+	err := row.Scan(&workflow.ID, &workflow.Name, &workflow.Definition, &workflow.Version, &workflow.CreatedAt)
+	if err != nil {
+		return nil, wrapLookupError(what, err)
+	}
+
+	return workflow, nil
+}
+
+// GetWorkflowByName retrieves a workflow definition by name.
+func (s *sqlStore) GetWorkflowByName(ctx context.Context, name string) (*Workflow, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	what := fmt.Sprintf("workflow named %q", name)
+	row := s.conn().QueryRowContext(ctx, s.q("SELECT id, name, definition, version, created_at FROM workflows WHERE name = ?"), name)
+
+	workflow := &Workflow{}
+	err := row.Scan(&workflow.ID, &workflow.Name, &workflow.Definition, &workflow.Version, &workflow.CreatedAt)
+	if err != nil {
+		return nil, wrapLookupError(what, err)
+	}
+
+	return workflow, nil
+}
+
+// ListWorkflows lists all stored workflow definitions in the order they were
+// saved.
+func (s *sqlStore) ListWorkflows(ctx context.Context) ([]*Workflow, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	rows, err := s.conn().QueryContext(ctx, s.q("SELECT id, name, definition, version, created_at FROM workflows ORDER BY seq"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workflows: %w", err)
+	}
+	defer rows.Close()
+
+	var workflows []*Workflow
+	for rows.Next() {
+		workflow := &Workflow{}
+		err := rows.Scan(&workflow.ID, &workflow.Name, &workflow.Definition, &workflow.Version, &workflow.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan workflow row: %w", err)
+		}
+		workflows = append(workflows, workflow)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read workflows: %w", err)
+	}
+
+	return workflows, nil
+}
+
+// ListVersions lists every recorded version of a workflow, oldest first.
+func (s *sqlStore) ListVersions(ctx context.Context, workflowID string) ([]*WorkflowVersion, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	rows, err := s.conn().QueryContext(ctx, s.q("SELECT workflow_id, version, definition, created_at FROM workflow_versions WHERE workflow_id = ? ORDER BY version"), workflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workflow versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []*WorkflowVersion
+	for rows.Next() {
+		version := &WorkflowVersion{}
+		err := rows.Scan(&version.WorkflowID, &version.Version, &version.Definition, &version.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan workflow version row: %w", err)
+		}
+		versions = append(versions, version)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read workflow versions: %w", err)
+	}
+
+	return versions, nil
+}
+
+// GetVersion retrieves a single version of a workflow.
+func (s *sqlStore) GetVersion(ctx context.Context, workflowID string, version int) (*WorkflowVersion, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	row := s.conn().QueryRowContext(ctx, s.q("SELECT workflow_id, version, definition, created_at FROM workflow_versions WHERE workflow_id = ? AND version = ?"), workflowID, version)
+
+	v := &WorkflowVersion{}
+	err := row.Scan(&v.WorkflowID, &v.Version, &v.Definition, &v.CreatedAt)
+	if err != nil {
+		return nil, wrapLookupError(fmt.Sprintf("workflow %s version %d", workflowID, version), err)
+	}
+
+	return v, nil
+}
+
+// ActivateVersion makes an existing version the live definition of a workflow,
+// e.g. to roll back a bad change. No new version is recorded.
+func (s *sqlStore) ActivateVersion(ctx context.Context, workflowID string, version int) (*Workflow, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	workflow := &Workflow{}
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		var definition string
+		err := tx.QueryRowContext(ctx, s.q("SELECT definition FROM workflow_versions WHERE workflow_id = ? AND version = ?"), workflowID, version).Scan(&definition)
+		if err != nil {
+			return wrapLookupError(fmt.Sprintf("workflow %s version %d", workflowID, version), err)
+		}
+
+		_, err = tx.ExecContext(ctx, s.q("UPDATE workflows SET definition = ?, version = ? WHERE id = ?"), definition, version, workflowID)
+		if err != nil {
+			return fmt.Errorf("failed to activate workflow version: %w", err)
+		}
+
+		row := tx.QueryRowContext(ctx, s.q("SELECT id, name, definition, version, created_at FROM workflows WHERE id = ?"), workflowID)
+		err = row.Scan(&workflow.ID, &workflow.Name, &workflow.Definition, &workflow.Version, &workflow.CreatedAt)
+		if err != nil {
+			return wrapLookupError(fmt.Sprintf("workflow %s", workflowID), err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return workflow, nil
+}
+
+// SaveRun records the start of a workflow run.
+func (s *sqlStore) SaveRun(ctx context.Context, run *WorkflowRun) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	nodeStatuses, err := encodeNodeStatuses(run.NodeStatuses)
+	if err != nil {
+		return err
+	}
+	trace, err := encodeTrace(run.Trace)
+	if err != nil {
+		return err
+	}
+	_, err = s.conn().ExecContext(ctx, s.q("INSERT INTO workflow_runs(id, workflow_id, version, status, error, started_at, finished_at, node_statuses, trace) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)"),
+		run.ID, run.WorkflowID, run.Version, run.Status, run.Error, run.StartedAt, run.FinishedAt, nodeStatuses, trace)
+	if err != nil {
+		return wrapWriteError("failed to save workflow run", err)
+	}
+
+	return nil
+}
+
+// UpdateRun updates the status, error, finish time, node statuses and trace
+// of a workflow run.
+func (s *sqlStore) UpdateRun(ctx context.Context, run *WorkflowRun) error {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	nodeStatuses, err := encodeNodeStatuses(run.NodeStatuses)
+	if err != nil {
+		return err
+	}
+	trace, err := encodeTrace(run.Trace)
+	if err != nil {
+		return err
+	}
+	res, err := s.conn().ExecContext(ctx, s.q("UPDATE workflow_runs SET status = ?, error = ?, finished_at = ?, node_statuses = ?, trace = ? WHERE id = ?"),
+		run.Status, run.Error, run.FinishedAt, nodeStatuses, trace, run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update workflow run: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return notFound("workflow run %s", run.ID)
+	}
+
+	return nil
+}
+
+// GetRun retrieves a workflow run by ID.
+func (s *sqlStore) GetRun(ctx context.Context, id string) (*WorkflowRun, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	row := s.conn().QueryRowContext(ctx, s.q("SELECT id, workflow_id, version, status, error, started_at, finished_at, node_statuses, trace FROM workflow_runs WHERE id = ?"), id)
+
+	run, err := scanRun(row)
+	if err != nil {
+		return nil, wrapLookupError(fmt.Sprintf("workflow run %s", id), err)
+	}
+
+	return run, nil
+}
+
+// ListRuns lists the runs of a workflow, most recent first.
+func (s *sqlStore) ListRuns(ctx context.Context, workflowID string) ([]*WorkflowRun, error) {
+	ctx, cancel := s.context(ctx)
+	defer cancel()
+
+	rows, err := s.conn().QueryContext(ctx, s.q("SELECT id, workflow_id, version, status, error, started_at, finished_at, node_statuses, trace FROM workflow_runs WHERE workflow_id = ? ORDER BY started_at DESC"), workflowID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query workflow runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*WorkflowRun
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan workflow run row: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read workflow runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRun(row rowScanner) (*WorkflowRun, error) {
+	run := &WorkflowRun{}
+	var finishedAt sql.NullTime
+	var nodeStatuses, trace string
+	err := row.Scan(&run.ID, &run.WorkflowID, &run.Version, &run.Status, &run.Error, &run.StartedAt, &finishedAt, &nodeStatuses, &trace)
+	if err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	if err := json.Unmarshal([]byte(nodeStatuses), &run.NodeStatuses); err != nil {
+		return nil, fmt.Errorf("invalid node statuses of workflow run %s: %w", run.ID, err)
+	}
+	if len(run.NodeStatuses) == 0 {
+		run.NodeStatuses = nil
+	}
+	if err := json.Unmarshal([]byte(trace), &run.Trace); err != nil {
+		return nil, fmt.Errorf("invalid trace of workflow run %s: %w", run.ID, err)
+	}
+	if len(run.Trace) == 0 {
+		run.Trace = nil
+	}
+	return run, nil
+}
+
+// encodeNodeStatuses encodes node statuses for the node_statuses column.
+func encodeNodeStatuses(statuses map[string]string) (string, error) {
+	if statuses == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(statuses)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode node statuses: %w", err)
+	}
+	return string(data), nil
+}
+
+// encodeTrace encodes the steps of a run for the trace column.
+func encodeTrace(trace []TraceStep) (string, error) {
+	if trace == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(trace)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode run trace: %w", err)
+	}
+	return string(data), nil
+}
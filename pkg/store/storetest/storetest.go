@@ -0,0 +1,347 @@
+// Package storetest is a conformance suite for store.WorkflowStore
+// implementations. Every backend runs the same suite so they behave alike.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-workflow/pkg/store"
+)
+
+// Factory returns a new, initialized and empty store for a single test.
+type Factory func(t *testing.T) store.WorkflowStore
+
+// Run runs the conformance suite against the stores created by newStore.
+func Run(t *testing.T, newStore Factory) {
+	t.Run("SaveAndGet", func(t *testing.T) { testSaveAndGet(t, newStore(t)) })
+	t.Run("DuplicateName", func(t *testing.T) { testDuplicateName(t, newStore(t)) })
+	t.Run("NotFound", func(t *testing.T) { testNotFound(t, newStore(t)) })
+	t.Run("List", func(t *testing.T) { testList(t, newStore(t)) })
+	t.Run("Versions", func(t *testing.T) { testVersions(t, newStore(t)) })
+	t.Run("Runs", func(t *testing.T) { testRuns(t, newStore(t)) })
+	t.Run("TxCommit", func(t *testing.T) { testTxCommit(t, newStore(t)) })
+	t.Run("TxRollback", func(t *testing.T) { testTxRollback(t, newStore(t)) })
+	t.Run("CanceledContext", func(t *testing.T) { testCanceledContext(t, newStore(t)) })
+}
+
+func newWorkflow(name string) *store.Workflow {
+	return &store.Workflow{
+		ID:         fmt.Sprintf("%s-id", name),
+		Name:       name,
+		Definition: fmt.Sprintf("definition of %s", name),
+	}
+}
+
+func testSaveAndGet(t *testing.T, s store.WorkflowStore) {
+	ctx := context.Background()
+	wf := newWorkflow("save_and_get")
+	if err := s.SaveWorkflow(ctx, wf); err != nil {
+		t.Fatalf("SaveWorkflow failed: %v", err)
+	}
+	if wf.Version != 1 {
+		t.Errorf("expected version 1 after save, got %d", wf.Version)
+	}
+
+	got, err := s.GetWorkflow(ctx, wf.ID)
+	if err != nil {
+		t.Fatalf("GetWorkflow failed: %v", err)
+	}
+	if got.ID != wf.ID || got.Name != wf.Name || got.Definition != wf.Definition || got.Version != 1 {
+		t.Errorf("GetWorkflow mismatch: expected %+v, got %+v", wf, got)
+	}
+	if got.CreatedAt.IsZero() {
+		t.Error("expected CreatedAt to be set")
+	}
+
+	got, err = s.GetWorkflowByName(ctx, wf.Name)
+	if err != nil {
+		t.Fatalf("GetWorkflowByName failed: %v", err)
+	}
+	if got.ID != wf.ID {
+		t.Errorf("GetWorkflowByName mismatch: expected %s, got %s", wf.ID, got.ID)
+	}
+}
+
+func testDuplicateName(t *testing.T, s store.WorkflowStore) {
+	ctx := context.Background()
+	if err := s.SaveWorkflow(ctx, newWorkflow("duplicate")); err != nil {
+		t.Fatalf("SaveWorkflow failed: %v", err)
+	}
+	dup := newWorkflow("duplicate")
+	dup.ID = "another-id"
+	if err := s.SaveWorkflow(ctx, dup); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("expected ErrConflict saving a duplicate name, got %v", err)
+	}
+	if _, err := s.GetWorkflow(ctx, dup.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Error("workflow with duplicate name was stored")
+	}
+
+	other := newWorkflow("duplicate_other")
+	if err := s.SaveWorkflow(ctx, other); err != nil {
+		t.Fatalf("SaveWorkflow failed: %v", err)
+	}
+	other.Name = "duplicate"
+	if err := s.UpdateWorkflow(ctx, other); !errors.Is(err, store.ErrConflict) {
+		t.Errorf("expected ErrConflict renaming onto an existing name, got %v", err)
+	}
+}
+
+func testNotFound(t *testing.T, s store.WorkflowStore) {
+	ctx := context.Background()
+	if _, err := s.GetWorkflow(ctx, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from GetWorkflow for unknown ID, got %v", err)
+	}
+	if _, err := s.GetWorkflowByName(ctx, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from GetWorkflowByName for unknown name, got %v", err)
+	}
+	if _, err := s.GetVersion(ctx, "missing", 1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from GetVersion for unknown workflow, got %v", err)
+	}
+	if _, err := s.ActivateVersion(ctx, "missing", 1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from ActivateVersion for unknown workflow, got %v", err)
+	}
+	if _, err := s.GetRun(ctx, "missing"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from GetRun for unknown ID, got %v", err)
+	}
+	if err := s.UpdateWorkflow(ctx, newWorkflow("missing")); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from UpdateWorkflow for unknown workflow, got %v", err)
+	}
+	if err := s.UpdateRun(ctx, &store.WorkflowRun{ID: "missing", Status: store.RunStatusFailed}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound from UpdateRun for unknown run, got %v", err)
+	}
+}
+
+func testList(t *testing.T, s store.WorkflowStore) {
+	ctx := context.Background()
+	// Saved within the same second, which is all created_at records, with
+	// names and IDs in no particular order: the list must still follow the
+	// order they were saved in.
+	saved := []*store.Workflow{
+		{ID: "7f3a-id", Name: "list_m", Definition: "definition of list_m"},
+		{ID: "0c19-id", Name: "list_z", Definition: "definition of list_z"},
+		{ID: "e2b8-id", Name: "list_a", Definition: "definition of list_a"},
+		{ID: "5d40-id", Name: "list_q", Definition: "definition of list_q"},
+	}
+	for _, wf := range saved {
+		if err := s.SaveWorkflow(ctx, wf); err != nil {
+			t.Fatalf("SaveWorkflow failed: %v", err)
+		}
+	}
+
+	workflows, err := s.ListWorkflows(ctx)
+	if err != nil {
+		t.Fatalf("ListWorkflows failed: %v", err)
+	}
+	if len(workflows) != len(saved) {
+		t.Fatalf("expected %d workflows, got %d", len(saved), len(workflows))
+	}
+	for i, workflow := range workflows {
+		if workflow.ID != saved[i].ID {
+			t.Errorf("expected workflow %d to be %s, in the order they were saved, got %s", i, saved[i].ID, workflow.ID)
+		}
+	}
+}
+
+func testVersions(t *testing.T, s store.WorkflowStore) {
+	ctx := context.Background()
+	wf := newWorkflow("versions")
+	if err := s.SaveWorkflow(ctx, wf); err != nil {
+		t.Fatalf("SaveWorkflow failed: %v", err)
+	}
+	wf.Definition = "second definition"
+	if err := s.UpdateWorkflow(ctx, wf); err != nil {
+		t.Fatalf("UpdateWorkflow failed: %v", err)
+	}
+	wf.Definition = "third definition"
+	if err := s.UpdateWorkflow(ctx, wf); err != nil {
+		t.Fatalf("UpdateWorkflow failed: %v", err)
+	}
+	if wf.Version != 3 {
+		t.Errorf("expected version 3, got %d", wf.Version)
+	}
+
+	versions, err := s.ListVersions(ctx, wf.ID)
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("expected 3 versions, got %d", len(versions))
+	}
+	for i, v := range versions {
+		if v.Version != i+1 {
+			t.Errorf("expected versions in ascending order, got %d at %d", v.Version, i)
+		}
+	}
+
+	v, err := s.GetVersion(ctx, wf.ID, 2)
+	if err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	if v.Definition != "second definition" {
+		t.Errorf("expected second definition, got %q", v.Definition)
+	}
+
+	activated, err := s.ActivateVersion(ctx, wf.ID, 1)
+	if err != nil {
+		t.Fatalf("ActivateVersion failed: %v", err)
+	}
+	if activated.Version != 1 || activated.Definition != "definition of versions" {
+		t.Errorf("unexpected workflow after rollback: %+v", activated)
+	}
+	if _, err := s.ActivateVersion(ctx, wf.ID, 4); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expected ErrNotFound activating an unknown version, got %v", err)
+	}
+
+	// A save after rollback continues the version sequence.
+	wf.Definition = "fourth definition"
+	if err := s.UpdateWorkflow(ctx, wf); err != nil {
+		t.Fatalf("UpdateWorkflow failed: %v", err)
+	}
+	if wf.Version != 4 {
+		t.Errorf("expected version 4 after rollback and save, got %d", wf.Version)
+	}
+}
+
+func testRuns(t *testing.T, s store.WorkflowStore) {
+	ctx := context.Background()
+	wf := newWorkflow("runs")
+	if err := s.SaveWorkflow(ctx, wf); err != nil {
+		t.Fatalf("SaveWorkflow failed: %v", err)
+	}
+
+	started := time.Now().UTC().Truncate(time.Second)
+	first := &store.WorkflowRun{ID: "run-1", WorkflowID: wf.ID, Version: 1, Status: store.RunStatusRunning, StartedAt: started}
+	second := &store.WorkflowRun{ID: "run-2", WorkflowID: wf.ID, Version: 1, Status: store.RunStatusRunning, StartedAt: started.Add(time.Minute)}
+	for _, run := range []*store.WorkflowRun{first, second} {
+		if err := s.SaveRun(ctx, run); err != nil {
+			t.Fatalf("SaveRun failed: %v", err)
+		}
+	}
+
+	finished := started.Add(2 * time.Minute)
+	first.Status = store.RunStatusCompleted
+	first.FinishedAt = &finished
+	first.NodeStatuses = map[string]string{"trigger": store.NodeStatusCompleted, "http": store.NodeStatusFailed}
+	first.Trace = []store.TraceStep{
+		{Node: "agent", Step: 1, Type: "tool", Tool: "lookup", Input: map[string]interface{}{"company": "acme"}, Output: []interface{}{map[string]interface{}{"employees": "50"}}, Time: finished},
+		{Node: "agent", Step: 2, Type: "answer", Output: "done", Time: finished},
+	}
+	if err := s.UpdateRun(ctx, first); err != nil {
+		t.Fatalf("UpdateRun failed: %v", err)
+	}
+
+	got, err := s.GetRun(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("GetRun failed: %v", err)
+	}
+	if got.Status != store.RunStatusCompleted || got.FinishedAt == nil || !got.FinishedAt.Equal(finished) || !got.StartedAt.Equal(started) {
+		t.Errorf("GetRun mismatch: got %+v", got)
+	}
+	if !reflect.DeepEqual(got.NodeStatuses, first.NodeStatuses) {
+		t.Errorf("expected node statuses %v, got %v", first.NodeStatuses, got.NodeStatuses)
+	}
+	if !reflect.DeepEqual(got.Trace, first.Trace) {
+		t.Errorf("expected trace %+v, got %+v", first.Trace, got.Trace)
+	}
+
+	runs, err := s.ListRuns(ctx, wf.ID)
+	if err != nil {
+		t.Fatalf("ListRuns failed: %v", err)
+	}
+	if len(runs) != 2 || runs[0].ID != second.ID {
+		t.Errorf("expected 2 runs, most recent first, got %+v", runs)
+	}
+}
+
+func testTxCommit(t *testing.T, s store.WorkflowStore) {
+	ctx := context.Background()
+	wf := newWorkflow("tx_commit")
+	err := s.WithTx(ctx, func(tx store.Tx) error {
+		if err := tx.SaveWorkflow(ctx, wf); err != nil {
+			return err
+		}
+		wf.Definition = "second definition"
+		if err := tx.UpdateWorkflow(ctx, wf); err != nil {
+			return err
+		}
+		// Reads inside the transaction see its own writes.
+		got, err := tx.GetWorkflow(ctx, wf.ID)
+		if err != nil {
+			return err
+		}
+		if got.Version != 2 {
+			t.Errorf("expected version 2 inside the transaction, got %d", got.Version)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx failed: %v", err)
+	}
+
+	got, err := s.GetWorkflow(ctx, wf.ID)
+	if err != nil {
+		t.Fatalf("GetWorkflow failed: %v", err)
+	}
+	if got.Version != 2 || got.Definition != "second definition" {
+		t.Errorf("unexpected workflow after commit: %+v", got)
+	}
+}
+
+func testTxRollback(t *testing.T, s store.WorkflowStore) {
+	ctx := context.Background()
+	existing := newWorkflow("tx_existing")
+	if err := s.SaveWorkflow(ctx, existing); err != nil {
+		t.Fatalf("SaveWorkflow failed: %v", err)
+	}
+
+	wf := newWorkflow("tx_rollback")
+	err := s.WithTx(ctx, func(tx store.Tx) error {
+		if err := tx.SaveWorkflow(ctx, wf); err != nil {
+			return err
+		}
+		existing.Definition = "changed in a failed transaction"
+		if err := tx.UpdateWorkflow(ctx, existing); err != nil {
+			return err
+		}
+		dup := newWorkflow("tx_existing")
+		dup.ID = "another-id"
+		return tx.SaveWorkflow(ctx, dup)
+	})
+	if !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("expected WithTx to return the callback's ErrConflict, got %v", err)
+	}
+
+	if _, err := s.GetWorkflow(ctx, wf.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("workflow saved in a rolled back transaction was kept: %v", err)
+	}
+	got, err := s.GetWorkflow(ctx, existing.ID)
+	if err != nil {
+		t.Fatalf("GetWorkflow failed: %v", err)
+	}
+	if got.Version != 1 || got.Definition != "definition of tx_existing" {
+		t.Errorf("update in a rolled back transaction was kept: %+v", got)
+	}
+	versions, err := s.ListVersions(ctx, existing.ID)
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != 1 {
+		t.Errorf("expected 1 version after rollback, got %d", len(versions))
+	}
+}
+
+func testCanceledContext(t *testing.T, s store.WorkflowStore) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.SaveWorkflow(ctx, newWorkflow("canceled")); err == nil {
+		t.Error("expected SaveWorkflow to fail with a canceled context")
+	}
+	if _, err := s.GetWorkflow(context.Background(), "canceled-id"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("workflow saved with a canceled context: %v", err)
+	}
+}
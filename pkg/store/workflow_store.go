@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -13,118 +14,117 @@ type Workflow struct {
 	ID         string
 	Name       string
 	Definition string // YAML workflow definition
+	Version    int    // Active version; Definition is that version's content
 	CreatedAt  time.Time
 }
 
+// WorkflowVersion is an immutable snapshot of a workflow definition.
+// A new version is recorded every time a workflow is saved.
+type WorkflowVersion struct {
+	WorkflowID string
+	Version    int
+	Definition string
+	CreatedAt  time.Time
+}
+
+// Run statuses recorded in WorkflowRun.Status.
+const (
+	RunStatusRunning   = "running"
+	RunStatusCompleted = "completed"
+	RunStatusFailed    = "failed"
+)
+
+// Node statuses recorded in WorkflowRun.NodeStatuses. Nodes a run did not
+// reach have no status.
+const (
+	NodeStatusCompleted = "completed"
+	NodeStatusFailed    = "failed"
+)
+
+// WorkflowRun records a single execution of a workflow and the version it ran.
+type WorkflowRun struct {
+	ID         string
+	WorkflowID string
+	Version    int
+	Status     string
+	Error      string
+	StartedAt  time.Time
+	FinishedAt *time.Time
+	// NodeStatuses maps the nodes the run executed to their status. It is
+	// recorded by UpdateRun.
+	NodeStatuses map[string]string
+	// Trace holds the steps of nodes that work in several steps, such as
+	// the tool calls of an aiAgent, in order. It is recorded by UpdateRun.
+	Trace []TraceStep
+}
+
+// TraceStep is a step of a node recorded in WorkflowRun.Trace.
+type TraceStep struct {
+	Node   string      `json:"node"`
+	Step   int         `json:"step"`
+	Type   string      `json:"type"`
+	Tool   string      `json:"tool,omitempty"`
+	Input  interface{} `json:"input,omitempty"`
+	Output interface{} `json:"output,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	Time   time.Time   `json:"time"`
+}
+
+// Tx is the set of operations on stored workflows and runs. It is
+// implemented by every WorkflowStore and by the transaction-bound store a
+// WithTx callback receives.
+//
+// Lookups of a missing workflow, version or run return an error wrapping
+// ErrNotFound; writes that would duplicate a workflow name or version return
+// an error wrapping ErrConflict.
+type Tx interface {
+	SaveWorkflow(ctx context.Context, workflow *Workflow) error
+	UpdateWorkflow(ctx context.Context, workflow *Workflow) error
+	GetWorkflow(ctx context.Context, id string) (*Workflow, error)
+	GetWorkflowByName(ctx context.Context, name string) (*Workflow, error)
+	ListWorkflows(ctx context.Context) ([]*Workflow, error)
+
+	ListVersions(ctx context.Context, workflowID string) ([]*WorkflowVersion, error)
+	GetVersion(ctx context.Context, workflowID string, version int) (*WorkflowVersion, error)
+	ActivateVersion(ctx context.Context, workflowID string, version int) (*Workflow, error)
+
+	SaveRun(ctx context.Context, run *WorkflowRun) error
+	UpdateRun(ctx context.Context, run *WorkflowRun) error
+	GetRun(ctx context.Context, id string) (*WorkflowRun, error)
+	ListRuns(ctx context.Context, workflowID string) ([]*WorkflowRun, error)
+}
+
 // WorkflowStore defines the interface for storing and retrieving workflows.
 type WorkflowStore interface {
-	Init() error
-	SaveWorkflow(workflow *Workflow) error
-	GetWorkflow(id string) (*Workflow, error)
-	GetWorkflowByName(name string) (*Workflow, error)
-	ListWorkflows() ([]*Workflow, error)
+	Tx
+	Init(ctx context.Context) error
+	// WithTx runs fn atomically: everything fn does through tx is committed
+	// if fn returns nil and rolled back otherwise.
+	WithTx(ctx context.Context, fn func(tx Tx) error) error
 }
 
 // SQLiteStore implements WorkflowStore for SQLite.
 type SQLiteStore struct {
-	db *sql.DB
+	sqlStore
 	dbPath string
 }
 
 // NewSQLiteStore creates a new SQLiteStore.
 func NewSQLiteStore(dbPath string) *SQLiteStore {
-	return &SQLiteStore{dbPath: dbPath}
+	return &SQLiteStore{sqlStore: sqlStore{dialect: DialectSQLite}, dbPath: dbPath}
 }
 
-// Init initializes the SQLite database and creates the workflows table.
-func (s *SQLiteStore) Init() error {
+// Init opens the SQLite database and applies any pending schema migrations.
+func (s *SQLiteStore) Init(ctx context.Context) error {
 	var err error
 	s.db, err = sql.Open("sqlite3", s.dbPath)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
 
-	createTableSQL := `
-	CREATE TABLE IF NOT EXISTS workflows (
-		id TEXT PRIMARY KEY,
-		name TEXT NOT NULL UNIQUE,
-		definition TEXT NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-	`
-	_, err = s.db.Exec(createTableSQL)
-	if err != nil {
-		return fmt.Errorf("failed to create workflows table: %w", err)
-	}
-
-	return nil
-}
-
-// SaveWorkflow saves a workflow definition to the database.
-func (s *SQLiteStore) SaveWorkflow(workflow *Workflow) error {
-	stmt, err := s.db.Prepare("INSERT INTO workflows(id, name, definition) VALUES(?, ?, ?)")
-	if err != nil {
-		return fmt.Errorf("failed to prepare statement: %w", err)
-	}
-	defer stmt.Close()
-
-	_, err = stmt.Exec(workflow.ID, workflow.Name, workflow.Definition)
-	if err != nil {
-		return fmt.Errorf("failed to execute statement: %w", err)
+	if _, err := NewMigrator(s.db, DialectSQLite, MigrationsFor(DialectSQLite)).Up(ctx); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	return nil
 }
-
-// GetWorkflow retrieves a workflow definition by ID.
-func (s *SQLiteStore) GetWorkflow(id string) (*Workflow, error) {
-	row := s.db.QueryRow("SELECT id, name, definition, created_at FROM workflows WHERE id = ?", id)
-
-	workflow := &Workflow{}
-	err := row.Scan(&workflow.ID, &workflow.Name, &workflow.Definition, &workflow.CreatedAt)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, sql.ErrNoRows
-		}
-		return nil, fmt.Errorf("failed to scan workflow: %w", err)
-	}
-
-	return workflow, nil
-}
-
-// GetWorkflowByName retrieves a workflow definition by name.
-func (s *SQLiteStore) GetWorkflowByName(name string) (*Workflow, error) {
-	row := s.db.QueryRow("SELECT id, name, definition, created_at FROM workflows WHERE name = ?", name)
-
-	workflow := &Workflow{}
-	err := row.Scan(&workflow.ID, &workflow.Name, &workflow.Definition, &workflow.CreatedAt)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, sql.ErrNoRows
-		}
-		return nil, fmt.Errorf("failed to scan workflow: %w", err)
-	}
-
-	return workflow, nil
-}
-
-// ListWorkflows lists all stored workflow definitions.
-func (s *SQLiteStore) ListWorkflows() ([]*Workflow, error) {
-	rows, err := s.db.Query("SELECT id, name, definition, created_at FROM workflows")
-	if err != nil {
-		return nil, fmt.Errorf("failed to query workflows: %w", err)
-	}
-	defer rows.Close()
-
-	var workflows []*Workflow
-	for rows.Next() {
-		workflow := &Workflow{}
-		err := rows.Scan(&workflow.ID, &workflow.Name, &workflow.Definition, &workflow.CreatedAt)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan workflow row: %w", err)
-		}
-		workflows = append(workflows, workflow)
-	}
-
-	return workflows, nil
-}
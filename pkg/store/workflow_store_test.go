@@ -1,20 +1,24 @@
 package store
 
 import (
+	"context"
+	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 func TestSQLiteStore(t *testing.T) {
+	ctx := context.Background()
 	dbPath := "test_workflows.db"
 	defer os.Remove(dbPath) // Clean up after test
 
 	store := NewSQLiteStore(dbPath)
 
 	// Test Init
-	if err := store.Init(); err != nil {
+	if err := store.Init(ctx); err != nil {
 		t.Fatalf("Init failed: %v", err)
 	}
 
@@ -24,7 +28,7 @@ func TestSQLiteStore(t *testing.T) {
 		Name:       "workflow1",
 		Definition: "workflow_def_1",
 	}
-	if err := store.SaveWorkflow(workflow1); err != nil {
+	if err := store.SaveWorkflow(ctx, workflow1); err != nil {
 		t.Fatalf("SaveWorkflow failed: %v", err)
 	}
 
@@ -33,12 +37,12 @@ func TestSQLiteStore(t *testing.T) {
 		Name:       "workflow2",
 		Definition: "workflow_def_2",
 	}
-	if err := store.SaveWorkflow(workflow2); err != nil {
+	if err := store.SaveWorkflow(ctx, workflow2); err != nil {
 		t.Fatalf("SaveWorkflow failed: %v", err)
 	}
 
 	// Test GetWorkflow
-	retrievedWorkflow, err := store.GetWorkflow(workflow1.ID)
+	retrievedWorkflow, err := store.GetWorkflow(ctx, workflow1.ID)
 	if err != nil {
 		t.Fatalf("GetWorkflow failed: %v", err)
 	}
@@ -47,7 +51,7 @@ func TestSQLiteStore(t *testing.T) {
 	}
 
 	// Test GetWorkflowByName
-	retrievedWorkflowByName, err := store.GetWorkflowByName(workflow2.Name)
+	retrievedWorkflowByName, err := store.GetWorkflowByName(ctx, workflow2.Name)
 	if err != nil {
 		t.Fatalf("GetWorkflowByName failed: %v", err)
 	}
@@ -56,7 +60,7 @@ func TestSQLiteStore(t *testing.T) {
 	}
 
 	// Test ListWorkflows
-	workflows, err := store.ListWorkflows()
+	workflows, err := store.ListWorkflows(ctx)
 	if err != nil {
 		t.Fatalf("ListWorkflows failed: %v", err)
 	}
@@ -70,7 +74,130 @@ func TestSQLiteStore(t *testing.T) {
 		Name:       "workflow1", // Duplicate name
 		Definition: "workflow_def_duplicate",
 	}
-	if err := store.SaveWorkflow(duplicateWorkflow); err == nil {
-		t.Error("SaveWorkflow with duplicate name did not return an error")
+	if err := store.SaveWorkflow(ctx, duplicateWorkflow); !errors.Is(err, ErrConflict) {
+		t.Errorf("expected ErrConflict saving a duplicate name, got %v", err)
+	}
+}
+
+func TestSQLiteStore_Versions(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "test_workflow_versions.db"
+	defer os.Remove(dbPath)
+
+	store := NewSQLiteStore(dbPath)
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	workflow := &Workflow{
+		ID:         uuid.New().String(),
+		Name:       "versioned",
+		Definition: "definition_v1",
+	}
+	if err := store.SaveWorkflow(ctx, workflow); err != nil {
+		t.Fatalf("SaveWorkflow failed: %v", err)
+	}
+	if workflow.Version != 1 {
+		t.Errorf("expected version 1 after save, got %d", workflow.Version)
+	}
+
+	workflow.Definition = "definition_v2"
+	if err := store.UpdateWorkflow(ctx, workflow); err != nil {
+		t.Fatalf("UpdateWorkflow failed: %v", err)
+	}
+	if workflow.Version != 2 {
+		t.Errorf("expected version 2 after update, got %d", workflow.Version)
+	}
+
+	versions, err := store.ListVersions(ctx, workflow.ID)
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Definition != "definition_v1" || versions[1].Definition != "definition_v2" {
+		t.Fatalf("unexpected versions: %+v", versions)
+	}
+
+	// Roll back to version 1
+	activated, err := store.ActivateVersion(ctx, workflow.ID, 1)
+	if err != nil {
+		t.Fatalf("ActivateVersion failed: %v", err)
+	}
+	if activated.Version != 1 || activated.Definition != "definition_v1" {
+		t.Errorf("expected version 1 to be active, got %+v", activated)
+	}
+
+	// Versions are immutable; activation does not create a new one.
+	versions, err = store.ListVersions(ctx, workflow.ID)
+	if err != nil {
+		t.Fatalf("ListVersions failed: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("expected 2 versions after rollback, got %d", len(versions))
+	}
+
+	if _, err := store.ActivateVersion(ctx, workflow.ID, 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for unknown version, got %v", err)
+	}
+	if err := store.UpdateWorkflow(ctx, &Workflow{ID: "missing", Name: "missing", Definition: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound updating unknown workflow, got %v", err)
+	}
+}
+
+func TestSQLiteStore_Runs(t *testing.T) {
+	ctx := context.Background()
+	dbPath := "test_workflow_runs.db"
+	defer os.Remove(dbPath)
+
+	store := NewSQLiteStore(dbPath)
+	if err := store.Init(ctx); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+
+	workflow := &Workflow{
+		ID:         uuid.New().String(),
+		Name:       "with_runs",
+		Definition: "definition",
+	}
+	if err := store.SaveWorkflow(ctx, workflow); err != nil {
+		t.Fatalf("SaveWorkflow failed: %v", err)
+	}
+
+	run := &WorkflowRun{
+		ID:         uuid.New().String(),
+		WorkflowID: workflow.ID,
+		Version:    workflow.Version,
+		Status:     RunStatusRunning,
+		StartedAt:  time.Now().UTC(),
+	}
+	if err := store.SaveRun(ctx, run); err != nil {
+		t.Fatalf("SaveRun failed: %v", err)
+	}
+
+	finishedAt := time.Now().UTC()
+	run.Status = RunStatusFailed
+	run.Error = "boom"
+	run.FinishedAt = &finishedAt
+	if err := store.UpdateRun(ctx, run); err != nil {
+		t.Fatalf("UpdateRun failed: %v", err)
+	}
+
+	retrieved, err := store.GetRun(ctx, run.ID)
+	if err != nil {
+		t.Fatalf("GetRun failed: %v", err)
+	}
+	if retrieved.Version != 1 || retrieved.Status != RunStatusFailed || retrieved.Error != "boom" || retrieved.FinishedAt == nil {
+		t.Errorf("GetRun mismatch: got %+v", retrieved)
+	}
+
+	runs, err := store.ListRuns(ctx, workflow.ID)
+	if err != nil {
+		t.Fatalf("ListRuns failed: %v", err)
+	}
+	if len(runs) != 1 {
+		t.Errorf("expected 1 run, got %d", len(runs))
+	}
+
+	if _, err := store.GetRun(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for unknown run, got %v", err)
 	}
 }
@@ -0,0 +1,44 @@
+package vectorstore
+
+import (
+	"context"
+	"sync"
+
+	"go-workflow/pkg/framework"
+)
+
+// MemoryStore is a VectorStore held in memory. It is safe for concurrent
+// use.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	indexes map[string]map[string]framework.VectorDocument
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{indexes: map[string]map[string]framework.VectorDocument{}}
+}
+
+func (s *MemoryStore) Upsert(ctx context.Context, index string, docs []framework.VectorDocument) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.indexes[index] == nil {
+		s.indexes[index] = map[string]framework.VectorDocument{}
+	}
+	for _, doc := range docs {
+		s.indexes[index][doc.ID] = doc
+	}
+	return nil
+}
+
+func (s *MemoryStore) Query(ctx context.Context, index string, query framework.VectorQuery) ([]framework.VectorMatch, error) {
+	s.mu.RLock()
+	docs := make([]framework.VectorDocument, 0, len(s.indexes[index]))
+	for _, doc := range s.indexes[index] {
+		docs = append(docs, doc)
+	}
+	s.mu.RUnlock()
+	return search(docs, query)
+}
+
+var _ framework.VectorStore = (*MemoryStore)(nil)
@@ -0,0 +1,117 @@
+package vectorstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"go-workflow/pkg/framework"
+)
+
+// SQLiteStore is a VectorStore in a SQLite file, which keeps indexes across
+// runs and processes.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// OpenSQLiteStore opens the SQLite store at path, creating it if needed.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	if path == "" {
+		return nil, errors.New("missing vector store file")
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS vectors (
+	idx TEXT NOT NULL,
+	id TEXT NOT NULL,
+	vector BLOB NOT NULL,
+	metadata TEXT NOT NULL,
+	PRIMARY KEY (idx, id)
+)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create vector store %s: %w", path, err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// Close closes the store file.
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStore) Upsert(ctx context.Context, index string, docs []framework.VectorDocument) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, doc := range docs {
+		metadata, err := json.Marshal(doc.Metadata)
+		if err != nil {
+			return fmt.Errorf("document %s: invalid metadata: %w", doc.ID, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO vectors(idx, id, vector, metadata) VALUES(?, ?, ?, ?)",
+			index, doc.ID, encodeVector(doc.Vector), string(metadata)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) Query(ctx context.Context, index string, query framework.VectorQuery) ([]framework.VectorMatch, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, vector, metadata FROM vectors WHERE idx = ?", index)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var docs []framework.VectorDocument
+	for rows.Next() {
+		var doc framework.VectorDocument
+		var vector []byte
+		var metadata string
+		if err := rows.Scan(&doc.ID, &vector, &metadata); err != nil {
+			return nil, err
+		}
+		if doc.Vector, err = decodeVector(vector); err != nil {
+			return nil, fmt.Errorf("document %s: %w", doc.ID, err)
+		}
+		if err := json.Unmarshal([]byte(metadata), &doc.Metadata); err != nil {
+			return nil, fmt.Errorf("document %s: invalid metadata: %w", doc.ID, err)
+		}
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return search(docs, query)
+}
+
+// encodeVector stores a vector as little-endian float32s.
+func encodeVector(vector []float32) []byte {
+	data := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(v))
+	}
+	return data
+}
+
+func decodeVector(data []byte) ([]float32, error) {
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("invalid vector of %d bytes", len(data))
+	}
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vector, nil
+}
+
+var _ framework.VectorStore = (*SQLiteStore)(nil)
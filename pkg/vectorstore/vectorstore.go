@@ -0,0 +1,91 @@
+// Package vectorstore implements framework.VectorStore in memory and in
+// SQLite. Both compare every vector of an index with the query, which is
+// fast enough for the thousands of documents a workflow keeps locally.
+package vectorstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"go-workflow/pkg/framework"
+)
+
+// Open opens the store described by spec: "memory" for a store held in
+// memory, or the path of a SQLite file, optionally prefixed with "sqlite:".
+func Open(spec string) (framework.VectorStore, error) {
+	if spec == "memory" {
+		return NewMemoryStore(), nil
+	}
+	store, err := OpenSQLiteStore(strings.TrimPrefix(spec, "sqlite:"))
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Cosine returns the cosine similarity of two vectors of the same length:
+// 1 for vectors pointing the same way, 0 for orthogonal ones.
+func Cosine(a, b []float32) (float64, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("cannot compare vectors of %d and %d dimensions", len(a), len(b))
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0, nil
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB)), nil
+}
+
+// search ranks docs by their similarity to the query.
+func search(docs []framework.VectorDocument, query framework.VectorQuery) ([]framework.VectorMatch, error) {
+	var matches []framework.VectorMatch
+	for _, doc := range docs {
+		if !matchesFilter(doc.Metadata, query.Filter) {
+			continue
+		}
+		score, err := Cosine(query.Vector, doc.Vector)
+		if err != nil {
+			return nil, fmt.Errorf("document %s: %w", doc.ID, err)
+		}
+		if score < query.MinScore {
+			continue
+		}
+		matches = append(matches, framework.VectorMatch{VectorDocument: doc, Score: score})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if query.TopK > 0 && len(matches) > query.TopK {
+		matches = matches[:query.TopK]
+	}
+	return matches, nil
+}
+
+// matchesFilter reports whether metadata has the values of filter. Values
+// are compared as JSON, so the number 1 from YAML matches the 1.0 read back
+// from SQLite.
+func matchesFilter(metadata, filter map[string]interface{}) bool {
+	for key, want := range filter {
+		got, ok := metadata[key]
+		if !ok {
+			return false
+		}
+		gotJSON, err1 := json.Marshal(got)
+		wantJSON, err2 := json.Marshal(want)
+		if err1 != nil || err2 != nil || string(gotJSON) != string(wantJSON) {
+			return false
+		}
+	}
+	return true
+}
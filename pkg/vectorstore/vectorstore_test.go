@@ -0,0 +1,133 @@
+package vectorstore
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"go-workflow/pkg/framework"
+)
+
+func TestCosine(t *testing.T) {
+	tests := []struct {
+		a, b []float32
+		want float64
+	}{
+		{[]float32{1, 0}, []float32{2, 0}, 1},
+		{[]float32{1, 0}, []float32{0, 3}, 0},
+		{[]float32{1, 1}, []float32{-1, -1}, -1},
+		{[]float32{0, 0}, []float32{1, 0}, 0},
+	}
+	for _, tt := range tests {
+		got, err := Cosine(tt.a, tt.b)
+		if err != nil || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Cosine(%v, %v) = %v, %v; want %v", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+	if _, err := Cosine([]float32{1}, []float32{1, 0}); err == nil {
+		t.Error("expected an error for vectors of different dimensions")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestSQLiteStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vectors.db")
+	store, err := OpenSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testStore(t, store)
+	store.Close()
+
+	// Indexes outlive the process that wrote them.
+	reopened, err := Open("sqlite:" + path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer reopened.(*SQLiteStore).Close()
+	matches, err := reopened.Query(context.Background(), "leads", framework.VectorQuery{Vector: []float32{1, 0, 0}, TopK: 1})
+	if err != nil || len(matches) != 1 || matches[0].ID != "ada" {
+		t.Errorf("expected the index to be kept, got %v, %v", matches, err)
+	}
+}
+
+// testStore checks the behavior shared by all stores.
+func testStore(t *testing.T, store framework.VectorStore) {
+	t.Helper()
+	ctx := context.Background()
+	docs := []framework.VectorDocument{
+		{ID: "ada", Vector: []float32{1, 0, 0}, Metadata: map[string]interface{}{"company": "Acme", "employees": 10}},
+		{ID: "bob", Vector: []float32{0.9, 0.1, 0}, Metadata: map[string]interface{}{"company": "Initech", "employees": 20}},
+		{ID: "cy", Vector: []float32{0, 0, 1}, Metadata: map[string]interface{}{"company": "Acme", "employees": 30}},
+	}
+	if err := store.Upsert(ctx, "leads", docs); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.Upsert(ctx, "other", []framework.VectorDocument{{ID: "zed", Vector: []float32{1, 0, 0}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query framework.VectorQuery
+		want  []string
+	}{
+		{"ranked by similarity", framework.VectorQuery{Vector: []float32{1, 0, 0}}, []string{"ada", "bob", "cy"}},
+		{"top k", framework.VectorQuery{Vector: []float32{1, 0, 0}, TopK: 1}, []string{"ada"}},
+		{"min score", framework.VectorQuery{Vector: []float32{1, 0, 0}, MinScore: 0.5}, []string{"ada", "bob"}},
+		{"filter", framework.VectorQuery{Vector: []float32{1, 0, 0}, Filter: map[string]interface{}{"company": "Acme"}}, []string{"ada", "cy"}},
+		{"numeric filter", framework.VectorQuery{Vector: []float32{1, 0, 0}, Filter: map[string]interface{}{"employees": 20.0}}, []string{"bob"}},
+		{"missing filter field", framework.VectorQuery{Vector: []float32{1, 0, 0}, Filter: map[string]interface{}{"title": "CTO"}}, nil},
+	}
+	for _, tt := range tests {
+		matches, err := store.Query(ctx, "leads", tt.query)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		var got []string
+		for _, m := range matches {
+			got = append(got, m.ID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+				break
+			}
+		}
+	}
+
+	// Upserting an existing ID replaces the document.
+	if err := store.Upsert(ctx, "leads", []framework.VectorDocument{{ID: "cy", Vector: []float32{1, 0, 0}, Metadata: map[string]interface{}{"company": "Globex"}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	matches, err := store.Query(ctx, "leads", framework.VectorQuery{Vector: []float32{1, 0, 0}, Filter: map[string]interface{}{"company": "Globex"}})
+	if err != nil || len(matches) != 1 || matches[0].ID != "cy" || math.Abs(matches[0].Score-1) > 1e-6 {
+		t.Errorf("expected cy to be replaced, got %v, %v", matches, err)
+	}
+
+	if _, err := store.Query(ctx, "leads", framework.VectorQuery{Vector: []float32{1, 0}}); err == nil {
+		t.Error("expected an error for a query of other dimensions")
+	}
+	if matches, err := store.Query(ctx, "missing", framework.VectorQuery{Vector: []float32{1, 0, 0}}); err != nil || len(matches) != 0 {
+		t.Errorf("expected no matches in a missing index, got %v, %v", matches, err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if store, err := Open("memory"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if _, ok := store.(*MemoryStore); !ok {
+		t.Errorf("expected a memory store, got %T", store)
+	}
+	if _, err := Open(""); err == nil {
+		t.Error("expected an error without a file")
+	}
+}